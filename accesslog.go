@@ -0,0 +1,161 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// accessLogFlush is the interval of writing buffered access log lines.
+const accessLogFlush = 100 * time.Millisecond
+
+// AccessLogFormat is the format of access log lines.
+type AccessLogFormat int
+
+const (
+	// AccessLogCommon is a line similar to common log format:
+	//
+	//	127.0.0.1 0191b9c4-... [16/Oct/2026:17:04:05 +0000] "CLOSE /" 1000 1024 2048 12.500
+	//
+	// fields are ip, connection id, time, event with namespace or message name, close code,
+	// bytes in, bytes out and duration of connection in seconds, missing values are "-".
+	AccessLogCommon AccessLogFormat = iota
+	// AccessLogJSON is a JSON object per line.
+	AccessLogJSON
+)
+
+// WithAccessLog writes access log of connections to w: a line when connection is opened and
+// a line with duration, close code and transferred bytes when it's closed.
+// With messages every received message is logged too. Lines are buffered and written to w
+// every 100ms from timer goroutine, so slow writer doesn't hold read and write paths. Writes to w are serialized.
+func WithAccessLog(w io.Writer, format AccessLogFormat, messages bool) Option {
+	return WithObserver(&accessLog{w: w, format: format, messages: messages})
+}
+
+type accessLog struct {
+	w        io.Writer
+	format   AccessLogFormat
+	messages bool
+	buf      bytes.Buffer // lines waiting for flush
+	flush    *time.Timer  // scheduled while buf has lines
+	pending  bool
+	mu       sync.Mutex
+	writeMu  sync.Mutex // serializes writes to w
+}
+
+// accessEntry is a line of access log.
+type accessEntry struct {
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event"`
+	ID        string    `json:"id"`
+	IP        string    `json:"ip"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Code      int       `json:"code,omitempty"`
+	BytesIn   int64     `json:"bytes_in,omitempty"`
+	BytesOut  int64     `json:"bytes_out,omitempty"`
+	Duration  float64   `json:"duration,omitempty"`
+}
+
+// Observe implements Observer.
+func (l *accessLog) Observe(e Event) {
+	if e.Conn == nil {
+		return
+	}
+
+	entry := accessEntry{
+		Time: e.Time,
+		ID:   e.Conn.id,
+		IP:   e.Conn.ip,
+	}
+	if e.Conn.namespace != nil {
+		entry.Namespace = e.Conn.namespace.name
+	}
+
+	switch {
+	case e.Type == ConnectionOpened:
+		entry.Event = "open"
+	case e.Type == ConnectionClosed:
+		entry.Event = "close"
+		entry.Code = int(e.Code)
+		entry.BytesIn = e.Conn.stats.bytesIn.Load()
+		entry.BytesOut = e.Conn.stats.bytesOut.Load()
+		entry.Duration = e.Time.Sub(e.Conn.created).Seconds()
+	case e.Type == MessageReceived && l.messages:
+		entry.Event = "message"
+		entry.Name = e.Name
+		entry.BytesIn = int64(e.Size)
+	default:
+		return
+	}
+
+	l.write(entry)
+}
+
+func (l *accessLog) write(entry accessEntry) {
+	var b []byte
+	switch l.format {
+	case AccessLogJSON:
+		b, _ = json.Marshal(entry)
+		b = append(b, '\n')
+	default:
+		target := entry.Name
+		if entry.Event != "message" {
+			target = entry.Namespace
+			if target == "" {
+				target = "/"
+			}
+		}
+		// name comes from client, it's escaped so it can't break the line or quotes
+		quoted := strconv.Quote(orDash(target))
+		b = fmt.Appendf(nil, "%s %s [%s] \"%s %s\" %s %s %s %s\n",
+			orDash(entry.IP), entry.ID, entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+			strings.ToUpper(entry.Event), quoted[1:len(quoted)-1],
+			orDash(entry.Code), orDash(entry.BytesIn), orDash(entry.BytesOut), orDash(entry.Duration))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf.Write(b)
+	if l.pending {
+		return
+	}
+	l.pending = true
+	if l.flush == nil {
+		l.flush = time.AfterFunc(accessLogFlush, l.flushLines)
+		return
+	}
+	l.flush.Reset(accessLogFlush)
+}
+
+// flushLines write buffered lines to writer.
+func (l *accessLog) flushLines() {
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+
+	l.mu.Lock()
+	b := bytes.Clone(l.buf.Bytes())
+	l.buf.Reset()
+	l.pending = false
+	l.mu.Unlock()
+
+	_, _ = l.w.Write(b)
+}
+
+// orDash format the value or "-" if it's empty.
+func orDash[T int | int64 | float64 | string](v T) string {
+	var zero T
+	if v == zero {
+		return "-"
+	}
+	if f, ok := any(v).(float64); ok {
+		return strconv.FormatFloat(f, 'f', 3, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,123 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Split(strings.TrimSpace(b.buf.String()), "\n")
+}
+
+func TestServer_WithAccessLog(t *testing.T) {
+	var buf syncBuffer
+	ts, _, shutdown := server(t, WithAccessLog(&buf, AccessLogCommon, true))
+	defer shutdown()
+
+	c := dial(t, ts)
+	writeMessage(t, c, "hello", "world")
+	require.NoError(t, wsutil.WriteClientMessage(c, ws.OpClose, ws.NewCloseFrameBody(ws.StatusNormalClosure, "")))
+	_ = c.Close()
+
+	require.Eventually(t, func() bool {
+		return len(buf.lines()) == 3
+	}, time.Second, 5*time.Millisecond)
+
+	lines := buf.lines()
+	require.Regexp(t, regexp.MustCompile(`^127\.0\.0\.1 \S+ \[[^\]]+\] "OPEN /" - - - -$`), lines[0])
+	require.Regexp(t, regexp.MustCompile(`^127\.0\.0\.1 \S+ \[[^\]]+\] "MESSAGE hello" - 31 - -$`), lines[1])
+	require.Regexp(t, regexp.MustCompile(`^127\.0\.0\.1 \S+ \[[^\]]+\] "CLOSE /" 1000 \d+ \d+ \d+\.\d{3}$`), lines[2])
+}
+
+func TestServer_WithAccessLog_json(t *testing.T) {
+	var buf syncBuffer
+	ts, wsServer, shutdown := server(t, WithAccessLog(&buf, AccessLogJSON, false))
+	defer shutdown()
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		_ = c.Emit("echo", msg.Data)
+	})
+
+	c := dial(t, ts)
+	writeMessage(t, c, "echo", "world")
+	_, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	_ = c.Close()
+
+	require.Eventually(t, func() bool {
+		return len(buf.lines()) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	var opened, closed accessEntry
+	lines := buf.lines()
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &opened))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &closed))
+	require.Equal(t, "open", opened.Event)
+	require.Equal(t, "close", closed.Event)
+	require.Equal(t, opened.ID, closed.ID)
+	require.Equal(t, int(ws.StatusAbnormalClosure), closed.Code)
+	require.Positive(t, closed.BytesIn)
+	require.Positive(t, closed.BytesOut)
+	require.Positive(t, closed.Duration)
+}
+
+func TestServer_WithAccessLog_quoted(t *testing.T) {
+	var buf syncBuffer
+	ts, _, shutdown := server(t, WithAccessLog(&buf, AccessLogCommon, true))
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+	writeMessage(t, c, "a\" b\nFAKE", nil)
+
+	require.Eventually(t, func() bool {
+		return len(buf.lines()) == 2
+	}, time.Second, 5*time.Millisecond)
+	require.Contains(t, buf.lines()[1], `"MESSAGE a\" b\nFAKE"`)
+}
+
+// blockedWriter blocks writes until it's released.
+type blockedWriter struct {
+	release chan struct{}
+}
+
+func (w *blockedWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func TestAccessLog_slowWriter(t *testing.T) {
+	w := &blockedWriter{release: make(chan struct{})}
+	l := &accessLog{w: w, format: AccessLogCommon}
+	defer close(w.release)
+
+	l.write(accessEntry{Event: "open", ID: "1"})
+	time.Sleep(2 * accessLogFlush)
+
+	started := time.Now()
+	l.write(accessEntry{Event: "close", ID: "1"})
+	require.Less(t, time.Since(started), accessLogFlush, "write must not wait for writer")
+}
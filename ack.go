@@ -0,0 +1,206 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WithAcks enables at-least-once delivery: every message emitted to connection gets the id
+// ({"id": 17, "name": "event", "data": ...}) and client must acknowledge it with _ack event,
+// data is id or list of ids. Not acknowledged messages are sent again after timeout,
+// up to retries times, then OnDeliveryFailed is called. System events are not acknowledged.
+func WithAcks(timeout time.Duration, retries int) Option {
+	return func(s *Server) {
+		s.ackTimeout = timeout
+		s.ackRetries = retries
+		s.handleSystem(EventAck, ack)
+	}
+}
+
+// OnDeliveryFailed function which will be called when message was not acknowledged after all retries
+// or connection was dropped before acknowledge (see WithAcks).
+// Data of msg is []byte or binary payload as is and other data encoded to json.
+func (s *Server) OnDeliveryFailed(f func(c Connection, msg *Message)) {
+	s.mu.Lock()
+	s.onDeliveryFailed = f
+	s.mu.Unlock()
+}
+
+// delivery is a message waiting for acknowledge.
+type delivery struct {
+	env      envelope
+	sent     time.Time
+	attempts int
+}
+
+// outbox keeps messages of connection which were not acknowledged.
+type outbox struct {
+	seq     uint64
+	pending map[uint64]*delivery
+	mu      sync.Mutex
+}
+
+func newOutbox() *outbox {
+	return &outbox{pending: make(map[uint64]*delivery)}
+}
+
+// add assign id to the envelope and keep it until acknowledge.
+func (o *outbox) add(env envelope, now time.Time) envelope {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.seq++
+	env.ID = o.seq
+	o.pending[env.ID] = &delivery{env: env, sent: now, attempts: 1}
+	return env
+}
+
+func (o *outbox) ack(ids ...uint64) {
+	o.mu.Lock()
+	for _, id := range ids {
+		delete(o.pending, id)
+	}
+	o.mu.Unlock()
+}
+
+// expired return messages to resend and messages which are out of retries, the last are removed.
+func (o *outbox) expired(now time.Time, timeout time.Duration, retries int) (resend, failed []delivery) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for id, d := range o.pending {
+		if now.Sub(d.sent) < timeout {
+			continue
+		}
+		if d.attempts > retries {
+			failed = append(failed, *d)
+			delete(o.pending, id)
+			continue
+		}
+		d.attempts++
+		d.sent = now
+		resend = append(resend, *d)
+	}
+
+	sortDeliveries(resend)
+	sortDeliveries(failed)
+	return resend, failed
+}
+
+// drain remove and return all pending messages.
+func (o *outbox) drain() []delivery {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	list := make([]delivery, 0, len(o.pending))
+	for _, d := range o.pending {
+		list = append(list, *d)
+	}
+	clear(o.pending)
+
+	sortDeliveries(list)
+	return list
+}
+
+func sortDeliveries(list []delivery) {
+	sort.Slice(list, func(i, j int) bool { return list[i].env.ID < list[j].env.ID })
+}
+
+// Pending return the number of messages which were not acknowledged yet.
+func (c *Conn) Pending() int {
+	if c.outbox == nil {
+		return 0
+	}
+
+	c.outbox.mu.Lock()
+	defer c.outbox.mu.Unlock()
+	return len(c.outbox.pending)
+}
+
+// emitReliable send message with id and keep it until acknowledge.
+func (c *Conn) emitReliable(env envelope) error {
+	// []byte and binary payload are kept as is, they are sent raw in binary envelope
+	switch env.Data.(type) {
+	case []byte, BinaryPayload:
+	default:
+		b, err := json.Marshal(env.Data)
+		if err != nil {
+			return err
+		}
+		env.Data = json.RawMessage(b)
+	}
+
+	return c.emit(c.outbox.add(env, time.Now()))
+}
+
+// ack handle _ack event from client.
+func ack(c *Conn, msg *Message) {
+	if c.outbox == nil {
+		return
+	}
+
+	var ids []uint64
+	if err := json.Unmarshal(msg.Data, &ids); err != nil {
+		var id uint64
+		if err = json.Unmarshal(msg.Data, &id); err != nil {
+			replyError(c, EventAck, "", &Error{Code: CodeBadRequest, Message: "invalid ack: " + err.Error()})
+			return
+		}
+		ids = append(ids, id)
+	}
+	c.outbox.ack(ids...)
+}
+
+// redeliver resend messages which were not acknowledged in time.
+func (s *Server) redeliver() {
+	ticker := time.NewTicker(max(s.ackTimeout/4, 10*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.connections.forEach(func(c *Conn) {
+				if c.outbox == nil {
+					return
+				}
+
+				resend, failed := c.outbox.expired(now, s.ackTimeout, s.ackRetries)
+				for _, d := range resend {
+					_ = c.emit(d.env)
+				}
+				s.deliveryFailed(c, failed)
+			})
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// deliveryFailed pass not delivered messages to OnDeliveryFailed.
+func (s *Server) deliveryFailed(c *Conn, list []delivery) {
+	if len(list) == 0 {
+		return
+	}
+
+	s.mu.RLock()
+	f := s.onDeliveryFailed
+	s.mu.RUnlock()
+	if f == nil {
+		return
+	}
+
+	for _, d := range list {
+		msg := &Message{Name: d.env.Name}
+		switch data := d.env.Data.(type) {
+		case []byte:
+			msg.Data = data
+		case BinaryPayload:
+			msg.Data, _ = data.BinaryPayload()
+		default:
+			msg.Data, _ = json.Marshal(data)
+		}
+		_ = s.safe(c, func() { f(c, msg) })
+	}
+}
@@ -0,0 +1,172 @@
+package websocket
+
+import (
+	"encoding/json"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func readDelivery(t *testing.T, c net.Conn) (uint64, string) {
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+
+	var msg struct {
+		ID   uint64 `json:"id"`
+		Name string `json:"name"`
+	}
+	require.NoError(t, json.Unmarshal(b, &msg))
+	return msg.ID, msg.Name
+}
+
+func TestServer_WithAcks(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithAcks(100*time.Millisecond, 3))
+	defer shutdown()
+
+	connected := make(chan *Conn, 1)
+	wsServer.OnConnect(func(c Connection) {
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, c.Emit("first", 1))
+		require.NoError(t, c.Emit("second", 2))
+		connected <- c.(*Conn)
+	})
+	failed := make(chan *Message, 1)
+	wsServer.OnDeliveryFailed(func(c Connection, msg *Message) {
+		failed <- msg
+	})
+
+	c := dial(t, ts)
+	conn := <-connected
+
+	id, name := readDelivery(t, c)
+	require.Equal(t, uint64(1), id)
+	require.Equal(t, "first", name)
+	id, name = readDelivery(t, c)
+	require.Equal(t, uint64(2), id)
+	require.Equal(t, "second", name)
+	writeMessage(t, c, EventAck, 1)
+
+	id, name = readDelivery(t, c)
+	require.Equal(t, uint64(2), id, "not acknowledged message must be redelivered")
+	require.Equal(t, "second", name)
+	writeMessage(t, c, EventAck, []uint64{2})
+
+	require.Eventually(t, func() bool {
+		return conn.Pending() == 0
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, c.Close())
+	select {
+	case msg := <-failed:
+		t.Fatalf("acknowledged message %q must not fail", msg.Name)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestServer_OnDeliveryFailed(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithAcks(50*time.Millisecond, 1))
+	defer shutdown()
+
+	wsServer.OnConnect(func(c Connection) {
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, c.Emit("msg", "data"))
+	})
+	failed := make(chan *Message, 1)
+	wsServer.OnDeliveryFailed(func(c Connection, msg *Message) {
+		failed <- msg
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	go func() {
+		_, _ = io.Copy(io.Discard, c)
+	}()
+
+	select {
+	case msg := <-failed:
+		require.Equal(t, "msg", msg.Name)
+		require.JSONEq(t, `"data"`, string(msg.Data))
+	case <-time.After(time.Second):
+		t.Fatal("OnDeliveryFailed must be called after retries")
+	}
+}
+
+func TestServer_OnDeliveryFailed_bytes(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithAcks(20*time.Millisecond, 0))
+	defer shutdown()
+
+	wsServer.OnConnect(func(c Connection) {
+		require.NoError(t, c.Emit("x", []byte("hello")))
+	})
+	failed := make(chan *Message, 1)
+	wsServer.OnDeliveryFailed(func(c Connection, msg *Message) {
+		failed <- msg
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	go func() {
+		_, _ = io.Copy(io.Discard, c)
+	}()
+
+	select {
+	case msg := <-failed:
+		require.Equal(t, "x", msg.Name)
+		require.Equal(t, "hello", string(msg.Data), "[]byte data must be passed as is")
+	case <-time.After(time.Second):
+		t.Fatal("OnDeliveryFailed must be called for []byte data")
+	}
+}
+
+func TestServer_OnDeliveryFailed_disconnect(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithAcks(time.Minute, 3))
+	defer shutdown()
+
+	wsServer.OnConnect(func(c Connection) {
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, c.Emit("msg", "data"))
+	})
+	failed := make(chan *Message, 1)
+	wsServer.OnDeliveryFailed(func(c Connection, msg *Message) {
+		failed <- msg
+	})
+
+	c := dial(t, ts)
+	readDelivery(t, c)
+	require.NoError(t, c.Close())
+
+	select {
+	case msg := <-failed:
+		require.Equal(t, "msg", msg.Name)
+	case <-time.After(time.Second):
+		t.Fatal("pending messages must fail when connection dropped")
+	}
+}
+
+func TestOutbox_expired(t *testing.T) {
+	o := newOutbox()
+	now := time.Now()
+	o.add(envelope{Name: "a", Data: json.RawMessage(`1`)}, now)
+	o.add(envelope{Name: "b", Data: json.RawMessage(`2`)}, now.Add(time.Second))
+
+	resend, failed := o.expired(now.Add(time.Second), time.Second, 1)
+	require.Len(t, resend, 1)
+	require.Equal(t, "a", resend[0].env.Name)
+	require.Empty(t, failed)
+
+	resend, failed = o.expired(now.Add(2*time.Second), time.Second, 1)
+	require.Len(t, resend, 1)
+	require.Equal(t, "b", resend[0].env.Name)
+	require.Len(t, failed, 1)
+	require.Equal(t, "a", failed[0].env.Name)
+
+	o.ack(2, 100)
+	require.Empty(t, o.drain())
+}
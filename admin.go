@@ -0,0 +1,115 @@
+package websocket
+
+import (
+	"encoding/json"
+	"github.com/gobwas/ws"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ConnInfo describes live connection in AdminHandler.
+type ConnInfo struct {
+	ID           string    `json:"id"`
+	RemoteAddr   string    `json:"remote_addr"`
+	Namespace    string    `json:"namespace,omitempty"`
+	Channels     []string  `json:"channels"`
+	Connected    time.Time `json:"connected"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// ChannelInfo describes channel in AdminHandler.
+type ChannelInfo struct {
+	ID    string `json:"id"`
+	Count int    `json:"count"`
+}
+
+// Info return the description of connection.
+func (c *Conn) Info() ConnInfo {
+	info := ConnInfo{
+		ID:           c.id,
+		RemoteAddr:   c.ip,
+		Channels:     c.Channels(),
+		Connected:    c.created,
+		LastActivity: c.created,
+	}
+	c.mu.Lock()
+	// address with port is shown unless client is behind proxy
+	if c.conn != nil {
+		addr := c.conn.RemoteAddr().String()
+		if host, _, err := net.SplitHostPort(addr); err != nil || host == c.ip {
+			info.RemoteAddr = addr
+		}
+	}
+	c.mu.Unlock()
+	if c.namespace != nil {
+		info.Namespace = c.namespace.name
+	}
+	if received := c.stats.lastReceived.Load(); received != 0 {
+		info.LastActivity = time.Unix(0, received)
+	}
+	return info
+}
+
+// AdminHandler return http handler with introspection endpoints for operators:
+//
+//	GET    /connections       list of connections (see ConnInfo)
+//	GET    /channels          list of channels with number of connections
+//	DELETE /connections/{id}  close connection with 1008 (Policy Violation)
+//
+// Paths are relative, mount it with http.StripPrefix. Handler has no authentication,
+// it must be protected by middleware or served on internal address only.
+func (s *Server) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /connections", s.adminConnections)
+	mux.HandleFunc("GET /channels", s.adminChannels)
+	mux.HandleFunc("DELETE /connections/{id}", s.adminDisconnect)
+	return mux
+}
+
+func (s *Server) adminConnections(w http.ResponseWriter, _ *http.Request) {
+	list := make([]ConnInfo, 0, s.Count())
+	s.connections.forEach(func(c *Conn) {
+		list = append(list, c.Info())
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Connected.Before(list[j].Connected)
+	})
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) adminChannels(w http.ResponseWriter, _ *http.Request) {
+	s.mu.RLock()
+	channels := make([]*Channel, 0, len(s.channels))
+	for _, ch := range s.channels {
+		channels = append(channels, ch)
+	}
+	s.mu.RUnlock()
+
+	list := make([]ChannelInfo, 0, len(channels))
+	for _, ch := range channels {
+		list = append(list, ChannelInfo{ID: ch.ID(), Count: ch.Count()})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) adminDisconnect(w http.ResponseWriter, r *http.Request) {
+	c := s.connections.get(r.PathValue("id"))
+	if c == nil {
+		writeJSON(w, http.StatusNotFound, ErrorReply{Code: CodeNotFound, Message: "connection not found"})
+		return
+	}
+
+	_ = c.closeWith(ws.StatusPolicyViolation, "closed by admin")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"encoding/json"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServer_AdminHandler(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	admin := httptest.NewServer(http.StripPrefix("/admin", wsServer.AdminHandler()))
+	defer admin.Close()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+	joinChannel(t, c, "room-1")
+
+	var conns []ConnInfo
+	getJSON(t, admin.URL+"/admin/connections", &conns)
+	require.Len(t, conns, 1)
+	require.Equal(t, []string{"room-1"}, conns[0].Channels)
+	require.Equal(t, c.LocalAddr().String(), conns[0].RemoteAddr)
+	require.False(t, conns[0].Connected.IsZero())
+	require.False(t, conns[0].LastActivity.Before(conns[0].Connected))
+
+	var channels []ChannelInfo
+	getJSON(t, admin.URL+"/admin/channels", &channels)
+	require.Equal(t, []ChannelInfo{{ID: "room-1", Count: 1}}, channels)
+
+	req, err := http.NewRequest(http.MethodDelete, admin.URL+"/admin/connections/unknown", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	req, err = http.NewRequest(http.MethodDelete, admin.URL+"/admin/connections/"+conns[0].ID, nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	require.Equal(t, ws.StatusPolicyViolation, readClose(t, c))
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func getJSON(t *testing.T, url string, v any) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+}
@@ -0,0 +1,49 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+)
+
+// ChannelAuthorizer authorizes joining the channel, returned error rejects the connection.
+type ChannelAuthorizer func(ctx context.Context, c Connection) error
+
+// WithChannelAuthorizer sets authorizer for channels which have no own one (see Channel.SetAuthorizer),
+// e.g. to check permissions by prefix of channel id.
+func WithChannelAuthorizer(f func(ctx context.Context, ch *Channel, c Connection) error) Option {
+	return func(s *Server) {
+		s.channelAuthorizer = f
+	}
+}
+
+// SetAuthorizer sets the callback which is consulted by Add and _subscribe event before connection joins.
+// Context is the context of connection. Connections which are already in channel are not checked again.
+func (c *Channel) SetAuthorizer(f ChannelAuthorizer) {
+	c.mu.Lock()
+	c.authorizer = f
+	c.mu.Unlock()
+}
+
+// authorize connection with authorizer of channel or server default.
+func (c *Channel) authorize(conn Connection) error {
+	c.mu.Lock()
+	f := c.authorizer
+	c.mu.Unlock()
+	if f != nil {
+		return f(conn.Context(), conn)
+	}
+
+	if c.server != nil && c.server.channelAuthorizer != nil {
+		return c.server.channelAuthorizer(conn.Context(), c, conn)
+	}
+	return nil
+}
+
+// forbidden return error for client, errors which are not *Error are sent with CodeForbidden.
+func forbidden(err error) error {
+	var e *Error
+	if err != nil && !errors.As(err, &e) {
+		return &Error{Code: CodeForbidden, Message: err.Error()}
+	}
+	return err
+}
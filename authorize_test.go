@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChannel_SetAuthorizer(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithChannelAuthorizer(func(ctx context.Context, ch *Channel, c Connection) error {
+		if strings.HasPrefix(ch.ID(), "private-") && !c.HasTag("role", "admin") {
+			return errors.New("private channel")
+		}
+		return nil
+	}))
+	defer shutdown()
+
+	vip := wsServer.NewChannel("vip")
+	vip.SetAuthorizer(func(ctx context.Context, c Connection) error {
+		require.NotNil(t, ctx)
+		if !c.HasTag("vip", true) {
+			return NewError(402, "vip only")
+		}
+		return nil
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+	require.Eventually(t, func() bool { return wsServer.Count() == 1 }, time.Second, 5*time.Millisecond)
+	conn := wsServer.ConnectionsWhere(func(*Conn) bool { return true })[0]
+
+	writeMessage(t, c, EventSubscribe, map[string]string{"channel": "private-1"})
+	name, data := readEnvelope(t, c)
+	require.Equal(t, EventError, name)
+	require.Contains(t, string(data), `"code":403`)
+	require.Contains(t, string(data), "private channel")
+
+	writeMessage(t, c, EventSubscribe, map[string]string{"channel": "vip"})
+	name, data = readEnvelope(t, c)
+	require.Equal(t, EventError, name)
+	require.Contains(t, string(data), `"code":402`)
+
+	var e *Error
+	require.ErrorAs(t, vip.Add(conn), &e)
+	require.Equal(t, 0, vip.Count())
+
+	conn.Tag("vip", true)
+	require.NoError(t, vip.Add(conn))
+	require.Equal(t, 1, vip.Count())
+	conn.Untag("vip")
+	require.NoError(t, vip.Add(conn), "members are not checked again")
+
+	conn.Tag("role", "admin")
+	joinChannel(t, c, "private-1")
+	require.Equal(t, 1, wsServer.Channel("private-1").Count())
+
+	require.NoError(t, wsServer.NewChannel("public").Add(conn))
+}
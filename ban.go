@@ -0,0 +1,55 @@
+package websocket
+
+import (
+	"errors"
+	"github.com/gobwas/ws"
+	"time"
+)
+
+// ErrBanned is returned when connection comes from banned address, see Server.Ban.
+var ErrBanned = errors.New("websocket: address is banned")
+
+// Ban rejects upgrades from ip with 403 for d, zero d bans the address until Unban.
+// Live connections from the address are closed with 1008 (Policy Violation).
+// It's meant to be called by handlers which detect abuse, e.g. flood of invalid messages.
+func (s *Server) Ban(ip string, d time.Duration) {
+	var until time.Time
+	if d > 0 {
+		until = time.Now().Add(d)
+	}
+	s.bansMu.Lock()
+	if s.bans == nil {
+		s.bans = make(map[string]time.Time)
+	}
+	s.bans[ip] = until
+	s.bansMu.Unlock()
+
+	s.connections.forEach(func(c *Conn) {
+		if c.ip == ip {
+			_ = c.closeWith(ws.StatusPolicyViolation, "banned")
+		}
+	})
+}
+
+// Unban allows upgrades from ip again.
+func (s *Server) Unban(ip string) {
+	s.bansMu.Lock()
+	delete(s.bans, ip)
+	s.bansMu.Unlock()
+}
+
+// Banned reports whether upgrades from ip are rejected.
+func (s *Server) Banned(ip string) bool {
+	s.bansMu.Lock()
+	defer s.bansMu.Unlock()
+
+	until, ok := s.bans[ip]
+	if !ok {
+		return false
+	}
+	if !until.IsZero() && time.Now().After(until) {
+		delete(s.bans, ip)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_Ban(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer c.Close()
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	wsServer.Ban("127.0.0.1", 0)
+	require.True(t, wsServer.Banned("127.0.0.1"))
+	require.False(t, wsServer.Banned("127.0.0.2"))
+	require.Equal(t, ws.StatusPolicyViolation, readClose(t, c), "live connections must be closed")
+
+	u := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+	_, _, _, err := ws.Dial(context.Background(), u)
+	var status ws.StatusError
+	require.ErrorAs(t, err, &status)
+	require.Equal(t, ws.StatusError(http.StatusForbidden), status)
+
+	wsServer.Unban("127.0.0.1")
+	c = dial(t, ts)
+	_ = c.Close()
+
+	wsServer.Ban("127.0.0.1", 50*time.Millisecond)
+	require.True(t, wsServer.Banned("127.0.0.1"))
+	require.Eventually(t, func() bool {
+		return !wsServer.Banned("127.0.0.1")
+	}, time.Second, 10*time.Millisecond, "ban must expire")
+}
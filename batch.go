@@ -0,0 +1,133 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"github.com/gobwas/ws"
+	"sync"
+	"time"
+)
+
+// flagBatch is the flags byte of binary batch frame.
+const flagBatch byte = 1 << 7
+
+// WithBatching coalesces named messages of every connection into one frame, which is written after
+// interval since the first message of the batch or when the batch reaches maxSize bytes.
+// It reduces per-frame overhead for chatty workloads like telemetry, at the cost of latency.
+// Batch of json envelopes is a json array of envelopes: [{"name": "a", "data": 1}, {"name": "b", "data": 2}],
+// batch of binary envelopes is a frame with flags byte 0x80 followed by envelopes prefixed with uvarint length.
+// Clients split frames with Unbatch. Messages with explicit frame type (EmitText, EmitBinary) and
+// connections with flow control are not batched. Interval has precision of server timers (10ms).
+// Pending batch is written when connection is closed, messages of batch which can't be written
+// are passed to observers as MessageDropped.
+func WithBatching(interval time.Duration, maxSize int) Option {
+	return func(s *Server) {
+		s.batchInterval = interval
+		s.batchSize = maxSize
+	}
+}
+
+// batch is pending batch frame of connection.
+type batch struct {
+	mu        sync.Mutex
+	buf       []byte
+	names     []string
+	scheduled bool
+}
+
+// batched add encoded envelope to the batch of connection, it's written when the batch is full.
+func (c *Conn) batched(name string, b []byte) error {
+	bt := c.batch
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if c.envelope == BinaryEnvelope {
+		if len(bt.names) == 0 {
+			bt.buf = append(bt.buf, flagBatch)
+		}
+		bt.buf = binary.AppendUvarint(bt.buf, uint64(len(b)))
+	} else {
+		if len(bt.names) == 0 {
+			bt.buf = append(bt.buf, '[')
+		} else {
+			bt.buf = append(bt.buf, ',')
+		}
+	}
+	bt.buf = append(bt.buf, b...)
+	bt.names = append(bt.names, name)
+
+	if c.server.batchSize > 0 && len(bt.buf) >= c.server.batchSize {
+		return c.flushBatch()
+	}
+	if !bt.scheduled {
+		bt.scheduled = true
+		if _, err := c.server.schedule(c.server.batchInterval, func() { _ = c.Flush() }); err != nil {
+			return c.flushBatch()
+		}
+	}
+	return nil
+}
+
+// Flush write pending batch of connection immediately, see WithBatching.
+func (c *Conn) Flush() error {
+	if c.batch == nil {
+		return nil
+	}
+	c.batch.mu.Lock()
+	defer c.batch.mu.Unlock()
+	return c.flushBatch()
+}
+
+// flushBatch write the batch, c.batch.mu must be locked to keep the order of batches.
+func (c *Conn) flushBatch() error {
+	bt := c.batch
+	bt.scheduled = false
+	if len(bt.names) == 0 {
+		return nil
+	}
+	if c.envelope != BinaryEnvelope {
+		bt.buf = append(bt.buf, ']')
+	}
+
+	opCode := c.messageOp()
+	if c.envelope == BinaryEnvelope {
+		opCode = ws.OpBinary
+	}
+	err := c.Write(ws.Header{Fin: true, OpCode: opCode, Length: int64(len(bt.buf))}, bt.buf)
+	if err != nil && c.server != nil {
+		for _, name := range bt.names {
+			c.server.observe(Event{Type: MessageDropped, Conn: c, Name: name, Err: err})
+		}
+	}
+	bt.buf, bt.names = bt.buf[:0], bt.names[:0]
+	return err
+}
+
+// Unbatch split payload of frame sent with WithBatching into envelopes, frame which is not a batch
+// is returned as the only envelope. Envelopes of json batch are raw json of every array item.
+func Unbatch(payload []byte) ([][]byte, error) {
+	switch {
+	case len(payload) != 0 && payload[0] == '[':
+		var list []json.RawMessage
+		if err := json.Unmarshal(payload, &list); err != nil {
+			return nil, err
+		}
+		res := make([][]byte, len(list))
+		for i, b := range list {
+			res[i] = b
+		}
+		return res, nil
+	case len(payload) != 0 && payload[0] == flagBatch:
+		var res [][]byte
+		for b := payload[1:]; len(b) != 0; {
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return nil, errInvalidEnvelope
+			}
+			res = append(res, b[n:n+int(size)])
+			b = b[n+int(size):]
+		}
+		return res, nil
+	}
+	return [][]byte{payload}, nil
+}
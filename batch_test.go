@@ -0,0 +1,136 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServer_WithBatching(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithBatching(50*time.Millisecond, 0))
+	defer shutdown()
+
+	wsServer.On("burst", func(c Connection, msg *Message) {
+		for i := 1; i <= 3; i++ {
+			require.NoError(t, c.Emit("tick", i))
+		}
+		require.NoError(t, c.EmitText("text", "not batched"))
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+	writeMessage(t, c, "burst", nil)
+
+	b, op, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpText, op, "messages with explicit frame type are written immediately")
+	require.JSONEq(t, `{"name":"text","data":"not batched"}`, string(b))
+
+	started := time.Now()
+	b, _, err = wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"name":"tick","data":1},{"name":"tick","data":2},{"name":"tick","data":3}]`, string(b))
+	require.Less(t, time.Since(started), time.Second)
+
+	list, err := Unbatch(b)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	require.JSONEq(t, `{"name":"tick","data":2}`, string(list[1]))
+}
+
+func TestServer_WithBatching_maxSize(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithBatching(time.Hour, 40))
+	defer shutdown()
+
+	wsServer.On("burst", func(c Connection, msg *Message) {
+		for i := 1; i <= 3; i++ {
+			require.NoError(t, c.Emit("tick", i))
+		}
+		// the last message is sent with close frame
+		require.NoError(t, c.CloseWith(ws.StatusNormalClosure, ""))
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+	writeMessage(t, c, "burst", nil)
+
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, `[{"name":"tick","data":1},{"name":"tick","data":2}]`, string(b), "batch must be written when it's full")
+
+	b, _, err = wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, `[{"name":"tick","data":3}]`, string(b), "pending batch must be written before close")
+	require.Equal(t, ws.StatusNormalClosure, readClose(t, c))
+}
+
+func TestServer_WithBatching_binary(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithEnvelope(BinaryEnvelope), WithBatching(10*time.Millisecond, 0))
+	defer shutdown()
+
+	wsServer.On("ab", func(c Connection, msg *Message) {
+		require.NoError(t, c.Emit("a", []byte{1}))
+		require.NoError(t, c.Emit("b", []byte{2, 3}))
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+	require.NoError(t, wsutil.WriteClientBinary(c, []byte{0, 2, 'a', 'b'}))
+
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x80, 4, 0, 1, 'a', 1, 5, 0, 1, 'b', 2, 3}, b)
+
+	list, err := Unbatch(b)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{{0, 1, 'a', 1}, {0, 1, 'b', 2, 3}}, list)
+}
+
+func TestServer_WithBatching_close(t *testing.T) {
+	dropped := make(chan Event, 2)
+	ts, wsServer, shutdown := server(t, WithBatching(time.Hour, 0), WithObserver(ObserverFunc(func(e Event) {
+		if e.Type == MessageDropped {
+			dropped <- e
+		}
+	})))
+	defer shutdown()
+
+	wsServer.On("burst", func(c Connection, msg *Message) {
+		require.NoError(t, c.Emit("tick", 1))
+		require.NoError(t, c.Close())
+		// batch of closed connection can't be written
+		require.NoError(t, c.Emit("late", 2))
+		require.ErrorIs(t, c.(*Conn).Flush(), net.ErrClosed)
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+	writeMessage(t, c, "burst", nil)
+
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"name":"tick","data":1}]`, string(b), "pending batch must be written on close")
+
+	select {
+	case e := <-dropped:
+		require.Equal(t, "late", e.Name)
+		require.ErrorIs(t, e.Err, net.ErrClosed)
+	case <-time.After(time.Second):
+		t.Fatal("messages of batch which can't be written must be reported")
+	}
+}
+
+func TestUnbatch(t *testing.T) {
+	list, err := Unbatch([]byte(`{"name":"a"}`))
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte(`{"name":"a"}`)}, list, "frame which is not a batch is returned as is")
+
+	_, err = Unbatch([]byte{0x80, 5, 1})
+	require.ErrorIs(t, err, errInvalidEnvelope)
+
+	_, err = Unbatch([]byte(`[{"name":`))
+	require.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// BroadcastResult is the result of message sent to group of connections.
+type BroadcastResult struct {
+	// Delivered is the number of connections which received the message.
+	Delivered int
+	// Failed keeps write errors by connection id.
+	Failed map[string]error
+}
+
+// add count the result of send to connection.
+func (r *BroadcastResult) add(c Connection, err error) {
+	if err == nil {
+		r.Delivered++
+		return
+	}
+	if r.Failed == nil {
+		r.Failed = make(map[string]error)
+	}
+	r.Failed[c.ID()] = err
+}
+
+// Err return errors of failed connections joined in one, nil if nobody failed.
+func (r BroadcastResult) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(r.Failed))
+	for id := range r.Failed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	errs := make([]error, 0, len(ids))
+	for _, id := range ids {
+		errs = append(errs, fmt.Errorf("connection %s: %w", id, r.Failed[id]))
+	}
+	return errors.Join(errs...)
+}
+
+// Broadcast send message to all connections of the server and wait until it's written, unlike Emit which
+// only queues it. Use it for critical notifications when caller needs to know who received them.
+// Connections of namespaces are not included, see Namespace.Emit.
+func (s *Server) Broadcast(name string, data any) BroadcastResult {
+	var (
+		res BroadcastResult
+		mu  sync.Mutex
+	)
+	s.connections.forEachShard(func(c *Conn) {
+		if c.namespace != nil {
+			return
+		}
+		err := c.Emit(name, data)
+		mu.Lock()
+		res.add(c, err)
+		mu.Unlock()
+	})
+	return res
+}
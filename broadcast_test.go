@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"errors"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBroadcastResult(t *testing.T) {
+	var res BroadcastResult
+	require.NoError(t, res.Err())
+
+	res.add(&Conn{id: "a"}, nil)
+	res.add(&Conn{id: "c"}, errors.New("broken pipe"))
+	res.add(&Conn{id: "b"}, ErrServerClosed)
+
+	require.Equal(t, 1, res.Delivered)
+	require.Len(t, res.Failed, 2)
+	require.ErrorIs(t, res.Err(), ErrServerClosed)
+	require.EqualError(t, res.Err(), "connection b: websocket: server closed\nconnection c: broken pipe")
+}
+
+func TestServer_Broadcast(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	c1, c2 := dial(t, ts), dial(t, ts)
+	defer func() {
+		_ = c1.Close()
+		_ = c2.Close()
+	}()
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 2
+	}, time.Second, 5*time.Millisecond)
+
+	res := wsServer.Broadcast("alert", "disk is full")
+	require.Equal(t, 2, res.Delivered)
+	require.NoError(t, res.Err())
+
+	for _, c := range []net.Conn{c1, c2} {
+		b, _, err := wsutil.ReadServerData(c)
+		require.NoError(t, err)
+		require.Equal(t, `{"name":"alert","data":"disk is full"}`, string(b))
+	}
+}
+
+func TestChannel_Emit_result(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	ch := wsServer.NewChannel("room")
+	require.Equal(t, 0, ch.Emit("empty", nil).Delivered)
+
+	wsServer.OnConnect(func(c Connection) {
+		ch.Add(c)
+	})
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+	require.Eventually(t, func() bool {
+		return ch.Count() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	res := ch.Emit("hello", 1)
+	require.Equal(t, 1, res.Delivered)
+	require.Empty(t, res.Failed)
+}
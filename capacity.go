@@ -0,0 +1,36 @@
+package websocket
+
+// CodeConflict is the code of _error event for subscription to full channel.
+const CodeConflict = 409
+
+// ErrChannelFull is returned by Channel.Add when channel has reached its limit, see Channel.SetLimit.
+var ErrChannelFull = NewError(CodeConflict, "websocket: channel is full")
+
+// SetLimit sets the maximum number of connections in channel, zero removes the limit.
+// Add returns ErrChannelFull for new connections when limit is reached, _subscribe is replied
+// with _error (CodeConflict). Connections which are already in channel stay when limit is lowered.
+func (c *Channel) SetLimit(n int) {
+	c.mu.Lock()
+	c.limit = max(n, 0)
+	c.mu.Unlock()
+}
+
+// Limit return the maximum number of connections in channel, zero means no limit.
+func (c *Channel) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit
+}
+
+// OnFull function which will be called when connection is rejected because channel is full,
+// e.g. to emit "lobby full" event or to redirect client to another channel.
+func (c *Channel) OnFull(f func(c Connection)) {
+	c.mu.Lock()
+	c.onFull = f
+	c.mu.Unlock()
+}
+
+// full reports whether new connection can't join. Must be called with c.mu locked.
+func (c *Channel) full() bool {
+	return c.limit > 0 && len(c.connections) >= c.limit
+}
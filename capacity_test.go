@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestChannel_SetLimit(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	lobby := wsServer.NewChannel("lobby")
+	lobby.SetLimit(2)
+	require.Equal(t, 2, lobby.Limit())
+	lobby.OnFull(func(c Connection) {
+		_ = c.Emit("lobby.full", lobby.ID())
+	})
+
+	c1, c2, c3 := dial(t, ts), dial(t, ts), dial(t, ts)
+	defer c1.Close()
+	defer c2.Close()
+	defer c3.Close()
+	require.Eventually(t, func() bool { return wsServer.Count() == 3 }, time.Second, 5*time.Millisecond)
+	conns := wsServer.ConnectionsWhere(func(*Conn) bool { return true })
+
+	require.NoError(t, lobby.Add(conns[0]))
+	require.NoError(t, lobby.Add(conns[1]))
+	require.NoError(t, lobby.Add(conns[1]), "member could be added again")
+	require.ErrorIs(t, lobby.Add(conns[2]), ErrChannelFull)
+	require.Equal(t, 2, lobby.Count())
+
+	lobby.Remove(conns[0])
+	require.NoError(t, lobby.Add(conns[2]))
+
+	lobby.SetLimit(0)
+	require.Equal(t, 0, lobby.Limit())
+	require.NoError(t, lobby.Add(conns[0]))
+	require.Equal(t, 3, lobby.Count())
+}
+
+func TestChannel_SetLimit_subscribe(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	room := wsServer.NewChannel("room")
+	room.SetLimit(1)
+	room.OnFull(func(c Connection) {
+		_ = c.Emit("room.full", room.ID())
+	})
+
+	c1 := dial(t, ts)
+	defer c1.Close()
+	joinChannel(t, c1, "room")
+
+	c2 := dial(t, ts)
+	defer c2.Close()
+	writeMessage(t, c2, EventSubscribe, map[string]string{"channel": "room"})
+	name, data := readEnvelope(t, c2)
+	require.Equal(t, "room.full", name)
+	require.Equal(t, `"room"`, string(data))
+	name, data = readEnvelope(t, c2)
+	require.Equal(t, EventError, name)
+	require.Contains(t, string(data), `"code":409`)
+	require.Equal(t, 1, room.Count())
+}
@@ -2,47 +2,60 @@ package websocket
 
 import (
 	"sync"
+	"time"
 )
 
 // Channel represent group of connections (similar to group in socket.io).
 type Channel struct {
-	id          string
-	connections map[*Conn]bool
-	delConn     chan *Conn
+	id string
+	// connections with false are read-only members
+	connections map[Connection]bool
+	members     map[Connection]Member
+	presence    func(c Connection) any
+	closed      bool
+	// auto channels are created by _subscribe and closed when the last connection leaves
+	auto       bool
+	emptySince time.Time
+	server     *Server
+	log        Log
+	logOptions LogOptions
+	logQueue   chan LogEntry
+	logDone    chan struct{}
+	history    HistoryStore
+	seq        uint64
+	conflated  map[string]*conflated
+
+	authorizer ChannelAuthorizer
+	limit      int
+	dispatcher *dispatcher
+	queueSet   bool
+
+	onJoin  func(c Connection)
+	onLeave func(c Connection)
+	onEmpty func(ch *Channel)
+	onFull  func(c Connection)
 
 	mu sync.Mutex
+	// emitMu keeps the order of sequenced messages.
+	emitMu sync.Mutex
 }
 
 func newChannel(id string) *Channel {
 	c := Channel{
 		id:          id,
-		connections: make(map[*Conn]bool),
-		delConn:     make(chan *Conn),
-	}
-
-	go func() {
-		for {
-			select {
-			case conn := <-c.delConn:
-				c.mu.Lock()
-				_ = conn.Close()
-				delete(c.connections, conn)
-				c.mu.Unlock()
-			}
-		}
-	}()
+		connections: make(map[Connection]bool),
+		members:     make(map[Connection]Member),
+		emptySince:  time.Now(),
+	}
 
 	return &c
 }
 
 // Count return number of live connections in channel.
 func (c *Channel) Count() int {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	count := 0
-	for con := range c.connections {
-		if con.conn != nil {
+	for _, con := range c.snapshot() {
+		if !connClosed(con) {
 			count++
 		}
 	}
@@ -50,46 +63,330 @@ func (c *Channel) Count() int {
 	return count
 }
 
+// Connections return snapshot of connections in channel, it's not affected by later joins and leaves.
+func (c *Channel) Connections() []Connection {
+	return c.snapshot()
+}
+
+// ForEach calls f for every connection in channel until f returns false.
+// Connections are taken from snapshot, so f could add and remove connections of channel.
+func (c *Channel) ForEach(f func(c Connection) bool) {
+	for _, con := range c.snapshot() {
+		if !f(con) {
+			return
+		}
+	}
+}
+
 // ID return channel id.
 func (c *Channel) ID() string {
 	return c.id
 }
 
-// Add connection to channel.
-func (c *Channel) Add(conn *Conn) {
+// Add connection to channel. Error of authorizer (see SetAuthorizer), ErrChannelFull (see SetLimit)
+// or ErrChannelClosed is returned and connection doesn't join.
+// Read-only member added again becomes a regular member.
+func (c *Channel) Add(conn Connection) error {
+	return c.add(conn, true)
+}
+
+// add connection to channel, writable is false for read-only member.
+func (c *Channel) add(conn Connection, writable bool) error {
 	c.mu.Lock()
-	c.connections[conn] = true
+	presence := c.presence
+	_, exists := c.connections[conn]
 	c.mu.Unlock()
+
+	if !exists {
+		if err := c.authorize(conn); err != nil {
+			return err
+		}
+	}
+
+	var member *Member
+	if presence != nil {
+		member = &Member{ID: conn.ID(), Info: presence(conn)}
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrChannelClosed
+	}
+	_, exists = c.connections[conn]
+	if !exists && c.full() {
+		onFull := c.onFull
+		c.mu.Unlock()
+		if onFull != nil {
+			onFull(conn)
+		}
+		return ErrChannelFull
+	}
+	c.connections[conn] = writable
+	c.emptySince = time.Time{}
+	if member != nil && !exists {
+		c.members[conn] = *member
+	}
+	onJoin := c.onJoin
+	c.mu.Unlock()
+
+	if exists {
+		return nil
+	}
+	if k, ok := conn.(*Conn); ok {
+		k.join(c)
+	}
+	if member != nil {
+		c.emitExcept(conn, EventMemberAdded, member)
+	}
+	if onJoin != nil {
+		onJoin(conn)
+	}
+	return nil
 }
 
 // Remove connection from channel.
-func (c *Channel) Remove(conn *Conn) {
+func (c *Channel) Remove(conn Connection) {
 	c.mu.Lock()
+	_, exists := c.connections[conn]
+	member, ok := c.members[conn]
 	delete(c.connections, conn)
+	delete(c.members, conn)
+	empty := exists && len(c.connections) == 0
+	if empty {
+		c.emptySince = time.Now()
+	}
+	onLeave, onEmpty, auto := c.onLeave, c.onEmpty, c.auto
 	c.mu.Unlock()
+
+	if k, ok := conn.(*Conn); ok && exists {
+		k.leave(c)
+	}
+	if ok {
+		c.emitExcept(nil, EventMemberRemoved, member)
+	}
+	if exists && onLeave != nil {
+		onLeave(conn)
+	}
+	if empty && onEmpty != nil {
+		onEmpty(c)
+	}
+	if empty && auto {
+		c.close(c.empty)
+	}
 }
 
 // Emit message to all connections in channel.
-func (c *Channel) Emit(name string, data interface{}) {
-	c.mu.Lock()
+// Connections which failed to receive the message are closed and removed from channel.
+// With WithChannelSequence messages are stamped with sequence number.
+// Returns number of connections which received the message and errors of the failed ones.
+func (c *Channel) Emit(name string, data interface{}) BroadcastResult {
+	if c.sequenced() {
+		return c.emitSequenced(name, data)
+	}
 
-	for con := range c.connections {
-		if err := con.Emit(name, data); err != nil {
-			_ = con.Close()
+	c.persist(name, data, 0)
 
-			c.mu.Unlock()
+	var res BroadcastResult
+	for _, con := range c.snapshot() {
+		err := con.Emit(name, data)
+		res.add(con, err)
+		if err != nil {
+			_ = con.Close()
 			c.Remove(con)
-			c.mu.Lock()
 		}
 	}
-
-	c.mu.Unlock()
+	return res
 }
 
 // Purge remove all connections from channel.
+// Connections stay open and disconnect handling of the channel keeps working,
+// so it's safe to call Purge while channel is in use.
+// Removed connections of presence channel receive member_removed of every member.
 func (c *Channel) Purge() {
 	c.mu.Lock()
-	c.connections = make(map[*Conn]bool)
-	c.delConn = make(chan *Conn)
+	removed := make([]Connection, 0, len(c.connections))
+	for con := range c.connections {
+		removed = append(removed, con)
+	}
+	members := make([]Member, 0, len(c.members))
+	for _, m := range c.members {
+		members = append(members, m)
+	}
+	clear(c.connections)
+	clear(c.members)
+	if len(removed) != 0 {
+		c.emptySince = time.Now()
+	}
+	onLeave, onEmpty := c.onLeave, c.onEmpty
+	c.mu.Unlock()
+
+	for _, con := range removed {
+		if k, ok := con.(*Conn); ok {
+			k.leave(c)
+		}
+		for _, m := range members {
+			_ = con.Emit(EventMemberRemoved, m)
+		}
+	}
+	if onLeave != nil {
+		for _, con := range removed {
+			onLeave(con)
+		}
+	}
+	if len(removed) != 0 && onEmpty != nil {
+		onEmpty(c)
+	}
+}
+
+// OnChannelEmit adding callback which is called when message is emitted to any channel of the server
+// (Emit, Publish, Enqueue, EmitToPattern), before it's delivered to connections. Data is passed as it was emitted.
+// It's called synchronously, so it must not block, e.g. to mirror channel traffic to a message broker.
+// Callbacks are called in order of adding, returned function removes the callback.
+func (s *Server) OnChannelEmit(f func(ch *Channel, name string, data any)) (off func()) {
+	h := &channelEmitHook{f: f}
+	s.mu.Lock()
+	// hooks are copied on write, persist calls them without the lock
+	s.onChannelEmit = append(s.onChannelEmit[:len(s.onChannelEmit):len(s.onChannelEmit)], h)
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		hooks := make([]*channelEmitHook, 0, len(s.onChannelEmit))
+		for _, v := range s.onChannelEmit {
+			if v != h {
+				hooks = append(hooks, v)
+			}
+		}
+		s.onChannelEmit = hooks
+	}
+}
+
+// channelEmitHook is OnChannelEmit callback, pointer identifies it for removal.
+type channelEmitHook struct {
+	f func(ch *Channel, name string, data any)
+}
+
+// OnJoin function which will be called when connection is added to channel.
+func (c *Channel) OnJoin(f func(c Connection)) {
+	c.mu.Lock()
+	c.onJoin = f
+	c.mu.Unlock()
+}
+
+// OnLeave function which will be called when connection is removed from channel
+// or dropped while being in channel.
+func (c *Channel) OnLeave(f func(c Connection)) {
+	c.mu.Lock()
+	c.onLeave = f
 	c.mu.Unlock()
 }
+
+// OnEmpty function which will be called when the last connection leaves channel.
+// It's a good place to stop upstream subscriptions of the channel.
+func (c *Channel) OnEmpty(f func(ch *Channel)) {
+	c.mu.Lock()
+	c.onEmpty = f
+	c.mu.Unlock()
+}
+
+// Close remove all connections from channel and stop accepting new ones. Connections stay open.
+// Channel created by server is removed from the server.
+func (c *Channel) Close() {
+	c.close(nil)
+}
+
+// close the channel if cond is nil or returns true, cond is called with c.mu locked,
+// so connection can't join between the check and the close.
+func (c *Channel) close(cond func() bool) {
+	c.mu.Lock()
+	if c.closed || cond != nil && !cond() {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	s := c.server
+	d := c.dispatcher
+	if c.logDone != nil {
+		close(c.logDone)
+	}
+	c.mu.Unlock()
+
+	if d != nil {
+		d.stop()
+	}
+
+	if s != nil {
+		s.mu.Lock()
+		if s.channels[c.id] == c {
+			delete(s.channels, c.id)
+		}
+		s.mu.Unlock()
+	}
+
+	c.Purge()
+}
+
+// empty return true when channel has no connections, c.mu must be locked.
+func (c *Channel) empty() bool {
+	return len(c.connections) == 0
+}
+
+// idle return true when channel has no connections for d, c.mu must be locked.
+func (c *Channel) idle(now time.Time, d time.Duration) bool {
+	return len(c.connections) == 0 && !c.emptySince.IsZero() && now.Sub(c.emptySince) >= d
+}
+
+// WithChannelTTL removes channels which have no connections for ttl.
+// Channels are checked every ttl/2, so channel could live up to 1.5*ttl.
+func WithChannelTTL(ttl time.Duration) Option {
+	return func(s *Server) {
+		s.channelTTL = ttl
+	}
+}
+
+// collectChannels close channels which are idle longer than ttl.
+func (s *Server) collectChannels() {
+	ticker := time.NewTicker(s.channelTTL / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.mu.RLock()
+			channels := make([]*Channel, 0, len(s.channels))
+			for _, ch := range s.channels {
+				channels = append(channels, ch)
+			}
+			s.mu.RUnlock()
+
+			for _, ch := range channels {
+				ch.close(func() bool { return ch.idle(now, s.channelTTL) })
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// Has reports whether connection is in channel.
+func (c *Channel) Has(conn Connection) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.connections[conn]
+	return ok
+}
+
+// snapshot return a copy of channel connections, so they could be used without holding the lock.
+func (c *Channel) snapshot() []Connection {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	list := make([]Connection, 0, len(c.connections))
+	for con := range c.connections {
+		list = append(list, con)
+	}
+	return list
+}
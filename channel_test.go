@@ -6,8 +6,10 @@ import (
 	"github.com/gobwas/ws"
 	"github.com/gobwas/ws/wsutil"
 	"github.com/stretchr/testify/require"
+	"io"
 	"net/url"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -18,7 +20,7 @@ func TestChannel_Add(t *testing.T) {
 
 	ch := wsServer.NewChannel("test-channel-add")
 
-	wsServer.OnConnect(func(c *Conn) {
+	wsServer.OnConnect(func(c Connection) {
 		ch.Add(c)
 		require.Equal(t, 1, ch.Count(), "channel must contain only 1 connection")
 	})
@@ -39,12 +41,12 @@ func TestChannel_Emit(t *testing.T) {
 
 	_message := Message{
 		Name: "test-channel-emit",
-		Data: []byte("message"),
+		Data: json.RawMessage(`"message"`),
 	}
 	messageBytes, err := json.Marshal(_message)
 	require.NoError(t, err)
 
-	wsServer.OnConnect(func(c *Conn) {
+	wsServer.OnConnect(func(c Connection) {
 		ch.Add(c)
 		time.Sleep(300 * time.Millisecond)
 		ch.Emit(_message.Name, _message.Data)
@@ -78,7 +80,7 @@ func TestChannel_Remove(t *testing.T) {
 
 	ch := wsServer.NewChannel("test-channel-add")
 
-	wsServer.OnConnect(func(c *Conn) {
+	wsServer.OnConnect(func(c Connection) {
 		ch.Add(c)
 		require.Equal(t, 1, ch.Count(), "channel must contain only 1 connection")
 		ch.Remove(c)
@@ -100,3 +102,304 @@ func TestChannel_Id(t *testing.T) {
 	ch := wsServer.NewChannel("test-channel-id")
 	require.Equal(t, "test-channel-id", ch.ID(), "channel must have same id")
 }
+
+func TestChannel_Purge(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	ch := wsServer.NewChannel("test-channel-purge")
+	connected := make(chan *Conn, 10)
+	wsServer.OnConnect(func(c Connection) {
+		ch.Add(c)
+		connected <- c.(*Conn)
+	})
+
+	c1 := dial(t, ts)
+	defer func() {
+		_ = c1.Close()
+	}()
+	<-connected
+	require.Equal(t, 1, ch.Count())
+
+	ch.Purge()
+	require.Equal(t, 0, ch.Count(), "channel must be empty after purge")
+
+	c2 := dial(t, ts)
+	<-connected
+	require.Equal(t, 1, ch.Count())
+
+	require.NoError(t, c2.Close())
+	require.Eventually(t, func() bool {
+		return ch.Count() == 0
+	}, time.Second, 5*time.Millisecond, "disconnect must remove connection from channel after purge")
+}
+
+func TestChannel_Connections(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	ch := wsServer.NewChannel("test-channel-connections")
+	connected := make(chan *Conn, 10)
+	wsServer.OnConnect(func(c Connection) {
+		connected <- c.(*Conn)
+	})
+
+	var conns []*Conn
+	for range 3 {
+		c := dial(t, ts)
+		defer func() {
+			_ = c.Close()
+		}()
+		conns = append(conns, <-connected)
+	}
+	require.NoError(t, ch.Add(conns[0]))
+	require.NoError(t, ch.Add(conns[1]))
+
+	require.ElementsMatch(t, conns[:2], ch.Connections())
+	require.True(t, ch.Has(conns[0]))
+	require.False(t, ch.Has(conns[2]))
+
+	list := ch.Connections()
+	ch.Remove(conns[0])
+	require.Len(t, list, 2, "snapshot must not change")
+	require.False(t, ch.Has(conns[0]))
+
+	require.NoError(t, ch.Add(conns[2]))
+	visited := 0
+	ch.ForEach(func(c Connection) bool {
+		visited++
+		ch.Remove(c)
+		return true
+	})
+	require.Equal(t, 2, visited)
+	require.Empty(t, ch.Connections())
+
+	require.NoError(t, ch.Add(conns[0]))
+	require.NoError(t, ch.Add(conns[1]))
+	visited = 0
+	ch.ForEach(func(c Connection) bool {
+		visited++
+		return false
+	})
+	require.Equal(t, 1, visited, "iteration must stop when f returns false")
+}
+
+func TestChannel_Purge_underTraffic(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	ch := wsServer.NewChannel("test-channel-purge-traffic")
+	connected := make(chan *Conn, 10)
+	wsServer.OnConnect(func(c Connection) {
+		connected <- c.(*Conn)
+	})
+
+	conns := make([]*Conn, 0)
+	for i := 0; i < 5; i++ {
+		c := dial(t, ts)
+		defer func() {
+			_ = c.Close()
+		}()
+		go func() {
+			_, _ = io.Copy(io.Discard, c)
+		}()
+		conns = append(conns, <-connected)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for _, c := range conns {
+				ch.Add(c)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			ch.Emit("test", "message")
+		}()
+		go func() {
+			defer wg.Done()
+			ch.Purge()
+		}()
+	}
+	wg.Wait()
+
+	ch.Purge()
+	require.Equal(t, 0, ch.Count())
+	require.Equal(t, 5, wsServer.Count(), "purge must not close connections")
+}
+
+func TestChannel_lifecycle(t *testing.T) {
+	ch := newChannel("lifecycle")
+
+	var joined, left []string
+	empty := 0
+	ch.OnJoin(func(c Connection) {
+		joined = append(joined, c.ID())
+	})
+	ch.OnLeave(func(c Connection) {
+		left = append(left, c.ID())
+	})
+	ch.OnEmpty(func(c *Channel) {
+		require.Equal(t, ch, c)
+		empty++
+	})
+
+	c1, c2 := &Conn{id: "1"}, &Conn{id: "2"}
+	ch.Add(c1)
+	ch.Add(c2)
+	ch.Add(c1)
+	require.Equal(t, []string{"1", "2"}, joined, "OnJoin must be called once per connection")
+
+	ch.Remove(c1)
+	ch.Remove(c1)
+	require.Equal(t, []string{"1"}, left, "OnLeave must be called only for members")
+	require.Equal(t, 0, empty)
+
+	ch.Remove(c2)
+	require.Equal(t, []string{"1", "2"}, left)
+	require.Equal(t, 1, empty)
+
+	ch.Add(c1)
+	ch.Add(c2)
+	ch.Purge()
+	require.ElementsMatch(t, []string{"1", "2", "1", "2"}, left)
+	require.Equal(t, 2, empty)
+
+	ch.Purge()
+	require.Equal(t, 2, empty, "empty channel must not call OnEmpty again")
+}
+
+func TestChannel_OnEmpty_disconnect(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	ch := wsServer.NewChannel("room")
+	empty := make(chan struct{}, 1)
+	ch.OnEmpty(func(*Channel) {
+		empty <- struct{}{}
+	})
+
+	c := dial(t, ts)
+	writeMessage(t, c, EventSubscribe, map[string]string{"channel": "room"})
+	_, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+
+	select {
+	case <-empty:
+	case <-time.After(time.Second):
+		t.Fatal("OnEmpty must be called when the last connection dropped")
+	}
+}
+
+func TestChannel_Close(t *testing.T) {
+	wsServer := New()
+	ch := wsServer.NewChannel("closed")
+
+	c := &Conn{id: "1"}
+	ch.Add(c)
+	ch.Close()
+	ch.Close()
+
+	require.Nil(t, wsServer.Channel("closed"), "closed channel must be removed from server")
+	require.Equal(t, 0, ch.Count())
+
+	require.ErrorIs(t, ch.Add(c), ErrChannelClosed)
+	require.Equal(t, 0, ch.Count(), "closed channel must ignore new connections")
+	require.Empty(t, c.Channels())
+}
+
+func TestServer_RemoveChannel(t *testing.T) {
+	wsServer := New()
+	ch := wsServer.NewChannel("test")
+	left := 0
+	ch.OnLeave(func(c Connection) {
+		left++
+	})
+	ch.Add(&Conn{id: "1"})
+
+	wsServer.RemoveChannel("test")
+	wsServer.RemoveChannel("unknown")
+	require.Nil(t, wsServer.Channel("test"))
+	require.Equal(t, 1, left)
+
+	ch2 := wsServer.NewChannel("test")
+	ch3 := wsServer.NewChannel("test")
+	require.Equal(t, ch3, wsServer.Channel("test"))
+	require.True(t, ch2.closed, "replaced channel must be closed")
+}
+
+func TestServer_WithChannelTTL(t *testing.T) {
+	_, wsServer, shutdown := server(t, WithChannelTTL(50*time.Millisecond))
+	defer shutdown()
+
+	idle := wsServer.NewChannel("idle")
+	used := wsServer.NewChannel("used")
+	used.Add(&Conn{id: "1"})
+
+	require.Eventually(t, func() bool {
+		return wsServer.Channel("idle") == nil
+	}, time.Second, 10*time.Millisecond, "idle channel must be removed")
+	require.True(t, idle.closed)
+	require.Equal(t, used, wsServer.Channel("used"), "channel with connections must stay")
+	require.ErrorIs(t, idle.Add(&Conn{id: "2"}), ErrChannelClosed, "removed channel must reject connections")
+}
+
+func TestServer_WithChannelTTL_rejoined(t *testing.T) {
+	wsServer := New(WithChannelTTL(time.Millisecond))
+	ch := wsServer.NewChannel("room")
+	ch.emptySince = time.Now().Add(-time.Second)
+
+	now := time.Now()
+	require.NoError(t, ch.Add(&Conn{id: "1"}), "connection joins after idle channel was collected")
+	ch.close(func() bool { return ch.idle(now, time.Millisecond) })
+	require.False(t, ch.closed, "channel must be checked for idle under lock")
+	require.Equal(t, ch, wsServer.Channel("room"))
+}
+
+func TestChannel_Purge_presence(t *testing.T) {
+	ch := newChannel("room")
+	ch.SetPresence(func(c Connection) any { return nil })
+	c1, c2 := newFakeConn("1"), newFakeConn("2")
+	require.NoError(t, ch.Add(c1))
+	require.NoError(t, ch.Add(c2))
+
+	ch.Purge()
+	require.Empty(t, ch.Members())
+	require.Equal(t, []string{EventMemberAdded, EventMemberRemoved, EventMemberRemoved}, c1.emitted())
+	require.Equal(t, []string{EventMemberRemoved, EventMemberRemoved}, c2.emitted())
+}
+
+func TestServer_OnChannelEmit(t *testing.T) {
+	wsServer := New()
+	var emitted []string
+	wsServer.OnChannelEmit(func(ch *Channel, name string, data any) {
+		emitted = append(emitted, ch.ID()+":"+name)
+	})
+
+	wsServer.NewChannel("org.a").Emit("one", 1)
+	require.NoError(t, wsServer.EmitToPattern("org.*", "two", 2))
+	require.NoError(t, wsServer.EmitToChannel("org.a", "three", 3))
+	require.Equal(t, []string{"org.a:one", "org.a:two", "org.a:three"}, emitted)
+}
+
+func TestServer_OnChannelEmit_off(t *testing.T) {
+	wsServer := New()
+	var first, second int
+	off := wsServer.OnChannelEmit(func(ch *Channel, name string, data any) {
+		first++
+	})
+	wsServer.OnChannelEmit(func(ch *Channel, name string, data any) {
+		second++
+	})
+
+	ch := wsServer.NewChannel("room")
+	ch.Emit("one", 1)
+	off()
+	ch.Emit("two", 2)
+	require.Equal(t, 1, first, "removed hook must not be called")
+	require.Equal(t, 2, second, "hooks must be additive")
+}
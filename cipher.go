@@ -0,0 +1,107 @@
+package websocket
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrDecrypt is returned when payload of message from client can't be decrypted.
+var ErrDecrypt = errors.New("websocket: can't decrypt payload")
+
+// PayloadCipher encrypts data of messages of one connection on application level, so payload stays
+// encrypted even when TLS is terminated by proxy. See package naclbox for NaCl box implementation.
+type PayloadCipher interface {
+	Encrypt(data []byte) ([]byte, error)
+	Decrypt(data []byte) ([]byte, error)
+}
+
+// SetPayloadCipher enables encryption of data of named messages of connection, nil disables it.
+// Cipher is usually set by key exchange handler after it replied with its key.
+// Data of sent messages is encrypted after OnBeforeSend and sent as base64 string in json envelope
+// and as is in binary envelope, client must send data encrypted in the same way.
+// Messages from client which can't be decrypted are rejected with CodeBadRequest, handlers and OnMessage
+// receive decrypted data.
+// System events (e.g. _subscribe) are not encrypted.
+func (c *Conn) SetPayloadCipher(pc PayloadCipher) {
+	if pc == nil {
+		c.cipher.Store(nil)
+		return
+	}
+	c.cipher.Store(&pc)
+}
+
+// payloadCipher return cipher of connection, nil if it's not set.
+func (c *Conn) payloadCipher() PayloadCipher {
+	if pc := c.cipher.Load(); pc != nil {
+		return *pc
+	}
+	return nil
+}
+
+// encrypt data of envelope with cipher of connection.
+func (c *Conn) encrypt(env envelope, pc PayloadCipher) (envelope, error) {
+	b, err := c.envelopeData(env)
+	if err != nil {
+		return env, err
+	}
+	if b, err = pc.Encrypt(b); err != nil {
+		return env, err
+	}
+
+	if c.envelope == BinaryEnvelope {
+		env.Data = b
+	} else {
+		env.Data = base64.StdEncoding.EncodeToString(b)
+	}
+	return env, nil
+}
+
+// decrypt payload of received message with cipher of connection.
+func (c *Conn) decrypt(payload []byte, pc PayloadCipher) ([]byte, error) {
+	if c.envelope != BinaryEnvelope {
+		var s string
+		if err := json.Unmarshal(payload, &s); err != nil {
+			return nil, ErrDecrypt
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+		payload = b
+	}
+
+	b, err := pc.Decrypt(payload)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return b, nil
+}
+
+// plainFrame encode received envelope again with decrypted data.
+func (c *Conn) plainFrame(env envelope, data []byte) ([]byte, error) {
+	e := getEncoder()
+	defer putEncoder(e)
+
+	var (
+		b   []byte
+		err error
+	)
+	if c.envelope == BinaryEnvelope {
+		env.Data = data
+		b, err = e.encodeBinary(env)
+	} else {
+		if json.Valid(data) {
+			env.Data = json.RawMessage(data)
+		} else {
+			env.Data, err = jsonData(data)
+		}
+		if err == nil {
+			b, err = e.encode(env)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, b...), nil
+}
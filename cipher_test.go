@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// xorCipher flips bits of payload, prefix marks encrypted data.
+type xorCipher struct{}
+
+func (xorCipher) Encrypt(data []byte) ([]byte, error) {
+	b := []byte("x:")
+	for _, v := range data {
+		b = append(b, v^0xff)
+	}
+	return b, nil
+}
+
+func (xorCipher) Decrypt(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte("x:")) {
+		return nil, errors.New("not encrypted")
+	}
+	b := make([]byte, 0, len(data)-2)
+	for _, v := range data[2:] {
+		b = append(b, v^0xff)
+	}
+	return b, nil
+}
+
+func TestConn_SetPayloadCipher(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithEnvelope(BinaryEnvelope))
+	defer shutdown()
+
+	wsServer.On("secure", func(c Connection, msg *Message) {
+		c.(*Conn).SetPayloadCipher(xorCipher{})
+		_ = c.Emit("secure", "ok")
+	})
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		_ = c.Emit("echo", msg.Data)
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+
+	read := func() envelope {
+		b, _, err := wsutil.ReadServerData(c)
+		require.NoError(t, err)
+		env, err := decodeBinary(b)
+		require.NoError(t, err)
+		return env
+	}
+
+	require.NoError(t, wsutil.WriteClientBinary(c, []byte{0, 6, 's', 'e', 'c', 'u', 'r', 'e'}))
+	env := read()
+	require.Equal(t, "secure", env.Name)
+	plain, err := xorCipher{}.Decrypt(env.Data.([]byte))
+	require.NoError(t, err)
+	require.Equal(t, `"ok"`, string(plain))
+
+	payload, _ := xorCipher{}.Encrypt([]byte("hi"))
+	require.NoError(t, wsutil.WriteClientBinary(c, append([]byte{0, 4, 'e', 'c', 'h', 'o'}, payload...)))
+	env = read()
+	require.Equal(t, payload, env.Data, "echo is encrypted again")
+
+	// plaintext is rejected with system event which is not encrypted
+	require.NoError(t, wsutil.WriteClientMessage(c, ws.OpBinary, []byte{0, 4, 'e', 'c', 'h', 'o', 'h', 'i'}))
+	env = read()
+	require.Equal(t, EventError, env.Name)
+	require.Contains(t, string(env.Data.([]byte)), "decrypt")
+}
+
+func TestConn_SetPayloadCipher_onMessage(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithEnvelope(BinaryEnvelope))
+	defer shutdown()
+
+	wsServer.On("secure", func(c Connection, msg *Message) {
+		c.(*Conn).SetPayloadCipher(xorCipher{})
+		_ = c.Emit("secure", "ok")
+	})
+	frames := make(chan []byte, 1)
+	wsServer.OnMessage(func(c Connection, h ws.Header, b []byte) {
+		frames <- append([]byte{}, b...)
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+
+	require.NoError(t, wsutil.WriteClientBinary(c, []byte{0, 6, 's', 'e', 'c', 'u', 'r', 'e'}))
+	_, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+
+	payload, _ := xorCipher{}.Encrypt([]byte("hi"))
+	require.NoError(t, wsutil.WriteClientBinary(c, append([]byte{0, 7, 'u', 'n', 'k', 'n', 'o', 'w', 'n'}, payload...)))
+	env, err := decodeBinary(<-frames)
+	require.NoError(t, err)
+	require.Equal(t, "unknown", env.Name)
+	require.Equal(t, []byte("hi"), env.Data, "OnMessage must get decrypted data")
+}
@@ -0,0 +1,67 @@
+// Command wsbench runs load test against websocket server and prints latency and errors:
+//
+//	wsbench -url ws://localhost:8080/ws -c 1000 -channels room-1,room-2 -spread -rate 1 -duration 1m
+//
+// Server should echo data of the event back or emit it to the channel, see package wsbench.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/pkgz/websocket/wsbench"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+type headers http.Header
+
+func (h headers) String() string { return "" }
+
+func (h headers) Set(v string) error {
+	key, value, ok := strings.Cut(v, ":")
+	if !ok {
+		return fmt.Errorf("header must be Key: Value, got %q", v)
+	}
+	http.Header(h).Add(strings.TrimSpace(key), strings.TrimSpace(value))
+	return nil
+}
+
+func main() {
+	cfg := wsbench.Config{Header: http.Header{}}
+	channels := flag.String("channels", "", "comma separated channels to join")
+	flag.StringVar(&cfg.URL, "url", "", "websocket url, e.g. ws://localhost:8080/ws")
+	flag.IntVar(&cfg.Connections, "c", 100, "number of connections")
+	flag.DurationVar(&cfg.Ramp, "ramp", 0, "spread connects over the duration")
+	flag.BoolVar(&cfg.Spread, "spread", false, "join every connection to one channel instead of all")
+	flag.StringVar(&cfg.Event, "event", wsbench.DefaultEvent, "name of sent events")
+	flag.Float64Var(&cfg.Rate, "rate", 1, "events per second of every connection, 0 to only listen")
+	flag.IntVar(&cfg.Payload, "payload", 0, "padding size of every event")
+	flag.DurationVar(&cfg.Duration, "duration", 30*time.Second, "duration of the test")
+	flag.Var(headers(cfg.Header), "H", "handshake header, e.g. -H 'Authorization: Bearer token' (repeatable)")
+	flag.Parse()
+
+	if cfg.URL == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *channels != "" {
+		cfg.Channels = strings.Split(*channels, ",")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	report, err := wsbench.Run(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(report)
+	if len(report.Errors) != 0 {
+		os.Exit(1)
+	}
+}
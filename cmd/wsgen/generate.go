@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"strings"
+	"text/template"
+)
+
+var goTemplate = template.Must(template.New("go").Funcs(template.FuncMap{
+	"goName":  goName,
+	"goType":  goType,
+	"comment": comment,
+}).Parse(`// Code generated by wsgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- if .HasClient}}
+	"encoding/json"
+{{- end}}
+	"github.com/pkgz/websocket"
+)
+
+// Event names.
+const (
+{{- range .Events}}
+	Event{{goName .Name}} = "{{.Name}}"
+{{- end}}
+)
+{{range .Events}}{{if .Fields}}
+{{comment .Type .Description}}
+type {{.Type}} struct {
+{{- range .Fields}}
+	{{goName .Name}} {{goType .Type}} ` + "`" + `json:"{{.Name}}{{if .Optional}},omitempty{{end}}"` + "`" + `{{if .Description}} // {{.Description}}{{end}}
+{{- end}}
+}
+{{end}}{{end}}
+{{- range .Events}}{{if .FromServer}}
+// Emit{{goName .Name}} send "{{.Name}}" event to the connection.
+func Emit{{goName .Name}}(c websocket.Connection, data {{.DataType}}) error {
+	return c.Emit(Event{{goName .Name}}, data)
+}
+{{end}}{{if .FromClient}}
+// On{{goName .Name}} register handler of "{{.Name}}" event.
+// Messages with data which can't be decoded are ignored.
+func On{{goName .Name}}(s *websocket.Server, f func(c websocket.Connection, data {{.DataType}})) {
+	s.On(Event{{goName .Name}}, func(c websocket.Connection, msg *websocket.Message) {
+		var data {{.DataType}}
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			return
+		}
+		f(c, data)
+	})
+}
+{{end}}{{end}}`))
+
+type goEvent struct {
+	Event
+	DataType   string
+	FromClient bool
+	FromServer bool
+}
+
+// generateGo produce formatted go code for schema.
+func generateGo(s *Schema) ([]byte, error) {
+	data := struct {
+		Package   string
+		HasClient bool
+		Events    []goEvent
+	}{
+		Package: s.Package,
+	}
+	for _, e := range s.Events {
+		data.Events = append(data.Events, goEvent{
+			Event:      e,
+			DataType:   e.dataType(),
+			FromClient: e.fromClient(),
+			FromServer: e.fromServer(),
+		})
+		data.HasClient = data.HasClient || e.fromClient()
+	}
+
+	var buf bytes.Buffer
+	if err := goTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// generateTS produce typescript definitions for schema.
+func generateTS(s *Schema) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by wsgen. DO NOT EDIT.\n")
+
+	for _, e := range s.Events {
+		if len(e.Fields) == 0 {
+			continue
+		}
+		buf.WriteString("\n")
+		if e.Description != "" {
+			buf.WriteString("/** " + e.Description + " */\n")
+		}
+		buf.WriteString("export interface " + e.Type + " {\n")
+		for _, f := range e.Fields {
+			if f.Description != "" {
+				buf.WriteString("  /** " + f.Description + " */\n")
+			}
+			optional := ""
+			if f.Optional {
+				optional = "?"
+			}
+			buf.WriteString("  " + f.Name + optional + ": " + tsType(f.Type) + ";\n")
+		}
+		buf.WriteString("}\n")
+	}
+
+	writeMap := func(name, doc string, match func(Event) bool) {
+		buf.WriteString("\n/** " + doc + " */\n")
+		buf.WriteString("export interface " + name + " {\n")
+		for _, e := range s.Events {
+			if !match(e) {
+				continue
+			}
+			t := tsType(e.Data)
+			if len(e.Fields) != 0 {
+				t = e.Type
+			}
+			buf.WriteString("  \"" + e.Name + "\": " + t + ";\n")
+		}
+		buf.WriteString("}\n")
+	}
+	writeMap("ServerEvents", "Events sent by server.", Event.fromServer)
+	writeMap("ClientEvents", "Events sent by client.", Event.fromClient)
+
+	buf.WriteString(`
+/** Envelope of the message on the wire. */
+export interface Envelope<E, K extends keyof E = keyof E> {
+  name: K;
+  data: E[K];
+}
+`)
+
+	return buf.Bytes()
+}
+
+// comment build doc comment for type.
+func comment(name, description string) string {
+	if description == "" {
+		return "// " + name + " is data of the event."
+	}
+	return "// " + name + " is " + strings.TrimSuffix(description, ".") + "."
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const testSchema = `
+package: chat
+events:
+  - name: chat.message
+    description: message in the chat room
+    fields:
+      - name: text
+        type: string
+      - name: user_id
+        type: int
+      - name: tags
+        type: "[]string"
+        optional: true
+  - name: chat.typing
+    direction: client
+    data: bool
+  - name: chat.history
+    direction: server
+    data: "[]chat.message"
+`
+
+func TestParseSchema(t *testing.T) {
+	s, err := parseSchema([]byte(testSchema))
+	require.NoError(t, err)
+	require.Equal(t, "chat", s.Package)
+	require.Len(t, s.Events, 3)
+	require.Equal(t, "ChatMessage", s.Events[0].Type)
+	require.Equal(t, directionBoth, s.Events[0].Direction)
+	require.Equal(t, "[]ChatMessage", s.Events[2].dataType())
+
+	_, err = parseSchema([]byte(`{"package": "chat", "events": [{"name": "_auth"}]}`))
+	require.Error(t, err, "system events must be rejected")
+
+	_, err = parseSchema([]byte(`{"package": "chat", "events": [{"name": "a", "direction": "up"}]}`))
+	require.Error(t, err)
+
+	_, err = parseSchema([]byte(`{"events": []}`))
+	require.Error(t, err)
+}
+
+func TestGenerateGo(t *testing.T) {
+	s, err := parseSchema([]byte(testSchema))
+	require.NoError(t, err)
+
+	b, err := generateGo(s)
+	require.NoError(t, err)
+
+	code := string(b)
+	require.Contains(t, code, "package chat")
+	require.Contains(t, code, `EventChatMessage = "chat.message"`)
+	require.Contains(t, code, "type ChatMessage struct {")
+	require.Contains(t, code, "UserID int      `json:\"user_id\"`")
+	require.Contains(t, code, "Tags   []string `json:\"tags,omitempty\"`")
+	require.Contains(t, code, "func EmitChatMessage(c websocket.Connection, data ChatMessage) error")
+	require.Contains(t, code, "func OnChatMessage(s *websocket.Server, f func(c websocket.Connection, data ChatMessage))")
+	require.Contains(t, code, "func OnChatTyping(s *websocket.Server, f func(c websocket.Connection, data bool))")
+	require.NotContains(t, code, "func EmitChatTyping")
+	require.Contains(t, code, "func EmitChatHistory(c websocket.Connection, data []ChatMessage) error")
+	require.NotContains(t, code, "func OnChatHistory")
+}
+
+func TestGenerateTS(t *testing.T) {
+	s, err := parseSchema([]byte(testSchema))
+	require.NoError(t, err)
+
+	ts := string(generateTS(s))
+	require.Contains(t, ts, "export interface ChatMessage {")
+	require.Contains(t, ts, "  user_id: number;")
+	require.Contains(t, ts, "  tags?: string[];")
+	require.Contains(t, ts, "export interface ServerEvents {\n  \"chat.message\": ChatMessage;\n  \"chat.history\": ChatMessage[];\n}")
+	require.Contains(t, ts, "export interface ClientEvents {\n  \"chat.message\": ChatMessage;\n  \"chat.typing\": boolean;\n}")
+}
+
+func TestGoName(t *testing.T) {
+	require.Equal(t, "ChatMessage", goName("chat.message"))
+	require.Equal(t, "UserID", goName("user_id"))
+	require.Equal(t, "OrderCreatedV2", goName("order-created.v2"))
+}
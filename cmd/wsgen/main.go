@@ -0,0 +1,72 @@
+// Command wsgen generates typed event helpers from event schema.
+//
+// Schema is YAML or JSON file:
+//
+//	package: chat
+//	events:
+//	  - name: chat.message
+//	    direction: both # client, server or both
+//	    description: message in the chat room
+//	    fields:
+//	      - name: text
+//	        type: string
+//	      - name: user_id
+//	        type: int
+//	  - name: chat.typing
+//	    direction: client
+//	    data: bool
+//
+// For every event wsgen produces the constant with event name, data type (for events with fields),
+// Emit helper for events sent by server and On helper for events sent by client.
+// Optionally it writes TypeScript definitions for browser clients.
+//
+// Usage with go generate:
+//
+//	//go:generate go run github.com/pkgz/websocket/cmd/wsgen -schema events.yaml -out events_gen.go -ts web/events.ts
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+)
+
+func main() {
+	schemaPath := flag.String("schema", "", "path to events schema (yaml or json)")
+	out := flag.String("out", "", "path to generated go file")
+	ts := flag.String("ts", "", "path to generated typescript definitions (optional)")
+	pkg := flag.String("package", "", "go package name, overrides schema package")
+	flag.Parse()
+
+	if *schemaPath == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	b, err := os.ReadFile(*schemaPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	schema, err := parseSchema(b)
+	if err != nil {
+		log.Fatalf("wsgen: %v", err)
+	}
+	if *pkg != "" {
+		schema.Package = *pkg
+	}
+
+	code, err := generateGo(schema)
+	if err != nil {
+		log.Fatalf("wsgen: %v", err)
+	}
+	if err = os.WriteFile(*out, code, 0o644); err != nil {
+		log.Fatal(err)
+	}
+
+	if *ts != "" {
+		if err = os.WriteFile(*ts, generateTS(schema), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
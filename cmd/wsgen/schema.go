@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"strings"
+	"unicode"
+)
+
+// Schema describes events of the application.
+type Schema struct {
+	Package string  `yaml:"package"`
+	Events  []Event `yaml:"events"`
+}
+
+// Event is one named message.
+type Event struct {
+	Name        string  `yaml:"name"`
+	Direction   string  `yaml:"direction"`
+	Description string  `yaml:"description"`
+	Type        string  `yaml:"type"`
+	Data        string  `yaml:"data"`
+	Fields      []Field `yaml:"fields"`
+}
+
+// Field of the event data.
+type Field struct {
+	Name        string `yaml:"name"`
+	Type        string `yaml:"type"`
+	Optional    bool   `yaml:"optional"`
+	Description string `yaml:"description"`
+}
+
+const (
+	directionClient = "client"
+	directionServer = "server"
+	directionBoth   = "both"
+)
+
+// parseSchema parse yaml or json schema and validate it.
+func parseSchema(b []byte) (*Schema, error) {
+	var s Schema
+	if err := yaml.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+
+	if s.Package == "" {
+		return nil, errors.New("package is required")
+	}
+
+	names := make(map[string]bool)
+	for i := range s.Events {
+		e := &s.Events[i]
+		if e.Name == "" {
+			return nil, fmt.Errorf("event %d: name is required", i)
+		}
+		if strings.HasPrefix(e.Name, "_") {
+			return nil, fmt.Errorf("event %q: names starting with _ are reserved", e.Name)
+		}
+		if names[e.Name] {
+			return nil, fmt.Errorf("event %q: duplicated", e.Name)
+		}
+		names[e.Name] = true
+
+		switch e.Direction {
+		case "":
+			e.Direction = directionBoth
+		case directionClient, directionServer, directionBoth:
+		default:
+			return nil, fmt.Errorf("event %q: unknown direction %q", e.Name, e.Direction)
+		}
+
+		if len(e.Fields) != 0 && e.Data != "" {
+			return nil, fmt.Errorf("event %q: data and fields can't be used together", e.Name)
+		}
+		if len(e.Fields) != 0 && e.Type == "" {
+			e.Type = goName(e.Name)
+		}
+		for _, f := range e.Fields {
+			if f.Name == "" || f.Type == "" {
+				return nil, fmt.Errorf("event %q: field name and type are required", e.Name)
+			}
+		}
+	}
+
+	return &s, nil
+}
+
+// dataType return go type of event data.
+func (e Event) dataType() string {
+	switch {
+	case len(e.Fields) != 0:
+		return e.Type
+	case e.Data != "":
+		return goType(e.Data)
+	default:
+		return "any"
+	}
+}
+
+func (e Event) fromClient() bool {
+	return e.Direction == directionClient || e.Direction == directionBoth
+}
+
+func (e Event) fromServer() bool {
+	return e.Direction == directionServer || e.Direction == directionBoth
+}
+
+var initialisms = map[string]string{
+	"id":   "ID",
+	"ip":   "IP",
+	"url":  "URL",
+	"uri":  "URI",
+	"uuid": "UUID",
+	"json": "JSON",
+	"http": "HTTP",
+	"api":  "API",
+}
+
+// goName convert event or field name (chat.message, user_id) to exported go identifier.
+func goName(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var sb strings.Builder
+	for _, p := range parts {
+		if v, ok := initialisms[strings.ToLower(p)]; ok {
+			sb.WriteString(v)
+			continue
+		}
+		r := []rune(p)
+		r[0] = unicode.ToUpper(r[0])
+		sb.WriteString(string(r))
+	}
+	return sb.String()
+}
+
+// goType convert schema type to go type.
+func goType(t string) string {
+	switch {
+	case strings.HasPrefix(t, "[]"):
+		return "[]" + goType(t[2:])
+	case strings.HasPrefix(t, "map[string]"):
+		return "map[string]" + goType(t[len("map[string]"):])
+	}
+
+	switch t {
+	case "string", "bool", "int", "int64", "float64", "any":
+		return t
+	case "number", "float":
+		return "float64"
+	case "integer":
+		return "int"
+	case "boolean":
+		return "bool"
+	case "object":
+		return "map[string]any"
+	default:
+		return goName(t)
+	}
+}
+
+// tsType convert schema type to typescript type.
+func tsType(t string) string {
+	switch {
+	case strings.HasPrefix(t, "[]"):
+		return tsType(t[2:]) + "[]"
+	case strings.HasPrefix(t, "map[string]"):
+		return "Record<string, " + tsType(t[len("map[string]"):]) + ">"
+	}
+
+	switch t {
+	case "string":
+		return "string"
+	case "bool", "boolean":
+		return "boolean"
+	case "int", "int64", "float64", "number", "float", "integer":
+		return "number"
+	case "any", "":
+		return "unknown"
+	case "object":
+		return "Record<string, unknown>"
+	default:
+		return goName(t)
+	}
+}
@@ -0,0 +1,45 @@
+package websocket
+
+import "time"
+
+// conflated is the latest message of conflation key waiting for flush.
+type conflated struct {
+	name string
+	data any
+}
+
+// EmitConflated emit message to channel after window, messages with the same key emitted during
+// the window replace each other and only the latest one is sent. It's useful for high-frequency
+// updates like prices or cursor positions. Message is sent immediately if server is not running.
+func (c *Channel) EmitConflated(key string, name string, data any, window time.Duration) {
+	c.mu.Lock()
+	if p, ok := c.conflated[key]; ok {
+		p.name, p.data = name, data
+		c.mu.Unlock()
+		return
+	}
+	if c.conflated == nil {
+		c.conflated = make(map[string]*conflated)
+	}
+	c.conflated[key] = &conflated{name: name, data: data}
+	c.mu.Unlock()
+
+	flush := func() {
+		c.mu.Lock()
+		p := c.conflated[key]
+		delete(c.conflated, key)
+		c.mu.Unlock()
+
+		if p != nil {
+			c.Emit(p.name, p.data)
+		}
+	}
+
+	if c.server == nil {
+		flush()
+		return
+	}
+	if _, err := c.server.schedule(window, flush); err != nil {
+		flush()
+	}
+}
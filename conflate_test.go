@@ -0,0 +1,41 @@
+package websocket
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestChannel_EmitConflated(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	ch := wsServer.NewChannel("prices")
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	joinChannel(t, c, "prices")
+
+	for i := 0; i < 10; i++ {
+		ch.EmitConflated("BTC", "price", i, 50*time.Millisecond)
+		ch.EmitConflated("ETH", "price", 100+i, 50*time.Millisecond)
+	}
+
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		_, data := readEnvelope(t, c)
+		got[string(data)] = true
+	}
+	require.Equal(t, map[string]bool{"9": true, "109": true}, got, "only the latest message of key must be sent")
+
+	ch.EmitConflated("BTC", "price", 10, 10*time.Millisecond)
+	_, data := readEnvelope(t, c)
+	require.Equal(t, "10", string(data), "key must be flushed after the window")
+}
+
+func TestChannel_EmitConflated_noServer(t *testing.T) {
+	ch := newChannel("prices")
+	ch.EmitConflated("BTC", "price", 1, time.Second)
+	require.Empty(t, ch.conflated, "message must be sent immediately without server")
+}
@@ -1,11 +1,14 @@
 package websocket
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"github.com/gobwas/ws"
 	"net"
 	"net/url"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,7 +18,49 @@ type Conn struct {
 	conn   net.Conn
 	params url.Values
 	done   chan bool
+	server *Server
+	reader *frameReader
+	unpoll func()
+	stats  connStats
 	mu     sync.Mutex
+	// msgMu serializes data messages, so fragments of streamed message are not mixed with others.
+	// Control frames are written only under mu and could be sent between fragments.
+	msgMu sync.Mutex
+	flow  *flow
+	batch *batch
+
+	ctx          context.Context
+	cancel       context.CancelFunc
+	created      time.Time
+	namespace    *Namespace
+	ip           string
+	dropped      atomic.Bool
+	channels     map[*Channel]struct{}
+	channelsMu   sync.Mutex
+	limits       map[string]*bucket
+	limitsMu     sync.Mutex
+	rateQueue    chan queued
+	rateOnce     sync.Once
+	tags         map[string]any
+	tagsMu       sync.RWMutex
+	disconnect   atomic.Pointer[DisconnectReason]
+	extensions   []ExtensionCodec
+	datagrams    DatagramSession
+	ageTimer     atomic.Pointer[Timer]
+	pingInterval atomic.Int64
+	opCode       atomic.Uint32
+	pingReset    chan struct{}
+	lastPing     atomic.Int64
+	outbox       *outbox
+	envelope     EnvelopeFormat
+	protocol     string
+	tlsState     *tls.ConnectionState
+	sse          *sseStream
+	readTimeout  atomic.Int64
+	writeTimeout atomic.Int64
+	fragmentSize atomic.Int64
+	recorder     atomic.Pointer[recorder]
+	cipher       atomic.Pointer[PayloadCipher]
 }
 
 var pingHeader = ws.Header{
@@ -26,28 +71,122 @@ var pingHeader = ws.Header{
 }
 
 var PingInterval = time.Second * 5
+
+// DefaultWriteTimeout is the write timeout used when WithWriteTimeout is not set.
+const DefaultWriteTimeout = 15 * time.Second
+
+// TextMessage makes all connections send text frames.
+//
+// Deprecated: use WithTextMessages, Conn.SetTextMessages or Conn.EmitText.
 var TextMessage = false
 
-// ID return an connection identifier (could be not unique)
+// ID return an connection identifier, it is unique among live connections (see WithIDGenerator).
 func (c *Conn) ID() string {
 	return c.id
 }
 
-// Emit message to connection.
+// Emit message to connection, see Server.Emit for encoding of data.
+// []byte which is not valid UTF-8 returns ErrInvalidUTF8 in json envelope.
 func (c *Conn) Emit(name string, data interface{}) error {
-	var msg = struct {
-		Name string      `json:"name"`
-		Data interface{} `json:"data"`
-	}{
+	return c.send(envelope{
+		Name: name,
+		Data: data,
+	})
+}
+
+// EmitText emit message to connection in text frame, regardless of connection default.
+// Binary envelope (see WithEnvelope) is always sent in binary frame.
+func (c *Conn) EmitText(name string, data interface{}) error {
+	return c.send(envelope{
 		Name: name,
 		Data: data,
+		op:   ws.OpText,
+	})
+}
+
+// EmitBinary emit message to connection in binary frame, regardless of connection default.
+func (c *Conn) EmitBinary(name string, data interface{}) error {
+	return c.send(envelope{
+		Name: name,
+		Data: data,
+		op:   ws.OpBinary,
+	})
+}
+
+// SetTextMessages sets the default frame of connection: text or binary.
+// It overrides WithTextMessages of the server, e.g. for browsers which want text among native apps.
+func (c *Conn) SetTextMessages(text bool) {
+	if text {
+		c.opCode.Store(uint32(ws.OpText))
+	} else {
+		c.opCode.Store(uint32(ws.OpBinary))
 	}
+}
 
-	b, _ := json.Marshal(msg)
+// messageOp return the opcode of data messages of connection.
+func (c *Conn) messageOp() ws.OpCode {
+	if op := ws.OpCode(c.opCode.Load()); op != 0 {
+		return op
+	}
+	if TextMessage || (c.server != nil && c.server.textMessages) {
+		return ws.OpText
+	}
+	return ws.OpBinary
+}
 
-	opCode := ws.OpBinary
-	if TextMessage {
-		opCode = ws.OpText
+// send the envelope, with acks enabled (see WithAcks) it's kept until client acknowledge it.
+func (c *Conn) send(env envelope) error {
+	if c.outbox != nil && !IsSystemEvent(env.Name) {
+		return c.emitReliable(env)
+	}
+	return c.emit(env)
+}
+
+// emit write the envelope to connection.
+func (c *Conn) emit(env envelope) (err error) {
+	before, after := c.sendHooks()
+	size := 0
+	if after != nil {
+		defer func() { after(c, env.Name, size, err) }()
+	}
+	if before != nil && !IsSystemEvent(env.Name) {
+		if env, err = c.beforeSend(env, before); err != nil {
+			return err
+		}
+	}
+	if pc := c.payloadCipher(); pc != nil && !IsSystemEvent(env.Name) {
+		if env, err = c.encrypt(env, pc); err != nil {
+			return err
+		}
+	}
+
+	e := getEncoder()
+	defer putEncoder(e)
+
+	var b []byte
+	if c.envelope == BinaryEnvelope {
+		b, err = e.encodeBinary(env)
+	} else {
+		if env.Data, err = jsonData(env.Data); err == nil {
+			b, err = e.encode(env)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	opCode := env.op
+	if opCode == 0 {
+		opCode = c.messageOp()
+	}
+	if c.envelope == BinaryEnvelope {
+		opCode = ws.OpBinary
+	}
+	if c.batch != nil && env.op == 0 {
+		if err = c.batched(env.Name, b); err == nil {
+			size = len(b)
+		}
+		return err
 	}
 	h := ws.Header{
 		Fin:    true,
@@ -56,24 +195,145 @@ func (c *Conn) Emit(name string, data interface{}) error {
 		Length: int64(len(b)),
 	}
 
-	return c.Write(h, b)
+	if err = c.Write(h, b); err == nil {
+		size = len(b)
+	}
+	return err
 }
 
 // Write byte array to connection.
+// Data messages bigger than the fragment size (see WithFragmentSize) are split
+// into continuation frames, h.Length is ignored in that case.
+// With flow control (see WithFlowControl) data messages are queued while the client has no credits.
 func (c *Conn) Write(h ws.Header, b []byte) error {
+	enqueued := time.Now()
+
+	if !h.OpCode.IsControl() {
+		c.msgMu.Lock()
+		defer c.msgMu.Unlock()
+
+		if c.flow != nil && h.Fin && h.OpCode != ws.OpContinuation {
+			if queued, err := c.flow.acquire(h, b, enqueued); queued || err != nil {
+				if err != nil && c.server != nil {
+					c.server.observe(Event{Type: MessageDropped, Conn: c, Err: err})
+				}
+				return err
+			}
+		}
+	}
+
+	return c.write(h, b, enqueued)
+}
+
+// write the message to network. Data messages must be written with c.msgMu locked.
+func (c *Conn) write(h ws.Header, b []byte, enqueued time.Time) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	_ = c.conn.SetWriteDeadline(time.Now().Add(15000 * time.Millisecond))
-	err := ws.WriteHeader(c.conn, h)
-	if err != nil {
+	if c.conn == nil {
+		return net.ErrClosed
+	}
+	c.recordFrame(RecordOut, h.OpCode, b)
+
+	started := time.Now()
+	_ = c.conn.SetWriteDeadline(deadline(started, c.writeTimeout.Load()))
+
+	var err error
+	if len(c.extensions) != 0 && h.Fin && (h.OpCode == ws.OpText || h.OpCode == ws.OpBinary) && c.sse == nil {
+		if h, b, err = c.encodeExtensions(h, b); err != nil {
+			return err
+		}
+	}
+	if size := int(c.fragmentSize.Load()); size > 0 && len(b) > size && h.Fin && !h.OpCode.IsControl() && c.sse == nil {
+		err = c.writeFragments(h, b, size)
+	} else {
+		err = c.writeFrame(h, b)
+	}
+
+	c.observeWrite(enqueued, started, time.Now())
+	if err == nil && !h.OpCode.IsControl() {
+		c.observeOut()
+	}
+	if err != nil && c.server != nil {
+		c.server.observe(Event{Type: WriteError, Conn: c, Err: err})
+	}
+	return err
+}
+
+// writeFrame write header and payload. Must be called with c.mu locked.
+func (c *Conn) writeFrame(h ws.Header, b []byte) error {
+	if c.sse != nil {
+		c.observeBytes(0, int64(len(b)))
+		return c.sse.frame(h, b)
+	}
+
+	if err := ws.WriteHeader(c.conn, h); err != nil {
 		return err
 	}
 
-	_, err = c.conn.Write(b)
+	n, err := c.conn.Write(b)
+	c.observeBytes(0, int64(ws.HeaderSize(h)+n))
 	return err
 }
 
+// writeFragments split payload to frames of size bytes. Must be called with c.mu locked.
+func (c *Conn) writeFragments(h ws.Header, b []byte, size int) error {
+	op := h.OpCode
+	for len(b) > 0 {
+		n := min(size, len(b))
+		frame := ws.Header{
+			Fin:    n == len(b),
+			Rsv:    h.Rsv,
+			OpCode: op,
+			Length: int64(n),
+		}
+		if err := c.writeFrame(frame, b[:n]); err != nil {
+			return err
+		}
+
+		b = b[n:]
+		op = ws.OpContinuation
+		// rsv bits are set only in the first frame
+		h.Rsv = 0
+	}
+	return nil
+}
+
+// writeClose send close frame with status code to the connection.
+func (c *Conn) writeClose(conn net.Conn, code ws.StatusCode, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sse != nil {
+		return nil
+	}
+
+	body := ws.NewCloseFrameBody(code, reason)
+	c.recordFrame(RecordOut, ws.OpClose, body)
+	_ = conn.SetWriteDeadline(deadline(time.Now(), c.writeTimeout.Load()))
+	return ws.WriteFrame(conn, ws.NewCloseFrame(body))
+}
+
+// CloseWith send close frame with status code and reason and close connection.
+func (c *Conn) CloseWith(code ws.StatusCode, reason string) error {
+	return c.closeWith(code, reason)
+}
+
+// closeWith send close frame with code and close connection.
+func (c *Conn) closeWith(code ws.StatusCode, reason string) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	c.setDisconnect(DisconnectReason{Code: code, Reason: reason})
+	_ = c.Flush()
+	_ = c.writeClose(conn, code, reason)
+	return c.Close()
+}
+
 // Send data to connection.
 func (c *Conn) Send(data any) error {
 	var b []byte
@@ -82,16 +342,18 @@ func (c *Conn) Send(data any) error {
 	case []byte:
 		b = data.([]byte)
 	default:
-		b, _ = json.Marshal(data)
-	}
+		e := getEncoder()
+		defer putEncoder(e)
 
-	opCode := ws.OpBinary
-	if TextMessage {
-		opCode = ws.OpText
+		var err error
+		if b, err = e.encode(data); err != nil {
+			return err
+		}
 	}
+
 	h := ws.Header{
 		Fin:    true,
-		OpCode: opCode,
+		OpCode: c.messageOp(),
 		Masked: false,
 		Length: int64(len(b)),
 	}
@@ -100,8 +362,45 @@ func (c *Conn) Send(data any) error {
 	return err
 }
 
-// Close closing websocket connection.
+// Ping send ping with payload to connection, client replies with pong with the same payload (see OnPong).
+// Payload is limited to 125 bytes.
+func (c *Conn) Ping(payload []byte) error {
+	if len(payload) > ws.MaxControlFramePayloadSize {
+		return ws.ErrProtocolControlPayloadOverflow
+	}
+	return c.Write(ws.Header{
+		Fin:    true,
+		OpCode: ws.OpPing,
+		Length: int64(len(payload)),
+	}, payload)
+}
+
+// SetDeadlines overrides read and write timeouts of connection set by WithReadTimeout and WithWriteTimeout.
+// Read timeout is the maximum time between frames from client, write timeout limits each write.
+// Zero disables the timeout.
+func (c *Conn) SetDeadlines(read, write time.Duration) {
+	c.readTimeout.Store(int64(read))
+	c.writeTimeout.Store(int64(write))
+}
+
+// readTimedOut reports whether nothing was received from client during read timeout.
+func (c *Conn) readTimedOut(now time.Time) bool {
+	timeout := time.Duration(c.readTimeout.Load())
+	if timeout <= 0 {
+		return false
+	}
+
+	last := c.created
+	if received := c.stats.lastReceived.Load(); received != 0 {
+		last = time.Unix(0, received)
+	}
+	return now.Sub(last) > timeout
+}
+
+// Close closing websocket connection, pending batch (see WithBatching) is written first.
 func (c *Conn) Close() error {
+	_ = c.Flush()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -110,6 +409,13 @@ func (c *Conn) Close() error {
 	}
 
 	c.done <- true
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	if c.unpoll != nil {
+		c.unpoll()
+	}
 
 	err := c.conn.Close()
 	c.conn = nil
@@ -117,6 +423,59 @@ func (c *Conn) Close() error {
 	return err
 }
 
+// Channels return ids of channels which connection is in.
+func (c *Conn) Channels() []string {
+	list := c.channelList()
+	ids := make([]string, 0, len(list))
+	for _, ch := range list {
+		ids = append(ids, ch.ID())
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (c *Conn) channelList() []*Channel {
+	c.channelsMu.Lock()
+	defer c.channelsMu.Unlock()
+
+	list := make([]*Channel, 0, len(c.channels))
+	for ch := range c.channels {
+		list = append(list, ch)
+	}
+	return list
+}
+
+func (c *Conn) join(ch *Channel) {
+	c.channelsMu.Lock()
+	if c.channels == nil {
+		c.channels = make(map[*Channel]struct{})
+	}
+	c.channels[ch] = struct{}{}
+	c.channelsMu.Unlock()
+}
+
+func (c *Conn) leave(ch *Channel) {
+	c.channelsMu.Lock()
+	delete(c.channels, ch)
+	c.channelsMu.Unlock()
+}
+
+// closed reports whether connection was closed.
+func (c *Conn) closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.conn == nil
+}
+
+// Context return the context of connection, it's cancelled when connection is closed.
+func (c *Conn) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
 // Param gets the value from url params.
 // If there are no values associated with the key, Get returns
 // the empty string. To access multiple values, use the map
@@ -125,20 +484,71 @@ func (c *Conn) Param(key string) string {
 	return c.params.Get(key)
 }
 
-func (c *Conn) startPing() {
-	ticker := time.NewTicker(PingInterval)
+// SetPingInterval overrides PingInterval for the connection, zero restores PingInterval.
+// With WithNetpoll pings are checked every second, so shorter intervals are rounded up.
+func (c *Conn) SetPingInterval(d time.Duration) {
+	c.pingInterval.Store(int64(max(d, 0)))
+	c.resetPing()
+}
+
+// DisablePing stops pings to the connection, e.g. for embedded clients which do their own heartbeat.
+// Set the read timeout of such connection (see SetDeadlines), as dead peer is not detected by pings anymore.
+func (c *Conn) DisablePing() {
+	c.pingInterval.Store(-1)
+	c.resetPing()
+}
+
+// interval return ping interval of the connection, it's negative when pings are disabled.
+func (c *Conn) interval() time.Duration {
+	if d := time.Duration(c.pingInterval.Load()); d != 0 {
+		return d
+	}
+	return PingInterval
+}
+
+// resetPing wake up the pinger, so the new interval is applied.
+func (c *Conn) resetPing() {
+	select {
+	case c.pingReset <- struct{}{}:
+	default:
+	}
+}
 
-	go func() {
+func (c *Conn) startPing() {
+	spawn(&c.server.goroutines.pingers, func() {
 		for {
+			var (
+				timer *time.Timer
+				tick  <-chan time.Time
+			)
+			if d := c.interval(); d > 0 {
+				timer = time.NewTimer(d)
+				tick = timer.C
+			}
+
 			select {
-			case <-ticker.C:
+			case <-tick:
 				if err := c.Write(pingHeader, nil); err != nil {
 					_ = c.Close()
 				}
+			case <-c.pingReset:
 			case <-c.done:
-				ticker.Stop()
+				if timer != nil {
+					timer.Stop()
+				}
 				return
 			}
+			if timer != nil {
+				timer.Stop()
+			}
 		}
-	}()
+	})
+}
+
+// deadline return the time after timeout or zero time if timeout is not set.
+func deadline(now time.Time, timeout int64) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return now.Add(time.Duration(timeout))
 }
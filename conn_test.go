@@ -75,12 +75,49 @@ func TestConn_Pong(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestConn_SetPingInterval(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+	wsServer.On("interval", func(c Connection, msg *Message) {
+		c.(*Conn).SetPingInterval(30 * time.Millisecond)
+	})
+	wsServer.On("quiet", func(c Connection, msg *Message) {
+		c.(*Conn).DisablePing()
+		_ = c.Emit("quiet", nil)
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	writeMessage(t, c, "interval", nil)
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(time.Second)))
+	f, err := ws.ReadFrame(c)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpPing, f.Header.OpCode, "ping must be sent with connection interval")
+
+	writeMessage(t, c, "quiet", nil)
+	for {
+		f, err = ws.ReadFrame(c)
+		require.NoError(t, err)
+		if f.Header.OpCode != ws.OpPing {
+			break
+		}
+	}
+	require.Contains(t, string(f.Payload), `"quiet"`)
+
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(150*time.Millisecond)))
+	_, err = ws.ReadFrame(c)
+	require.Error(t, err, "pings must be disabled")
+}
+
 func TestConn_Send_bytes(t *testing.T) {
 	ts, wsServer, shutdown := server(t)
 	defer shutdown()
 
 	msg := []byte{0, 1, 2, 3, 4, 5, 6, 7}
-	wsServer.OnConnect(func(c *Conn) {
+	wsServer.OnConnect(func(c Connection) {
 		time.Sleep(300 * time.Millisecond)
 		err := c.Send(msg)
 		require.NoError(t, err)
@@ -111,7 +148,7 @@ func TestConn_Send_struct(t *testing.T) {
 	}{
 		Value: "test",
 	}
-	wsServer.OnConnect(func(c *Conn) {
+	wsServer.OnConnect(func(c Connection) {
 		time.Sleep(300 * time.Millisecond)
 		err := c.Send(msg)
 		require.NoError(t, err)
@@ -136,6 +173,48 @@ func TestConn_Send_struct(t *testing.T) {
 	require.Equal(t, b, mes, "response and request must be the same")
 }
 
+func TestConn_SetDeadlines(t *testing.T) {
+	c := &Conn{created: time.Now().Add(-time.Minute)}
+	require.False(t, c.readTimedOut(time.Now()), "no timeout by default")
+
+	c.SetDeadlines(time.Second, 2*time.Second)
+	require.Equal(t, int64(time.Second), c.readTimeout.Load())
+	require.Equal(t, int64(2*time.Second), c.writeTimeout.Load())
+	require.True(t, c.readTimedOut(time.Now()))
+
+	c.stats.lastReceived.Store(time.Now().UnixNano())
+	require.False(t, c.readTimedOut(time.Now()))
+
+	now := time.Now()
+	require.Equal(t, time.Time{}, deadline(now, 0))
+	require.Equal(t, now.Add(time.Second), deadline(now, int64(time.Second)))
+}
+
+func TestServer_ReadTimeout(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithReadTimeout(50*time.Millisecond))
+	defer shutdown()
+
+	disconnected := make(chan bool, 1)
+	wsServer.OnDisconnect(func(c Connection) {
+		disconnected <- true
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	writeMessage(t, c, "keep", "alive")
+	_, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("idle connection must be dropped after read timeout")
+	}
+}
+
 func TestConn_Fragment(t *testing.T) {
 	ts, _, shutdown := server(t)
 	defer shutdown()
@@ -170,17 +249,156 @@ func TestConn_Fragment(t *testing.T) {
 	_, err = c.Write(m2)
 	require.NoError(t, err)
 
-	time.Sleep(time.Millisecond * 10)
+	mes, op, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpText, op, "fragments must be delivered as one message")
+	require.Equal(t, append(m1, m2...), mes, "response and request must be the same")
+}
 
-	resp := make([]byte, 128)
-	n, err := c.Read(resp)
-	resp = resp[:n]
-	final := []byte{}
-	for _, b := range resp {
-		if b != 0x1 && b != 0x5 && b != 0x6 && b != 0x80 {
-			final = append(final, b)
+func TestConn_Fragment_named(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	done := make(chan *Message, 1)
+	wsServer.On("fragmented", func(c Connection, msg *Message) {
+		done <- msg
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	parts := []string{`{"name":"frag`, `mented","data":`, `"hello world"}`}
+	for i, p := range parts {
+		op := ws.OpContinuation
+		if i == 0 {
+			op = ws.OpBinary
 		}
+		frame := ws.NewFrame(op, i == len(parts)-1, []byte(p))
+		require.NoError(t, ws.WriteFrame(c, ws.MaskFrame(frame)))
+	}
+
+	select {
+	case msg := <-done:
+		require.Equal(t, `"hello world"`, string(msg.Data))
+	case <-time.After(time.Second):
+		t.Fatal("fragmented message must be delivered to callback")
 	}
+}
+
+func TestConn_Write_fragmented(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithFragmentSize(4))
+	defer shutdown()
+
+	msg := []byte("0123456789")
+	wsServer.OnConnect(func(c Connection) {
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, c.Send(msg))
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	var payload []byte
+	for _, expected := range []struct {
+		op  ws.OpCode
+		fin bool
+	}{{ws.OpBinary, false}, {ws.OpContinuation, false}, {ws.OpContinuation, true}} {
+		frame, err := ws.ReadFrame(c)
+		require.NoError(t, err)
+		require.Equal(t, expected.op, frame.Header.OpCode)
+		require.Equal(t, expected.fin, frame.Header.Fin)
+		require.LessOrEqual(t, len(frame.Payload), 4)
+		payload = append(payload, frame.Payload...)
+	}
+	require.Equal(t, msg, payload)
+}
+
+func TestConn_Channels(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	other := wsServer.NewChannel("other")
+
+	c := dial(t, ts)
+	joinChannel(t, c, "b")
+	joinChannel(t, c, "a")
+
+	var conn *Conn
+	wsServer.connections.forEach(func(c *Conn) {
+		conn = c
+	})
+	require.Equal(t, []string{"a", "b"}, conn.Channels())
+
+	wsServer.Channel("b").Remove(conn)
+	require.Equal(t, []string{"a"}, conn.Channels())
+
+	left := make(chan string, 2)
+	wsServer.Channel("a").OnLeave(func(c Connection) {
+		left <- "a"
+	})
+	other.OnLeave(func(c Connection) {
+		left <- "other"
+	})
+
+	require.NoError(t, c.Close())
+	select {
+	case ch := <-left:
+		require.Equal(t, "a", ch, "connection must be removed only from its channels")
+	case <-time.After(time.Second):
+		t.Fatal("dropped connection must leave its channels")
+	}
+	require.Eventually(t, func() bool {
+		return len(conn.Channels()) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestConn_EmitText(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+	wsServer.On("mixed", func(c Connection, msg *Message) {
+		_ = c.EmitText("text", nil)
+		_ = c.EmitBinary("binary", nil)
+		_ = c.Emit("default", nil)
+		c.(*Conn).SetTextMessages(true)
+		_ = c.Emit("default", nil)
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	writeMessage(t, c, "mixed", nil)
+	for _, op := range []ws.OpCode{ws.OpText, ws.OpBinary, ws.OpBinary, ws.OpText} {
+		_, o, err := wsutil.ReadServerData(c)
+		require.NoError(t, err)
+		require.Equal(t, op, o)
+	}
+}
+
+func TestWithTextMessages(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithTextMessages())
+	defer shutdown()
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		_ = c.Emit("echo", msg.Data)
+		c.(*Conn).SetTextMessages(false)
+		_ = c.Emit("echo", msg.Data)
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	writeMessage(t, c, "echo", "test")
+	_, op, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpText, op)
+	_, op, err = wsutil.ReadServerData(c)
 	require.NoError(t, err)
-	require.Equal(t, append(m1, m2...), final, "response and request must be the same")
+	require.Equal(t, ws.OpBinary, op, "connection default overrides the server")
 }
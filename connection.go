@@ -0,0 +1,41 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+)
+
+// Connection is the part of Conn which handlers usually need. Handlers, hooks and Channel take it,
+// so they could be tested with fake connection (see websockettest.Conn) instead of real one.
+// Server passes *Conn, use type assertion for the rest of Conn API (e.g. Stats or RemoteAddr).
+type Connection interface {
+	ID() string
+	Param(key string) string
+	Context() context.Context
+	Subprotocol() string
+	Channels() []string
+
+	Emit(name string, data any) error
+	EmitText(name string, data any) error
+	EmitBinary(name string, data any) error
+	Send(data any) error
+	Write(h ws.Header, b []byte) error
+
+	Tag(key string, value any)
+	Untag(key string)
+	TagValue(key string) (any, bool)
+	HasTag(key string, value any) bool
+
+	Close() error
+	CloseWith(code ws.StatusCode, reason string) error
+}
+
+var _ Connection = (*Conn)(nil)
+
+// connClosed reports whether connection is closed, connection which isn't *Conn is closed when its context is done.
+func connClosed(c Connection) bool {
+	if k, ok := c.(*Conn); ok {
+		return k.closed()
+	}
+	return c.Context().Err() != nil
+}
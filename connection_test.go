@@ -0,0 +1,111 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConn is Connection which records names of emitted messages.
+type fakeConn struct {
+	id     string
+	ctx    context.Context
+	cancel context.CancelFunc
+	names  []string
+	mu     sync.Mutex
+}
+
+func newFakeConn(id string) *fakeConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &fakeConn{id: id, ctx: ctx, cancel: cancel}
+}
+
+func (c *fakeConn) ID() string                             { return c.id }
+func (c *fakeConn) Param(string) string                    { return "" }
+func (c *fakeConn) Context() context.Context               { return c.ctx }
+func (c *fakeConn) Subprotocol() string                    { return "" }
+func (c *fakeConn) Channels() []string                     { return nil }
+func (c *fakeConn) EmitText(name string, data any) error   { return c.Emit(name, data) }
+func (c *fakeConn) EmitBinary(name string, data any) error { return c.Emit(name, data) }
+func (c *fakeConn) Write(ws.Header, []byte) error          { return nil }
+func (c *fakeConn) Send(any) error                         { return nil }
+func (c *fakeConn) Tag(string, any)                        {}
+func (c *fakeConn) Untag(string)                           {}
+func (c *fakeConn) TagValue(string) (any, bool)            { return nil, false }
+func (c *fakeConn) HasTag(string, any) bool                { return false }
+func (c *fakeConn) Close() error                           { c.cancel(); return nil }
+func (c *fakeConn) CloseWith(ws.StatusCode, string) error  { return c.Close() }
+
+func (c *fakeConn) Emit(name string, _ any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.names = append(c.names, name)
+	return nil
+}
+
+func (c *fakeConn) emitted() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.names...)
+}
+
+func TestConnection_handler(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	connected := make(chan string, 1)
+	wsServer.OnConnect(func(c Connection) {
+		connected <- c.ID()
+	})
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		c.Tag("echoed", true)
+		_ = c.Emit("echo", msg.Data)
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+
+	select {
+	case id := <-connected:
+		require.NotEmpty(t, id)
+	case <-time.After(time.Second):
+		t.Fatal("OnConnect must be called")
+	}
+
+	writeMessage(t, c, "echo", "hello")
+	name, data := readEnvelope(t, c)
+	require.Equal(t, "echo", name)
+	require.JSONEq(t, `"hello"`, string(data))
+	require.Len(t, wsServer.ConnectionsWhere(func(c *Conn) bool { return c.HasTag("echoed", true) }), 1)
+}
+
+func TestConnection_channel(t *testing.T) {
+	ch := newChannel("room")
+	joined := make(chan string, 1)
+	ch.OnJoin(func(c Connection) {
+		joined <- c.ID()
+	})
+
+	first, second := newFakeConn("1"), newFakeConn("2")
+	require.NoError(t, ch.Add(first))
+	require.Equal(t, "1", <-joined)
+	require.NoError(t, ch.AddReadOnly(second))
+	require.Equal(t, "2", <-joined)
+	require.True(t, ch.Has(first))
+	require.True(t, ch.ReadOnly(second))
+
+	_, err := ch.Publish(second, "msg", 1)
+	require.ErrorIs(t, err, ErrReadOnly)
+	res, err := ch.Publish(first, "msg", 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, res.Delivered)
+	require.Equal(t, []string{"msg"}, first.emitted())
+
+	require.NoError(t, second.Close())
+	require.Equal(t, 1, ch.Count(), "closed connection must not be counted")
+	ch.Remove(first)
+	require.False(t, ch.Has(first))
+}
@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"sync/atomic"
+)
+
+// Diagnostics is a snapshot of resources used by the server.
+type Diagnostics struct {
+	Goroutines GoroutineStats
+	Queues     QueueStats
+	Pools      PoolStats
+}
+
+// GoroutineStats is the number of goroutines started by the server.
+type GoroutineStats struct {
+	Readers      int64 // read loops of connections and frames read from poller
+	Pingers      int64 // ping loops of connections and the poller ping loop
+	Broadcasters int64 // broadcast loop, Emit deliveries and channel dispatchers
+	Workers      int64 // workers of handler pool
+	Background   int64 // callbacks, snapshots, channel gc and other short living tasks
+	Total        int64
+}
+
+// QueueStats is the number of messages waiting in server queues.
+type QueueStats struct {
+	Broadcast     int // messages waiting for broadcast loop
+	Workers       int // messages waiting for handler workers
+	Subscriptions int // messages waiting in Subscribe channels
+	FlowPending   int // messages waiting for credits (see WithFlowControl)
+	Channels      int // messages waiting in channel queues (see Channel.SetQueue)
+}
+
+// PoolStats shows how effective buffer pools are, pools are shared by all servers.
+type PoolStats struct {
+	BufferGets  int64
+	BufferNews  int64
+	EncoderGets int64
+	EncoderNews int64
+}
+
+// goroutineCounters count goroutines started by the server.
+type goroutineCounters struct {
+	readers, pingers, broadcasters, workers, background atomic.Int64
+}
+
+// poolCounters count usage of buffer pools.
+var poolCounters struct {
+	bufferGets, bufferNews, encoderGets, encoderNews atomic.Int64
+}
+
+// spawn run f in goroutine counted by counter.
+func spawn(counter *atomic.Int64, f func()) {
+	counter.Add(1)
+	go func() {
+		defer counter.Add(-1)
+		f()
+	}()
+}
+
+// Diagnostics return goroutines, queues and pools usage of the server.
+func (s *Server) Diagnostics() Diagnostics {
+	var d Diagnostics
+
+	s.mu.RLock()
+	for _, subs := range s.subscriptions {
+		for _, sub := range subs {
+			d.Queues.Subscriptions += len(sub.ch)
+		}
+	}
+	d.Queues.Broadcast = len(s.broadcast)
+	for _, queue := range s.workers {
+		d.Queues.Workers += len(queue)
+	}
+	for _, ch := range s.channels {
+		d.Queues.Channels += ch.QueueStats().Depth
+	}
+	s.mu.RUnlock()
+
+	s.connections.forEach(func(c *Conn) {
+		_, pending := c.Credits()
+		d.Queues.FlowPending += pending
+	})
+
+	g := &s.goroutines
+	d.Goroutines.Readers = g.readers.Load()
+	d.Goroutines.Pingers = g.pingers.Load()
+	d.Goroutines.Broadcasters = g.broadcasters.Load()
+	d.Goroutines.Workers = g.workers.Load()
+	d.Goroutines.Background = g.background.Load()
+	d.Goroutines.Total = d.Goroutines.Readers + d.Goroutines.Pingers + d.Goroutines.Broadcasters + d.Goroutines.Workers + d.Goroutines.Background
+
+	d.Pools = PoolStats{
+		BufferGets:  poolCounters.bufferGets.Load(),
+		BufferNews:  poolCounters.bufferNews.Load(),
+		EncoderGets: poolCounters.encoderGets.Load(),
+		EncoderNews: poolCounters.encoderNews.Load(),
+	}
+
+	return d
+}
@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestServer_Diagnostics(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithFlowControl(0, 10))
+	defer shutdown()
+
+	d := wsServer.Diagnostics()
+	require.Equal(t, int64(1), d.Goroutines.Broadcasters, "broadcast loop must be counted")
+	require.Equal(t, int64(0), d.Goroutines.Readers)
+
+	wsServer.NewChannel("room")
+	wsServer.NewChannel("closed").Close()
+	sub, err := wsServer.Subscribe("event", 10)
+	require.NoError(t, err)
+
+	connected := make(chan *Conn, 1)
+	wsServer.OnConnect(func(c Connection) {
+		connected <- c.(*Conn)
+	})
+
+	c := dial(t, ts)
+	conn := <-connected
+	require.NoError(t, conn.Emit("queued", 1))
+
+	writeMessage(t, c, "event", 1)
+	require.Eventually(t, func() bool {
+		return len(sub) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	d = wsServer.Diagnostics()
+	require.Equal(t, int64(1), d.Goroutines.Readers)
+	require.Equal(t, int64(1), d.Goroutines.Pingers)
+	require.Equal(t, 1, d.Queues.Subscriptions)
+	require.Equal(t, 1, d.Queues.FlowPending)
+	require.GreaterOrEqual(t, d.Goroutines.Total, int64(3))
+	require.Positive(t, d.Pools.BufferGets)
+	require.Positive(t, d.Pools.EncoderGets)
+
+	require.NoError(t, c.Close())
+	require.Eventually(t, func() bool {
+		d = wsServer.Diagnostics()
+		return d.Goroutines.Readers == 0 && d.Goroutines.Pingers == 0
+	}, time.Second, 10*time.Millisecond, "goroutines of closed connection must exit")
+}
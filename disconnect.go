@@ -0,0 +1,53 @@
+package websocket
+
+import (
+	"errors"
+	"github.com/gobwas/ws"
+)
+
+// DisconnectReason describes why connection was closed.
+type DisconnectReason struct {
+	// Code is the status code of close frame sent by client or server,
+	// ws.StatusNoStatusRcvd (1005) for close frame without code and
+	// ws.StatusAbnormalClosure (1006) when connection was lost without close frame.
+	Code ws.StatusCode
+	// Reason is the text of close frame.
+	Reason string
+	// Err is the read error for connection lost without close frame.
+	Err error
+}
+
+// DisconnectReason return why connection was closed, e.g. in OnDisconnect to distinguish
+// normal logout (1000) from network failure (1006). It's zero while connection is open.
+func (c *Conn) DisconnectReason() DisconnectReason {
+	if r := c.disconnect.Load(); r != nil {
+		return *r
+	}
+	return DisconnectReason{}
+}
+
+// setDisconnect keeps the first disconnect reason of connection.
+func (c *Conn) setDisconnect(r DisconnectReason) {
+	c.disconnect.CompareAndSwap(nil, &r)
+}
+
+// setCloseCode keeps the first close code of connection.
+func (c *Conn) setCloseCode(code ws.StatusCode) {
+	c.setDisconnect(DisconnectReason{Code: code})
+}
+
+// readFailed keeps the read error as disconnect reason, unless client sent close frame.
+func (c *Conn) readFailed(err error) {
+	if !errors.Is(err, errClosed) {
+		c.setDisconnect(DisconnectReason{Code: ws.StatusAbnormalClosure, Err: err})
+	}
+}
+
+// closeReason parse the close frame payload.
+func closeReason(payload []byte) DisconnectReason {
+	code, reason := ws.ParseCloseFrameData(payload)
+	if code == 0 {
+		code = ws.StatusNoStatusRcvd
+	}
+	return DisconnectReason{Code: code, Reason: reason}
+}
@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConn_DisconnectReason(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	reasons := make(chan DisconnectReason, 1)
+	wsServer.OnDisconnect(func(c Connection) {
+		reasons <- c.(*Conn).DisconnectReason()
+	})
+	wsServer.On("kick", func(c Connection, msg *Message) {
+		_ = c.CloseWith(ws.StatusPolicyViolation, "banned")
+	})
+
+	reason := func() DisconnectReason {
+		select {
+		case r := <-reasons:
+			return r
+		case <-time.After(time.Second):
+			t.Fatal("OnDisconnect must be called")
+		}
+		return DisconnectReason{}
+	}
+
+	c := dial(t, ts)
+	require.NoError(t, wsutil.WriteClientMessage(c, ws.OpClose, ws.NewCloseFrameBody(ws.StatusNormalClosure, "logout")))
+	require.Equal(t, DisconnectReason{Code: ws.StatusNormalClosure, Reason: "logout"}, reason())
+	_ = c.Close()
+
+	c = dial(t, ts)
+	writeMessage(t, c, "kick", nil)
+	require.Equal(t, DisconnectReason{Code: ws.StatusPolicyViolation, Reason: "banned"}, reason())
+	_ = c.Close()
+
+	c = dial(t, ts)
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 1
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, c.Close())
+	r := reason()
+	require.Equal(t, ws.StatusAbnormalClosure, r.Code, "connection lost without close frame")
+	require.Error(t, r.Err)
+}
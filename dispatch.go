@@ -0,0 +1,278 @@
+package websocket
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrChannelQueueFull is returned by Channel.Enqueue when queue of channel is full and policy is QueueDropNewest.
+var ErrChannelQueueFull = errors.New("websocket: channel queue is full")
+
+// ErrChannelClosed is returned by Channel.Add and Channel.Enqueue after the channel was closed, e.g. by WithChannelTTL.
+var ErrChannelClosed = errors.New("websocket: channel is closed")
+
+// QueuePolicy defines what happens with message enqueued to the full channel queue.
+type QueuePolicy int
+
+const (
+	// QueueBlock waits for room in the queue.
+	QueueBlock QueuePolicy = iota
+	// QueueDropNewest drops the new message and Enqueue returns ErrChannelQueueFull.
+	QueueDropNewest
+	// QueueDropOldest drops the oldest queued message to make room for the new one.
+	QueueDropOldest
+)
+
+// ChannelQueueStats describe the queue of channel, see Channel.SetQueue.
+type ChannelQueueStats struct {
+	Depth    int   `json:"depth"`
+	Capacity int   `json:"capacity"`
+	Enqueued int64 `json:"enqueued"`
+	Dropped  int64 `json:"dropped"`
+}
+
+// queueConfig is size and policy of channel queue.
+type queueConfig struct {
+	size   int
+	policy QueuePolicy
+}
+
+// WithChannelQueue gives every channel without own queue (see Channel.SetQueue) the queue of size messages.
+func WithChannelQueue(size int, policy QueuePolicy) Option {
+	return func(s *Server) {
+		s.channelQueue = queueConfig{size: size, policy: policy}
+	}
+}
+
+// dispatcher emits queued messages of channel in own goroutine.
+type dispatcher struct {
+	queue    chan envelope
+	policy   QueuePolicy
+	start    sync.Once
+	stopOnce sync.Once
+	done     chan struct{}
+	// sealed is closed after done when Enqueue calls in progress returned
+	sealed chan struct{}
+	// drained is closed when dispatch of queue returns
+	drained chan struct{}
+	// prev is the replaced queue, its messages are sent first
+	prev     *dispatcher
+	enqueued atomic.Int64
+	dropped  atomic.Int64
+
+	// mu is held by Enqueue while it puts the message, so nothing is queued after stop
+	mu      sync.RWMutex
+	stopped bool
+}
+
+func newDispatcher(cfg queueConfig) *dispatcher {
+	return &dispatcher{
+		queue:   make(chan envelope, cfg.size),
+		policy:  cfg.policy,
+		done:    make(chan struct{}),
+		sealed:  make(chan struct{}),
+		drained: make(chan struct{}),
+	}
+}
+
+func (d *dispatcher) stop() {
+	d.stopOnce.Do(func() {
+		// done wakes Enqueue waiting for room, lock waits for the others
+		close(d.done)
+		d.mu.Lock()
+		d.stopped = true
+		d.mu.Unlock()
+		close(d.sealed)
+	})
+}
+
+// SetQueue gives channel own queue of size messages, they are emitted by dedicated goroutine of channel,
+// so burst of messages on one busy channel doesn't block callers and other channels. See Enqueue.
+// Messages of the replaced queue are sent before messages of the new one.
+// Zero size removes the queue, messages which are already queued are still sent, but not in order
+// with messages emitted after it.
+func (c *Channel) SetQueue(size int, policy QueuePolicy) {
+	c.mu.Lock()
+	old := c.dispatcher
+	c.dispatcher = nil
+	if size > 0 {
+		c.dispatcher = newDispatcher(queueConfig{size: size, policy: policy})
+		c.dispatcher.prev = old
+	}
+	c.queueSet = true
+	c.mu.Unlock()
+
+	if old != nil {
+		old.stop()
+		// queue which was never started is drained too
+		c.run(old)
+	}
+}
+
+// QueueStats return depth and counters of channel queue, it's zero for channel without queue.
+func (c *Channel) QueueStats() ChannelQueueStats {
+	c.mu.Lock()
+	d := c.dispatcher
+	c.mu.Unlock()
+	if d == nil {
+		return ChannelQueueStats{}
+	}
+
+	return ChannelQueueStats{
+		Depth:    len(d.queue),
+		Capacity: cap(d.queue),
+		Enqueued: d.enqueued.Load(),
+		Dropped:  d.dropped.Load(),
+	}
+}
+
+// Enqueue put message to the queue of channel and return, the message is emitted to connections of channel
+// later in the order of Enqueue calls (see Emit). Channel without queue emits the message immediately.
+// Full queue is handled according to the policy of channel, dropped messages are passed to observers
+// as MessageDropped.
+func (c *Channel) Enqueue(name string, data any) error {
+	env := envelope{Name: name, Data: data}
+	for {
+		d, err := c.queue()
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			return c.Emit(name, data).Err()
+		}
+		if queued, err := c.enqueue(d, env); queued || err != nil {
+			return err
+		}
+		// queue was replaced by SetQueue, message goes to the new one
+	}
+}
+
+// enqueue put message to the queue, queued is false when queue was stopped.
+func (c *Channel) enqueue(d *dispatcher, env envelope) (queued bool, err error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.stopped {
+		return false, nil
+	}
+
+	var quit chan struct{}
+	if c.server != nil {
+		quit = c.server.quit
+	}
+	select {
+	case <-quit:
+		return false, ErrServerClosed
+	default:
+	}
+
+	switch d.policy {
+	case QueueDropNewest:
+		select {
+		case d.queue <- env:
+		default:
+			c.dropQueued(d, env.Name)
+			return false, ErrChannelQueueFull
+		}
+	case QueueDropOldest:
+		for sent := false; !sent; {
+			select {
+			case d.queue <- env:
+				sent = true
+			default:
+				select {
+				case old := <-d.queue:
+					c.dropQueued(d, old.Name)
+				default:
+				}
+			}
+		}
+	default:
+		select {
+		case d.queue <- env:
+		case <-d.done:
+			return false, nil
+		case <-quit:
+			return false, ErrServerClosed
+		}
+	}
+	d.enqueued.Add(1)
+	return true, nil
+}
+
+// queue return dispatcher of channel or server default and start it, nil is returned for channel without queue.
+func (c *Channel) queue() (*dispatcher, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrChannelClosed
+	}
+	if c.dispatcher == nil && !c.queueSet && c.server != nil && c.server.channelQueue.size > 0 {
+		c.dispatcher = newDispatcher(c.server.channelQueue)
+	}
+	d := c.dispatcher
+	c.mu.Unlock()
+
+	if d != nil {
+		c.run(d)
+	}
+	return d, nil
+}
+
+// run start dispatch of queue once.
+func (c *Channel) run(d *dispatcher) {
+	d.start.Do(func() {
+		if c.server == nil {
+			go c.dispatch(d)
+			return
+		}
+		spawn(&c.server.goroutines.broadcasters, func() {
+			c.dispatch(d)
+		})
+	})
+}
+
+// dispatch emits queued messages until dispatcher is stopped or server is closed.
+func (c *Channel) dispatch(d *dispatcher) {
+	defer close(d.drained)
+
+	var quit chan struct{}
+	if c.server != nil {
+		quit = c.server.quit
+	}
+
+	if d.prev != nil {
+		select {
+		case <-d.prev.drained:
+		case <-quit:
+			return
+		}
+	}
+
+	for {
+		select {
+		case env := <-d.queue:
+			c.Emit(env.Name, env.Data)
+		case <-d.sealed:
+			// messages queued before the stop are still sent
+			for {
+				select {
+				case env := <-d.queue:
+					c.Emit(env.Name, env.Data)
+				default:
+					return
+				}
+			}
+		case <-quit:
+			return
+		}
+	}
+}
+
+// dropQueued count and observe message dropped by the queue.
+func (c *Channel) dropQueued(d *dispatcher, name string) {
+	d.dropped.Add(1)
+	if c.server != nil {
+		c.server.observe(Event{Type: MessageDropped, Channel: c.id, Name: name, Err: ErrChannelQueueFull})
+	}
+}
@@ -0,0 +1,176 @@
+package websocket
+
+import (
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+// queueChannel return channel with one connection, delivery of the first "block" message to it
+// is paused until release is closed.
+func queueChannel(t *testing.T, opts ...Option) (ch *Channel, c net.Conn, wsServer *Server, entered, release chan struct{}, shutdown func()) {
+	ts, wsServer, shutdown := server(t, opts...)
+
+	entered, release = make(chan struct{}), make(chan struct{})
+	wsServer.OnBeforeSend(func(c Connection, name string, data []byte) ([]byte, error) {
+		if name == "block" {
+			close(entered)
+			<-release
+		}
+		return data, nil
+	})
+
+	ch = wsServer.NewChannel("queue")
+	connected := make(chan *Conn, 1)
+	wsServer.OnConnect(func(c Connection) {
+		connected <- c.(*Conn)
+	})
+	c = dial(t, ts)
+	require.NoError(t, ch.Add(<-connected))
+	return ch, c, wsServer, entered, release, shutdown
+}
+
+func TestChannel_Enqueue(t *testing.T) {
+	ch, c, _, _, _, shutdown := queueChannel(t)
+	defer shutdown()
+	defer c.Close()
+
+	// channel without queue emits immediately
+	require.NoError(t, ch.Enqueue("hello", "world"))
+	name, data := readEnvelope(t, c)
+	require.Equal(t, "hello", name)
+	require.Equal(t, `"world"`, string(data))
+	require.Equal(t, ChannelQueueStats{}, ch.QueueStats())
+
+	ch.SetQueue(10, QueueBlock)
+	for _, v := range []string{"a", "b", "c"} {
+		require.NoError(t, ch.Enqueue("letter", v))
+	}
+	for _, v := range []string{"a", "b", "c"} {
+		_, data = readEnvelope(t, c)
+		require.Equal(t, `"`+v+`"`, string(data), "messages must keep the order")
+	}
+	require.Equal(t, int64(3), ch.QueueStats().Enqueued)
+
+	ch.Close()
+	require.ErrorIs(t, ch.Enqueue("letter", "d"), ErrChannelClosed)
+}
+
+func TestChannel_Enqueue_dropNewest(t *testing.T) {
+	var dropped []string
+	ch, c, wsServer, entered, release, shutdown := queueChannel(t, WithObserver(ObserverFunc(func(e Event) {
+		if e.Type == MessageDropped && e.Channel == "queue" {
+			dropped = append(dropped, e.Name)
+		}
+	})))
+	defer shutdown()
+	defer c.Close()
+
+	ch.SetQueue(2, QueueDropNewest)
+	require.NoError(t, ch.Enqueue("block", 1))
+	<-entered
+	require.NoError(t, ch.Enqueue("m", 2))
+	require.NoError(t, ch.Enqueue("m", 3))
+	require.ErrorIs(t, ch.Enqueue("overflow", 4), ErrChannelQueueFull)
+
+	require.Equal(t, ChannelQueueStats{Depth: 2, Capacity: 2, Enqueued: 3, Dropped: 1}, ch.QueueStats())
+	require.Equal(t, 2, wsServer.Diagnostics().Queues.Channels)
+	require.Equal(t, []string{"overflow"}, dropped)
+
+	close(release)
+	for _, v := range []string{"1", "2", "3"} {
+		_, data := readEnvelope(t, c)
+		require.Equal(t, v, string(data))
+	}
+}
+
+func TestChannel_Enqueue_dropOldest(t *testing.T) {
+	ch, c, _, entered, release, shutdown := queueChannel(t)
+	defer shutdown()
+	defer c.Close()
+
+	ch.SetQueue(2, QueueDropOldest)
+	require.NoError(t, ch.Enqueue("block", 1))
+	<-entered
+	for i := 2; i <= 4; i++ {
+		require.NoError(t, ch.Enqueue("m", i))
+	}
+	require.Equal(t, int64(1), ch.QueueStats().Dropped)
+
+	close(release)
+	for _, v := range []string{"1", "3", "4"} {
+		_, data := readEnvelope(t, c)
+		require.Equal(t, v, string(data))
+	}
+}
+
+func TestChannel_Enqueue_block(t *testing.T) {
+	ch, c, _, entered, release, shutdown := queueChannel(t, WithChannelQueue(1, QueueBlock))
+	defer shutdown()
+	defer c.Close()
+	defer close(release)
+
+	require.NoError(t, ch.Enqueue("block", 1))
+	<-entered
+	require.NoError(t, ch.Enqueue("m", 2))
+	require.Equal(t, 1, ch.QueueStats().Capacity, "server default must be used")
+
+	res := make(chan error, 1)
+	go func() {
+		res <- ch.Enqueue("m", 3)
+	}()
+	select {
+	case err := <-res:
+		t.Fatalf("enqueue must wait for room in the queue, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ch.Close()
+	select {
+	case err := <-res:
+		require.ErrorIs(t, err, ErrChannelClosed)
+	case <-time.After(time.Second):
+		t.Fatal("enqueue must return when channel is closed")
+	}
+}
+
+func TestChannel_SetQueue_order(t *testing.T) {
+	ch, c, _, entered, release, shutdown := queueChannel(t)
+	defer shutdown()
+	defer c.Close()
+
+	ch.SetQueue(10, QueueBlock)
+	require.NoError(t, ch.Enqueue("block", 1))
+	<-entered
+	require.NoError(t, ch.Enqueue("m", 2))
+
+	ch.SetQueue(10, QueueDropNewest)
+	require.NoError(t, ch.Enqueue("m", 3))
+	require.NoError(t, ch.Enqueue("m", 4))
+
+	close(release)
+	for _, v := range []string{"1", "2", "3", "4"} {
+		_, data := readEnvelope(t, c)
+		require.Equal(t, v, string(data), "messages of replaced queue must be sent first")
+	}
+}
+
+func TestChannel_Enqueue_closed(t *testing.T) {
+	for _, policy := range []QueuePolicy{QueueBlock, QueueDropNewest, QueueDropOldest} {
+		ch, c, wsServer, _, _, shutdown := queueChannel(t)
+		ch.SetQueue(1, policy)
+		require.NoError(t, ch.Enqueue("m", 1))
+
+		ch.Close()
+		require.ErrorIs(t, ch.Enqueue("m", 2), ErrChannelClosed, "policy %d", policy)
+
+		other := wsServer.NewChannel("other")
+		other.SetQueue(1, policy)
+		require.NoError(t, wsServer.Shutdown())
+		require.ErrorIs(t, other.Enqueue("m", 3), ErrServerClosed, "policy %d", policy)
+
+		_ = c.Close()
+		shutdown()
+	}
+}
@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// EventReconnect is sent to clients by Drain, clients should reconnect to another node.
+const EventReconnect = SystemPrefix + "reconnect"
+
+// DefaultRetryAfter is the Retry-After of upgrades rejected during Drain.
+const DefaultRetryAfter = 5 * time.Second
+
+// WithDrainEvent sets the event which Drain sends to clients, default is _reconnect with no data.
+func WithDrainEvent(name string, data any) Option {
+	return func(s *Server) {
+		s.drainEvent = name
+		s.drainData = data
+	}
+}
+
+// WithRetryAfter sets the Retry-After of upgrades rejected during Drain.
+func WithRetryAfter(d time.Duration) Option {
+	return func(s *Server) {
+		s.retryAfter = d
+	}
+}
+
+// Drain prepares the server to stop without dropping clients: new upgrades are rejected
+// with 503 and Retry-After, connected clients receive the drain event (see WithDrainEvent)
+// and have time to disconnect until ctx is done. Remaining connections are closed with 1001,
+// ctx error is returned in this case.
+func (s *Server) Drain(ctx context.Context) error {
+	s.draining.Store(true)
+
+	s.mu.RLock()
+	name, data := s.drainEvent, s.drainData
+	s.mu.RUnlock()
+
+	s.connections.forEach(func(c *Conn) {
+		_ = c.Emit(name, data)
+	})
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for s.Count() != 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			s.connections.forEach(func(c *Conn) {
+				_ = c.closeWith(ws.StatusGoingAway, "server is draining")
+			})
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// IsDraining return true after Drain was called.
+func (s *Server) IsDraining() bool {
+	return s.draining.Load()
+}
+
+// rejectDraining reply with 503 to upgrade during Drain.
+func (s *Server) rejectDraining(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((s.retryAfter+time.Second-1)/time.Second)))
+	http.Error(w, ErrDraining.Error(), http.StatusServiceUnavailable)
+}
@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServer_Drain(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithDrainEvent("bye", "node-2"))
+	defer shutdown()
+
+	c := dial(t, ts)
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- wsServer.Drain(context.Background())
+	}()
+
+	name, data := readEnvelope(t, c)
+	require.Equal(t, "bye", name)
+	require.Equal(t, `"node-2"`, string(data))
+	require.True(t, wsServer.IsDraining())
+
+	resp, err := http.Get(ts.URL + "/ws")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "5", resp.Header.Get("Retry-After"))
+
+	require.NoError(t, c.Close())
+	select {
+	case err = <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("drain must finish when all clients disconnected")
+	}
+}
+
+func TestServer_Drain_deadline(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, wsServer.Drain(ctx), context.DeadlineExceeded)
+
+	name, _ := readEnvelope(t, c)
+	require.Equal(t, EventReconnect, name)
+	require.Equal(t, ws.StatusGoingAway, readClose(t, c), "remaining connections must be closed")
+}
@@ -0,0 +1,253 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"unicode/utf8"
+)
+
+// EnvelopeFormat is the wire format of named messages.
+type EnvelopeFormat int
+
+const (
+	// JSONEnvelope sends messages as {"name": "event", "data": ...}, it's the default.
+	JSONEnvelope EnvelopeFormat = iota
+	// BinaryEnvelope sends messages as length-prefixed binary frames:
+	//
+	//	flags (1 byte) | [id uvarint] | [channel length uvarint | channel | seq uvarint] | name length uvarint | name | payload
+	//
+	// Flags has bit 1 when id is present (see WithAcks) and bit 2 when channel and seq are present
+	// (see WithChannelSequence). Payload is sent as is for []byte data, as result of BinaryPayload
+	// for BinaryPayload data (e.g. protobuf messages, see package protows) and as json for other types.
+	// Clients send messages in the same format.
+	BinaryEnvelope
+)
+
+// Subprotocols which client could request to select envelope format of connection.
+const (
+	ProtocolJSON   = "pkgz.json"
+	ProtocolBinary = "pkgz.binary"
+)
+
+const (
+	flagID byte = 1 << iota
+	flagSeq
+)
+
+// BinaryPayload is data with its own encoding for binary envelope, e.g. protobuf message.
+// It's encoded to json for json envelope, so it usually implements json.Marshaler too.
+type BinaryPayload interface {
+	BinaryPayload() ([]byte, error)
+}
+
+// RawData is data of channel message which came from outside of the server, e.g. from message broker.
+// Json is sent as is, other bytes as string, binary envelope gets the bytes as is.
+// Bridges don't mirror RawData in OnChannelEmit, so received messages are not sent back to brokers.
+type RawData []byte
+
+// MarshalJSON implements json.Marshaler.
+func (d RawData) MarshalJSON() ([]byte, error) {
+	if json.Valid(d) {
+		return d, nil
+	}
+	return json.Marshal(string(d))
+}
+
+// BinaryPayload implements BinaryPayload.
+func (d RawData) BinaryPayload() ([]byte, error) {
+	return d, nil
+}
+
+// errInvalidEnvelope is returned when binary envelope can't be decoded.
+var errInvalidEnvelope = errors.New("websocket: invalid binary envelope")
+
+// WithEnvelope sets the envelope format for connections which didn't select it with subprotocol
+// (ProtocolJSON or ProtocolBinary in Sec-WebSocket-Protocol header).
+func WithEnvelope(f EnvelopeFormat) Option {
+	return func(s *Server) {
+		s.envelope = f
+	}
+}
+
+// Envelope return the envelope format of connection.
+func (c *Conn) Envelope() EnvelopeFormat {
+	return c.envelope
+}
+
+// WithSubprotocols adds subprotocols which server accepts besides ProtocolJSON and ProtocolBinary.
+// The first protocol requested by client which server supports is selected, see Conn.Subprotocol.
+func WithSubprotocols(protocols ...string) Option {
+	return func(s *Server) {
+		s.protocols = append(s.protocols, protocols...)
+	}
+}
+
+// Subprotocol return the subprotocol selected during handshake, empty if client requested none.
+func (c *Conn) Subprotocol() string {
+	return c.protocol
+}
+
+// selectProtocol reports whether server supports subprotocol.
+func (s *Server) selectProtocol(p string) bool {
+	if p == ProtocolJSON || p == ProtocolBinary {
+		return true
+	}
+	for _, protocol := range s.protocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// envelopeFormat return format for selected subprotocol.
+func (s *Server) envelopeFormat(protocol string) EnvelopeFormat {
+	switch protocol {
+	case ProtocolJSON:
+		return JSONEnvelope
+	case ProtocolBinary:
+		return BinaryEnvelope
+	}
+	return s.envelope
+}
+
+// encodeBinary write envelope in binary format. Returned bytes are valid until encoder is returned to the pool.
+func (e *encoder) encodeBinary(env envelope) ([]byte, error) {
+	var flags byte
+	if env.ID != 0 {
+		flags |= flagID
+	}
+	if env.Channel != "" || env.Seq != 0 {
+		flags |= flagSeq
+	}
+
+	b := e.buf.AvailableBuffer()
+	b = append(b, flags)
+	if flags&flagID != 0 {
+		b = binary.AppendUvarint(b, env.ID)
+	}
+	if flags&flagSeq != 0 {
+		b = binary.AppendUvarint(b, uint64(len(env.Channel)))
+		b = append(b, env.Channel...)
+		b = binary.AppendUvarint(b, env.Seq)
+	}
+	b = binary.AppendUvarint(b, uint64(len(env.Name)))
+	b = append(b, env.Name...)
+	e.buf.Write(b)
+
+	switch data := env.Data.(type) {
+	case []byte:
+		e.buf.Write(data)
+	case json.RawMessage:
+		e.buf.Write(data)
+	case BinaryPayload:
+		b, err := data.BinaryPayload()
+		if err != nil {
+			return nil, err
+		}
+		e.buf.Write(b)
+	default:
+		// json is appended to the header, without the trailing newline of encoder
+		if err := e.enc.Encode(data); err != nil {
+			return nil, err
+		}
+		e.buf.Truncate(e.buf.Len() - 1)
+	}
+
+	return e.buf.Bytes(), nil
+}
+
+// decodeBinary read envelope in binary format, data of envelope is a copy of payload.
+func decodeBinary(b []byte) (envelope, error) {
+	var env envelope
+	if len(b) == 0 {
+		return env, errInvalidEnvelope
+	}
+	flags := b[0]
+	b = b[1:]
+
+	uvarint := func() (uint64, bool) {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return 0, false
+		}
+		b = b[n:]
+		return v, true
+	}
+	str := func() (string, bool) {
+		n, ok := uvarint()
+		if !ok || n > uint64(len(b)) {
+			return "", false
+		}
+		s := string(b[:n])
+		b = b[n:]
+		return s, true
+	}
+
+	ok := true
+	if flags&flagID != 0 {
+		env.ID, ok = uvarint()
+	}
+	if ok && flags&flagSeq != 0 {
+		if env.Channel, ok = str(); ok {
+			env.Seq, ok = uvarint()
+		}
+	}
+	if ok {
+		env.Name, ok = str()
+	}
+	if !ok || env.Name == "" {
+		return envelope{}, errInvalidEnvelope
+	}
+
+	env.Data = append([]byte{}, b...)
+	return env, nil
+}
+
+// decode received message in the envelope format of connection.
+func (c *Conn) decode(b []byte) (envelope, error) {
+	if c.envelope == BinaryEnvelope {
+		return decodeBinary(b)
+	}
+
+	// data is kept as received, so numbers and order of keys don't change
+	var in struct {
+		ID      uint64          `json:"id"`
+		Name    string          `json:"name"`
+		Data    json.RawMessage `json:"data"`
+		Channel string          `json:"channel"`
+		Seq     uint64          `json:"seq"`
+	}
+	if err := json.Unmarshal(b, &in); err != nil {
+		return envelope{}, err
+	}
+	env := envelope{ID: in.ID, Name: in.Name, Channel: in.Channel, Seq: in.Seq}
+	if in.Data != nil {
+		env.Data = in.Data
+	}
+	return env, nil
+}
+
+// jsonData return data for json envelope: []byte is always sent as string, json.RawMessage is embedded as is.
+// Invalid UTF-8 is rejected with ErrInvalidUTF8, json would silently replace such bytes in string.
+func jsonData(data any) (any, error) {
+	if b, ok := data.([]byte); ok && b != nil {
+		if !utf8.Valid(b) {
+			return nil, ErrInvalidUTF8
+		}
+		return string(b), nil
+	}
+	return data, nil
+}
+
+// payload return data of received envelope, json data and payload of binary envelope are returned as is.
+func (env envelope) payload() ([]byte, error) {
+	switch data := env.Data.(type) {
+	case []byte:
+		return data, nil
+	case json.RawMessage:
+		return data, nil
+	}
+	return json.Marshal(env.Data)
+}
@@ -0,0 +1,184 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvelope_binary(t *testing.T) {
+	tests := []envelope{
+		{Name: "raw", Data: []byte{0, 1, 2}},
+		{Name: "json", Data: map[string]int{"a": 1}},
+		{ID: 7, Name: "acked", Data: []byte("x")},
+		{Name: "seq", Data: []byte("y"), Channel: "book", Seq: 300},
+		{ID: 1, Name: "all", Data: []byte{}, Channel: "c", Seq: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			e := getEncoder()
+			defer putEncoder(e)
+
+			b, err := e.encodeBinary(tt)
+			require.NoError(t, err)
+
+			env, err := decodeBinary(b)
+			require.NoError(t, err)
+			require.Equal(t, tt.ID, env.ID)
+			require.Equal(t, tt.Name, env.Name)
+			require.Equal(t, tt.Channel, env.Channel)
+			require.Equal(t, tt.Seq, env.Seq)
+
+			want, ok := tt.Data.([]byte)
+			if !ok {
+				want, _ = json.Marshal(tt.Data)
+			}
+			require.Equal(t, want, env.Data)
+		})
+	}
+
+	for _, b := range [][]byte{nil, {0}, {0, 5, 'a'}, {flagID}, {flagSeq, 10}} {
+		_, err := decodeBinary(b)
+		require.ErrorIs(t, err, errInvalidEnvelope, "%v", b)
+	}
+}
+
+func TestServer_jsonDataAsIs(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	received := make(chan string, 2)
+	wsServer.On("order", func(c Connection, msg *Message) {
+		received <- string(msg.Data)
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+	require.NoError(t, wsutil.WriteClientText(c, []byte(`{"name":"order","data":{"z":1,"id":12345678901234567890}}`)))
+	require.NoError(t, wsutil.WriteClientText(c, []byte(`{"name":"order"}`)))
+
+	require.Equal(t, `{"z":1,"id":12345678901234567890}`, <-received, "data must be passed without decoding")
+	require.Equal(t, `null`, <-received)
+}
+
+func TestServer_WithEnvelope(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithEnvelope(BinaryEnvelope))
+	defer shutdown()
+
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		require.Equal(t, BinaryEnvelope, c.(*Conn).Envelope())
+		require.NoError(t, c.Emit("echo", msg.Data))
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	require.NoError(t, wsutil.WriteClientBinary(c, []byte{0, 4, 'e', 'c', 'h', 'o', 0xff, 0x00}))
+	b, op, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpBinary, op)
+	require.Equal(t, []byte{0, 4, 'e', 'c', 'h', 'o', 0xff, 0x00}, b, "payload must be sent without encoding")
+}
+
+func TestServer_Handler_envelopeProtocol(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		require.NoError(t, c.Emit("echo", map[string]string{"data": string(msg.Data)}))
+	})
+
+	u := url.URL{Scheme: "ws", Host: strings.Replace(ts.URL, "http://", "", 1), Path: "/ws"}
+	dialer := ws.Dialer{Protocols: []string{"unknown", ProtocolBinary}}
+	c, _, hs, err := dialer.Dial(context.Background(), u.String())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	require.NoError(t, c.SetDeadline(time.Now().Add(3*time.Second)))
+	require.Equal(t, ProtocolBinary, hs.Protocol)
+
+	require.NoError(t, wsutil.WriteClientBinary(c, []byte{0, 4, 'e', 'c', 'h', 'o', 'h', 'i'}))
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+
+	env, err := decodeBinary(b)
+	require.NoError(t, err)
+	require.Equal(t, "echo", env.Name)
+	require.JSONEq(t, `{"data":"hi"}`, string(env.Data.([]byte)))
+}
+
+func TestServer_WithSubprotocols(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithSubprotocols("chat.v2"))
+	defer shutdown()
+
+	protocol := make(chan string, 1)
+	wsServer.OnConnect(func(c Connection) {
+		protocol <- c.Subprotocol()
+	})
+
+	u := url.URL{Scheme: "ws", Host: strings.Replace(ts.URL, "http://", "", 1), Path: "/ws"}
+	dialer := ws.Dialer{Protocols: []string{"chat.v1", "chat.v2"}}
+	c, _, hs, err := dialer.Dial(context.Background(), u.String())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	require.Equal(t, "chat.v2", hs.Protocol)
+	require.Equal(t, "chat.v2", <-protocol)
+}
+
+// point has compact binary encoding and json for json envelope.
+type point struct{ x, y byte }
+
+func (p point) BinaryPayload() ([]byte, error) { return []byte{p.x, p.y}, nil }
+
+func (p point) MarshalJSON() ([]byte, error) { return json.Marshal([]byte{p.x, p.y}) }
+
+func TestServer_WithEnvelope_binaryPayload(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithEnvelope(BinaryEnvelope))
+	defer shutdown()
+
+	wsServer.On("point", func(c Connection, msg *Message) {
+		require.NoError(t, c.Emit("point", point{1, 2}))
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+
+	require.NoError(t, wsutil.WriteClientBinary(c, []byte{0, 5, 'p', 'o', 'i', 'n', 't'}))
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 5, 'p', 'o', 'i', 'n', 't', 1, 2}, b)
+}
+
+func TestJSONData(t *testing.T) {
+	tests := []struct {
+		data any
+		want string
+	}{
+		{data: []byte("hello"), want: `"hello"`},
+		{data: []byte(`{"a":1}`), want: `"{\"a\":1}"`},
+		{data: json.RawMessage(`{"a":1}`), want: `{"a":1}`},
+	}
+	for _, tt := range tests {
+		v, err := jsonData(tt.data)
+		require.NoError(t, err)
+		b, err := json.Marshal(v)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, string(b), "[]byte must be sent as string whatever it contains")
+	}
+
+	_, err := jsonData([]byte{0xff, 0xfe})
+	require.ErrorIs(t, err, ErrInvalidUTF8)
+}
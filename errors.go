@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+)
+
+// Error codes of _error event, they follow HTTP status codes.
+const (
+	CodeBadRequest      = 400
+	CodeUnauthorized    = 401
+	CodeForbidden       = 403
+	CodeNotFound        = 404
+	CodeTooManyRequests = 429
+	CodeInternal        = 500
+)
+
+// ErrInternal is sent to client instead of errors which are not *Error, the original error is logged.
+var ErrInternal = NewError(CodeInternal, "internal error")
+
+// Error is returned from handlers to reply to client with the code.
+// Other errors are sent as ErrInternal.
+type Error struct {
+	Code    int
+	Message string
+}
+
+// NewError return error which is sent to client with code and message.
+func NewError(code int, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorReply is the data of _error event sent to client when message can't be processed:
+//
+//	{"name": "_error", "data": {"event": "order.created", "code": 400, "message": "..."}}
+type ErrorReply struct {
+	Event   string `json:"event"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Channel string `json:"channel,omitempty"`
+}
+
+// ErrorHandlerFunc is a HandlerFunc which can reply to client with error, see Handle.
+type ErrorHandlerFunc func(c Connection, msg *Message) error
+
+// Handle adding callback for message which returns error.
+// Non-nil error is sent to client as _error event (see ErrorReply). Returned function removes the callback.
+func (s *Server) Handle(name string, f ErrorHandlerFunc) (off func()) {
+	return s.On(name, f.handler())
+}
+
+// Handle adding callback for message of namespace connections which returns error, see Server.Handle.
+func (ns *Namespace) Handle(name string, f ErrorHandlerFunc) (off func()) {
+	return ns.On(name, f.handler())
+}
+
+func (f ErrorHandlerFunc) handler() HandlerFunc {
+	return func(c Connection, msg *Message) {
+		if err := f(c, msg); err != nil {
+			replyError(c, msg.Name, "", err)
+		}
+	}
+}
+
+// newErrorReply build the reply for error of event. Errors which are not *Error or errors of the package
+// could expose internals, they are sent as ErrInternal.
+func newErrorReply(event, channel string, err error) ErrorReply {
+	r := ErrorReply{
+		Event:   event,
+		Code:    CodeInternal,
+		Message: err.Error(),
+		Channel: channel,
+	}
+
+	var (
+		e       *Error
+		syntax  *json.SyntaxError
+		invalid *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &e):
+		r.Code = e.Code
+	case errors.Is(err, ErrNoChannel), errors.Is(err, ErrInvalidChannel),
+		errors.As(err, &syntax), errors.As(err, &invalid):
+		r.Code = CodeBadRequest
+	case errors.Is(err, ErrNotMember), errors.Is(err, ErrReadOnly):
+		r.Code = CodeForbidden
+	case errors.Is(err, ErrNoLog):
+		r.Code = CodeNotFound
+	default:
+		r.Message = ErrInternal.Message
+	}
+	return r
+}
+
+// replyError send _error event for the event to connection, details of internal errors are logged.
+func replyError(c Connection, event, channel string, err error) {
+	r := newErrorReply(event, channel, err)
+	if r.Code == CodeInternal && r.Message == ErrInternal.Message && err != ErrInternal {
+		log.Printf("websocket: %s of %s failed: %v", event, c.ID(), err)
+	}
+	_ = c.Emit(EventError, r)
+}
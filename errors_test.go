@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestServer_Handle(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	wsServer.Handle("pay", func(c Connection, msg *Message) error {
+		switch string(msg.Data) {
+		case `"ok"`:
+			return c.Emit("paid", true)
+		case `"limit"`:
+			return fmt.Errorf("payment: %w", NewError(CodeTooManyRequests, "too many payments"))
+		}
+		return errors.New("boom")
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, "pay", "ok")
+	name, _ := readEnvelope(t, c)
+	require.Equal(t, "paid", name)
+
+	writeMessage(t, c, "pay", "limit")
+	name, data := readEnvelope(t, c)
+	require.Equal(t, EventError, name)
+	require.JSONEq(t, `{"event":"pay","code":429,"message":"payment: too many payments"}`, string(data))
+
+	writeMessage(t, c, "pay", "other")
+	_, data = readEnvelope(t, c)
+	require.JSONEq(t, `{"event":"pay","code":500,"message":"internal error"}`, string(data))
+}
+
+func TestServer_OnSubscribe_code(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	wsServer.OnSubscribe(func(ctx context.Context, c Connection, channel string) error {
+		return NewError(CodeNotFound, "no such room")
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, EventSubscribe, map[string]string{"channel": "room"})
+	_, data := readEnvelope(t, c)
+	require.JSONEq(t, `{"event":"_subscribe","code":404,"channel":"room","message":"no such room"}`, string(data))
+}
@@ -0,0 +1,151 @@
+package websocket
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"github.com/gobwas/httphead"
+	"github.com/gobwas/ws"
+	"net/http"
+	"strings"
+)
+
+const headerExtensions = "Sec-WebSocket-Extensions"
+
+// Extension is websocket extension negotiated with Sec-WebSocket-Extensions header, e.g. compression.
+// Frames with RSV bits set are rejected with 1002 unless negotiated extension of connection claims them,
+// bits are allowed only in the first frame of data message.
+type Extension interface {
+	// Name is the extension token, e.g. "permessage-deflate".
+	Name() string
+	// Negotiate is called with the offer of client. It returns parameters for the response and
+	// codec for the connection, nil codec declines the offer. Error rejects the upgrade.
+	Negotiate(offer httphead.Option) (httphead.Option, ExtensionCodec, error)
+}
+
+// ExtensionCodec transforms messages of one connection. Encode calls are serialized, as well as Decode calls,
+// but Encode and Decode could be called concurrently.
+type ExtensionCodec interface {
+	// RSV return reserved bits claimed by extension, e.g. ws.Rsv(true, false, false) for RSV1.
+	RSV() byte
+	// Encode transforms payload of data message sent to client, returned bits are set in the first frame.
+	Encode(payload []byte) ([]byte, byte, error)
+	// Decode transforms payload of data message received from client, rsv are bits of its first frame.
+	Decode(payload []byte, rsv byte) ([]byte, error)
+}
+
+// WithExtensions adds extensions which server negotiates with clients. Extensions are accepted
+// in order of client offer, extension which claims bits of already accepted one is skipped.
+// Streams (see OnStream) receive payload as is.
+func WithExtensions(extensions ...Extension) Option {
+	return func(s *Server) {
+		s.extensions = append(s.extensions, extensions...)
+	}
+}
+
+// negotiated is the result of handshake negotiation, datagrams is set by transport which supports them.
+type negotiated struct {
+	protocol   string
+	extensions []ExtensionCodec
+	tls        *tls.ConnectionState
+	datagrams  DatagramSession
+}
+
+// negotiateExtensions select extensions offered by client, it adds accepted extensions to h.
+func (s *Server) negotiateExtensions(r *http.Request, h http.Header) ([]ExtensionCodec, error) {
+	if len(s.extensions) == 0 {
+		return nil, nil
+	}
+	values := r.Header.Values(headerExtensions)
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	offers, ok := httphead.ParseOptions([]byte(strings.Join(values, ",")), nil)
+	if !ok {
+		return nil, NewError(http.StatusBadRequest, "websocket: malformed "+headerExtensions)
+	}
+
+	var (
+		codecs   []ExtensionCodec
+		accepted []httphead.Option
+		rsv      byte
+	)
+	for _, offer := range offers {
+		for _, ext := range s.extensions {
+			if ext.Name() != string(offer.Name) {
+				continue
+			}
+			opt, codec, err := ext.Negotiate(offer)
+			if err != nil {
+				return nil, NewError(http.StatusBadRequest, fmt.Sprintf("websocket: extension %s: %v", ext.Name(), err))
+			}
+			if codec == nil || codec.RSV()&rsv != 0 {
+				continue
+			}
+			if len(opt.Name) == 0 {
+				opt.Name = offer.Name
+			}
+			rsv |= codec.RSV()
+			codecs = append(codecs, codec)
+			accepted = append(accepted, opt)
+			break
+		}
+	}
+
+	if len(accepted) != 0 {
+		var b bytes.Buffer
+		_, _ = httphead.WriteOptions(&b, accepted)
+		h.Set(headerExtensions, b.String())
+	}
+	return codecs, nil
+}
+
+// rsvMask return reserved bits claimed by extensions of connection.
+func (c *Conn) rsvMask() byte {
+	var rsv byte
+	for _, codec := range c.extensions {
+		rsv |= codec.RSV()
+	}
+	return rsv
+}
+
+// checkHeader validate frame header, RSV bits are allowed only when extension claims them.
+func (c *Conn) checkHeader(h ws.Header, state ws.State) error {
+	if h.Rsv != 0 {
+		if h.OpCode.IsControl() || h.OpCode == ws.OpContinuation || h.Rsv&^c.rsvMask() != 0 {
+			return ws.ErrProtocolNonZeroRsv
+		}
+		state = state.Set(ws.StateExtended)
+	}
+	return ws.CheckHeader(h, state)
+}
+
+// decodeExtensions transforms received message with extensions which claim its bits.
+func (c *Conn) decodeExtensions(payload []byte, rsv byte) ([]byte, error) {
+	var err error
+	for i := len(c.extensions) - 1; i >= 0 && rsv != 0; i-- {
+		codec := c.extensions[i]
+		if codec.RSV()&rsv == 0 {
+			continue
+		}
+		if payload, err = codec.Decode(payload, rsv); err != nil {
+			return nil, err
+		}
+	}
+	return payload, nil
+}
+
+// encodeExtensions transforms message sent to client, returns header with RSV bits of extensions.
+func (c *Conn) encodeExtensions(h ws.Header, payload []byte) (ws.Header, []byte, error) {
+	for _, codec := range c.extensions {
+		b, rsv, err := codec.Encode(payload)
+		if err != nil {
+			return h, nil, err
+		}
+		payload = b
+		h.Rsv |= rsv
+	}
+	h.Length = int64(len(payload))
+	return h, payload, nil
+}
@@ -0,0 +1,123 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gobwas/httphead"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reverse is a test extension which reverses payload of messages with RSV1.
+type reverse struct{}
+
+func (reverse) Name() string { return "x-reverse" }
+
+func (reverse) Negotiate(offer httphead.Option) (httphead.Option, ExtensionCodec, error) {
+	return httphead.Option{}, reverse{}, nil
+}
+
+func (reverse) RSV() byte { return ws.Rsv(true, false, false) }
+
+func (reverse) Encode(payload []byte) ([]byte, byte, error) {
+	b := slices.Clone(payload)
+	slices.Reverse(b)
+	return b, ws.Rsv(true, false, false), nil
+}
+
+func (reverse) Decode(payload []byte, rsv byte) ([]byte, error) {
+	b := slices.Clone(payload)
+	slices.Reverse(b)
+	return b, nil
+}
+
+func writeFrame(t *testing.T, c net.Conn, op ws.OpCode, rsv byte, payload []byte) {
+	f := ws.NewFrame(op, true, payload)
+	f.Header.Rsv = rsv
+	require.NoError(t, ws.WriteFrame(c, ws.MaskFrameInPlace(f)))
+}
+
+func TestServer_rsvWithoutExtension(t *testing.T) {
+	ts, _, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	writeFrame(t, c, ws.OpText, ws.Rsv(true, false, false), []byte(`{"name":"test"}`))
+	require.Equal(t, ws.StatusProtocolError, readClose(t, c))
+}
+
+func TestServer_reservedOpCode(t *testing.T) {
+	ts, _, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	writeFrame(t, c, ws.OpCode(0x3), 0, []byte("test"))
+	require.Equal(t, ws.StatusProtocolError, readClose(t, c))
+}
+
+func TestServer_WithExtensions(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithExtensions(reverse{}))
+	defer shutdown()
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		_ = c.Emit("echo", msg.Data)
+	})
+
+	u := url.URL{Scheme: "ws", Host: strings.Replace(ts.URL, "http://", "", 1), Path: "/ws"}
+	d := ws.Dialer{Extensions: []httphead.Option{httphead.NewOption("x-reverse", nil), httphead.NewOption("x-unknown", nil)}}
+	c, _, hs, err := d.Dial(context.Background(), u.String())
+	require.NoError(t, err)
+	defer func() {
+		_ = c.Close()
+	}()
+	require.NoError(t, c.SetDeadline(time.Now().Add(time.Second)))
+	require.Len(t, hs.Extensions, 1)
+	require.Equal(t, "x-reverse", string(hs.Extensions[0].Name))
+
+	msg := []byte(`{"name":"echo","data":"test"}`)
+	slices.Reverse(msg)
+	writeFrame(t, c, ws.OpText, ws.Rsv(true, false, false), msg)
+
+	f, err := ws.ReadFrame(c)
+	require.NoError(t, err)
+	require.Equal(t, ws.Rsv(true, false, false), f.Header.Rsv)
+	slices.Reverse(f.Payload)
+	require.Equal(t, `{"name":"echo","data":"test"}`, string(f.Payload))
+
+	writeFrame(t, c, ws.OpText, ws.Rsv(false, true, false), []byte(`{"name":"echo"}`))
+	require.Equal(t, ws.StatusProtocolError, readClose(t, c), "bits which are not claimed by extension must be rejected")
+}
+
+func TestServer_ServeListener_extensions(t *testing.T) {
+	wsServer := New(WithExtensions(reverse{}))
+	defer func() {
+		_ = wsServer.Shutdown()
+	}()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = wsServer.ServeListener(l)
+	}()
+
+	d := ws.Dialer{Extensions: []httphead.Option{httphead.NewOption("x-reverse", nil)}}
+	c, _, hs, err := d.Dial(context.Background(), "ws://"+l.Addr().String()+"/ws")
+	require.NoError(t, err)
+	defer func() {
+		_ = c.Close()
+	}()
+	require.Len(t, hs.Extensions, 1)
+	require.Equal(t, "x-reverse", string(hs.Extensions[0].Name))
+}
@@ -0,0 +1,128 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gobwas/ws"
+	"sync"
+	"time"
+)
+
+// EventCredit is sent by client to grant the server credits for n messages, data is the number of credits.
+const EventCredit = SystemPrefix + "credit"
+
+// ErrFlowQueueFull is returned when client has no credits and the queue of pending messages is full.
+var ErrFlowQueueFull = errors.New("websocket: flow control queue is full")
+
+// WithFlowControl enables credit-based flow control.
+// Each connection starts with initial credits and every data message sent to it consumes one credit.
+// When credits are exhausted messages are queued (up to queue messages) until the client
+// grants more credits with _credit event: {"name": "_credit", "data": 10}.
+// Messages streamed with NextWriter are not counted.
+func WithFlowControl(initial, queue int) Option {
+	return func(s *Server) {
+		s.flowControl = true
+		s.flowCredits = initial
+		s.flowQueue = queue
+		s.handleSystem(EventCredit, credit)
+	}
+}
+
+type pendingMessage struct {
+	h        ws.Header
+	b        []byte
+	enqueued time.Time
+}
+
+// flow keeps credits of the connection and messages waiting for credits.
+type flow struct {
+	credits int
+	limit   int
+	pending []pendingMessage
+	mu      sync.Mutex
+}
+
+func newFlow(credits, limit int) *flow {
+	return &flow{
+		credits: credits,
+		limit:   limit,
+	}
+}
+
+// acquire take the credit for the message or put the message to the queue.
+// Returns true if the message was queued.
+func (f *flow) acquire(h ws.Header, b []byte, enqueued time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.credits > 0 && len(f.pending) == 0 {
+		f.credits--
+		return false, nil
+	}
+
+	if len(f.pending) >= f.limit {
+		return false, ErrFlowQueueFull
+	}
+
+	f.pending = append(f.pending, pendingMessage{
+		h:        h,
+		b:        append([]byte(nil), b...),
+		enqueued: enqueued,
+	})
+	return true, nil
+}
+
+// grant add credits and return queued messages which could be sent now.
+func (f *flow) grant(n int) []pendingMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.credits += n
+	k := min(f.credits, len(f.pending))
+	ready := f.pending[:k:k]
+	f.pending = f.pending[k:]
+	f.credits -= k
+
+	return ready
+}
+
+// Credits return the number of messages which could be sent to the connection without queueing
+// and the number of queued messages. Without flow control both are zero.
+func (c *Conn) Credits() (credits, pending int) {
+	if c.flow == nil {
+		return 0, 0
+	}
+
+	c.flow.mu.Lock()
+	defer c.flow.mu.Unlock()
+	return c.flow.credits, len(c.flow.pending)
+}
+
+// GrantCredits add n credits to the connection and send queued messages.
+// Usually credits are granted by client with _credit event.
+func (c *Conn) GrantCredits(n int) error {
+	if c.flow == nil {
+		return nil
+	}
+
+	c.msgMu.Lock()
+	defer c.msgMu.Unlock()
+
+	for _, m := range c.flow.grant(n) {
+		if err := c.write(m.h, m.b, m.enqueued); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// credit is the handler of _credit event.
+func credit(c *Conn, msg *Message) {
+	var n int
+	if err := json.Unmarshal(msg.Data, &n); err != nil || n <= 0 {
+		replyError(c, EventCredit, "", NewError(CodeBadRequest, fmt.Sprintf("invalid credit %s", msg.Data)))
+		return
+	}
+	_ = c.GrantCredits(n)
+}
@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"encoding/json"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestFlow(t *testing.T) {
+	f := newFlow(1, 2)
+	h := ws.Header{Fin: true, OpCode: ws.OpText}
+
+	queued, err := f.acquire(h, []byte("1"), time.Now())
+	require.NoError(t, err)
+	require.False(t, queued, "message must be sent with credit")
+
+	for _, m := range []string{"2", "3"} {
+		queued, err = f.acquire(h, []byte(m), time.Now())
+		require.NoError(t, err)
+		require.True(t, queued, "message must be queued without credits")
+	}
+
+	_, err = f.acquire(h, []byte("4"), time.Now())
+	require.ErrorIs(t, err, ErrFlowQueueFull)
+
+	ready := f.grant(1)
+	require.Len(t, ready, 1)
+	require.Equal(t, []byte("2"), ready[0].b)
+
+	ready = f.grant(5)
+	require.Len(t, ready, 1)
+	require.Equal(t, []byte("3"), ready[0].b)
+	require.Equal(t, 4, f.credits)
+}
+
+func TestServer_FlowControl(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithFlowControl(1, 10))
+	defer shutdown()
+
+	conns := make(chan *Conn, 1)
+	wsServer.OnConnect(func(c Connection) {
+		conns <- c.(*Conn)
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	conn := <-conns
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, conn.Emit("seq", i))
+	}
+	credits, pending := conn.Credits()
+	require.Equal(t, 0, credits)
+	require.Equal(t, 2, pending)
+
+	read := func() int {
+		b, _, err := wsutil.ReadServerData(c)
+		require.NoError(t, err)
+		var msg struct {
+			Data int `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(b, &msg))
+		return msg.Data
+	}
+	require.Equal(t, 1, read())
+
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, _, err := wsutil.ReadServerData(c)
+	require.Error(t, err, "messages must wait for credits")
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(time.Second)))
+
+	writeMessage(t, c, EventCredit, 5)
+	require.Equal(t, 2, read())
+	require.Equal(t, 3, read())
+
+	credits, pending = conn.Credits()
+	require.Equal(t, 3, credits)
+	require.Equal(t, 0, pending)
+
+	writeMessage(t, c, EventCredit, -1)
+	name, data := readEnvelope(t, c)
+	require.Equal(t, EventError, name)
+	require.JSONEq(t, `{"event":"_credit","code":400,"message":"invalid credit -1"}`, string(data))
+}
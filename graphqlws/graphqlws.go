@@ -0,0 +1,346 @@
+// Package graphqlws implements graphql-transport-ws protocol (GraphQL over WebSocket) on top of websocket.Server.
+//
+// Query execution is done by application, ExecuteFunc returns a channel of results for the operation:
+//
+//	srv := websocket.New(websocket.WithSubprotocols(graphqlws.Protocol))
+//	graphqlws.New(srv, func(ctx context.Context, c websocket.Connection, req graphqlws.Request) (<-chan graphqlws.Result, error) {
+//		return schema.Subscribe(ctx, req.Query, req.OperationName, req.Variables)
+//	})
+//	srv.Run(ctx)
+//	http.HandleFunc("/graphql", srv.Handler)
+//
+// Server must be used for GraphQL only, New replaces OnConnect and OnMessage of the server.
+// See https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+package graphqlws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gobwas/ws"
+	"github.com/pkgz/websocket"
+	"sync"
+	"time"
+)
+
+// Protocol is the subprotocol of graphql-transport-ws, it must be accepted by server with websocket.WithSubprotocols.
+const Protocol = "graphql-transport-ws"
+
+// DefaultInitTimeout is the time for client to send connection_init.
+const DefaultInitTimeout = 3 * time.Second
+
+// Message types of the protocol.
+const (
+	TypeConnectionInit = "connection_init"
+	TypeConnectionAck  = "connection_ack"
+	TypePing           = "ping"
+	TypePong           = "pong"
+	TypeSubscribe      = "subscribe"
+	TypeNext           = "next"
+	TypeError          = "error"
+	TypeComplete       = "complete"
+)
+
+// Close codes of the protocol.
+const (
+	CloseInvalidMessage  ws.StatusCode = 4400
+	CloseUnauthorized    ws.StatusCode = 4401
+	CloseForbidden       ws.StatusCode = 4403
+	CloseInitTimeout     ws.StatusCode = 4408
+	CloseSubscriberExist ws.StatusCode = 4409
+	CloseTooManyInit     ws.StatusCode = 4429
+)
+
+// Request is the payload of subscribe message.
+type Request struct {
+	OperationName string          `json:"operationName,omitempty"`
+	Query         string          `json:"query"`
+	Variables     map[string]any  `json:"variables,omitempty"`
+	Extensions    map[string]any  `json:"extensions,omitempty"`
+	Payload       json.RawMessage `json:"-"`
+}
+
+// Result is the execution result sent in next message.
+type Result struct {
+	Data       any            `json:"data,omitempty"`
+	Errors     []Error        `json:"errors,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// Error is GraphQL error.
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// ExecuteFunc execute the operation and return channel of results, the operation is completed
+// when channel is closed. Context is cancelled when client completes the operation or disconnects.
+// Returned error is sent to client in error message.
+type ExecuteFunc func(ctx context.Context, c websocket.Connection, req Request) (<-chan Result, error)
+
+// InitFunc is called for connection_init message with its payload, e.g. to check the token.
+// Returned payload is sent in connection_ack, error closes connection with 4403.
+type InitFunc func(c websocket.Connection, payload json.RawMessage) (any, error)
+
+// Option configures the Handler.
+type Option func(h *Handler)
+
+// WithInit sets the function to check connection_init payload.
+func WithInit(f InitFunc) Option {
+	return func(h *Handler) {
+		h.init = f
+	}
+}
+
+// WithInitTimeout sets the time for client to send connection_init, default is DefaultInitTimeout.
+func WithInitTimeout(d time.Duration) Option {
+	return func(h *Handler) {
+		h.initTimeout = d
+	}
+}
+
+// Handler serves graphql-transport-ws connections of the server.
+type Handler struct {
+	execute     ExecuteFunc
+	init        InitFunc
+	initTimeout time.Duration
+
+	sessions map[websocket.Connection]*session
+	mu       sync.Mutex
+}
+
+// message is the wire format of the protocol.
+type message struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// session is the state of one connection.
+type session struct {
+	initRequested bool
+	acknowledged  bool
+	operations    map[string]*operation
+	mu            sync.Mutex
+}
+
+// operation is a running subscription, the pointer tells it from later operation with the same id.
+type operation struct {
+	cancel context.CancelFunc
+}
+
+// New serve graphql-transport-ws protocol on the server.
+func New(srv *websocket.Server, execute ExecuteFunc, opts ...Option) *Handler {
+	h := &Handler{
+		execute:     execute,
+		initTimeout: DefaultInitTimeout,
+		sessions:    make(map[websocket.Connection]*session),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	srv.OnConnect(h.connect)
+	srv.OnMessage(func(c websocket.Connection, _ ws.Header, b []byte) {
+		h.message(c, b)
+	})
+	return h
+}
+
+// connect start the session and close connection which doesn't init in time.
+func (h *Handler) connect(c websocket.Connection) {
+	s := h.session(c)
+	if s == nil {
+		return
+	}
+
+	timer := time.NewTimer(h.initTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		s.mu.Lock()
+		acknowledged := s.acknowledged
+		s.mu.Unlock()
+		if !acknowledged {
+			_ = c.CloseWith(CloseInitTimeout, "Connection initialisation timeout")
+		}
+	case <-c.Context().Done():
+	}
+
+	<-c.Context().Done()
+	h.mu.Lock()
+	delete(h.sessions, c)
+	h.mu.Unlock()
+
+	s.mu.Lock()
+	for _, op := range s.operations {
+		op.cancel()
+	}
+	s.mu.Unlock()
+}
+
+// session return the session of connection, it's created on the first call.
+// Messages could come before OnConnect is called, so both of them create it.
+func (h *Handler) session(c websocket.Connection) *session {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if c.Context().Err() != nil {
+		return nil
+	}
+	s, ok := h.sessions[c]
+	if !ok {
+		s = &session{operations: make(map[string]*operation)}
+		h.sessions[c] = s
+	}
+	return s
+}
+
+// message handle one message from client.
+func (h *Handler) message(c websocket.Connection, b []byte) {
+	s := h.session(c)
+	if s == nil {
+		return
+	}
+
+	var msg message
+	if err := json.Unmarshal(b, &msg); err != nil || msg.Type == "" {
+		_ = c.CloseWith(CloseInvalidMessage, "Invalid message received")
+		return
+	}
+
+	switch msg.Type {
+	case TypeConnectionInit:
+		h.connectionInit(c, s, msg)
+	case TypePing:
+		_ = send(c, message{Type: TypePong, Payload: msg.Payload})
+	case TypePong:
+	case TypeSubscribe:
+		h.subscribe(c, s, msg)
+	case TypeComplete:
+		s.mu.Lock()
+		op := s.operations[msg.ID]
+		delete(s.operations, msg.ID)
+		s.mu.Unlock()
+		if op != nil {
+			op.cancel()
+		}
+	default:
+		_ = c.CloseWith(CloseInvalidMessage, fmt.Sprintf("Invalid message type %q", msg.Type))
+	}
+}
+
+func (h *Handler) connectionInit(c websocket.Connection, s *session, msg message) {
+	s.mu.Lock()
+	requested := s.initRequested
+	s.initRequested = true
+	s.mu.Unlock()
+	if requested {
+		_ = c.CloseWith(CloseTooManyInit, "Too many initialisation requests")
+		return
+	}
+
+	ack := message{Type: TypeConnectionAck}
+	if h.init != nil {
+		payload, err := h.init(c, msg.Payload)
+		if err != nil {
+			_ = c.CloseWith(CloseForbidden, "Forbidden")
+			return
+		}
+		if payload != nil {
+			b, err := json.Marshal(payload)
+			if err != nil {
+				_ = c.CloseWith(CloseForbidden, "Forbidden")
+				return
+			}
+			ack.Payload = b
+		}
+	}
+
+	s.mu.Lock()
+	s.acknowledged = true
+	s.mu.Unlock()
+	_ = send(c, ack)
+}
+
+func (h *Handler) subscribe(c websocket.Connection, s *session, msg message) {
+	var req Request
+	if msg.ID == "" || json.Unmarshal(msg.Payload, &req) != nil {
+		_ = c.CloseWith(CloseInvalidMessage, "Invalid message received")
+		return
+	}
+	req.Payload = msg.Payload
+
+	ctx, cancel := context.WithCancel(c.Context())
+	s.mu.Lock()
+	if !s.acknowledged {
+		s.mu.Unlock()
+		cancel()
+		_ = c.CloseWith(CloseUnauthorized, "Unauthorized")
+		return
+	}
+	if _, ok := s.operations[msg.ID]; ok {
+		s.mu.Unlock()
+		cancel()
+		_ = c.CloseWith(CloseSubscriberExist, fmt.Sprintf("Subscriber for %s already exists", msg.ID))
+		return
+	}
+	op := &operation{cancel: cancel}
+	s.operations[msg.ID] = op
+	s.mu.Unlock()
+
+	results, err := h.execute(ctx, c, req)
+	if err != nil {
+		s.finish(msg.ID, op)
+		cancel()
+		payload, _ := json.Marshal([]Error{{Message: err.Error()}})
+		_ = send(c, message{ID: msg.ID, Type: TypeError, Payload: payload})
+		return
+	}
+
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case res, ok := <-results:
+				if !ok {
+					if s.finish(msg.ID, op) {
+						_ = send(c, message{ID: msg.ID, Type: TypeComplete})
+					}
+					return
+				}
+				payload, err := json.Marshal(res)
+				if err != nil {
+					payload, _ = json.Marshal(Result{Errors: []Error{{Message: err.Error()}}})
+				}
+				_ = send(c, message{ID: msg.ID, Type: TypeNext, Payload: payload})
+			case <-ctx.Done():
+				s.finish(msg.ID, op)
+				return
+			}
+		}
+	}()
+}
+
+// finish remove the operation, returns false if it was already completed by client.
+// Client could reuse the id after complete, so operation with the id is removed only if it's still op.
+func (s *session) finish(id string, op *operation) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.operations[id] != op {
+		return false
+	}
+	delete(s.operations, id)
+	return true
+}
+
+// send message in text frame.
+func send(c websocket.Connection, msg message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.Write(ws.Header{Fin: true, OpCode: ws.OpText, Length: int64(len(b))}, b)
+}
@@ -0,0 +1,198 @@
+package graphqlws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/pkgz/websocket"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func start(t *testing.T, execute ExecuteFunc, opts ...Option) net.Conn {
+	srv := websocket.New(websocket.WithSubprotocols(Protocol))
+	New(srv, execute, opts...)
+	srv.Run(context.Background())
+
+	ts := httptest.NewServer(http.HandlerFunc(srv.Handler))
+	t.Cleanup(func() {
+		ts.Close()
+		_ = srv.Shutdown()
+	})
+
+	dialer := ws.Dialer{Protocols: []string{Protocol}}
+	c, _, hs, err := dialer.Dial(context.Background(), "ws://"+strings.TrimPrefix(ts.URL, "http://"))
+	require.NoError(t, err)
+	require.Equal(t, Protocol, hs.Protocol)
+	require.NoError(t, c.SetDeadline(time.Now().Add(3*time.Second)))
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+	return c
+}
+
+func write(t *testing.T, c net.Conn, msg string) {
+	require.NoError(t, wsutil.WriteClientText(c, []byte(msg)))
+}
+
+func read(t *testing.T, c net.Conn) message {
+	b, op, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpText, op)
+
+	var msg message
+	require.NoError(t, json.Unmarshal(b, &msg))
+	return msg
+}
+
+func readClose(t *testing.T, c net.Conn) ws.StatusCode {
+	for {
+		h, err := ws.ReadHeader(c)
+		require.NoError(t, err)
+		payload := make([]byte, h.Length)
+		_, err = io.ReadFull(c, payload)
+		require.NoError(t, err)
+		if h.OpCode == ws.OpClose {
+			code, _ := ws.ParseCloseFrameData(payload)
+			return code
+		}
+	}
+}
+
+func counter(ctx context.Context, _ websocket.Connection, req Request) (<-chan Result, error) {
+	if req.Query == "" {
+		return nil, errors.New("empty query")
+	}
+
+	n := int(req.Variables["n"].(float64))
+	ch := make(chan Result)
+	go func() {
+		defer close(ch)
+		for i := 1; i <= n; i++ {
+			select {
+			case ch <- Result{Data: map[string]int{"count": i}}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func TestHandler_subscribe(t *testing.T) {
+	c := start(t, counter, WithInit(func(_ websocket.Connection, payload json.RawMessage) (any, error) {
+		require.JSONEq(t, `{"token":"secret"}`, string(payload))
+		return map[string]bool{"ok": true}, nil
+	}))
+
+	write(t, c, `{"type":"connection_init","payload":{"token":"secret"}}`)
+	ack := read(t, c)
+	require.Equal(t, TypeConnectionAck, ack.Type)
+	require.JSONEq(t, `{"ok":true}`, string(ack.Payload))
+
+	write(t, c, `{"type":"ping"}`)
+	require.Equal(t, TypePong, read(t, c).Type)
+
+	write(t, c, `{"id":"1","type":"subscribe","payload":{"query":"subscription { count }","variables":{"n":2}}}`)
+	for i := 1; i <= 2; i++ {
+		msg := read(t, c)
+		require.Equal(t, "1", msg.ID)
+		require.Equal(t, TypeNext, msg.Type)
+		require.JSONEq(t, `{"data":{"count":`+strconv.Itoa(i)+`}}`, string(msg.Payload))
+	}
+	msg := read(t, c)
+	require.Equal(t, "1", msg.ID)
+	require.Equal(t, TypeComplete, msg.Type)
+
+	write(t, c, `{"id":"2","type":"subscribe","payload":{"query":""}}`)
+	msg = read(t, c)
+	require.Equal(t, TypeError, msg.Type)
+	require.JSONEq(t, `[{"message":"empty query"}]`, string(msg.Payload))
+}
+
+func TestHandler_complete(t *testing.T) {
+	cancelled := make(chan struct{})
+	c := start(t, func(ctx context.Context, _ websocket.Connection, _ Request) (<-chan Result, error) {
+		go func() {
+			<-ctx.Done()
+			close(cancelled)
+		}()
+		return make(chan Result), nil
+	})
+
+	write(t, c, `{"type":"connection_init"}`)
+	require.Equal(t, TypeConnectionAck, read(t, c).Type)
+	write(t, c, `{"id":"1","type":"subscribe","payload":{"query":"subscription { x }"}}`)
+	write(t, c, `{"id":"1","type":"complete"}`)
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("complete must cancel the operation")
+	}
+}
+
+func TestHandler_completeReused(t *testing.T) {
+	contexts := make(chan context.Context, 2)
+	c := start(t, func(ctx context.Context, _ websocket.Connection, _ Request) (<-chan Result, error) {
+		contexts <- ctx
+		return make(chan Result), nil
+	})
+
+	write(t, c, `{"type":"connection_init"}`)
+	require.Equal(t, TypeConnectionAck, read(t, c).Type)
+	write(t, c, `{"id":"1","type":"subscribe","payload":{"query":"subscription { x }"}}`)
+	<-contexts
+	write(t, c, `{"id":"1","type":"complete"}`)
+	write(t, c, `{"id":"1","type":"subscribe","payload":{"query":"subscription { x }"}}`)
+	second := <-contexts
+	time.Sleep(50 * time.Millisecond)
+
+	write(t, c, `{"id":"1","type":"complete"}`)
+	select {
+	case <-second.Done():
+	case <-time.After(time.Second):
+		t.Fatal("completed operation must not remove the next one with the same id")
+	}
+}
+
+func TestHandler_closeCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+		opts     []Option
+		code     ws.StatusCode
+	}{
+		{name: "unauthorized", messages: []string{`{"id":"1","type":"subscribe","payload":{"query":"q"}}`}, code: CloseUnauthorized},
+		{name: "invalid", messages: []string{`not json`}, code: CloseInvalidMessage},
+		{name: "too many init", messages: []string{`{"type":"connection_init"}`, `{"type":"connection_init"}`}, code: CloseTooManyInit},
+		{name: "duplicate", messages: []string{
+			`{"type":"connection_init"}`,
+			`{"id":"1","type":"subscribe","payload":{"query":"q","variables":{"n":1000000}}}`,
+			`{"id":"1","type":"subscribe","payload":{"query":"q","variables":{"n":1}}}`,
+		}, code: CloseSubscriberExist},
+		{name: "forbidden", messages: []string{`{"type":"connection_init"}`}, code: CloseForbidden, opts: []Option{
+			WithInit(func(websocket.Connection, json.RawMessage) (any, error) { return nil, errors.New("no token") }),
+		}},
+		{name: "init timeout", code: CloseInitTimeout, opts: []Option{WithInitTimeout(50 * time.Millisecond)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := start(t, counter, tt.opts...)
+			for _, m := range tt.messages {
+				write(t, c, m)
+			}
+			require.Equal(t, tt.code, readClose(t, c))
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Package grpcws pipes gRPC streams into channels of websocket.Server: every message of the stream
+// is emitted to channel with the name registered in protows.Registry, and client events could be
+// relayed back as requests on bidi stream.
+//
+//	stream, err := client.Subscribe(ctx, &pb.SubscribeRequest{Room: "general"})
+//	go grpcws.Pipe[*pb.Update](ch, reg, stream)
+//
+//	chat, err := client.Chat(ctx)
+//	relay := grpcws.NewRelay[*pb.Vote, *pb.Update](ch, reg, chat)
+//	protows.On(srv, reg, relay.Handle)
+//	go relay.Run()
+//
+// Streams are stopped by the context they were opened with. Generated gRPC streams satisfy Receiver
+// and Stream interfaces, so package doesn't depend on grpc.
+package grpcws
+
+import (
+	"context"
+	"errors"
+	"github.com/pkgz/websocket"
+	"github.com/pkgz/websocket/protows"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"sync"
+)
+
+// ErrNotMember is returned by Relay.Handle when sender of event is not in channel of relay.
+var ErrNotMember = websocket.NewError(websocket.CodeForbidden, "not a member of channel")
+
+// ErrRelayClosed is returned by Relay.Send after requests are closed.
+var ErrRelayClosed = errors.New("grpcws: relay is closed")
+
+// Receiver is server side stream of gRPC call, e.g. pb.Feed_SubscribeClient.
+type Receiver[T proto.Message] interface {
+	Recv() (T, error)
+}
+
+// Stream is bidirectional stream of gRPC call, e.g. pb.Chat_ChatClient.
+type Stream[Req, Res proto.Message] interface {
+	Receiver[Res]
+	Send(Req) error
+	CloseSend() error
+}
+
+// Pipe emits every message of stream to channel until stream ends. Messages are encoded with
+// protows.Data, so clients with binary envelope receive protobuf and others protojson.
+// It returns nil when server finished the stream, error of the stream or protows error for message
+// which is not registered.
+func Pipe[T proto.Message](ch *websocket.Channel, reg *protows.Registry, stream Receiver[T]) error {
+	for {
+		m, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err = reg.EmitChannel(ch, m); err != nil {
+			return err
+		}
+	}
+}
+
+// Relay pipes responses of bidi stream into channel and sends events of channel members to the stream.
+type Relay[Req, Res proto.Message] struct {
+	ch     *websocket.Channel
+	reg    *protows.Registry
+	stream Stream[Req, Res]
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewRelay makes relay of stream to channel, responses are piped by Run.
+func NewRelay[Req, Res proto.Message](ch *websocket.Channel, reg *protows.Registry, stream Stream[Req, Res]) *Relay[Req, Res] {
+	return &Relay[Req, Res]{ch: ch, reg: reg, stream: stream}
+}
+
+// Run pipes responses to channel until stream ends (see Pipe), requests are closed after that.
+func (r *Relay[Req, Res]) Run() error {
+	err := Pipe[Res](r.ch, r.reg, r.stream)
+	if cerr := r.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Send request to the stream. Requests are serialized, gRPC streams don't allow concurrent Send.
+func (r *Relay[Req, Res]) Send(req Req) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrRelayClosed
+	}
+	return r.stream.Send(req)
+}
+
+// Handle sends event of client to the stream, it's the callback for protows.On.
+// Events of connections which are not in channel of relay are rejected with ErrNotMember,
+// events of read-only members with websocket.ErrReadOnly, as Channel.Publish does.
+func (r *Relay[Req, Res]) Handle(_ context.Context, c websocket.Connection, req Req) error {
+	if !r.ch.Has(c) {
+		return ErrNotMember
+	}
+	if r.ch.ReadOnly(c) {
+		return websocket.ErrReadOnly
+	}
+	return r.Send(req)
+}
+
+// Close requests of the stream, responses are piped until server finishes the stream.
+func (r *Relay[Req, Res]) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.stream.CloseSend()
+}
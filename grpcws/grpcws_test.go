@@ -0,0 +1,124 @@
+package grpcws
+
+import (
+	"context"
+	"errors"
+	"github.com/pkgz/websocket"
+	"github.com/pkgz/websocket/protows"
+	"github.com/pkgz/websocket/websockettest"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStream is bidi stream with responses from channel.
+type fakeStream struct {
+	responses chan *wrapperspb.StringValue
+	err       error
+
+	mu       sync.Mutex
+	requests []int64
+	closed   bool
+}
+
+func newFakeStream() *fakeStream {
+	return &fakeStream{responses: make(chan *wrapperspb.StringValue, 10), err: io.EOF}
+}
+
+func (s *fakeStream) Recv() (*wrapperspb.StringValue, error) {
+	if m, ok := <-s.responses; ok {
+		return m, nil
+	}
+	return nil, s.err
+}
+
+func (s *fakeStream) Send(req *wrapperspb.Int64Value) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req.Value)
+	return nil
+}
+
+func (s *fakeStream) CloseSend() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func setup(t *testing.T) (*websocket.Server, *protows.Registry, *websocket.Channel, *websockettest.Client) {
+	reg := protows.NewRegistry()
+	require.NoError(t, reg.Register("update", &wrapperspb.StringValue{}))
+	require.NoError(t, reg.Register("vote", &wrapperspb.Int64Value{}))
+
+	srv := websocket.Start(context.Background())
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+	conn, client := websockettest.NewPair(t, srv)
+	ch := srv.NewChannel("poll")
+	require.NoError(t, ch.Add(conn))
+	return srv, reg, ch, client
+}
+
+func TestPipe(t *testing.T) {
+	_, reg, ch, client := setup(t)
+
+	stream := newFakeStream()
+	stream.responses <- wrapperspb.String("one")
+	stream.responses <- wrapperspb.String("two")
+	close(stream.responses)
+
+	require.NoError(t, Pipe[*wrapperspb.StringValue](ch, reg, stream))
+	client.ExpectJSON(t, "update", "one")
+	client.ExpectJSON(t, "update", "two")
+
+	failed := newFakeStream()
+	failed.err = errors.New("unavailable")
+	close(failed.responses)
+	require.EqualError(t, Pipe[*wrapperspb.StringValue](ch, reg, failed), "unavailable")
+}
+
+func TestRelay(t *testing.T) {
+	srv, reg, ch, client := setup(t)
+
+	stream := newFakeStream()
+	relay := NewRelay[*wrapperspb.Int64Value, *wrapperspb.StringValue](ch, reg, stream)
+	protows.On(srv, reg, relay.Handle)
+	done := make(chan error, 1)
+	go func() {
+		done <- relay.Run()
+	}()
+
+	client.Emit(t, "vote", "42")
+	stream.responses <- wrapperspb.String("42 votes")
+	client.ExpectJSON(t, "update", "42 votes")
+	stream.mu.Lock()
+	require.Equal(t, []int64{42}, stream.requests)
+	stream.mu.Unlock()
+
+	_, outsider := websockettest.NewPair(t, srv)
+	outsider.Emit(t, "vote", "1")
+	require.Contains(t, outsider.Expect(t, "_error").String(), "not a member")
+
+	reader, listener := websockettest.NewPair(t, srv)
+	require.NoError(t, ch.AddReadOnly(reader))
+	listener.Emit(t, "vote", "1")
+	require.Contains(t, listener.Expect(t, "_error").String(), "read-only")
+	stream.mu.Lock()
+	require.Equal(t, []int64{42}, stream.requests, "votes of read-only member must not reach the stream")
+	stream.mu.Unlock()
+
+	close(stream.responses)
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("relay must stop when stream ends")
+	}
+	require.True(t, stream.closed)
+	require.ErrorIs(t, relay.Send(wrapperspb.Int64(1)), ErrRelayClosed)
+}
@@ -0,0 +1,127 @@
+package websocket
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// isExtendedConnect reports whether request is websocket bootstrapping over HTTP/2 (RFC 8441).
+// Go HTTP/2 server accepts such requests when GODEBUG=http2xconnect=1 is set.
+func isExtendedConnect(r *http.Request) bool {
+	return r.ProtoMajor >= 2 && r.Method == http.MethodConnect && strings.EqualFold(r.Header.Get(":protocol"), "websocket")
+}
+
+// upgradeH2 accept websocket stream over HTTP/2 extended CONNECT, returns selected subprotocol and extensions.
+// Frames are the same as for HTTP/1.1, only the handshake is different: no Sec-WebSocket-Key/Accept and 200 status.
+func (s *Server) upgradeH2(w http.ResponseWriter, r *http.Request) (net.Conn, negotiated, error) {
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "websocket: unsupported version", http.StatusBadRequest)
+		return nil, negotiated{}, errors.New("websocket: unsupported version " + r.Header.Get("Sec-WebSocket-Version"))
+	}
+
+	h, n, err := s.negotiate(r)
+	if err != nil {
+		http.Error(w, err.Error(), rejectCode(err))
+		return nil, negotiated{}, err
+	}
+	for k, v := range h {
+		w.Header()[k] = v
+	}
+	if n.protocol != "" {
+		w.Header().Set(headerProtocol, n.protocol)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		return nil, negotiated{}, err
+	}
+
+	return newH2Stream(w, r), n, nil
+}
+
+// h2Stream is net.Conn over HTTP/2 stream: request body for reading and response for writing.
+type h2Stream struct {
+	body   io.ReadCloser
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	local  net.Addr
+	remote net.Addr
+	once   sync.Once
+}
+
+func newH2Stream(w http.ResponseWriter, r *http.Request) *h2Stream {
+	remote, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	if err != nil {
+		remote = &net.TCPAddr{}
+	}
+	local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		local = &net.TCPAddr{}
+	}
+
+	return &h2Stream{
+		body:   r.Body,
+		w:      w,
+		rc:     http.NewResponseController(w),
+		local:  local,
+		remote: remote,
+	}
+}
+
+// Read implements net.Conn.
+func (s *h2Stream) Read(b []byte) (int, error) {
+	return s.body.Read(b)
+}
+
+// Write implements net.Conn.
+func (s *h2Stream) Write(b []byte) (int, error) {
+	n, err := s.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	return n, s.rc.Flush()
+}
+
+// Close implements net.Conn, the stream is finished when handler returns.
+func (s *h2Stream) Close() error {
+	var err error
+	s.once.Do(func() { err = s.body.Close() })
+	return err
+}
+
+// LocalAddr implements net.Conn.
+func (s *h2Stream) LocalAddr() net.Addr { return s.local }
+
+// RemoteAddr implements net.Conn.
+func (s *h2Stream) RemoteAddr() net.Addr { return s.remote }
+
+// SetDeadline implements net.Conn.
+func (s *h2Stream) SetDeadline(t time.Time) error {
+	_ = s.SetReadDeadline(t)
+	return s.SetWriteDeadline(t)
+}
+
+// SetReadDeadline implements net.Conn.
+func (s *h2Stream) SetReadDeadline(t time.Time) error {
+	if err := s.rc.SetReadDeadline(t); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// SetWriteDeadline implements net.Conn.
+func (s *h2Stream) SetWriteDeadline(t time.Time) error {
+	if err := s.rc.SetWriteDeadline(t); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+var _ net.Conn = (*h2Stream)(nil)
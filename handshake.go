@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrHeaderTooLarge is returned when handshake request exceeds the header size limit, see WithMaxHeaderSize.
+var ErrHeaderTooLarge = errors.New("websocket: request header too large")
+
+// WithHandshakeTimeout limits the time of handshake: reading the request by ServeListener
+// and http server of ListenAndServe and Serve, and writing the response by all of them including Handler
+// (each of them is limited separately). Client which stalls in the middle of handshake is disconnected.
+// Default is DefaultReadHeaderTimeout.
+func WithHandshakeTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.handshakeTimeout = d
+	}
+}
+
+// WithMaxHeaderSize limits the size of request uri and headers of handshake, bigger requests are rejected
+// with 431 (Request Header Fields Too Large). Default is http.DefaultMaxHeaderBytes.
+func WithMaxHeaderSize(n int) Option {
+	return func(s *Server) {
+		s.maxHeaderSize = n
+	}
+}
+
+// handshakeDeadline return the deadline of handshake started now.
+func (s *Server) handshakeDeadline(now time.Time) time.Time {
+	return now.Add(s.handshakeLimit())
+}
+
+// handshakeLimit return the maximum time of handshake.
+func (s *Server) handshakeLimit() time.Duration {
+	if s.handshakeTimeout > 0 {
+		return s.handshakeTimeout
+	}
+	return DefaultReadHeaderTimeout
+}
+
+// headerLimit return the maximum size of handshake request headers.
+func (s *Server) headerLimit() int {
+	if s.maxHeaderSize > 0 {
+		return s.maxHeaderSize
+	}
+	return http.DefaultMaxHeaderBytes
+}
+
+// headerSize return the size of request uri and headers as they were sent.
+func headerSize(r *http.Request) int {
+	n := len(r.RequestURI) + len(r.Host)
+	for k, values := range r.Header {
+		for _, v := range values {
+			n += len(k) + len(v) + 4 // ": " and "\r\n"
+		}
+	}
+	return n
+}
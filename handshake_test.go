@@ -0,0 +1,124 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_WithHandshakeTimeout(t *testing.T) {
+	wsServer := New(WithHandshakeTimeout(100 * time.Millisecond))
+	defer func() {
+		_ = wsServer.Shutdown()
+	}()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = wsServer.ServeListener(l)
+	}()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	// client stalls in the middle of request
+	_, err = c.Write([]byte("GET /ws HTTP/1.1\r\nHost: localhost\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(time.Second)))
+	started := time.Now()
+	_, err = io.ReadAll(c)
+	require.NoError(t, err, "server must close the connection")
+	require.Less(t, time.Since(started), 900*time.Millisecond)
+	require.Equal(t, 0, wsServer.Count())
+}
+
+func TestServer_WithHandshakeTimeout_handler(t *testing.T) {
+	wsServer := New(WithHandshakeTimeout(100 * time.Millisecond))
+	// response doesn't fit into socket buffers, so it's written only while client reads
+	wsServer.OnUpgrade(func(r *http.Request, h http.Header) error {
+		h.Set("X-Pad", strings.Repeat("x", 16<<20))
+		return nil
+	})
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wsServer.Handler(w, r)
+		close(done)
+	}))
+	defer ts.Close()
+
+	c, err := net.Dial("tcp", ts.Listener.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.(*net.TCPConn).SetReadBuffer(4096))
+
+	// client sends the request and stalls reading the response
+	_, err = c.Write([]byte("GET /ws HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	require.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler must give up writing the response after handshake timeout")
+	}
+	require.Equal(t, 0, wsServer.Count())
+}
+
+func TestServer_WithMaxHeaderSize(t *testing.T) {
+	big := http.Header{"X-Big": []string{strings.Repeat("x", 1024)}}
+
+	// handler
+	ts, _, shutdown := server(t, WithMaxHeaderSize(512))
+	defer shutdown()
+	u := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+
+	_, _, _, err := ws.Dialer{Header: ws.HandshakeHeaderHTTP(big)}.Dial(context.Background(), u)
+	var status ws.StatusError
+	require.ErrorAs(t, err, &status)
+	require.Equal(t, ws.StatusError(http.StatusRequestHeaderFieldsTooLarge), status)
+
+	c, _, _, err := ws.Dial(context.Background(), u)
+	require.NoError(t, err)
+	_ = c.Close()
+
+	// listener
+	wsServer := New(WithMaxHeaderSize(512))
+	defer func() {
+		_ = wsServer.Shutdown()
+	}()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = wsServer.ServeListener(l)
+	}()
+	u = "ws://" + l.Addr().String() + "/ws"
+
+	_, _, _, err = ws.Dialer{Header: ws.HandshakeHeaderHTTP(big)}.Dial(context.Background(), u)
+	require.ErrorAs(t, err, &status)
+	require.Equal(t, ws.StatusError(http.StatusRequestHeaderFieldsTooLarge), status)
+
+	_, _, _, err = ws.Dial(context.Background(), u+"?q="+strings.Repeat("x", 1024))
+	require.ErrorAs(t, err, &status, "request uri counts too")
+	require.Equal(t, ws.StatusError(http.StatusRequestHeaderFieldsTooLarge), status)
+
+	many := http.Header{}
+	for i := 0; i < 100; i++ {
+		many.Add("X-H"+strconv.Itoa(i), "v")
+	}
+	_, _, _, err = ws.Dialer{Header: ws.HandshakeHeaderHTTP(many)}.Dial(context.Background(), u)
+	require.ErrorAs(t, err, &status, "many small headers count too")
+	require.Equal(t, ws.StatusError(http.StatusRequestHeaderFieldsTooLarge), status)
+
+	c, _, _, err = ws.Dial(context.Background(), u)
+	require.NoError(t, err)
+	_ = c.Close()
+}
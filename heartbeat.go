@@ -0,0 +1,44 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws"
+	"time"
+)
+
+// WithHeartbeat enables heartbeat for proxies which strip ping and pong frames: server sends
+// _heartbeat event {"ts": 1700000000000} (unix milliseconds) to every connection each interval,
+// and connections which didn't send any frame (data or control) for timeout are closed with 1001 (Going Away).
+// Clients should send own _heartbeat more often than timeout, server replies to it as usual.
+// Heartbeats of server don't need a reply. Zero timeout only sends heartbeats.
+func WithHeartbeat(interval, timeout time.Duration) Option {
+	return func(s *Server) {
+		s.heartbeatInterval = interval
+		s.heartbeatTimeout = timeout
+	}
+}
+
+// serverHeartbeat is data of _heartbeat event sent by server.
+type serverHeartbeat struct {
+	Ts int64 `json:"ts"`
+}
+
+// runHeartbeat send heartbeats and close silent connections until server stops.
+func (s *Server) runHeartbeat() {
+	ticker := time.NewTicker(s.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.connections.forEachShard(func(c *Conn) {
+				if s.heartbeatTimeout > 0 && c.idle(now, s.heartbeatTimeout, true) {
+					_ = c.closeWith(ws.StatusGoingAway, "heartbeat timeout")
+					return
+				}
+				_ = c.Emit(EventHeartbeat, serverHeartbeat{Ts: now.UnixMilli()})
+			})
+		case <-s.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package websocket
+
+import (
+	"encoding/json"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestServer_WithHeartbeat(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithHeartbeat(20*time.Millisecond, 150*time.Millisecond))
+	defer shutdown()
+
+	alive, silent := dial(t, ts), dial(t, ts)
+	defer func() {
+		_ = alive.Close()
+		_ = silent.Close()
+	}()
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(30 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				writeMessage(t, alive, EventHeartbeat, "client")
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	// silent client receives heartbeats and is closed after timeout
+	heartbeats := 0
+	for {
+		frame, err := ws.ReadFrame(silent)
+		require.NoError(t, err)
+		if frame.Header.OpCode == ws.OpClose {
+			code, reason := ws.ParseCloseFrameData(frame.Payload)
+			require.Equal(t, ws.StatusGoingAway, code)
+			require.Equal(t, "heartbeat timeout", reason)
+			break
+		}
+
+		var msg struct {
+			Name string `json:"name"`
+			Data struct {
+				Ts int64 `json:"ts"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(frame.Payload, &msg))
+		require.Equal(t, EventHeartbeat, msg.Name)
+		require.Positive(t, msg.Data.Ts)
+		heartbeats++
+	}
+	require.Greater(t, heartbeats, 2)
+
+	// client which sends own heartbeats stays connected
+	for range 10 {
+		_, _ = readEnvelope(t, alive)
+	}
+	require.Equal(t, 1, wsServer.Count())
+}
@@ -0,0 +1,163 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// EventBackfill is sent by client to receive channel history: {"channel": "room-1", "since": "2024-01-02T15:04:05Z"}.
+// Server sends messages of history and then _backfill event with their count: {"channel": "room-1", "count": 10}.
+const EventBackfill = SystemPrefix + "backfill"
+
+// HistoryStore keeps recent messages of channels, e.g. in memory (see NewMemoryHistory) or in Redis.
+// Data of entries is sealed when server has WithStateEncryption.
+type HistoryStore interface {
+	Add(ctx context.Context, channel string, entry LogEntry) error
+	Since(ctx context.Context, channel string, since time.Time) ([]LogEntry, error)
+}
+
+// SetHistory keeps messages emitted to the channel in the store,
+// so late joiners can catch up with History or _backfill event.
+func (c *Channel) SetHistory(h HistoryStore) {
+	c.mu.Lock()
+	c.history = h
+	c.mu.Unlock()
+}
+
+// History return messages emitted to the channel after since.
+func (c *Channel) History(ctx context.Context, since time.Time) ([]Message, error) {
+	c.mu.Lock()
+	h := c.history
+	c.mu.Unlock()
+
+	if h == nil {
+		return nil, nil
+	}
+
+	entries, err := h.Since(ctx, c.id, since)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Message, 0, len(entries))
+	for _, e := range entries {
+		data, err := c.open(ctx, e.Data)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, Message{Name: e.Name, Data: data, Received: e.Time})
+	}
+	return list, nil
+}
+
+// MemoryHistory is HistoryStore which keeps up to limit messages not older than maxAge for every channel.
+// Zero limit or maxAge means no limit.
+type MemoryHistory struct {
+	limit    int
+	maxAge   time.Duration
+	channels map[string][]LogEntry
+	offsets  map[string]int64
+	mu       sync.Mutex
+}
+
+// NewMemoryHistory return in-memory history bounded by count and age.
+func NewMemoryHistory(limit int, maxAge time.Duration) *MemoryHistory {
+	return &MemoryHistory{
+		limit:    limit,
+		maxAge:   maxAge,
+		channels: make(map[string][]LogEntry),
+		offsets:  make(map[string]int64),
+	}
+}
+
+// Add implements HistoryStore.
+func (m *MemoryHistory) Add(_ context.Context, channel string, entry LogEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry.Offset = m.offsets[channel]
+	m.offsets[channel]++
+
+	list := append(m.channels[channel], entry)
+	if m.limit > 0 && len(list) > m.limit {
+		list = append(list[:0:0], list[len(list)-m.limit:]...)
+	}
+	m.channels[channel] = m.trim(list, entry.Time)
+	return nil
+}
+
+// Since implements HistoryStore.
+func (m *MemoryHistory) Since(_ context.Context, channel string, since time.Time) ([]LogEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := m.trim(m.channels[channel], time.Now())
+	m.channels[channel] = list
+
+	var res []LogEntry
+	for _, e := range list {
+		if e.Time.After(since) {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+// trim remove entries older than maxAge.
+func (m *MemoryHistory) trim(list []LogEntry, now time.Time) []LogEntry {
+	if m.maxAge <= 0 {
+		return list
+	}
+	i := 0
+	for i < len(list) && now.Sub(list[i].Time) > m.maxAge {
+		i++
+	}
+	return list[i:]
+}
+
+// backfillRequest is the data of _backfill event.
+type backfillRequest struct {
+	Channel string    `json:"channel"`
+	Since   time.Time `json:"since"`
+}
+
+// backfillResult is the data of _backfill event sent after messages.
+type backfillResult struct {
+	Channel string `json:"channel"`
+	Count   int    `json:"count"`
+}
+
+// backfill handle _backfill event from client.
+func backfill(c *Conn, msg *Message) {
+	var req backfillRequest
+	err := json.Unmarshal(msg.Data, &req)
+	if err == nil {
+		err = c.checkChannel(req.Channel)
+	}
+
+	var ch *Channel
+	if err == nil {
+		ch = c.server.Channel(c.channelID(req.Channel))
+		if ch == nil || !ch.Has(c) {
+			err = ErrNotMember
+		}
+	}
+
+	var list []Message
+	if err == nil {
+		list, err = ch.History(c.Context(), req.Since)
+	}
+	if err != nil {
+		replyError(c, EventBackfill, req.Channel, err)
+		return
+	}
+
+	for _, m := range list {
+		if err = c.Emit(m.Name, json.RawMessage(m.Data)); err != nil {
+			return
+		}
+	}
+	_ = c.Emit(EventBackfill, backfillResult{Channel: req.Channel, Count: len(list)})
+}
@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMemoryHistory(t *testing.T) {
+	ctx := context.Background()
+	h := NewMemoryHistory(3, time.Minute)
+
+	now := time.Now()
+	require.NoError(t, h.Add(ctx, "a", LogEntry{Time: now.Add(-2 * time.Minute), Name: "old"}))
+	for i := 0; i < 4; i++ {
+		require.NoError(t, h.Add(ctx, "a", LogEntry{Time: now.Add(time.Duration(i) * time.Millisecond), Name: fmt.Sprint(i)}))
+	}
+	require.NoError(t, h.Add(ctx, "b", LogEntry{Time: now, Name: "b"}))
+
+	list, err := h.Since(ctx, "a", time.Time{})
+	require.NoError(t, err)
+	require.Len(t, list, 3, "history must be limited by count")
+	require.Equal(t, "1", list[0].Name)
+	require.Equal(t, int64(2), list[0].Offset)
+
+	list, err = h.Since(ctx, "a", now.Add(time.Millisecond))
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	h = NewMemoryHistory(0, time.Minute)
+	require.NoError(t, h.Add(ctx, "a", LogEntry{Time: now.Add(-2 * time.Minute)}))
+	list, err = h.Since(ctx, "a", time.Time{})
+	require.NoError(t, err)
+	require.Empty(t, list, "history must be limited by age")
+}
+
+func TestChannel_History(t *testing.T) {
+	sealer := NewSealer(StaticKey(bytes.Repeat([]byte("k"), 32)))
+	wsServer := New(WithStateEncryption(sealer))
+
+	ch := wsServer.NewChannel("room")
+	list, err := ch.History(context.Background(), time.Time{})
+	require.NoError(t, err)
+	require.Empty(t, list, "channel without history must return nothing")
+
+	ch.SetHistory(NewMemoryHistory(10, 0))
+	started := time.Now()
+	ch.Emit("a", 1)
+	ch.Emit("b", "two")
+
+	list, err = ch.History(context.Background(), started.Add(-time.Second))
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "b", list[1].Name)
+	require.Equal(t, `"two"`, string(list[1].Data))
+}
+
+func TestServer_backfill(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	ch := wsServer.NewChannel("room")
+	ch.SetHistory(NewMemoryHistory(10, time.Hour))
+	ch.Emit("news", 1)
+	ch.Emit("news", 2)
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, EventBackfill, map[string]any{"channel": "room"})
+	name, _ := readEnvelope(t, c)
+	require.Equal(t, EventError, name, "only members can backfill")
+
+	joinChannel(t, c, "room")
+	writeMessage(t, c, EventBackfill, map[string]any{"channel": "room", "since": time.Now().Add(-time.Hour)})
+	for i := 1; i <= 2; i++ {
+		name, data := readEnvelope(t, c)
+		require.Equal(t, "news", name)
+		require.Equal(t, fmt.Sprint(i), string(data))
+	}
+	name, data := readEnvelope(t, c)
+	require.Equal(t, EventBackfill, name)
+	require.JSONEq(t, `{"channel":"room","count":2}`, string(data))
+}
@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// idAttempts is how many ids are generated for connection before giving up on duplicates.
+const idAttempts = 8
+
+// ErrDuplicateID is returned when IDGenerator keeps generating ids of live connections.
+var ErrDuplicateID = errors.New("websocket: can't generate unique connection id")
+
+// IDGenerator generates connection ids. It's called concurrently.
+// Ids are checked against live connections, duplicate is replaced with the next generated id.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDFunc is a function which implements IDGenerator.
+type IDFunc func() string
+
+// NewID implements IDGenerator.
+func (f IDFunc) NewID() string {
+	return f()
+}
+
+// WithIDGenerator sets the generator of connection ids, e.g. to align them with tracing ids.
+// Default is UUIDv7.
+func WithIDGenerator(g IDGenerator) Option {
+	return func(s *Server) {
+		s.idGenerator = g
+	}
+}
+
+// UUIDv7 generates time-ordered UUIDs version 7 (RFC 9562): 48 bits of unix milliseconds,
+// 12 bits of counter for ids generated in the same millisecond and random bits.
+type UUIDv7 struct {
+	last int64
+	seq  uint16
+	mu   sync.Mutex
+}
+
+// NewID implements IDGenerator.
+func (g *UUIDv7) NewID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[6:])
+
+	g.mu.Lock()
+	ms := time.Now().UnixMilli()
+	if ms <= g.last {
+		// keep ids monotonic if clock goes back or counter overflows
+		ms = g.last
+		if g.seq++; g.seq > 0xfff {
+			ms++
+			g.seq = 0
+		}
+	} else {
+		g.seq = binary.BigEndian.Uint16(b[6:]) & 0x7ff
+	}
+	g.last = ms
+	seq := g.seq
+	g.mu.Unlock()
+
+	binary.BigEndian.PutUint64(b[:8], uint64(ms)<<16)
+	b[6] = 0x70 | byte(seq>>8)
+	b[7] = byte(seq)
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// register assigns unique id to the connection and adds it to live connections.
+func (s *Server) register(c *Conn) error {
+	for range idAttempts {
+		c.id = s.idGenerator.NewID()
+		if s.connections.add(c) {
+			return nil
+		}
+	}
+	return ErrDuplicateID
+}
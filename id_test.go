@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUUIDv7_NewID(t *testing.T) {
+	var g UUIDv7
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	ids := make([]string, 0, 10000)
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		id := g.NewID()
+		require.Regexp(t, re, id)
+		require.False(t, seen[id], "id must be unique")
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	require.True(t, sort.StringsAreSorted(ids), "ids must be time-ordered")
+}
+
+func TestServer_WithIDGenerator(t *testing.T) {
+	ids := make(chan string, 1)
+	ts, wsServer, shutdown := server(t, WithIDGenerator(IDFunc(func() string {
+		return "conn-1"
+	})))
+	defer shutdown()
+	wsServer.OnConnect(func(c Connection) {
+		ids <- c.ID()
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+	select {
+	case id := <-ids:
+		require.Equal(t, "conn-1", id)
+	case <-time.After(time.Second):
+		t.Fatal("connection must be connected")
+	}
+
+	u := url.URL{Scheme: "ws", Host: strings.TrimPrefix(ts.URL, "http://"), Path: "/ws"}
+	duplicate, br, _, err := ws.Dial(context.Background(), u.String())
+	require.NoError(t, err)
+	defer func() {
+		_ = duplicate.Close()
+	}()
+	require.NoError(t, duplicate.SetDeadline(time.Now().Add(3*time.Second)))
+	var r io.Reader = duplicate
+	if br != nil {
+		r = io.MultiReader(br, duplicate)
+	}
+	h, err := ws.ReadHeader(r)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpClose, h.OpCode)
+	require.Equal(t, 1, wsServer.Count())
+}
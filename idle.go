@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws"
+	"time"
+)
+
+// WithIdleTimeout closes connections which didn't send any data frame for d with 1001 (Going Away).
+// With control pings and pongs from client also count as activity, so only dead connections are closed.
+// Unlike WithReadTimeout it keeps closing abandoned clients (e.g. browser tabs) which answer pings.
+// Connections are checked every d/2, so connection could be idle up to 1.5*d.
+func WithIdleTimeout(d time.Duration, control bool) Option {
+	return func(s *Server) {
+		s.idleTimeout = d
+		s.idleControl = control
+	}
+}
+
+// OnIdleClose function which will be called before idle connection is closed (see WithIdleTimeout).
+func (s *Server) OnIdleClose(f func(c Connection)) {
+	s.mu.Lock()
+	s.onIdleClose = f
+	s.mu.Unlock()
+}
+
+// idle reports whether connection didn't send anything for d.
+func (c *Conn) idle(now time.Time, d time.Duration, control bool) bool {
+	last := c.created
+	received := c.stats.lastData.Load()
+	if control {
+		received = c.stats.lastReceived.Load()
+	}
+	if received != 0 {
+		last = time.Unix(0, received)
+	}
+	return now.Sub(last) > d
+}
+
+// reapIdle close idle connections until server stops.
+func (s *Server) reapIdle() {
+	ticker := time.NewTicker(s.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			idle := s.ConnectionsWhere(func(c *Conn) bool {
+				return c.idle(now, s.idleTimeout, s.idleControl)
+			})
+			if len(idle) == 0 {
+				continue
+			}
+
+			s.mu.RLock()
+			onIdleClose := s.onIdleClose
+			s.mu.RUnlock()
+
+			for _, c := range idle {
+				if onIdleClose != nil {
+					_ = s.safe(c, func() { onIdleClose(c) })
+				}
+				_ = c.closeWith(ws.StatusGoingAway, "idle timeout")
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
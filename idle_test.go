@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServer_WithIdleTimeout(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithIdleTimeout(100*time.Millisecond, false))
+	defer shutdown()
+	closed := make(chan string, 2)
+	wsServer.OnIdleClose(func(c Connection) {
+		closed <- c.ID()
+	})
+
+	pinging, active := dial(t, ts), dial(t, ts)
+	defer func() {
+		_ = pinging.Close()
+		_ = active.Close()
+	}()
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = wsutil.WriteClientMessage(pinging, ws.OpPing, nil)
+				writeMessage(t, active, "noop", nil)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	require.Equal(t, ws.StatusGoingAway, readIdleClose(t, pinging), "pings must not count as activity")
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("OnIdleClose must be called")
+	}
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 1
+	}, time.Second, 10*time.Millisecond, "active connection must stay")
+}
+
+func TestServer_WithIdleTimeout_control(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithIdleTimeout(100*time.Millisecond, true))
+	defer shutdown()
+
+	pinging, silent := dial(t, ts), dial(t, ts)
+	defer func() {
+		_ = pinging.Close()
+		_ = silent.Close()
+	}()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = wsutil.WriteClientMessage(pinging, ws.OpPing, nil)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	require.Equal(t, ws.StatusGoingAway, readIdleClose(t, silent))
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 1
+	}, time.Second, 10*time.Millisecond, "connection which sends pings must stay")
+}
+
+// readIdleClose skip pongs and return the code of close frame.
+func readIdleClose(t *testing.T, c net.Conn) ws.StatusCode {
+	for {
+		h, err := ws.ReadHeader(c)
+		require.NoError(t, err)
+		payload := make([]byte, h.Length)
+		_, err = io.ReadFull(c, payload)
+		require.NoError(t, err)
+		if h.OpCode == ws.OpClose {
+			code, _ := ws.ParseCloseFrameData(payload)
+			return code
+		}
+	}
+}
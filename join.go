@@ -0,0 +1,131 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+)
+
+// ErrNoChannel is returned when subscribe or unsubscribe event has no channel name.
+var ErrNoChannel = errors.New("websocket: channel name is required")
+
+// SubscribeFunc authorize joining the channel, returned error rejects the subscription.
+type SubscribeFunc func(ctx context.Context, c Connection, channel string) error
+
+// channelRequest is the data of _subscribe and _unsubscribe events.
+type channelRequest struct {
+	Channel string `json:"channel"`
+}
+
+// OnSubscribe sets the callback which authorize client subscriptions.
+// Client joins the channel with {"name": "_subscribe", "data": {"channel": "room-1"}}
+// and leaves it with _unsubscribe event. Channel is created if it doesn't exist and it's removed again
+// when its last connection leaves, so clients can't pile up empty channels.
+// Without callback all subscriptions are allowed.
+// On success server replies with the same event, on failure with _error event.
+// Error returned by callback or by authorizer of channel (see Channel.SetAuthorizer) is sent
+// with CodeForbidden unless it's *Error with own code.
+func (s *Server) OnSubscribe(f SubscribeFunc) {
+	s.mu.Lock()
+	s.onSubscribe = f
+	s.mu.Unlock()
+}
+
+// channelOrNew return the channel with id, new channel is created if it doesn't exist, created reports it.
+func (s *Server) channelOrNew(id string) (ch *Channel, created bool) {
+	s.mu.RLock()
+	ch = s.channels[id]
+	s.mu.RUnlock()
+	if ch != nil {
+		return ch, false
+	}
+
+	s.mu.Lock()
+	if ch = s.channels[id]; ch == nil {
+		ch = newChannel(id)
+		ch.server = s
+		ch.auto = true
+		s.channels[id] = ch
+		created = true
+	}
+	s.mu.Unlock()
+
+	if created {
+		s.observe(Event{Type: ChannelCreated, Channel: id})
+	}
+	return ch, created
+}
+
+// join add connection to the channel with id. Channel created for rejected connection is closed again,
+// so rejected subscriptions don't leave empty channels behind.
+func (s *Server) join(c *Conn, id string) error {
+	for {
+		ch, created := s.channelOrNew(id)
+		err := ch.Add(c)
+		if err == nil {
+			return nil
+		}
+		if created {
+			ch.close(ch.empty)
+			return err
+		}
+		// channel was closed after it was found, the next one is created for this connection
+		if !errors.Is(err, ErrChannelClosed) {
+			return err
+		}
+	}
+}
+
+// subscribe join connection to the channel from client request.
+func subscribe(c *Conn, msg *Message) {
+	s := c.server
+
+	var req channelRequest
+	err := json.Unmarshal(msg.Data, &req)
+	if err == nil {
+		err = c.checkChannel(req.Channel)
+	}
+	if err == nil {
+		s.mu.RLock()
+		onSubscribe := s.onSubscribe
+		s.mu.RUnlock()
+		if ns := c.namespace; ns != nil {
+			ns.mu.RLock()
+			onSubscribe = ns.onSubscribe
+			ns.mu.RUnlock()
+		}
+
+		if onSubscribe != nil {
+			err = forbidden(onSubscribe(c.Context(), c, req.Channel))
+		}
+	}
+	if err == nil {
+		err = forbidden(s.join(c, c.channelID(req.Channel)))
+	}
+	if err != nil {
+		log.Printf("websocket: subscribe %s to %q rejected: %v", c.ID(), req.Channel, err)
+		replyError(c, EventSubscribe, req.Channel, err)
+		return
+	}
+
+	_ = c.Emit(EventSubscribe, req)
+}
+
+// unsubscribe remove connection from the channel from client request.
+func unsubscribe(c *Conn, msg *Message) {
+	var req channelRequest
+	err := json.Unmarshal(msg.Data, &req)
+	if err == nil {
+		err = c.checkChannel(req.Channel)
+	}
+	if err != nil {
+		replyError(c, EventUnsubscribe, req.Channel, err)
+		return
+	}
+
+	if ch := c.server.Channel(c.channelID(req.Channel)); ch != nil {
+		ch.Remove(c)
+	}
+	_ = c.Emit(EventUnsubscribe, req)
+}
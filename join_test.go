@@ -0,0 +1,170 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestServer_subscribe(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, EventSubscribe, map[string]string{"channel": "room-1"})
+
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"_subscribe","data":{"channel":"room-1"}}`, string(b))
+
+	ch := wsServer.Channel("room-1")
+	require.NotNil(t, ch, "channel must be created")
+	require.Equal(t, 1, ch.Count())
+
+	writeMessage(t, c, EventUnsubscribe, map[string]string{"channel": "room-1"})
+
+	b, _, err = wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"_unsubscribe","data":{"channel":"room-1"}}`, string(b))
+	require.Equal(t, 0, ch.Count())
+}
+
+func TestServer_subscribe_existing(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	ch := wsServer.NewChannel("room-1")
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, EventSubscribe, map[string]string{"channel": "room-1"})
+
+	_, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, ch, wsServer.Channel("room-1"))
+	require.Equal(t, 1, ch.Count())
+
+	ch.Emit("hello", "world")
+
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"hello","data":"world"}`, string(b))
+}
+
+func TestServer_OnSubscribe(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	wsServer.OnSubscribe(func(ctx context.Context, c Connection, channel string) error {
+		require.NoError(t, ctx.Err())
+		if channel == "private" {
+			return errors.New("forbidden")
+		}
+		return nil
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, EventSubscribe, map[string]string{"channel": "private"})
+
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"_error","data":{"event":"_subscribe","code":403,"channel":"private","message":"forbidden"}}`, string(b))
+	require.Nil(t, wsServer.Channel("private"), "rejected channel must not be created")
+
+	writeMessage(t, c, EventSubscribe, map[string]string{"channel": "public"})
+
+	b, _, err = wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"_subscribe","data":{"channel":"public"}}`, string(b))
+}
+
+func TestServer_subscribe_noChannel(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, EventSubscribe, map[string]string{})
+
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"_error","data":{"event":"_subscribe","code":400,"message":"websocket: channel name is required"}}`, string(b))
+	require.Empty(t, wsServer.Channels())
+}
+
+func TestServer_subscribe_disconnect(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	writeMessage(t, c, EventSubscribe, map[string]string{"channel": "room-1"})
+	_, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+
+	ch := wsServer.Channel("room-1")
+	require.Eventually(t, func() bool {
+		return len(ch.snapshot()) == 0
+	}, time.Second, 10*time.Millisecond, "closed connection must leave auto-created channel")
+}
+
+func TestServer_subscribe_rejectedByAuthorizer(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithChannelAuthorizer(func(ctx context.Context, ch *Channel, c Connection) error {
+		return errors.New("forbidden")
+	}))
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, EventSubscribe, map[string]string{"channel": "private"})
+	name, _ := readEnvelope(t, c)
+	require.Equal(t, EventError, name)
+	require.Nil(t, wsServer.Channel("private"), "channel created for rejected connection must be removed")
+}
+
+func TestServer_subscribe_removeEmpty(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	for _, id := range []string{"a", "b", "c"} {
+		writeMessage(t, c, EventSubscribe, map[string]string{"channel": id})
+		name, _ := readEnvelope(t, c)
+		require.Equal(t, EventSubscribe, name)
+		writeMessage(t, c, EventUnsubscribe, map[string]string{"channel": id})
+		name, _ = readEnvelope(t, c)
+		require.Equal(t, EventUnsubscribe, name)
+		require.Nil(t, wsServer.Channel(id), "channel created by subscription must be removed when it's empty")
+	}
+
+	ch := wsServer.NewChannel("kept")
+	writeMessage(t, c, EventSubscribe, map[string]string{"channel": "kept"})
+	_, _ = readEnvelope(t, c)
+	writeMessage(t, c, EventUnsubscribe, map[string]string{"channel": "kept"})
+	_, _ = readEnvelope(t, c)
+	require.Equal(t, ch, wsServer.Channel("kept"), "channel created by application must stay")
+}
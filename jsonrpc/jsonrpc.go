@@ -0,0 +1,230 @@
+// Package jsonrpc implements JSON-RPC 2.0 over websocket.Server.
+//
+// Methods are registered with typed params and result:
+//
+//	srv := websocket.New()
+//	rpc := jsonrpc.New(srv)
+//	jsonrpc.Register(rpc, "sum", func(ctx context.Context, c websocket.Connection, params []int) (int, error) {
+//		sum := 0
+//		for _, n := range params {
+//			sum += n
+//		}
+//		return sum, nil
+//	})
+//
+// Requests, notifications and batches are supported, every request (or batch) is handled in its own goroutine,
+// so responses could come in different order. Server must be used for JSON-RPC only, New replaces OnMessage of the server.
+// See https://www.jsonrpc.org/specification
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/pkgz/websocket"
+	"sync"
+)
+
+// Version is the protocol version sent in every message.
+const Version = "2.0"
+
+// Error codes defined by the specification.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Error is JSON-RPC error object. Return it from method to send the code and data to client,
+// other errors are sent with CodeInternalError.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// MethodFunc handle the call with raw params and return the result.
+type MethodFunc func(ctx context.Context, c websocket.Connection, params json.RawMessage) (any, error)
+
+// Server dispatches JSON-RPC calls of websocket connections to methods.
+type Server struct {
+	methods map[string]MethodFunc
+	mu      sync.RWMutex
+}
+
+// request is a call or notification from client.
+type request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// response is the result or error of the call.
+type response struct {
+	Version string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// notification is sent by server to client.
+type notification struct {
+	Version string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+var null = json.RawMessage("null")
+
+// New serve JSON-RPC on the server.
+func New(srv *websocket.Server) *Server {
+	s := &Server{methods: make(map[string]MethodFunc)}
+	srv.OnMessage(func(c websocket.Connection, _ ws.Header, b []byte) {
+		// the byte slice is reused after return
+		data := bytes.Clone(b)
+		go s.serve(c, data)
+	})
+	return s
+}
+
+// Handle register method with raw params.
+func (s *Server) Handle(method string, f MethodFunc) {
+	s.mu.Lock()
+	s.methods[method] = f
+	s.mu.Unlock()
+}
+
+// Register method with params decoded to P. Params which can't be decoded are rejected with CodeInvalidParams.
+func Register[P, R any](s *Server, method string, f func(ctx context.Context, c websocket.Connection, params P) (R, error)) {
+	s.Handle(method, func(ctx context.Context, c websocket.Connection, raw json.RawMessage) (any, error) {
+		var params P
+		if len(raw) != 0 {
+			if err := json.Unmarshal(raw, &params); err != nil {
+				return nil, &Error{Code: CodeInvalidParams, Message: "Invalid params", Data: err.Error()}
+			}
+		}
+		return f(ctx, c, params)
+	})
+}
+
+// Notify send notification to client.
+func (s *Server) Notify(c websocket.Connection, method string, params any) error {
+	return send(c, notification{Version: Version, Method: method, Params: params})
+}
+
+// serve handle one message which is a request or batch.
+func (s *Server) serve(c websocket.Connection, b []byte) {
+	b = bytes.TrimSpace(b)
+	if !json.Valid(b) {
+		_ = send(c, errorResponse(null, CodeParseError, "Parse error"))
+		return
+	}
+
+	if b[0] != '[' {
+		var req request
+		if err := json.Unmarshal(b, &req); err != nil {
+			_ = send(c, errorResponse(null, CodeInvalidRequest, "Invalid Request"))
+			return
+		}
+		if res := s.call(c.Context(), c, req); res != nil {
+			_ = send(c, res)
+		}
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(b, &batch); err != nil || len(batch) == 0 {
+		_ = send(c, errorResponse(null, CodeInvalidRequest, "Invalid Request"))
+		return
+	}
+
+	responses := make([]*response, 0, len(batch))
+	for _, raw := range batch {
+		var req request
+		if err := json.Unmarshal(raw, &req); err != nil {
+			responses = append(responses, errorResponse(null, CodeInvalidRequest, "Invalid Request"))
+			continue
+		}
+		if res := s.call(c.Context(), c, req); res != nil {
+			responses = append(responses, res)
+		}
+	}
+	// batch of notifications has no response
+	if len(responses) != 0 {
+		_ = send(c, responses)
+	}
+}
+
+// call the method, returns nil for notifications.
+func (s *Server) call(ctx context.Context, c websocket.Connection, req request) *response {
+	notification := len(req.ID) == 0
+	id := req.ID
+	if notification {
+		id = null
+	}
+
+	if req.Version != Version || req.Method == "" {
+		return errorResponse(id, CodeInvalidRequest, "Invalid Request")
+	}
+
+	s.mu.RLock()
+	f, ok := s.methods[req.Method]
+	s.mu.RUnlock()
+
+	var res *response
+	if !ok {
+		res = errorResponse(id, CodeMethodNotFound, "Method not found")
+	} else {
+		res = result(ctx, c, f, id, req.Params)
+	}
+
+	if notification {
+		return nil
+	}
+	return res
+}
+
+// result call the method and build the response, panic of method is returned as internal error.
+func result(ctx context.Context, c websocket.Connection, f MethodFunc, id, params json.RawMessage) (res *response) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = errorResponse(id, CodeInternalError, "Internal error")
+		}
+	}()
+
+	v, err := f(ctx, c, params)
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		return &response{Version: Version, Error: rpcErr, ID: id}
+	}
+	if v == nil {
+		v = null
+	}
+	return &response{Version: Version, Result: v, ID: id}
+}
+
+func errorResponse(id json.RawMessage, code int, message string) *response {
+	return &response{Version: Version, Error: &Error{Code: code, Message: message}, ID: id}
+}
+
+// send message in text frame.
+func send(c websocket.Connection, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Write(ws.Header{Fin: true, OpCode: ws.OpText, Length: int64(len(b))}, b)
+}
@@ -0,0 +1,119 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/pkgz/websocket"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func start(t *testing.T) (*Server, net.Conn) {
+	srv := websocket.New()
+	rpc := New(srv)
+	srv.Run(context.Background())
+
+	ts := httptest.NewServer(http.HandlerFunc(srv.Handler))
+	t.Cleanup(func() {
+		ts.Close()
+		_ = srv.Shutdown()
+	})
+
+	c, _, _, err := ws.Dial(context.Background(), "ws://"+strings.TrimPrefix(ts.URL, "http://"))
+	require.NoError(t, err)
+	require.NoError(t, c.SetDeadline(time.Now().Add(3*time.Second)))
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+
+	Register(rpc, "sum", func(_ context.Context, _ websocket.Connection, params []int) (int, error) {
+		sum := 0
+		for _, n := range params {
+			sum += n
+		}
+		return sum, nil
+	})
+	Register(rpc, "fail", func(context.Context, websocket.Connection, struct{}) (any, error) {
+		return nil, &Error{Code: 42, Message: "failed", Data: "details"}
+	})
+	Register(rpc, "broken", func(context.Context, websocket.Connection, struct{}) (any, error) {
+		return nil, errors.New("broken")
+	})
+	return rpc, c
+}
+
+func call(t *testing.T, c net.Conn, req string) string {
+	require.NoError(t, wsutil.WriteClientText(c, []byte(req)))
+	b, err := wsutil.ReadServerText(c)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestServer_call(t *testing.T) {
+	_, c := start(t)
+
+	tests := []struct {
+		name string
+		req  string
+		res  string
+	}{
+		{"result", `{"jsonrpc":"2.0","method":"sum","params":[1,2,3],"id":1}`, `{"jsonrpc":"2.0","result":6,"id":1}`},
+		{"string id", `{"jsonrpc":"2.0","method":"sum","params":[],"id":"a"}`, `{"jsonrpc":"2.0","result":0,"id":"a"}`},
+		{"error object", `{"jsonrpc":"2.0","method":"fail","id":2}`, `{"jsonrpc":"2.0","error":{"code":42,"message":"failed","data":"details"},"id":2}`},
+		{"internal error", `{"jsonrpc":"2.0","method":"broken","id":3}`, `{"jsonrpc":"2.0","error":{"code":-32603,"message":"broken"},"id":3}`},
+		{"invalid params", `{"jsonrpc":"2.0","method":"sum","params":{"a":1},"id":4}`, `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":"json: cannot unmarshal object into Go value of type []int"},"id":4}`},
+		{"method not found", `{"jsonrpc":"2.0","method":"foo","id":5}`, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":5}`},
+		{"parse error", `{"jsonrpc":"2.0","method":"foo`, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`},
+		{"invalid request", `{"jsonrpc":"2.0","method":1,"params":"bar"}`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`},
+		{"empty batch", `[]`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.JSONEq(t, tt.res, call(t, c, tt.req))
+		})
+	}
+}
+
+func TestServer_batch(t *testing.T) {
+	_, c := start(t)
+
+	res := call(t, c, `[
+		{"jsonrpc":"2.0","method":"sum","params":[1,2],"id":"1"},
+		{"jsonrpc":"2.0","method":"sum","params":[1]},
+		1,
+		{"jsonrpc":"2.0","method":"foo","id":"2"}
+	]`)
+	require.JSONEq(t, `[
+		{"jsonrpc":"2.0","result":3,"id":"1"},
+		{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null},
+		{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":"2"}
+	]`, res)
+}
+
+func TestServer_notification(t *testing.T) {
+	rpc, c := start(t)
+
+	called := make(chan websocket.Connection, 1)
+	Register(rpc, "notify", func(_ context.Context, c websocket.Connection, _ json.RawMessage) (any, error) {
+		called <- c
+		return nil, nil
+	})
+
+	require.NoError(t, wsutil.WriteClientText(c, []byte(`[{"jsonrpc":"2.0","method":"notify"},{"jsonrpc":"2.0","method":"notify"}]`)))
+	conn := <-called
+	<-called
+
+	require.NoError(t, rpc.Notify(conn, "update", []int{1}))
+	b, err := wsutil.ReadServerText(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc":"2.0","method":"update","params":[1]}`, string(b), "notifications must not have response")
+}
@@ -0,0 +1,309 @@
+// Package kafkaws bridges channels of websocket.Server and Kafka topics: messages emitted to channels
+// are produced to topics and messages consumed from topics are emitted to channels, so the server
+// becomes a thin real-time edge over the event backbone.
+//
+// Bridge doesn't depend on Kafka client, Producer and Consumer wrap a client of choice, e.g. segmentio/kafka-go:
+//
+//	b := kafkaws.New(srv, producer{writer}, consumer{reader},
+//		kafkaws.Outbound("chat.**", "chat-events"),
+//		kafkaws.Inbound("notifications", ""),
+//	)
+//	go b.Run(ctx)
+//
+// By default key of Kafka message is channel id and value is json {"name": "event", "data": {...}}, see Codec.
+// Messages consumed from Kafka are emitted as websocket.RawData, so they are not produced back.
+package kafkaws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/pkgz/websocket"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultQueueSize is the number of messages waiting to be produced, see WithQueueSize.
+const DefaultQueueSize = 1024
+
+// maxBatch is the max number of messages passed to one Produce call.
+const maxBatch = 100
+
+// Header is the header of Kafka message.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is Kafka message.
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers []Header
+}
+
+// Producer writes messages to Kafka.
+type Producer interface {
+	Produce(ctx context.Context, msgs ...Message) error
+}
+
+// Consumer reads messages of inbound topics. Messages are committed after they are emitted to channel.
+type Consumer interface {
+	Fetch(ctx context.Context) (Message, error)
+	Commit(ctx context.Context, msg Message) error
+}
+
+// Codec converts channel messages to Kafka messages and back. Decoded data is json of envelope data,
+// clients with binary envelope receive it as is. Topic of encoded message is set by bridge.
+type Codec interface {
+	Encode(channel, name string, data any) (Message, error)
+	Decode(msg Message) (channel, name string, data []byte, err error)
+}
+
+// JSONCodec is the default codec: key is channel id, value is {"name": "event", "data": {...}}.
+type JSONCodec struct{}
+
+// value is the value of message encoded with JSONCodec.
+type value struct {
+	Name string          `json:"name"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Encode implements Codec, []byte data is encoded as string.
+func (JSONCodec) Encode(channel, name string, data any) (Message, error) {
+	if b, ok := data.([]byte); ok {
+		data = string(b)
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return Message{}, err
+	}
+	v, err := json.Marshal(value{Name: name, Data: b})
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Key: []byte(channel), Value: v}, nil
+}
+
+// Decode implements Codec.
+func (JSONCodec) Decode(msg Message) (string, string, []byte, error) {
+	var v value
+	if err := json.Unmarshal(msg.Value, &v); err != nil {
+		return "", "", nil, err
+	}
+	if v.Name == "" {
+		return "", "", nil, errors.New("kafkaws: message without name")
+	}
+	return string(msg.Key), v.Name, v.Data, nil
+}
+
+// Option configures the Bridge.
+type Option func(b *Bridge)
+
+// Outbound produces messages of channels matching pattern (see websocket.MatchChannel) to topic.
+// Message of channel matching several patterns is produced to every topic.
+func Outbound(pattern, topic string) Option {
+	return func(b *Bridge) {
+		b.outbound = append(b.outbound, route{pattern: pattern, topic: topic})
+	}
+}
+
+// Inbound emits messages consumed from topic to channel. With empty channel it's the channel
+// returned by codec (key of message for JSONCodec). Messages of channels which don't exist are skipped.
+func Inbound(topic, channel string) Option {
+	return func(b *Bridge) {
+		b.inbound[topic] = channel
+	}
+}
+
+// WithCodec sets the codec of messages, default is JSONCodec.
+func WithCodec(c Codec) Option {
+	return func(b *Bridge) {
+		b.codec = c
+	}
+}
+
+// WithQueueSize sets the number of messages waiting to be produced, default is DefaultQueueSize.
+// Messages emitted when the queue is full are dropped (see Dropped), so slow Kafka doesn't block channels.
+func WithQueueSize(n int) Option {
+	return func(b *Bridge) {
+		b.queueSize = n
+	}
+}
+
+// Bridge mirrors channel messages to Kafka and Kafka messages to channels.
+type Bridge struct {
+	srv      *websocket.Server
+	producer Producer
+	consumer Consumer
+	codec    Codec
+
+	outbound  []route
+	inbound   map[string]string
+	queueSize int
+	queue     chan Message
+	dropped   atomic.Int64
+}
+
+// route maps channels to topic.
+type route struct {
+	pattern string
+	topic   string
+}
+
+// New makes bridge of the server. Producer is nil for inbound only bridge and consumer is nil for outbound only.
+func New(srv *websocket.Server, producer Producer, consumer Consumer, opts ...Option) *Bridge {
+	b := &Bridge{
+		srv:       srv,
+		producer:  producer,
+		consumer:  consumer,
+		codec:     JSONCodec{},
+		inbound:   make(map[string]string),
+		queueSize: DefaultQueueSize,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.queue = make(chan Message, b.queueSize)
+
+	if producer != nil && len(b.outbound) != 0 {
+		srv.OnChannelEmit(b.Mirror)
+	}
+	return b
+}
+
+// Mirror queue message of channel to be produced to topics of matching outbound routes.
+// New adds it to OnChannelEmit hooks of the server.
+func (b *Bridge) Mirror(ch *websocket.Channel, name string, data any) {
+	if _, ok := data.(websocket.RawData); ok {
+		return
+	}
+
+	var (
+		msg     Message
+		encoded bool
+	)
+	for _, r := range b.outbound {
+		if !websocket.MatchChannel(r.pattern, ch.ID()) {
+			continue
+		}
+		if !encoded {
+			var err error
+			if msg, err = b.codec.Encode(ch.ID(), name, data); err != nil {
+				log.Printf("kafkaws: encode message of channel %q: %v", ch.ID(), err)
+				return
+			}
+			encoded = true
+		}
+
+		msg.Topic = r.topic
+		select {
+		case b.queue <- msg:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped return number of messages dropped because the queue was full.
+func (b *Bridge) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// Run produces queued messages and consumes inbound topics until ctx is done. It returns the error
+// of Consumer.Fetch, errors of Produce and Commit are logged. Run could be restarted after error.
+func (b *Bridge) Run(ctx context.Context) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	if b.producer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.produce(ctx)
+		}()
+	}
+
+	var err error
+	if b.consumer != nil {
+		err = b.consume(ctx)
+	} else {
+		<-ctx.Done()
+	}
+	cancel()
+	wg.Wait()
+
+	if parent.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// produce write queued messages in batches until ctx is done.
+func (b *Bridge) produce(ctx context.Context) {
+	batch := make([]Message, 0, maxBatch)
+	for {
+		select {
+		case msg := <-b.queue:
+			batch = append(batch[:0], msg)
+		case <-ctx.Done():
+			return
+		}
+	fill:
+		for len(batch) < maxBatch {
+			select {
+			case msg := <-b.queue:
+				batch = append(batch, msg)
+			default:
+				break fill
+			}
+		}
+
+		if err := b.producer.Produce(ctx, batch...); err != nil {
+			log.Printf("kafkaws: produce %d messages: %v", len(batch), err)
+		}
+	}
+}
+
+// consume emit messages of inbound topics to channels until ctx is done or Fetch fails.
+func (b *Bridge) consume(ctx context.Context) error {
+	for {
+		msg, err := b.consumer.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+		b.emit(msg)
+		if err = b.consumer.Commit(ctx, msg); err != nil {
+			log.Printf("kafkaws: commit message of topic %q: %v", msg.Topic, err)
+		}
+	}
+}
+
+// emit decoded message to the channel of inbound route.
+func (b *Bridge) emit(msg Message) {
+	id, ok := b.inbound[msg.Topic]
+	if !ok {
+		return
+	}
+
+	channel, name, data, err := b.codec.Decode(msg)
+	if err != nil {
+		log.Printf("kafkaws: decode message of topic %q: %v", msg.Topic, err)
+		return
+	}
+	if id == "" {
+		id = channel
+	}
+
+	ch := b.srv.Channel(id)
+	if ch == nil {
+		return
+	}
+	if err = ch.Enqueue(name, websocket.RawData(data)); err != nil {
+		log.Printf("kafkaws: emit message of topic %q to channel %q: %v", msg.Topic, id, err)
+	}
+}
@@ -0,0 +1,143 @@
+package kafkaws
+
+import (
+	"context"
+	"errors"
+	"github.com/pkgz/websocket"
+	"github.com/pkgz/websocket/websockettest"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKafka is in-memory producer and consumer.
+type fakeKafka struct {
+	mu        sync.Mutex
+	produced  []Message
+	committed []Message
+	fetch     chan Message
+}
+
+func newFakeKafka() *fakeKafka {
+	return &fakeKafka{fetch: make(chan Message, 10)}
+}
+
+func (k *fakeKafka) Produce(_ context.Context, msgs ...Message) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.produced = append(k.produced, msgs...)
+	return nil
+}
+
+func (k *fakeKafka) Fetch(ctx context.Context) (Message, error) {
+	select {
+	case msg := <-k.fetch:
+		return msg, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+func (k *fakeKafka) Commit(_ context.Context, msg Message) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.committed = append(k.committed, msg)
+	return nil
+}
+
+func (k *fakeKafka) Produced() []Message {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]Message(nil), k.produced...)
+}
+
+func TestBridge(t *testing.T) {
+	srv := websocket.Start(context.Background())
+	defer func() {
+		require.NoError(t, srv.Shutdown())
+	}()
+	kafka := newFakeKafka()
+	b := New(srv, kafka, kafka,
+		Outbound("chat.**", "chat-events"),
+		Outbound("chat.support", "support"),
+		Inbound("notifications", ""),
+		Inbound("alerts", "chat.general"),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Run(ctx)
+	}()
+
+	conn, client := websockettest.NewPair(t, srv)
+	general := srv.NewChannel("chat.general")
+	require.NoError(t, general.Add(conn))
+	srv.NewChannel("chat.support").Emit("ticket", map[string]int{"id": 1})
+	srv.NewChannel("lobby").Emit("ignored", nil)
+
+	require.Eventually(t, func() bool { return len(kafka.Produced()) == 2 }, time.Second, 10*time.Millisecond)
+	produced := kafka.Produced()
+	require.Equal(t, "chat-events", produced[0].Topic)
+	require.Equal(t, "support", produced[1].Topic)
+	require.Equal(t, "chat.support", string(produced[0].Key))
+	require.JSONEq(t, `{"name":"ticket","data":{"id":1}}`, string(produced[0].Value))
+
+	kafka.fetch <- Message{Topic: "notifications", Key: []byte("chat.general"), Value: []byte(`{"name":"notice","data":"hi"}`)}
+	client.ExpectJSON(t, "notice", "hi")
+	kafka.fetch <- Message{Topic: "alerts", Value: []byte(`{"name":"alert","data":{"level":2}}`)}
+	client.ExpectJSON(t, "alert", map[string]int{"level": 2})
+	kafka.fetch <- Message{Topic: "notifications", Key: []byte("unknown"), Value: []byte(`{"name":"lost"}`)}
+	kafka.fetch <- Message{Topic: "notifications", Value: []byte(`not json`)}
+
+	require.Eventually(t, func() bool {
+		kafka.mu.Lock()
+		defer kafka.mu.Unlock()
+		return len(kafka.committed) == 4
+	}, time.Second, 10*time.Millisecond, "skipped messages must be committed too")
+	require.Len(t, kafka.Produced(), 2, "consumed messages must not be produced back")
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestBridge_queueFull(t *testing.T) {
+	srv := websocket.New()
+	b := New(srv, newFakeKafka(), nil, Outbound("**", "all"), WithQueueSize(1))
+
+	ch := srv.NewChannel("a")
+	ch.Emit("one", 1)
+	ch.Emit("two", 2)
+	require.Equal(t, int64(1), b.Dropped())
+}
+
+type failingConsumer struct{}
+
+func (failingConsumer) Fetch(context.Context) (Message, error) {
+	return Message{}, errors.New("broker is down")
+}
+
+func (failingConsumer) Commit(context.Context, Message) error {
+	return nil
+}
+
+func TestBridge_Run_error(t *testing.T) {
+	b := New(websocket.New(), newFakeKafka(), failingConsumer{})
+	require.EqualError(t, b.Run(context.Background()), "broker is down")
+}
+
+func TestJSONCodec(t *testing.T) {
+	msg, err := JSONCodec{}.Encode("room", "bytes", []byte("raw"))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"bytes","data":"raw"}`, string(msg.Value))
+
+	channel, name, data, err := JSONCodec{}.Decode(msg)
+	require.NoError(t, err)
+	require.Equal(t, "room", channel)
+	require.Equal(t, "bytes", name)
+	require.Equal(t, `"raw"`, string(data))
+
+	_, _, _, err = JSONCodec{}.Decode(Message{Value: []byte(`{"data":1}`)})
+	require.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrTooManyConnections is returned when server reached the limit set by WithMaxConnections.
+	ErrTooManyConnections = errors.New("websocket: too many connections")
+	// ErrTooManyConnectionsPerIP is returned when client reached the limit set by WithMaxConnectionsPerIP.
+	ErrTooManyConnectionsPerIP = errors.New("websocket: too many connections from address")
+)
+
+// WithMaxConnections limits the number of connections, new upgrades are rejected with 503.
+func WithMaxConnections(n int) Option {
+	return func(s *Server) {
+		s.maxConnections = int64(n)
+	}
+}
+
+// WithMaxConnectionsPerIP limits the number of concurrent connections from one address,
+// new upgrades are rejected with 429.
+func WithMaxConnectionsPerIP(n int) Option {
+	return func(s *Server) {
+		s.maxConnectionsPerIP = n
+	}
+}
+
+// reserve the place for connection from ip, it must be released by release.
+func (s *Server) reserve(ip string) (int, error) {
+	if s.Banned(ip) {
+		return http.StatusForbidden, ErrBanned
+	}
+
+	if n := s.accepted.Add(1); s.maxConnections > 0 && n > s.maxConnections {
+		s.accepted.Add(-1)
+		return http.StatusServiceUnavailable, ErrTooManyConnections
+	}
+
+	if s.maxConnectionsPerIP > 0 {
+		s.perIPMu.Lock()
+		defer s.perIPMu.Unlock()
+
+		if s.perIP[ip] >= s.maxConnectionsPerIP {
+			s.accepted.Add(-1)
+			return http.StatusTooManyRequests, ErrTooManyConnectionsPerIP
+		}
+		if s.perIP == nil {
+			s.perIP = make(map[string]int)
+		}
+		s.perIP[ip]++
+	}
+
+	return 0, nil
+}
+
+// release the place of connection from ip.
+func (s *Server) release(ip string) {
+	s.accepted.Add(-1)
+
+	if s.maxConnectionsPerIP > 0 {
+		s.perIPMu.Lock()
+		if s.perIP[ip]--; s.perIP[ip] <= 0 {
+			delete(s.perIP, ip)
+		}
+		s.perIPMu.Unlock()
+	}
+}
@@ -0,0 +1,55 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_WithMaxConnections(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithMaxConnections(1))
+	defer shutdown()
+
+	c := dial(t, ts)
+
+	u := url.URL{Scheme: "ws", Host: strings.Replace(ts.URL, "http://", "", 1), Path: "/ws"}
+	_, _, _, err := ws.Dial(context.Background(), u.String())
+	require.Error(t, err)
+	var status ws.StatusError
+	require.ErrorAs(t, err, &status)
+	require.Equal(t, http.StatusServiceUnavailable, int(status))
+
+	require.NoError(t, c.Close())
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 0
+	}, time.Second, 10*time.Millisecond)
+
+	c = dial(t, ts)
+	require.NoError(t, c.Close())
+}
+
+func TestServer_WithMaxConnectionsPerIP(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithMaxConnectionsPerIP(2))
+	defer shutdown()
+
+	c1, c2 := dial(t, ts), dial(t, ts)
+
+	u := url.URL{Scheme: "ws", Host: strings.Replace(ts.URL, "http://", "", 1), Path: "/ws"}
+	_, _, _, err := ws.Dial(context.Background(), u.String())
+	var status ws.StatusError
+	require.ErrorAs(t, err, &status)
+	require.Equal(t, http.StatusTooManyRequests, int(status))
+
+	require.NoError(t, c1.Close())
+	require.NoError(t, c2.Close())
+	require.Eventually(t, func() bool {
+		wsServer.perIPMu.Lock()
+		defer wsServer.perIPMu.Unlock()
+		return len(wsServer.perIP) == 0
+	}, time.Second, 10*time.Millisecond, "closed connections must release the address")
+}
@@ -0,0 +1,258 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/gobwas/httphead"
+	"github.com/gobwas/ws"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultPath is the path of websocket handler used by ListenAndServe when WithPath is not set.
+const DefaultPath = "/ws"
+
+// Timeouts of http server started by ListenAndServe. Read and write timeouts are not set,
+// they would break hijacked connections, see WithReadTimeout and WithWriteTimeout instead.
+// DefaultReadHeaderTimeout also limits the handshake of ServeListener, see WithHandshakeTimeout.
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultIdleTimeout       = 60 * time.Second
+)
+
+// WithPath sets the path of websocket handler used by ListenAndServe, ListenAndServeTLS and Serve.
+func WithPath(path string) Option {
+	return func(s *Server) {
+		s.path = path
+	}
+}
+
+// ListenAndServe listens on the TCP address and serves websocket connections at the path (see WithPath).
+// Server is started if it's not running. It blocks until Shutdown and returns ErrServerClosed after it.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// ListenAndServeTLS is the same as ListenAndServe, but serves TLS connections with certificate and key files.
+func (s *Server) ListenAndServeTLS(addr, certFile, keyFile string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	srv := s.httpServer()
+	if err := s.track(srv); err != nil {
+		_ = l.Close()
+		return err
+	}
+	return serveErr(srv.ServeTLS(s.listen(l), certFile, keyFile))
+}
+
+// Serve accepts connections on the listener and serves websocket connections at the path (see WithPath).
+// Listener is closed when Serve returns.
+func (s *Server) Serve(l net.Listener) error {
+	srv := s.httpServer()
+	if err := s.track(srv); err != nil {
+		_ = l.Close()
+		return err
+	}
+	return serveErr(srv.Serve(s.listen(l)))
+}
+
+// ServeListener accepts connections on the listener and makes websocket handshake on them directly,
+// without net/http. Any stream listener works, e.g. unix socket for IPC with sidecar.
+// Url params and namespace are taken from request uri, path is not checked.
+// Server is started if it's not running. It blocks until Shutdown and returns ErrServerClosed after it.
+func (s *Server) ServeListener(l net.Listener) error {
+	if err := s.track(l); err != nil {
+		_ = l.Close()
+		return err
+	}
+	l = s.listen(l)
+
+	var delay time.Duration
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.IsClosed() {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+				log.Printf("websocket: accept error %v, retrying in %v", err, delay)
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+
+		spawn(&s.goroutines.background, func() { s.handshake(conn) })
+	}
+}
+
+// handshake upgrade raw connection to websocket and serve it.
+func (s *Server) handshake(conn net.Conn) {
+	var (
+		ns       *Namespace
+		n        negotiated
+		reserved bool
+		// size is the running total of headerSize, so it's not counted again on every header
+		size int
+	)
+	var ip string
+	r := &http.Request{
+		Method:     http.MethodGet,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		RemoteAddr: conn.RemoteAddr().String(),
+	}
+
+	u := ws.Upgrader{
+		OnRequest: func(uri []byte) error {
+			if s.draining.Load() {
+				return reject(http.StatusServiceUnavailable, ErrDraining)
+			}
+
+			var err error
+			if r.URL, err = url.ParseRequestURI(string(uri)); err != nil {
+				return reject(http.StatusBadRequest, err)
+			}
+			r.RequestURI = string(uri)
+			size = len(uri)
+			if size > s.headerLimit() {
+				return reject(http.StatusRequestHeaderFieldsTooLarge, ErrHeaderTooLarge)
+			}
+
+			if ns, err = s.namespace(r.URL.Query().Get(NamespaceParam)); err != nil {
+				return reject(http.StatusNotFound, err)
+			}
+			return nil
+		},
+		OnHost: func(host []byte) error {
+			r.Host = string(host)
+			size += len(host)
+			if size > s.headerLimit() {
+				return reject(http.StatusRequestHeaderFieldsTooLarge, ErrHeaderTooLarge)
+			}
+			return nil
+		},
+		OnHeader: func(key, value []byte) error {
+			r.Header.Add(string(key), string(value))
+			size += len(key) + len(value) + 4 // ": " and "\r\n"
+			if size > s.headerLimit() {
+				return reject(http.StatusRequestHeaderFieldsTooLarge, ErrHeaderTooLarge)
+			}
+			return nil
+		},
+		// subprotocol and extensions are selected in OnBeforeUpgrade, after all headers are read
+		ProtocolCustom: func(v []byte) (string, bool) {
+			r.Header.Add(headerProtocol, string(v))
+			return "", true
+		},
+		Negotiate: func(opt httphead.Option) (httphead.Option, error) {
+			var b bytes.Buffer
+			_, _ = httphead.WriteOptions(&b, []httphead.Option{opt})
+			r.Header.Add(headerExtensions, b.String())
+			return httphead.Option{}, nil
+		},
+		OnBeforeUpgrade: func() (ws.HandshakeHeader, error) {
+			r.TLS = connectionState(conn)
+			h, result, err := s.negotiate(r)
+			if err != nil {
+				return nil, reject(rejectCode(err), err)
+			}
+			if result.protocol != "" {
+				h.Set(headerProtocol, result.protocol)
+			}
+
+			ip = s.clientIP(r.RemoteAddr, r.Header)
+			code, err := s.reserve(ip)
+			if err != nil {
+				return nil, reject(code, err)
+			}
+			reserved, n = true, result
+			return ws.HandshakeHeaderHTTP(h), nil
+		},
+	}
+
+	_ = conn.SetDeadline(s.handshakeDeadline(time.Now()))
+	if _, err := u.Upgrade(conn); err != nil {
+		if reserved {
+			s.release(ip)
+		}
+		log.Printf("websocket: upgrade error %v", err)
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	var params url.Values
+	if r.URL.RawQuery != "" {
+		params = r.URL.Query()
+	}
+	s.serve(conn, params, ns, ip, n)
+}
+
+// reject return handshake error with http status.
+func reject(code int, err error) error {
+	return ws.RejectConnectionError(ws.RejectionStatus(code), ws.RejectionReason(err.Error()))
+}
+
+// httpServer create http server with websocket handler at the path.
+func (s *Server) httpServer() *http.Server {
+	path := s.path
+	if path == "" {
+		path = DefaultPath
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, s.Handler)
+
+	readHeaderTimeout := DefaultReadHeaderTimeout
+	if s.handshakeTimeout > 0 {
+		readHeaderTimeout = s.handshakeTimeout
+	}
+	return &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       DefaultIdleTimeout,
+		MaxHeaderBytes:    s.headerLimit(),
+		TLSConfig:         s.tlsConfig.Clone(),
+	}
+}
+
+// track starts the server if it's not running and keeps the listener to close it on Shutdown.
+func (s *Server) track(l io.Closer) error {
+	if s.IsClosed() {
+		return ErrServerClosed
+	}
+	s.Run(context.Background())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return ErrServerClosed
+	}
+	s.listeners = append(s.listeners, l)
+	return nil
+}
+
+// serveErr replace http.ErrServerClosed with ErrServerClosed.
+func serveErr(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return ErrServerClosed
+	}
+	return err
+}
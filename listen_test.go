@@ -0,0 +1,124 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServer_Serve(t *testing.T) {
+	wsServer := New(WithPath("/chat"))
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		require.NoError(t, c.Emit("echo", msg.Data))
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() {
+		served <- wsServer.Serve(l)
+	}()
+
+	resp, err := http.Get("http://" + l.Addr().String() + DefaultPath)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	c, _, _, err := ws.Dial(context.Background(), "ws://"+l.Addr().String()+"/chat")
+	require.NoError(t, err)
+	require.NoError(t, c.SetDeadline(time.Now().Add(3*time.Second)))
+	defer func() {
+		_ = c.Close()
+	}()
+
+	writeMessage(t, c, "echo", "listen")
+	data, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"echo","data":"listen"}`, string(data))
+
+	require.NoError(t, wsServer.Shutdown())
+	select {
+	case err := <-served:
+		require.ErrorIs(t, err, ErrServerClosed)
+	case <-time.After(time.Second):
+		t.Fatal("Serve must return after Shutdown")
+	}
+
+	l, err = net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.ErrorIs(t, wsServer.Serve(l), ErrServerClosed)
+}
+
+func TestServer_ListenAndServe(t *testing.T) {
+	wsServer := New()
+	require.Error(t, wsServer.ListenAndServe("127.0.0.1:-1"))
+	require.Error(t, wsServer.ListenAndServeTLS("127.0.0.1:0", "missing.crt", "missing.key"))
+	require.NoError(t, wsServer.Shutdown())
+}
+
+func TestServer_ServeListener(t *testing.T) {
+	wsServer := New()
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		require.NoError(t, c.Emit("echo", c.Param("room")))
+	})
+	wsServer.Of("/chat")
+	wsServer.OnUpgrade(func(r *http.Request, h http.Header) error {
+		require.Equal(t, "localhost", r.Host)
+		h.Set("X-Room", r.URL.Query().Get("room"))
+		return nil
+	})
+
+	path := filepath.Join(t.TempDir(), "ws.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() {
+		served <- wsServer.ServeListener(l)
+	}()
+
+	headers := make(http.Header)
+	d := ws.Dialer{
+		Protocols: []string{ProtocolJSON},
+		OnHeader: func(key, value []byte) error {
+			headers.Add(string(key), string(value))
+			return nil
+		},
+		NetDial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var nd net.Dialer
+			return nd.DialContext(ctx, "unix", path)
+		},
+	}
+
+	_, _, _, err = d.Dial(context.Background(), "ws://localhost/ws?namespace=/unknown")
+	var status ws.StatusError
+	require.ErrorAs(t, err, &status)
+	require.Equal(t, ws.StatusError(http.StatusNotFound), status)
+
+	c, _, hs, err := d.Dial(context.Background(), "ws://localhost/ws?room=1")
+	require.NoError(t, err)
+	require.Equal(t, ProtocolJSON, hs.Protocol)
+	require.Equal(t, "1", headers.Get("X-Room"))
+	require.NoError(t, c.SetDeadline(time.Now().Add(3*time.Second)))
+	defer func() {
+		_ = c.Close()
+	}()
+
+	writeMessage(t, c, "echo", nil)
+	data, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"echo","data":"1"}`, string(data))
+
+	require.NoError(t, wsServer.Shutdown())
+	select {
+	case err := <-served:
+		require.ErrorIs(t, err, ErrServerClosed)
+	case <-time.After(time.Second):
+		t.Fatal("ServeListener must return after Shutdown")
+	}
+}
@@ -0,0 +1,69 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws"
+	"math/rand/v2"
+	"time"
+)
+
+// DefaultMaxAgeGrace is the default time client has to reconnect after the hint of WithMaxConnectionAge.
+const DefaultMaxAgeGrace = 5 * time.Second
+
+// WithMaxConnectionAge limits the lifetime of connections, so clients rebalance across nodes
+// and pick up new TLS certificates. After d plus random part of jitter connection receives the
+// drain event (see WithDrainEvent) and is closed with 1001 (Going Away) after grace
+// (DefaultMaxAgeGrace if grace <= 0). Jitter spreads reconnects of clients connected at the same time.
+func WithMaxConnectionAge(d, jitter, grace time.Duration) Option {
+	return func(s *Server) {
+		if grace <= 0 {
+			grace = DefaultMaxAgeGrace
+		}
+		s.maxAge = d
+		s.maxAgeJitter = jitter
+		s.maxAgeGrace = grace
+	}
+}
+
+// scheduleMaxAge starts the lifetime timer of connection. It's skipped if server is not running.
+func (s *Server) scheduleMaxAge(c *Conn) {
+	if s.maxAge <= 0 {
+		return
+	}
+
+	age := s.maxAge
+	if s.maxAgeJitter > 0 {
+		age += rand.N(s.maxAgeJitter)
+	}
+
+	t, err := s.schedule(age, func() {
+		s.mu.RLock()
+		name, data := s.drainEvent, s.drainData
+		s.mu.RUnlock()
+		_ = c.Emit(name, data)
+
+		t, err := s.schedule(s.maxAgeGrace, func() {
+			_ = c.closeWith(ws.StatusGoingAway, "max connection age")
+		})
+		if err == nil {
+			c.setAgeTimer(t)
+		}
+	})
+	if err == nil {
+		c.setAgeTimer(t)
+	}
+}
+
+// setAgeTimer keeps the timer of connection lifetime, so it's stopped when connection is dropped.
+func (c *Conn) setAgeTimer(t *Timer) {
+	c.ageTimer.Store(t)
+	if c.dropped.Load() {
+		t.Stop()
+	}
+}
+
+// stopAgeTimer stops the timer of connection lifetime.
+func (c *Conn) stopAgeTimer() {
+	if t := c.ageTimer.Swap(nil); t != nil {
+		t.Stop()
+	}
+}
@@ -0,0 +1,47 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestServer_WithMaxConnectionAge(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithMaxConnectionAge(50*time.Millisecond, 20*time.Millisecond, 50*time.Millisecond))
+	defer shutdown()
+
+	connected := time.Now()
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	name, _ := readEnvelope(t, c)
+	require.Equal(t, EventReconnect, name)
+	hinted := time.Since(connected)
+	require.GreaterOrEqual(t, hinted, 50*time.Millisecond)
+
+	require.Equal(t, ws.StatusGoingAway, readClose(t, c))
+	require.GreaterOrEqual(t, time.Since(connected)-hinted, 40*time.Millisecond, "client must have time to reconnect")
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestConn_stopAgeTimer(t *testing.T) {
+	s := Start(context.Background(), WithMaxConnectionAge(time.Hour, 0, 0))
+	defer func() {
+		require.NoError(t, s.Shutdown())
+	}()
+
+	c := &Conn{}
+	s.scheduleMaxAge(c)
+	timer := c.ageTimer.Load()
+	require.NotNil(t, timer)
+
+	c.stopAgeTimer()
+	require.Nil(t, c.ageTimer.Load())
+	require.False(t, timer.Stop(), "timer must be already stopped")
+}
@@ -0,0 +1,283 @@
+// Package mqttws bridges MQTT topics and channels of websocket.Server, so devices publishing via MQTT broker
+// appear as channel messages to browser clients and messages emitted to channels reach devices.
+//
+// Bridge doesn't depend on MQTT client, Client wraps a client of choice, e.g. eclipse/paho.mqtt.golang:
+//
+//	b := mqttws.New(srv, client{paho},
+//		mqttws.Inbound("devices/+/telemetry", mqttws.AtMostOnce),
+//		mqttws.Outbound("devices.*.commands", mqttws.AtLeastOnce),
+//	)
+//	go b.Run(ctx)
+//
+// Topic levels map to channel segments: devices/42/telemetry is channel devices.42.telemetry, see WithMapping.
+// Payload of MQTT message is data of the channel message with name set by WithEventName.
+// Messages received from MQTT are emitted as websocket.RawData, so they are not published back.
+package mqttws
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkgz/websocket"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultEventName is the name of channel messages received from MQTT.
+const DefaultEventName = "message"
+
+// DefaultQueueSize is the number of messages waiting to be published, see WithQueueSize.
+const DefaultQueueSize = 1024
+
+// QoS is MQTT quality of service level.
+type QoS byte
+
+// QoS levels.
+const (
+	AtMostOnce  QoS = 0
+	AtLeastOnce QoS = 1
+	ExactlyOnce QoS = 2
+)
+
+// Message is MQTT message.
+type Message struct {
+	Topic    string
+	Payload  []byte
+	QoS      QoS
+	Retained bool
+}
+
+// Client is connected MQTT client. Handler of Subscribe could be called concurrently.
+type Client interface {
+	Publish(ctx context.Context, msg Message) error
+	Subscribe(ctx context.Context, filter string, qos QoS, handler func(msg Message)) error
+	Unsubscribe(ctx context.Context, filters ...string) error
+}
+
+// Option configures the Bridge.
+type Option func(b *Bridge)
+
+// Inbound subscribes to topic filter (with + and # wildcards) with qos, messages are emitted
+// to the channel of topic. Messages of channels which don't exist are skipped.
+func Inbound(filter string, qos QoS) Option {
+	return func(b *Bridge) {
+		b.inbound = append(b.inbound, subscription{filter: filter, qos: qos})
+	}
+}
+
+// Outbound publishes messages of channels matching pattern (see websocket.MatchChannel) to the topic
+// of channel with qos. Message of channel matching several patterns is published once, with qos of the first one.
+func Outbound(pattern string, qos QoS) Option {
+	return func(b *Bridge) {
+		b.outbound = append(b.outbound, route{pattern: pattern, qos: qos})
+	}
+}
+
+// WithRetain publishes outbound messages as retained, so new subscribers get the last one.
+func WithRetain() Option {
+	return func(b *Bridge) {
+		b.retain = true
+	}
+}
+
+// WithMapping sets functions which map topic to channel id and back, default maps levels to segments.
+func WithMapping(channel func(topic string) string, topic func(channel string) string) Option {
+	return func(b *Bridge) {
+		b.channel, b.topic = channel, topic
+	}
+}
+
+// WithEventName sets the name of channel messages received from MQTT, default is DefaultEventName.
+func WithEventName(name string) Option {
+	return func(b *Bridge) {
+		b.name = name
+	}
+}
+
+// WithQueueSize sets the number of messages waiting to be published, default is DefaultQueueSize.
+// Messages of AtMostOnce routes emitted when the queue is full are dropped (see Dropped), so slow broker
+// doesn't block channels. Messages of AtLeastOnce and ExactlyOnce routes wait for room in the queue,
+// they are dropped only after Run returned.
+func WithQueueSize(n int) Option {
+	return func(b *Bridge) {
+		b.queueSize = n
+	}
+}
+
+// Bridge mirrors channel messages to MQTT and MQTT messages to channels.
+type Bridge struct {
+	srv    *websocket.Server
+	client Client
+
+	inbound   []subscription
+	outbound  []route
+	retain    bool
+	channel   func(topic string) string
+	topic     func(channel string) string
+	name      string
+	queueSize int
+	queue     chan Message
+	dropped   atomic.Int64
+	stopped   chan struct{}
+	stop      sync.Once
+}
+
+// subscription is inbound topic filter.
+type subscription struct {
+	filter string
+	qos    QoS
+}
+
+// route is outbound channel pattern.
+type route struct {
+	pattern string
+	qos     QoS
+}
+
+// TopicChannel is the default mapping of topic to channel id: devices/42/telemetry is devices.42.telemetry.
+func TopicChannel(topic string) string {
+	return strings.ReplaceAll(topic, "/", websocket.PatternSeparator)
+}
+
+// ChannelTopic is the default mapping of channel id to topic: devices.42.commands is devices/42/commands.
+// Ids with MQTT wildcards # and + (e.g. channels of namespaces) have no topic, empty string is returned.
+func ChannelTopic(channel string) string {
+	if strings.ContainsAny(channel, "#+") {
+		return ""
+	}
+	return strings.ReplaceAll(channel, websocket.PatternSeparator, "/")
+}
+
+// New makes bridge of the server.
+func New(srv *websocket.Server, client Client, opts ...Option) *Bridge {
+	b := &Bridge{
+		srv:       srv,
+		client:    client,
+		channel:   TopicChannel,
+		topic:     ChannelTopic,
+		name:      DefaultEventName,
+		queueSize: DefaultQueueSize,
+		stopped:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.queue = make(chan Message, b.queueSize)
+
+	if len(b.outbound) != 0 {
+		srv.OnChannelEmit(b.Mirror)
+	}
+	return b
+}
+
+// Mirror queue message of channel to be published to its topic if channel matches outbound pattern.
+// New adds it to OnChannelEmit hooks of the server. Channels without valid topic (see ChannelTopic)
+// are skipped.
+func (b *Bridge) Mirror(ch *websocket.Channel, _ string, data any) {
+	if _, ok := data.(websocket.RawData); ok {
+		return
+	}
+
+	for _, r := range b.outbound {
+		if !websocket.MatchChannel(r.pattern, ch.ID()) {
+			continue
+		}
+
+		topic := b.topic(ch.ID())
+		if topic == "" || strings.ContainsAny(topic, "#+") {
+			log.Printf("mqttws: channel %q has no valid topic, got %q", ch.ID(), topic)
+			return
+		}
+		payload, err := encode(data)
+		if err != nil {
+			log.Printf("mqttws: encode message of channel %q: %v", ch.ID(), err)
+			return
+		}
+
+		msg := Message{Topic: topic, Payload: payload, QoS: r.qos, Retained: b.retain}
+		if r.qos == AtMostOnce {
+			select {
+			case b.queue <- msg:
+			default:
+				b.dropped.Add(1)
+			}
+			return
+		}
+		// free room is used even after Run returned, select below would pick randomly
+		select {
+		case b.queue <- msg:
+			return
+		default:
+		}
+		select {
+		case b.queue <- msg:
+		case <-b.stopped:
+			b.dropped.Add(1)
+		}
+		return
+	}
+}
+
+// encode data of channel message to payload, []byte is sent as is.
+func encode(data any) ([]byte, error) {
+	switch v := data.(type) {
+	case []byte:
+		return v, nil
+	case websocket.BinaryPayload:
+		return v.BinaryPayload()
+	}
+	return json.Marshal(data)
+}
+
+// Dropped return number of messages dropped because the queue was full.
+func (b *Bridge) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// Run subscribes to inbound filters and publishes queued messages until ctx is done. It returns the error
+// of Subscribe, errors of Publish are logged. Filters are unsubscribed when Run returns.
+// Run can't be called again after it returned.
+func (b *Bridge) Run(ctx context.Context) error {
+	defer b.stop.Do(func() { close(b.stopped) })
+
+	filters := make([]string, 0, len(b.inbound))
+	defer func() {
+		if len(filters) == 0 {
+			return
+		}
+		if err := b.client.Unsubscribe(context.Background(), filters...); err != nil {
+			log.Printf("mqttws: unsubscribe: %v", err)
+		}
+	}()
+
+	for _, sub := range b.inbound {
+		if err := b.client.Subscribe(ctx, sub.filter, sub.qos, b.emit); err != nil {
+			return err
+		}
+		filters = append(filters, sub.filter)
+	}
+
+	for {
+		select {
+		case msg := <-b.queue:
+			if err := b.client.Publish(ctx, msg); err != nil {
+				log.Printf("mqttws: publish to %q: %v", msg.Topic, err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// emit received message to the channel of its topic.
+func (b *Bridge) emit(msg Message) {
+	id := b.channel(msg.Topic)
+	ch := b.srv.Channel(id)
+	if ch == nil {
+		return
+	}
+	if err := ch.Enqueue(b.name, websocket.RawData(msg.Payload)); err != nil {
+		log.Printf("mqttws: emit message of topic %q to channel %q: %v", msg.Topic, id, err)
+	}
+}
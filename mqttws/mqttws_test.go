@@ -0,0 +1,173 @@
+package mqttws
+
+import (
+	"context"
+	"errors"
+	"github.com/pkgz/websocket"
+	"github.com/pkgz/websocket/websockettest"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClient is in-memory broker with a single client.
+type fakeClient struct {
+	mu           sync.Mutex
+	published    []Message
+	handlers     map[string]func(msg Message)
+	qos          map[string]QoS
+	unsubscribed []string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{handlers: make(map[string]func(msg Message)), qos: make(map[string]QoS)}
+}
+
+func (c *fakeClient) Publish(_ context.Context, msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.published = append(c.published, msg)
+	return nil
+}
+
+func (c *fakeClient) Subscribe(_ context.Context, filter string, qos QoS, handler func(msg Message)) error {
+	if strings.HasPrefix(filter, "forbidden") {
+		return errors.New("not authorized")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers[filter], c.qos[filter] = handler, qos
+	return nil
+}
+
+func (c *fakeClient) Unsubscribe(_ context.Context, filters ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unsubscribed = append(c.unsubscribed, filters...)
+	return nil
+}
+
+// deliver message of device to the handler of filter.
+func (c *fakeClient) deliver(filter string, msg Message) {
+	c.mu.Lock()
+	h := c.handlers[filter]
+	c.mu.Unlock()
+	h(msg)
+}
+
+func (c *fakeClient) Published() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Message(nil), c.published...)
+}
+
+func TestBridge(t *testing.T) {
+	srv := websocket.Start(context.Background())
+	defer func() {
+		require.NoError(t, srv.Shutdown())
+	}()
+	client := newFakeClient()
+	b := New(srv, client,
+		Inbound("devices/+/telemetry", AtMostOnce),
+		Outbound("devices.*.commands", AtLeastOnce),
+		Outbound("devices.**", ExactlyOnce),
+		WithRetain(),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return len(client.handlers) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, AtMostOnce, client.qos["devices/+/telemetry"])
+
+	conn, browser := websockettest.NewPair(t, srv)
+	telemetry := srv.NewChannel("devices.42.telemetry")
+	require.NoError(t, telemetry.Add(conn))
+
+	client.deliver("devices/+/telemetry", Message{Topic: "devices/42/telemetry", Payload: []byte(`{"temp":21.5}`)})
+	browser.ExpectJSON(t, "message", map[string]float64{"temp": 21.5})
+	client.deliver("devices/+/telemetry", Message{Topic: "devices/42/telemetry", Payload: []byte("on")})
+	browser.ExpectJSON(t, "message", "on")
+	client.deliver("devices/+/telemetry", Message{Topic: "devices/7/telemetry", Payload: []byte("1")})
+
+	srv.NewChannel("devices.42.commands").Emit("command", map[string]string{"do": "reboot"})
+	require.Eventually(t, func() bool { return len(client.Published()) == 1 }, time.Second, 10*time.Millisecond)
+	msg := client.Published()[0]
+	require.Equal(t, "devices/42/commands", msg.Topic)
+	require.JSONEq(t, `{"do":"reboot"}`, string(msg.Payload))
+	require.Equal(t, AtLeastOnce, msg.QoS, "qos of the first matching pattern must be used")
+	require.True(t, msg.Retained)
+
+	cancel()
+	require.NoError(t, <-done)
+	require.Len(t, client.Published(), 1, "messages from devices must not be published back")
+	require.Equal(t, []string{"devices/+/telemetry"}, client.unsubscribed)
+}
+
+func TestBridge_Run_error(t *testing.T) {
+	client := newFakeClient()
+	b := New(websocket.New(), client, Inbound("devices/#", AtMostOnce), Inbound("forbidden/#", AtMostOnce))
+	require.EqualError(t, b.Run(context.Background()), "not authorized")
+	require.Equal(t, []string{"devices/#"}, client.unsubscribed)
+}
+
+func TestBridge_WithMapping(t *testing.T) {
+	srv := websocket.New()
+	b := New(srv, newFakeClient(), Outbound("**", AtMostOnce), WithQueueSize(1),
+		WithMapping(func(topic string) string { return topic }, func(channel string) string { return "ws/" + channel }))
+
+	srv.NewChannel("room").Emit("text", []byte("raw"))
+	srv.NewChannel("room").Emit("text", "dropped")
+	require.Equal(t, Message{Topic: "ws/room", Payload: []byte("raw")}, <-b.queue)
+	require.Equal(t, int64(1), b.Dropped())
+}
+
+func TestBridge_Mirror_qos(t *testing.T) {
+	srv := websocket.New()
+	b := New(srv, newFakeClient(), Outbound("**", AtLeastOnce), WithQueueSize(1))
+
+	ch := srv.NewChannel("room")
+	ch.Emit("text", "first")
+	emitted := make(chan struct{})
+	go func() {
+		ch.Emit("text", "second")
+		close(emitted)
+	}()
+	select {
+	case <-emitted:
+		t.Fatal("message of AtLeastOnce route must wait for room in the queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.Equal(t, `"first"`, string((<-b.queue).Payload))
+	<-emitted
+	require.Equal(t, `"second"`, string((<-b.queue).Payload))
+	require.Equal(t, int64(0), b.Dropped())
+
+	// after Run returned messages are dropped instead of blocking
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, b.Run(ctx))
+	ch.Emit("text", "third")
+	ch.Emit("text", "fourth")
+	require.Equal(t, int64(1), b.Dropped())
+}
+
+func TestChannelTopic(t *testing.T) {
+	require.Equal(t, "devices/42/commands", ChannelTopic("devices.42.commands"))
+	require.Empty(t, ChannelTopic("/admin#room"), "namespace ids must not map to topics with wildcards")
+	require.Empty(t, ChannelTopic("a+b"))
+
+	srv := websocket.New()
+	b := New(srv, newFakeClient(), Outbound("**", AtMostOnce))
+	srv.NewChannel("/admin#room").Emit("text", "skipped")
+	require.Len(t, b.queue, 0)
+}
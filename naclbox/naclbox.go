@@ -0,0 +1,145 @@
+// Package naclbox is websocket.PayloadCipher with NaCl box (Curve25519, XSalsa20 and Poly1305).
+//
+// Client sends its public key in key exchange event, server replies with public key generated
+// for the connection and enables encryption of connection:
+//
+//	srv.Handle("key", naclbox.KeyExchange("key"))
+//
+// Keys are sent as base64 strings, e.g. {"name": "key", "data": "base64 of 32 bytes"}. Encrypted data
+// is random 24 bytes nonce followed by the box, both sides derive the shared key with box.Precompute.
+//
+// KeyExchange doesn't authenticate the server, so it doesn't protect from man in the middle who replaces
+// the keys. SignedKeyExchange signs the reply with long-term ed25519 key of server, client checks it
+// with VerifyKey and the public key it knows in advance:
+//
+//	srv.Handle("key", naclbox.SignedKeyExchange("key", serverKey))
+package naclbox
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pkgz/websocket"
+	"golang.org/x/crypto/nacl/box"
+)
+
+const nonceSize = 24
+
+// ErrInvalidKey is returned when public key of client is not base64 of 32 bytes.
+var ErrInvalidKey = errors.New("naclbox: invalid public key")
+
+// ErrSignature is returned by VerifyKey when reply of SignedKeyExchange is not signed by the server key.
+var ErrSignature = errors.New("naclbox: invalid signature")
+
+// ErrOpen is returned when box can't be opened.
+var ErrOpen = errors.New("naclbox: can't open box")
+
+// Cipher encrypts messages of one connection with the key shared by two key pairs.
+type Cipher struct {
+	shared [32]byte
+}
+
+var _ websocket.PayloadCipher = (*Cipher)(nil)
+
+// New return cipher for peer public key and own private key.
+func New(peerPublicKey, privateKey *[32]byte) *Cipher {
+	c := &Cipher{}
+	box.Precompute(&c.shared, peerPublicKey, privateKey)
+	return c
+}
+
+// Encrypt seal data with random nonce, nonce is prepended to the box.
+func (c *Cipher) Encrypt(data []byte) ([]byte, error) {
+	var nonce [nonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	return box.SealAfterPrecomputation(nonce[:], data, &nonce, &c.shared), nil
+}
+
+// Decrypt open the box made by Encrypt of peer.
+func (c *Cipher) Decrypt(data []byte) ([]byte, error) {
+	if len(data) < nonceSize+box.Overhead {
+		return nil, ErrOpen
+	}
+	var nonce [nonceSize]byte
+	copy(nonce[:], data)
+	b, ok := box.OpenAfterPrecomputation(nil, data[nonceSize:], &nonce, &c.shared)
+	if !ok {
+		return nil, ErrOpen
+	}
+	return b, nil
+}
+
+// KeyExchange return handler of event in which client sends its public key. It replies with public key
+// of the connection in the same event and encrypts following messages of connection. When connection
+// is already encrypted, the reply is encrypted with the previous key.
+// Invalid key is replied with websocket.CodeBadRequest error.
+// The reply is not authenticated, use SignedKeyExchange when client must be sure it talks to the server.
+func KeyExchange(event string) websocket.ErrorHandlerFunc {
+	return keyExchange(event, nil)
+}
+
+// SignedKeyExchange is KeyExchange which appends ed25519 signature of the server public key and client
+// public key to the reply, so the reply is base64 of 32 bytes key followed by 64 bytes signature.
+// Client checks it with VerifyKey, the signature covers client key too, so old replies can't be reused.
+func SignedKeyExchange(event string, key ed25519.PrivateKey) websocket.ErrorHandlerFunc {
+	return keyExchange(event, key)
+}
+
+// VerifyKey check reply of SignedKeyExchange to client public key and return the public key of connection.
+func VerifyKey(serverKey ed25519.PublicKey, clientKey *[32]byte, reply string) (*[32]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(reply)
+	if err != nil || len(b) != 32+ed25519.SignatureSize {
+		return nil, ErrInvalidKey
+	}
+	if !ed25519.Verify(serverKey, signed(b[:32], clientKey[:]), b[32:]) {
+		return nil, ErrSignature
+	}
+	var public [32]byte
+	copy(public[:], b)
+	return &public, nil
+}
+
+// signed return the message signed by SignedKeyExchange.
+func signed(serverKey, clientKey []byte) []byte {
+	return append(append([]byte("naclbox:"), serverKey...), clientKey...)
+}
+
+func keyExchange(event string, signer ed25519.PrivateKey) websocket.ErrorHandlerFunc {
+	return func(c websocket.Connection, msg *websocket.Message) error {
+		conn, ok := c.(*websocket.Conn)
+		if !ok {
+			return fmt.Errorf("naclbox: %T can't encrypt messages", c)
+		}
+
+		var s string
+		if err := json.Unmarshal(msg.Data, &s); err != nil {
+			return websocket.NewError(websocket.CodeBadRequest, ErrInvalidKey.Error())
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil || len(b) != 32 {
+			return websocket.NewError(websocket.CodeBadRequest, ErrInvalidKey.Error())
+		}
+		var peer [32]byte
+		copy(peer[:], b)
+
+		public, private, err := box.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		reply := public[:]
+		if signer != nil {
+			reply = append(reply, ed25519.Sign(signer, signed(public[:], peer[:]))...)
+		}
+		// reply goes before the new cipher is set, client needs the key to decrypt anything
+		if err = c.Emit(event, base64.StdEncoding.EncodeToString(reply)); err != nil {
+			return err
+		}
+		conn.SetPayloadCipher(New(&peer, private))
+		return nil
+	}
+}
@@ -0,0 +1,113 @@
+package naclbox
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/pkgz/websocket"
+	"github.com/pkgz/websocket/websockettest"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+	"testing"
+)
+
+func TestCipher(t *testing.T) {
+	alicePublic, alicePrivate, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	bobPublic, bobPrivate, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	alice, bob := New(bobPublic, alicePrivate), New(alicePublic, bobPrivate)
+	b, err := alice.Encrypt([]byte("hello"))
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "hello")
+
+	plain, err := bob.Decrypt(b)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(plain))
+
+	b[len(b)-1] ^= 1
+	_, err = bob.Decrypt(b)
+	require.ErrorIs(t, err, ErrOpen)
+	_, err = bob.Decrypt([]byte("short"))
+	require.ErrorIs(t, err, ErrOpen)
+}
+
+func TestKeyExchange(t *testing.T) {
+	srv := websocket.Start(context.Background())
+	defer func() {
+		_ = srv.Shutdown()
+	}()
+	srv.Handle("key", KeyExchange("key"))
+	srv.On("echo", func(c websocket.Connection, msg *websocket.Message) {
+		_ = c.Emit("echo", json.RawMessage(msg.Data))
+	})
+	_, client := websockettest.NewPair(t, srv)
+
+	client.Emit(t, "key", "not a key")
+	require.Contains(t, client.Expect(t, "_error").String(), "invalid public key")
+
+	public, private, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	client.Emit(t, "key", base64.StdEncoding.EncodeToString(public[:]))
+
+	var key string
+	require.NoError(t, json.Unmarshal(client.Expect(t, "key").Data, &key))
+	b, err := base64.StdEncoding.DecodeString(key)
+	require.NoError(t, err)
+	var serverKey [32]byte
+	copy(serverKey[:], b)
+	cipher := New(&serverKey, private)
+
+	b, err = cipher.Encrypt([]byte(`{"text":"secret"}`))
+	require.NoError(t, err)
+	client.Emit(t, "echo", base64.StdEncoding.EncodeToString(b))
+
+	msg := client.Expect(t, "echo")
+	require.NotContains(t, string(msg.Data), "secret")
+	require.NoError(t, json.Unmarshal(msg.Data, &key))
+	b, err = base64.StdEncoding.DecodeString(key)
+	require.NoError(t, err)
+	plain, err := cipher.Decrypt(b)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"text":"secret"}`, string(plain))
+
+	// plaintext is rejected once connection is encrypted
+	client.Emit(t, "echo", map[string]string{"text": "plain"})
+	require.Contains(t, client.Expect(t, "_error").String(), "decrypt")
+}
+
+func TestSignedKeyExchange(t *testing.T) {
+	serverPublic, serverPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	srv := websocket.Start(context.Background())
+	defer func() {
+		_ = srv.Shutdown()
+	}()
+	srv.Handle("key", SignedKeyExchange("key", serverPrivate))
+	_, client := websockettest.NewPair(t, srv)
+
+	public, _, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	client.Emit(t, "key", base64.StdEncoding.EncodeToString(public[:]))
+
+	var reply string
+	require.NoError(t, json.Unmarshal(client.Expect(t, "key").Data, &reply))
+	key, err := VerifyKey(serverPublic, public, reply)
+	require.NoError(t, err)
+	require.NotNil(t, key)
+
+	_, err = VerifyKey(otherPublic, public, reply)
+	require.ErrorIs(t, err, ErrSignature, "reply must be signed by the server key")
+	other, _, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, err = VerifyKey(serverPublic, other, reply)
+	require.ErrorIs(t, err, ErrSignature, "reply for another client key must be rejected")
+	_, err = VerifyKey(serverPublic, public, "short")
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
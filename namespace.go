@@ -0,0 +1,216 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// NamespaceParam is the url param which selects the namespace of connection: /ws?namespace=/admin.
+// Connections without it belong to the server itself.
+const NamespaceParam = "namespace"
+
+var (
+	// ErrUnknownNamespace is returned when client asks for namespace which was not created with Of.
+	ErrUnknownNamespace = errors.New("websocket: unknown namespace")
+	// ErrInvalidChannel is returned when client of the server asks for channel of namespace ("/admin#room").
+	ErrInvalidChannel = errors.New("websocket: invalid channel name")
+)
+
+// Middleware wraps handlers of namespace, e.g. to check permissions or log messages.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Namespace is an isolated logical app on the same endpoint (similar to namespace in socket.io).
+// It has its own handlers, middleware and channels, system events are handled by the server.
+// Callbacks of the server (OnConnect, OnDisconnect, OnSubscribe, OnMessage, OnAny), Subscribe streams
+// and webhooks are not called for connections of namespace and Server.Emit doesn't reach them.
+type Namespace struct {
+	name         string
+	server       *Server
+	callbacks    map[string][]*handler
+	middleware   []Middleware
+	onAny        []AnyHandlerFunc
+	onConnect    func(c Connection)
+	onDisconnect func(c Connection)
+	onSubscribe  SubscribeFunc
+
+	mu sync.RWMutex
+}
+
+// Of return the namespace with name, it's created on the first call.
+// Name must start with "/".
+func (s *Server) Of(name string) *Namespace {
+	if !strings.HasPrefix(name, "/") || name == "/" {
+		panic(fmt.Sprintf("websocket: invalid namespace %q", name))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ns, ok := s.namespaces[name]; ok {
+		return ns
+	}
+	ns := &Namespace{
+		name:      name,
+		server:    s,
+		callbacks: make(map[string][]*handler),
+	}
+	s.namespaces[name] = ns
+	return ns
+}
+
+// namespace return the namespace by name, empty name is the server itself.
+func (s *Server) namespace(name string) (*Namespace, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if ns, ok := s.namespaces[name]; ok {
+		return ns, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownNamespace, name)
+}
+
+// Name return namespace name.
+func (ns *Namespace) Name() string {
+	return ns.name
+}
+
+// On adding callback for message of namespace connections, returned function removes it, see Server.On.
+// It panics if name is in reserved system namespace (see SystemPrefix).
+func (ns *Namespace) On(name string, f HandlerFunc) (off func()) {
+	mustNotBeSystem(name)
+
+	h := &handler{f: f}
+	ns.mu.Lock()
+	ns.callbacks[name] = append(ns.callbacks[name], h)
+	ns.mu.Unlock()
+
+	return func() {
+		ns.mu.Lock()
+		ns.callbacks[name] = removeHandler(ns.callbacks[name], h)
+		if len(ns.callbacks[name]) == 0 {
+			delete(ns.callbacks, name)
+		}
+		ns.mu.Unlock()
+	}
+}
+
+// Use add middleware to all handlers of namespace, middleware added first is called first.
+func (ns *Namespace) Use(m ...Middleware) {
+	ns.mu.Lock()
+	ns.middleware = append(ns.middleware, m...)
+	ns.mu.Unlock()
+}
+
+// OnConnect function which will be called when new connection to namespace come.
+func (ns *Namespace) OnConnect(f func(c Connection)) {
+	ns.mu.Lock()
+	ns.onConnect = f
+	ns.mu.Unlock()
+}
+
+// OnDisconnect function which will be called when connection of namespace is closed.
+func (ns *Namespace) OnDisconnect(f func(c Connection)) {
+	ns.mu.Lock()
+	ns.onDisconnect = f
+	ns.mu.Unlock()
+}
+
+// OnAny adding callback which is called for every named message of namespace connections, see Server.OnAny.
+func (ns *Namespace) OnAny(f AnyHandlerFunc) {
+	ns.mu.Lock()
+	ns.onAny = append(ns.onAny, f)
+	ns.mu.Unlock()
+}
+
+// OnSubscribe sets function which authorize joining channels of namespace, see Server.OnSubscribe.
+func (ns *Namespace) OnSubscribe(f SubscribeFunc) {
+	ns.mu.Lock()
+	ns.onSubscribe = f
+	ns.mu.Unlock()
+}
+
+// NewChannel create new channel in namespace.
+// Channels of different namespaces with the same id don't intersect.
+func (ns *Namespace) NewChannel(id string) *Channel {
+	return ns.server.NewChannel(ns.channelID(id))
+}
+
+// Channel find and return the channel of namespace.
+func (ns *Namespace) Channel(id string) *Channel {
+	return ns.server.Channel(ns.channelID(id))
+}
+
+// Emit message to all connections of namespace.
+// Returns number of connections which received the message and errors of the failed ones.
+func (ns *Namespace) Emit(name string, data any) BroadcastResult {
+	var res BroadcastResult
+	ns.server.connections.forEach(func(c *Conn) {
+		if c.namespace == ns {
+			res.add(c, c.Emit(name, data))
+		}
+	})
+	return res
+}
+
+// Count return number of active connections of namespace.
+func (ns *Namespace) Count() int {
+	count := 0
+	ns.server.connections.forEach(func(c *Conn) {
+		if c.namespace == ns {
+			count++
+		}
+	})
+	return count
+}
+
+// channelID return the server-wide id of namespace channel.
+func (ns *Namespace) channelID(id string) string {
+	return ns.name + "#" + id
+}
+
+// handlers return the handlers for message wrapped with middleware.
+func (ns *Namespace) handlers(name string) []*handler {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	list := make([]*handler, 0, len(ns.callbacks[name]))
+	for _, h := range ns.callbacks[name] {
+		f := h.f
+		for i := len(ns.middleware) - 1; i >= 0; i-- {
+			f = ns.middleware[i](f)
+		}
+		list = append(list, &handler{f: f})
+	}
+	return list
+}
+
+// channelID return the server-wide id of channel for connection namespace.
+func (c *Conn) channelID(id string) string {
+	if c.namespace == nil {
+		return id
+	}
+	return c.namespace.channelID(id)
+}
+
+// checkChannel validate channel id sent by client. Connections of the server can't reach
+// channels of namespaces, ids of namespaces are prefixed, so they can't reach other namespaces.
+func (c *Conn) checkChannel(id string) error {
+	if id == "" {
+		return ErrNoChannel
+	}
+	if c.namespace == nil && strings.Contains(id, "#") {
+		return ErrInvalidChannel
+	}
+	return nil
+}
+
+// Namespace return the namespace of connection, nil for connections of the server itself.
+func (c *Conn) Namespace() *Namespace {
+	return c.namespace
+}
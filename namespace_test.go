@@ -0,0 +1,224 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServer_Of(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	admin := wsServer.Of("/admin")
+	require.Equal(t, admin, wsServer.Of("/admin"))
+	require.Equal(t, "/admin", admin.Name())
+	require.Panics(t, func() { wsServer.Of("admin") })
+
+	var calls []string
+	admin.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c Connection, msg *Message) {
+			calls = append(calls, "first")
+			next(c, msg)
+		}
+	}, func(next HandlerFunc) HandlerFunc {
+		return func(c Connection, msg *Message) {
+			calls = append(calls, "second")
+			next(c, msg)
+		}
+	})
+	admin.On("whoami", func(c Connection, msg *Message) {
+		calls = append(calls, "handler")
+		_ = c.Emit("whoami", c.(*Conn).Namespace().Name())
+	})
+	wsServer.On("whoami", func(c Connection, msg *Message) {
+		_ = c.Emit("whoami", "root")
+	})
+
+	c := dialNamespace(t, ts, "/admin")
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	root := dial(t, ts)
+	defer func() {
+		require.NoError(t, root.Close())
+	}()
+
+	writeMessage(t, c, "whoami", nil)
+	_, data := readEnvelope(t, c)
+	require.Equal(t, `"/admin"`, string(data))
+	require.Equal(t, []string{"first", "second", "handler"}, calls)
+
+	writeMessage(t, root, "whoami", nil)
+	_, data = readEnvelope(t, root)
+	require.Equal(t, `"root"`, string(data))
+	require.Equal(t, 1, admin.Count())
+}
+
+func TestNamespace_channels(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	admin := wsServer.Of("/admin")
+
+	c := dialNamespace(t, ts, "/admin")
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	root := dial(t, ts)
+	defer func() {
+		require.NoError(t, root.Close())
+	}()
+
+	joinChannel(t, c, "room")
+	joinChannel(t, root, "room")
+
+	require.Equal(t, 1, admin.Channel("room").Count())
+	require.Equal(t, 1, wsServer.Channel("room").Count(), "channels of namespaces must be isolated")
+
+	admin.Channel("room").Emit("hello", "admin")
+	name, data := readEnvelope(t, c)
+	require.Equal(t, "hello", name)
+	require.Equal(t, `"admin"`, string(data))
+
+	admin.Emit("all", 1)
+	name, _ = readEnvelope(t, c)
+	require.Equal(t, "all", name)
+}
+
+func TestNamespace_isolation(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	admin := wsServer.Of("/admin")
+	admin.NewChannel("secret")
+
+	var rootConnects atomic.Int32
+	wsServer.OnConnect(func(c Connection) {
+		rootConnects.Add(1)
+	})
+	wsServer.OnSubscribe(func(ctx context.Context, c Connection, channel string) error {
+		return errors.New("root hook must not be called")
+	})
+	admin.OnSubscribe(func(ctx context.Context, c Connection, channel string) error {
+		if channel != "room" {
+			return errors.New("denied")
+		}
+		return nil
+	})
+	wsServer.OnMessage(func(c Connection, h ws.Header, b []byte) {
+		_ = c.Emit("raw", nil)
+	})
+
+	c := dialNamespace(t, ts, "/admin")
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	joinChannel(t, c, "room")
+	writeMessage(t, c, EventSubscribe, map[string]string{"channel": "other"})
+	name, _ := readEnvelope(t, c)
+	require.Equal(t, EventError, name, "subscription must be authorized by namespace")
+	writeMessage(t, c, "unhandled", nil)
+
+	root := dial(t, ts)
+	defer func() {
+		require.NoError(t, root.Close())
+	}()
+	writeMessage(t, root, EventSubscribe, map[string]string{"channel": "/admin#secret"})
+	name, data := readEnvelope(t, root)
+	require.Equal(t, EventError, name)
+	require.JSONEq(t, `{"event":"_subscribe","code":400,"channel":"/admin#secret","message":"websocket: invalid channel name"}`, string(data))
+	require.Equal(t, 0, admin.Channel("secret").Count(), "client of server must not join channel of namespace")
+
+	require.Eventually(t, func() bool { return rootConnects.Load() == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, _, err := wsutil.ReadServerData(c)
+	require.Error(t, err, "OnMessage of server must not be called for namespace")
+	require.Equal(t, int32(1), rootConnects.Load(), "OnConnect of server must not be called for namespace")
+}
+
+func TestNamespace_serverHooks(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	receiver, events := webhookReceiver(t, nil, 0)
+	wsServer.Webhook("ping", receiver.URL, WebhookOptions{})
+	stream, err := wsServer.Subscribe("ping", 10)
+	require.NoError(t, err)
+
+	var rootAny, rootDisconnects, nsAny atomic.Int32
+	wsServer.OnAny(func(ctx context.Context, c Connection, msg *Message) { rootAny.Add(1) })
+	wsServer.OnDisconnect(func(c Connection) { rootDisconnects.Add(1) })
+	wsServer.On("ping", func(c Connection, msg *Message) { _ = c.Emit("pong", "root") })
+
+	admin := wsServer.Of("/admin")
+	nsDisconnected := make(chan string, 1)
+	admin.OnAny(func(ctx context.Context, c Connection, msg *Message) { nsAny.Add(1) })
+	admin.OnDisconnect(func(c Connection) { nsDisconnected <- c.ID() })
+	admin.On("ping", func(c Connection, msg *Message) { _ = c.Emit("pong", "admin") })
+
+	c := dialNamespace(t, ts, "/admin")
+	writeMessage(t, c, "ping", nil)
+	_, data := readEnvelope(t, c)
+	require.Equal(t, `"admin"`, string(data))
+	require.Equal(t, int32(1), nsAny.Load())
+	require.Equal(t, int32(0), rootAny.Load(), "OnAny of server must not be called for namespace")
+	require.Empty(t, stream, "Subscribe stream must not receive messages of namespace")
+
+	root := dial(t, ts)
+	defer func() {
+		require.NoError(t, root.Close())
+	}()
+	writeMessage(t, root, "ping", nil)
+	_, data = readEnvelope(t, root)
+	require.Equal(t, `"root"`, string(data))
+	require.Len(t, stream, 1)
+	e := <-events
+	require.NotEmpty(t, e.Connection)
+	select {
+	case e = <-events:
+		t.Fatalf("webhook must not receive messages of namespace, got %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, wsServer.Emit("news", 1))
+	name, _ := readEnvelope(t, root)
+	require.Equal(t, "news", name)
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, _, err = wsutil.ReadServerData(c)
+	require.Error(t, err, "Server.Emit must not reach namespace")
+
+	require.NoError(t, c.Close())
+	select {
+	case <-nsDisconnected:
+	case <-time.After(time.Second):
+		t.Fatal("OnDisconnect of namespace is not called")
+	}
+	require.Equal(t, int32(0), rootDisconnects.Load(), "OnDisconnect of server must not be called for namespace")
+}
+
+func TestServer_Of_unknown(t *testing.T) {
+	ts, _, shutdown := server(t)
+	defer shutdown()
+
+	u := url.URL{Scheme: "ws", Host: strings.Replace(ts.URL, "http://", "", 1), Path: "/ws", RawQuery: "namespace=/unknown"}
+	_, _, _, err := ws.Dial(context.Background(), u.String())
+	require.Error(t, err, "unknown namespace must be rejected")
+}
+
+func dialNamespace(t *testing.T, ts *httptest.Server, ns string) net.Conn {
+	u := url.URL{Scheme: "ws", Host: strings.Replace(ts.URL, "http://", "", 1), Path: "/ws", RawQuery: "namespace=" + ns}
+	c, _, _, err := ws.Dial(context.Background(), u.String())
+	require.NoError(t, err)
+	require.NoError(t, c.SetDeadline(time.Now().Add(3000*time.Millisecond)))
+	return c
+}
@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// poll register connection in the server poller, so frames will be read only when data is available.
+func (s *Server) poll(c *Conn, conn net.Conn) error {
+	s.mu.Lock()
+	if s.done {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	if s.poller == nil {
+		p, err := newPoller()
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		s.poller = p
+		spawn(&s.goroutines.pingers, func() { s.pingPolled(p) })
+	}
+	p := s.poller
+	s.mu.Unlock()
+
+	var once sync.Once
+	unpoll := func() {
+		once.Do(func() {
+			p.remove(conn)
+			spawn(&s.goroutines.background, func() { s.dropConn(c) })
+		})
+	}
+
+	// connection is locked until unpoll is set, so Close of failed read waits for it
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := p.add(conn, func() {
+		spawn(&s.goroutines.readers, func() {
+			if err := s.readFrame(c, conn); err != nil {
+				c.readFailed(err)
+				_ = c.Close()
+				return
+			}
+			if err := p.resume(conn); err != nil {
+				_ = c.Close()
+			}
+		})
+	})
+	if err != nil {
+		return err
+	}
+	c.unpoll = unpoll
+	return nil
+}
+
+// pingPolled sends ping to all polled connections from one goroutine instead of goroutine per connection.
+// Polled connections are not blocked on reading, so the read timeout is checked here as well.
+// Connections are checked every second (or PingInterval if it's shorter) and pinged when their interval passed.
+func (s *Server) pingPolled(p *poller) {
+	ticker := time.NewTicker(min(PingInterval, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.connections.forEachShard(func(c *Conn) {
+				c.mu.Lock()
+				polled := c.unpoll != nil
+				c.mu.Unlock()
+
+				if !polled {
+					return
+				}
+				if c.readTimedOut(now) {
+					_ = c.Close()
+					return
+				}
+
+				d := c.interval()
+				last := c.created
+				if ping := c.lastPing.Load(); ping != 0 {
+					last = time.Unix(0, ping)
+				}
+				if d <= 0 || now.Sub(last) < d {
+					return
+				}
+				c.lastPing.Store(now.UnixNano())
+				if err := c.Write(pingHeader, nil); err != nil {
+					_ = c.Close()
+				}
+			})
+		case <-p.done:
+			return
+		}
+	}
+}
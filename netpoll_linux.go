@@ -0,0 +1,145 @@
+//go:build linux
+
+package websocket
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+const (
+	pollEvents  = syscall.EPOLLIN | syscall.EPOLLRDHUP | syscall.EPOLLONESHOT
+	pollTimeout = 100 // ms
+)
+
+// poller wait for readable connections using epoll.
+// Every connection is registered with EPOLLONESHOT and must be resumed after reading.
+type poller struct {
+	fd       int
+	handlers map[int]func()
+	closed   atomic.Bool
+	done     chan struct{}
+	mu       sync.RWMutex
+}
+
+func newPoller() (*poller, error) {
+	fd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &poller{
+		fd:       fd,
+		handlers: make(map[int]func()),
+		done:     make(chan struct{}),
+	}
+	go p.wait()
+
+	return p, nil
+}
+
+func (p *poller) add(conn net.Conn, f func()) error {
+	fd, err := connFd(conn)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.handlers[fd] = f
+	p.mu.Unlock()
+
+	ev := syscall.EpollEvent{Events: pollEvents, Fd: int32(fd)}
+	if err = syscall.EpollCtl(p.fd, syscall.EPOLL_CTL_ADD, fd, &ev); err != nil {
+		p.mu.Lock()
+		delete(p.handlers, fd)
+		p.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (p *poller) resume(conn net.Conn) error {
+	fd, err := connFd(conn)
+	if err != nil {
+		return err
+	}
+
+	ev := syscall.EpollEvent{Events: pollEvents, Fd: int32(fd)}
+	return syscall.EpollCtl(p.fd, syscall.EPOLL_CTL_MOD, fd, &ev)
+}
+
+func (p *poller) remove(conn net.Conn) {
+	fd, err := connFd(conn)
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	delete(p.handlers, fd)
+	p.mu.Unlock()
+
+	_ = syscall.EpollCtl(p.fd, syscall.EPOLL_CTL_DEL, fd, nil)
+}
+
+func (p *poller) close() {
+	if p.closed.Swap(true) {
+		return
+	}
+	<-p.done
+}
+
+func (p *poller) wait() {
+	defer func() {
+		_ = syscall.Close(p.fd)
+		close(p.done)
+	}()
+
+	events := make([]syscall.EpollEvent, 128)
+	for !p.closed.Load() {
+		n, err := syscall.EpollWait(p.fd, events, pollTimeout)
+		if err != nil {
+			if errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			log.Printf("websocket: poller error %v", err)
+			return
+		}
+
+		for i := 0; i < n; i++ {
+			p.mu.RLock()
+			f := p.handlers[int(events[i].Fd)]
+			p.mu.RUnlock()
+
+			if f != nil {
+				f()
+			}
+		}
+	}
+}
+
+// connFd return file descriptor of plain tcp/unix connection.
+func connFd(conn net.Conn) (int, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0, errors.New("websocket: connection doesn't provide file descriptor")
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var fd int
+	if err = raw.Control(func(f uintptr) {
+		fd = int(f)
+	}); err != nil {
+		return 0, err
+	}
+
+	return fd, nil
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package websocket
+
+import (
+	"errors"
+	"net"
+)
+
+// poller is not implemented outside linux, the server always fallback to the read loop.
+type poller struct {
+	done chan struct{}
+}
+
+func newPoller() (*poller, error) {
+	return nil, errors.New("websocket: netpoll is supported only on linux")
+}
+
+func (p *poller) add(conn net.Conn, f func()) error { return nil }
+
+func (p *poller) resume(conn net.Conn) error { return nil }
+
+func (p *poller) remove(conn net.Conn) {}
+
+func (p *poller) close() {}
@@ -0,0 +1,120 @@
+//go:build linux
+
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServer_Netpoll(t *testing.T) {
+	wsServer := Start(context.Background(), WithNetpoll())
+	r := http.NewServeMux()
+	r.HandleFunc("/ws", wsServer.Handler)
+	ts := httptest.NewServer(r)
+	defer func() {
+		require.NoError(t, wsServer.Shutdown())
+		ts.Close()
+	}()
+
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		_ = c.Emit("echo", msg.Data)
+	})
+
+	clients := 5
+	for i := 0; i < clients; i++ {
+		c := dial(t, ts)
+		defer func() {
+			_ = c.Close()
+		}()
+
+		for _, text := range []string{"first", "second"} {
+			writeMessage(t, c, "echo", text)
+
+			b, _, err := wsutil.ReadServerData(c)
+			require.NoError(t, err)
+
+			var msg Message
+			require.NoError(t, json.Unmarshal(b, &msg))
+			require.Equal(t, "echo", msg.Name)
+			require.Equal(t, `"`+text+`"`, string(msg.Data))
+		}
+	}
+
+	require.Equal(t, clients, wsServer.Count())
+	require.NotNil(t, wsServer.poller, "server must use poller")
+}
+
+func TestServer_Netpoll_drop(t *testing.T) {
+	wsServer := Start(context.Background(), WithNetpoll())
+	r := http.NewServeMux()
+	r.HandleFunc("/ws", wsServer.Handler)
+	ts := httptest.NewServer(r)
+	defer func() {
+		require.NoError(t, wsServer.Shutdown())
+		ts.Close()
+	}()
+
+	disconnected := make(chan bool, 1)
+	wsServer.OnDisconnect(func(c Connection) {
+		disconnected <- true
+	})
+
+	c := dial(t, ts)
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, 1, wsServer.Count())
+	require.NoError(t, c.Close())
+
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("connection must be dropped")
+	}
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, 0, wsServer.Count())
+}
+
+func TestServer_Netpoll_fallback(t *testing.T) {
+	wsServer := Start(context.Background(), WithNetpoll())
+	defer func() {
+		require.NoError(t, wsServer.Shutdown())
+	}()
+
+	var disconnects atomic.Int32
+	wsServer.OnDisconnect(func(c Connection) {
+		disconnects.Add(1)
+	})
+
+	// pipe can't be polled, so it's served with read loop
+	server, client := net.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- wsServer.ServeStream(server, &http.Request{URL: &url.URL{Path: "/"}, Header: http.Header{}, RemoteAddr: "pipe"})
+	}()
+	require.Eventually(t, func() bool { return wsServer.Count() == 1 }, time.Second, time.Millisecond)
+
+	wsServer.connections.forEach(func(c *Conn) {
+		c.mu.Lock()
+		require.Nil(t, c.unpoll, "connection must not be polled")
+		c.mu.Unlock()
+	})
+	require.NoError(t, client.Close())
+
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("stream must be closed")
+	}
+	require.Eventually(t, func() bool { return disconnects.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, int32(1), disconnects.Load(), "disconnect must be called once")
+}
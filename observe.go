@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws"
+	"time"
+)
+
+// EventType is the type of server Event.
+type EventType int
+
+// Types of server events.
+const (
+	ConnectionOpened EventType = iota + 1
+	ConnectionClosed
+	ChannelCreated
+	MessageReceived
+	MessageDropped
+	WriteError
+)
+
+// String implements fmt.Stringer.
+func (t EventType) String() string {
+	switch t {
+	case ConnectionOpened:
+		return "connection_opened"
+	case ConnectionClosed:
+		return "connection_closed"
+	case ChannelCreated:
+		return "channel_created"
+	case MessageReceived:
+		return "message_received"
+	case MessageDropped:
+		return "message_dropped"
+	case WriteError:
+		return "write_error"
+	}
+	return "unknown"
+}
+
+// Event describes what happened in the server, it's passed to observers (see WithObserver).
+// Conn is nil for ChannelCreated and messages dropped by channel queue, Channel is set only for them.
+// Name is the name of received or dropped message, empty for messages which are not an envelope,
+// Size is the size of received message.
+// Code is the close code of ConnectionClosed: sent by client, by server or 1006 if connection was lost.
+// Err is the reason of MessageDropped and the error of WriteError.
+type Event struct {
+	Type    EventType
+	Time    time.Time
+	Conn    *Conn
+	Channel string
+	Name    string
+	Size    int
+	Code    ws.StatusCode
+	Err     error
+}
+
+// Observer receives server events, e.g. for monitoring or audit.
+// Observe is called synchronously from read and write paths, so it must not block.
+type Observer interface {
+	Observe(e Event)
+}
+
+// ObserverFunc is a function which implements Observer.
+type ObserverFunc func(e Event)
+
+// Observe implements Observer.
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// WithObserver adds the observer of server events.
+func WithObserver(o Observer) Option {
+	return func(s *Server) {
+		s.observers = append(s.observers, o)
+	}
+}
+
+// observe pass the event to observers.
+func (s *Server) observe(e Event) {
+	if len(s.observers) == 0 {
+		return
+	}
+
+	e.Time = time.Now()
+	for _, o := range s.observers {
+		o.Observe(e)
+	}
+}
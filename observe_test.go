@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+type eventLog struct {
+	events []Event
+	mu     sync.Mutex
+}
+
+func (r *eventLog) Observe(e Event) {
+	r.mu.Lock()
+	r.events = append(r.events, e)
+	r.mu.Unlock()
+}
+
+func (r *eventLog) find(t EventType) (Event, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range r.events {
+		if e.Type == t {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
+
+func (r *eventLog) wait(t *testing.T, typ EventType) Event {
+	var e Event
+	require.Eventually(t, func() bool {
+		var ok bool
+		e, ok = r.find(typ)
+		return ok
+	}, time.Second, 5*time.Millisecond, "%s event is expected", typ)
+	return e
+}
+
+func TestServer_WithObserver(t *testing.T) {
+	events := &eventLog{}
+	ts, _, shutdown := server(t, WithObserver(events))
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+	opened := events.wait(t, ConnectionOpened)
+	require.NotNil(t, opened.Conn)
+	require.False(t, opened.Time.IsZero())
+
+	joinChannel(t, c, "room-1")
+	require.Equal(t, "room-1", events.wait(t, ChannelCreated).Channel)
+	require.Equal(t, EventSubscribe, events.wait(t, MessageReceived).Name)
+
+	writeMessage(t, c, "_unknown", nil)
+	dropped := events.wait(t, MessageDropped)
+	require.Equal(t, "_unknown", dropped.Name)
+	require.Error(t, dropped.Err)
+
+	require.NoError(t, wsutil.WriteClientMessage(c, ws.OpClose, ws.NewCloseFrameBody(ws.StatusGoingAway, "bye")))
+	closed := events.wait(t, ConnectionClosed)
+	require.Equal(t, ws.StatusGoingAway, closed.Code)
+	require.Equal(t, opened.Conn, closed.Conn)
+}
+
+func TestServer_WithObserver_serverClose(t *testing.T) {
+	events := &eventLog{}
+	ts, wsServer, shutdown := server(t, WithObserver(ObserverFunc(events.Observe)))
+	defer shutdown()
+	wsServer.On("kick", func(c Connection, msg *Message) {
+		_ = c.CloseWith(ws.StatusPolicyViolation, "kicked")
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+	writeMessage(t, c, "kick", nil)
+	require.Equal(t, ws.StatusPolicyViolation, events.wait(t, ConnectionClosed).Code)
+}
+
+func TestEventType_String(t *testing.T) {
+	require.Equal(t, "connection_closed", ConnectionClosed.String())
+	require.Equal(t, "unknown", EventType(0).String())
+}
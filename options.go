@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"time"
+)
+
+// Option is a function which allows to configure the Server.
+type Option func(s *Server)
+
+// WithNetpoll enables event-driven reading of connections.
+// Instead of goroutine per connection blocked on reading, connections are registered
+// in epoll and read only when data is available. Pings are sent from one shared loop.
+// It's useful when the server keeps a lot of mostly-idle connections.
+// Works only on linux with plain TCP connections, in other cases the read loop is used.
+func WithNetpoll() Option {
+	return func(s *Server) {
+		s.netpoll = true
+	}
+}
+
+// WithMaxMessageSize limits the size of message in bytes received from client.
+// For fragmented messages the limit applies to the sum of all fragments.
+// Connection which exceeds the limit is closed with 1009 (Message Too Big) status.
+// Zero means no limit.
+func WithMaxMessageSize(n int64) Option {
+	return func(s *Server) {
+		s.maxMessageSize = n
+	}
+}
+
+// MaxMessageSize return the limit set by WithMaxMessageSize, zero means no limit.
+func (s *Server) MaxMessageSize() int64 {
+	return s.maxMessageSize
+}
+
+// WithReadTimeout sets the maximum time between frames received from client.
+// Pings are sent every PingInterval, so the timeout should be bigger to not drop healthy connections.
+// Connection which doesn't send anything in time is dropped. Zero means no timeout (default).
+func WithReadTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.readTimeout = d
+	}
+}
+
+// WithWriteTimeout sets the maximum time of writing one message to connection.
+// Default is DefaultWriteTimeout, zero means no timeout.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.writeTimeout = d
+	}
+}
+
+// WithFragmentSize sets the maximum payload size of outgoing frame.
+// Bigger messages are sent as a sequence of continuation frames.
+// Some intermediaries and embedded clients reject large frames. Zero means no fragmentation (default).
+func WithFragmentSize(n int) Option {
+	return func(s *Server) {
+		s.fragmentSize = n
+	}
+}
+
+// WithTextMessages makes connections send messages in text frames instead of binary,
+// it could be changed per connection with Conn.SetTextMessages.
+func WithTextMessages() Option {
+	return func(s *Server) {
+		s.textMessages = true
+	}
+}
+
+// WithBroadcastBuffer sets the size of queue of messages passed to Server.Emit, default is DefaultBroadcastBuffer.
+// Emit return ErrBroadcastFull when queue is full, EmitContext waits for room.
+func WithBroadcastBuffer(n int) Option {
+	return func(s *Server) {
+		s.broadcastBuffer = n
+	}
+}
+
+// WithSyncConnect runs OnConnect callbacks before the first message of connection is read,
+// so channel joins and auth state set in OnConnect are ready for handlers. By default they run
+// in own goroutine, OnConnect must not block with this option as it holds reading of the connection.
+func WithSyncConnect() Option {
+	return func(s *Server) {
+		s.syncConnect = true
+	}
+}
@@ -0,0 +1,134 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PatternSeparator splits hierarchical channel ids (org.team.project) into segments.
+const PatternSeparator = "."
+
+// MatchChannel reports whether channel id matches the pattern.
+// Pattern segments are separated by dot, "*" matches exactly one segment
+// and "**" matches any number of segments (including none):
+//
+//	org.*.project  matches org.team.project
+//	org.**         matches org, org.team and org.team.project
+func MatchChannel(pattern, id string) bool {
+	return matchSegments(strings.Split(pattern, PatternSeparator), strings.Split(id, PatternSeparator))
+}
+
+func matchSegments(pattern, id []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case "**":
+			for i := 0; i <= len(id); i++ {
+				if matchSegments(pattern[1:], id[i:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(id) == 0 {
+				return false
+			}
+		default:
+			if len(id) == 0 || pattern[0] != id[0] {
+				return false
+			}
+		}
+		pattern, id = pattern[1:], id[1:]
+	}
+	return len(id) == 0
+}
+
+func validPattern(pattern string) error {
+	for _, s := range strings.Split(pattern, PatternSeparator) {
+		if s == "" {
+			return fmt.Errorf("websocket: invalid channel pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// ChannelsMatching return the channels which id matches the pattern (see MatchChannel).
+func (s *Server) ChannelsMatching(pattern string) []*Channel {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []*Channel
+	for id, ch := range s.channels {
+		if MatchChannel(pattern, id) {
+			list = append(list, ch)
+		}
+	}
+	return list
+}
+
+// EmitToPattern emit message to all channels which id matches the pattern (see MatchChannel).
+// Connection which is in several matched channels receives the message once,
+// with WithChannelSequence it receives the message from every channel, as each channel has own sequence.
+func (s *Server) EmitToPattern(pattern string, name string, data any) error {
+	if err := validPattern(pattern); err != nil {
+		return err
+	}
+
+	return emitToChannels(s.ChannelsMatching(pattern), name, data)
+}
+
+// EmitToChannels emit message to the union of connections of channels with ids, unknown ids are skipped.
+// Data is marshaled once and connection which is in several channels receives the message once,
+// with WithChannelSequence it receives the message from every channel, as each channel has own sequence.
+func (s *Server) EmitToChannels(ids []string, name string, data any) error {
+	s.mu.RLock()
+	channels := make([]*Channel, 0, len(ids))
+	for _, id := range ids {
+		if ch, ok := s.channels[id]; ok && !slices.Contains(channels, ch) {
+			channels = append(channels, ch)
+		}
+	}
+	s.mu.RUnlock()
+
+	return emitToChannels(channels, name, data)
+}
+
+// emitToChannels emit message to the union of channels connections.
+// Connections which failed to receive the message are closed.
+func emitToChannels(channels []*Channel, name string, data any) error {
+	if len(channels) == 0 {
+		return nil
+	}
+
+	// []byte is kept as is, it's sent raw in binary envelope
+	if _, ok := data.([]byte); !ok && data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		data = json.RawMessage(b)
+	}
+
+	seen := make(map[Connection]struct{})
+	for _, ch := range channels {
+		if ch.sequenced() {
+			ch.emitSequenced(name, data)
+			continue
+		}
+
+		ch.persist(name, data, 0)
+		for _, c := range ch.snapshot() {
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+
+			if err := c.Emit(name, data); err != nil {
+				_ = c.Close()
+				ch.Remove(c)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMatchChannel(t *testing.T) {
+	tbl := []struct {
+		pattern, id string
+		match       bool
+	}{
+		{"org.team.project", "org.team.project", true},
+		{"org.team.project", "org.team.other", false},
+		{"org.*.project", "org.team.project", true},
+		{"org.*", "org.team", true},
+		{"org.*", "org.team.project", false},
+		{"org.*", "org", false},
+		{"org.**", "org", true},
+		{"org.**", "org.team.project", true},
+		{"**.project", "org.team.project", true},
+		{"org.**.project", "org.project", true},
+		{"org.**.project", "org.a.b.project", true},
+		{"org.**.project", "org.a.b.other", false},
+		{"**", "anything.at.all", true},
+		{"room.*", "lobby.1", false},
+	}
+
+	for _, tt := range tbl {
+		require.Equal(t, tt.match, MatchChannel(tt.pattern, tt.id), "%s ~ %s", tt.pattern, tt.id)
+	}
+}
+
+func TestServer_EmitToPattern(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	joinChannel(t, c, "org.team.a")
+	joinChannel(t, c, "org.team.b")
+
+	other := dial(t, ts)
+	defer func() {
+		require.NoError(t, other.Close())
+	}()
+	joinChannel(t, other, "org.sales.a")
+
+	require.Len(t, wsServer.ChannelsMatching("org.team.*"), 2)
+	require.NoError(t, wsServer.EmitToPattern("org.team.*", "hello", 1))
+	require.NoError(t, wsServer.EmitToPattern("org.sales.**", "bye", 2))
+
+	name, data := readEnvelope(t, c)
+	require.Equal(t, "hello", name)
+	require.Equal(t, "1", string(data))
+
+	name, _ = readEnvelope(t, other)
+	require.Equal(t, "bye", name, "connection must receive only messages of its channels")
+
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, _, err := wsutil.ReadServerData(c)
+	require.Error(t, err, "connection in two channels must receive message once")
+}
+
+func TestServer_EmitToPattern_invalid(t *testing.T) {
+	wsServer := New()
+	require.Error(t, wsServer.EmitToPattern("org..team", "hello", 1))
+	require.Error(t, wsServer.EmitToPattern("", "hello", 1))
+}
+
+func TestServer_EmitToChannels(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	joinChannel(t, c, "room-1")
+	joinChannel(t, c, "room-2")
+
+	other := dial(t, ts)
+	defer func() {
+		require.NoError(t, other.Close())
+	}()
+	joinChannel(t, other, "room-3")
+
+	require.NoError(t, wsServer.EmitToChannels([]string{"room-1", "room-2", "room-2", "unknown"}, "hello", map[string]int{"n": 1}))
+	require.NoError(t, wsServer.EmitToChannels([]string{"room-3"}, "bye", []byte("raw")))
+	require.Error(t, wsServer.EmitToChannels([]string{"room-3"}, "bad", func() {}))
+
+	name, data := readEnvelope(t, c)
+	require.Equal(t, "hello", name)
+	require.JSONEq(t, `{"n":1}`, string(data))
+
+	name, data = readEnvelope(t, other)
+	require.Equal(t, "bye", name, "connection must receive only messages of its channels")
+	require.Equal(t, `"raw"`, string(data), "[]byte must be sent as string")
+
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, _, err := wsutil.ReadServerData(c)
+	require.Error(t, err, "connection in two channels must receive message once")
+}
@@ -0,0 +1,358 @@
+// Package phoenixws speaks Phoenix Channels wire format (serializer V2), so phoenix.js clients connect
+// to websocket.Server. Messages are json arrays [join_ref, ref, topic, event, payload], topics are mapped
+// to channels of the server and client pushes get phx_reply with the result of handler:
+//
+//	srv := websocket.New()
+//	px := phoenixws.New(srv)
+//	px.OnJoin("room:*", func(c websocket.Connection, topic string, payload json.RawMessage) (any, error) {
+//		return map[string]string{"welcome": topic}, nil
+//	})
+//	px.On("new_msg", func(c websocket.Connection, topic string, payload json.RawMessage) (any, error) {
+//		return nil, px.Broadcast(topic, "new_msg", payload)
+//	})
+//	srv.Run(ctx)
+//	http.HandleFunc("/socket/websocket", srv.Handler)
+//
+// Clients connect with new Socket("/socket") and channel.join(). Binary serializer is not supported.
+// Server must be used for Phoenix only, New replaces OnDisconnect, OnMessage and OnSubscribe of the server.
+// Generic _subscribe events are rejected, they would skip OnJoin callbacks.
+package phoenixws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/pkgz/websocket"
+	"strings"
+	"sync"
+)
+
+// Events of the protocol.
+const (
+	EventJoin      = "phx_join"
+	EventLeave     = "phx_leave"
+	EventReply     = "phx_reply"
+	EventClose     = "phx_close"
+	EventError     = "phx_error"
+	EventHeartbeat = "heartbeat"
+)
+
+// Topic is the topic of socket level messages (heartbeat).
+const Topic = "phoenix"
+
+// Statuses of replies.
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+var (
+	// ErrUnmatchedTopic is replied to join of topic without OnJoin callback.
+	ErrUnmatchedTopic = errors.New("unmatched topic")
+	// ErrNotJoined is replied to push to topic which client didn't join.
+	ErrNotJoined = errors.New("not joined")
+	// ErrUnhandledEvent is replied to push of event without callback.
+	ErrUnhandledEvent = errors.New("unhandled event")
+	// ErrNativeSubscribe is sent for _subscribe events of websocket.Server, clients join topics with phx_join.
+	ErrNativeSubscribe = errors.New("phoenixws: use phx_join")
+)
+
+// JoinFunc authorize join of topic, returned response is sent in reply and error rejects the join.
+type JoinFunc func(c websocket.Connection, topic string, payload json.RawMessage) (any, error)
+
+// HandlerFunc handle push of client, returned response is sent in reply, error is sent as reply with error status.
+type HandlerFunc func(c websocket.Connection, topic string, payload json.RawMessage) (any, error)
+
+// Server serves Phoenix Channels on websocket.Server.
+type Server struct {
+	srv *websocket.Server
+
+	joins    []join
+	handlers map[string]HandlerFunc
+	// joined keeps join_ref of topics joined by connection
+	joined map[websocket.Connection]map[string]string
+	mu     sync.RWMutex
+}
+
+// join is OnJoin callback of topic pattern.
+type join struct {
+	pattern string
+	f       JoinFunc
+}
+
+// message is the wire format of the protocol.
+type message struct {
+	JoinRef *string
+	Ref     *string
+	Topic   string
+	Event   string
+	Payload json.RawMessage
+}
+
+// MarshalJSON encode message as json array.
+func (m message) MarshalJSON() ([]byte, error) {
+	payload := m.Payload
+	if payload == nil {
+		payload = json.RawMessage(`{}`)
+	}
+	return json.Marshal([]any{m.JoinRef, m.Ref, m.Topic, m.Event, payload})
+}
+
+// UnmarshalJSON decode message from json array.
+func (m *message) UnmarshalJSON(b []byte) error {
+	var arr []json.RawMessage
+	if err := json.Unmarshal(b, &arr); err != nil {
+		return err
+	}
+	if len(arr) != 5 {
+		return errors.New("phoenixws: message must have 5 elements")
+	}
+	if err := json.Unmarshal(arr[0], &m.JoinRef); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(arr[1], &m.Ref); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(arr[2], &m.Topic); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(arr[3], &m.Event); err != nil {
+		return err
+	}
+	m.Payload = arr[4]
+	return nil
+}
+
+// reply is the payload of phx_reply.
+type reply struct {
+	Status   string `json:"status"`
+	Response any    `json:"response"`
+}
+
+// New serve Phoenix Channels on the server.
+func New(srv *websocket.Server) *Server {
+	s := &Server{
+		srv:      srv,
+		handlers: make(map[string]HandlerFunc),
+		joined:   make(map[websocket.Connection]map[string]string),
+	}
+
+	srv.OnDisconnect(s.close)
+	srv.OnMessage(func(c websocket.Connection, _ ws.Header, b []byte) {
+		s.message(c, b)
+	})
+	srv.OnSubscribe(func(context.Context, websocket.Connection, string) error {
+		return ErrNativeSubscribe
+	})
+	return s
+}
+
+// OnJoin sets callback for topics matching pattern: exact topic or prefix with * at the end (room:*).
+// The first matching pattern is used, joins of topics without callback are rejected with ErrUnmatchedTopic.
+func (s *Server) OnJoin(pattern string, f JoinFunc) {
+	s.mu.Lock()
+	s.joins = append(s.joins, join{pattern: pattern, f: f})
+	s.mu.Unlock()
+}
+
+// On register handler for event pushed by client to any joined topic.
+func (s *Server) On(event string, f HandlerFunc) {
+	s.mu.Lock()
+	s.handlers[event] = f
+	s.mu.Unlock()
+}
+
+// Broadcast sends event to all connections which joined topic.
+func (s *Server) Broadcast(topic, event string, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	ch := s.srv.Channel(topic)
+	if ch == nil {
+		return nil
+	}
+
+	var errs []error
+	ch.ForEach(func(c websocket.Connection) bool {
+		if err := write(c, message{Topic: topic, Event: event, Payload: b}); err != nil {
+			errs = append(errs, err)
+		}
+		return true
+	})
+	return errors.Join(errs...)
+}
+
+// Push sends event to connection which joined topic.
+func (s *Server) Push(c websocket.Connection, topic, event string, payload any) error {
+	joinRef, ok := s.joinRef(c, topic)
+	if !ok {
+		return ErrNotJoined
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return write(c, message{JoinRef: &joinRef, Topic: topic, Event: event, Payload: b})
+}
+
+// Leave removes connection from topic and sends phx_close to it.
+func (s *Server) Leave(c websocket.Connection, topic string) error {
+	joinRef, ok := s.leave(c, topic)
+	if !ok {
+		return ErrNotJoined
+	}
+	return write(c, message{JoinRef: &joinRef, Ref: &joinRef, Topic: topic, Event: EventClose})
+}
+
+// message handle frame of client.
+func (s *Server) message(c websocket.Connection, b []byte) {
+	var msg message
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return
+	}
+
+	switch {
+	case msg.Topic == Topic && msg.Event == EventHeartbeat:
+		s.reply(c, msg, nil, nil)
+	case msg.Event == EventJoin:
+		resp, err := s.join(c, msg)
+		s.reply(c, msg, resp, err)
+	case msg.Event == EventLeave:
+		s.leave(c, msg.Topic)
+		s.reply(c, msg, nil, nil)
+	default:
+		resp, err := s.push(c, msg)
+		s.reply(c, msg, resp, err)
+	}
+}
+
+// join call OnJoin callback of topic and add connection to channel of the topic.
+func (s *Server) join(c websocket.Connection, msg message) (any, error) {
+	if msg.JoinRef == nil {
+		return nil, errors.New("join_ref is required")
+	}
+
+	var f JoinFunc
+	s.mu.RLock()
+	for _, j := range s.joins {
+		if match(j.pattern, msg.Topic) {
+			f = j.f
+			break
+		}
+	}
+	s.mu.RUnlock()
+	if f == nil {
+		return nil, ErrUnmatchedTopic
+	}
+
+	resp, err := f(c, msg.Topic, msg.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := s.srv.Channel(msg.Topic)
+	if ch == nil {
+		ch = s.srv.NewChannel(msg.Topic)
+	}
+	// failed rejoin keeps the previous join
+	if err = ch.Add(c); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.joined[c] == nil {
+		s.joined[c] = make(map[string]string)
+	}
+	old, ok := s.joined[c][msg.Topic]
+	s.joined[c][msg.Topic] = *msg.JoinRef
+	s.mu.Unlock()
+
+	// the previous join of the same topic is closed, as Phoenix does
+	if ok && old != *msg.JoinRef {
+		_ = write(c, message{JoinRef: &old, Ref: &old, Topic: msg.Topic, Event: EventClose})
+	}
+	return resp, nil
+}
+
+// push call handler of event pushed to joined topic.
+func (s *Server) push(c websocket.Connection, msg message) (any, error) {
+	joinRef, ok := s.joinRef(c, msg.Topic)
+	if !ok || msg.JoinRef == nil || *msg.JoinRef != joinRef {
+		return nil, ErrNotJoined
+	}
+
+	s.mu.RLock()
+	f := s.handlers[msg.Event]
+	s.mu.RUnlock()
+	if f == nil {
+		return nil, ErrUnhandledEvent
+	}
+	return f(c, msg.Topic, msg.Payload)
+}
+
+// leave remove connection from topic, it returns join_ref of topic.
+func (s *Server) leave(c websocket.Connection, topic string) (string, bool) {
+	s.mu.Lock()
+	joinRef, ok := s.joined[c][topic]
+	delete(s.joined[c], topic)
+	s.mu.Unlock()
+
+	if ch := s.srv.Channel(topic); ch != nil {
+		ch.Remove(c)
+	}
+	return joinRef, ok
+}
+
+// close forget topics of closed connection, it leaves channels with the websocket connection.
+func (s *Server) close(c websocket.Connection) {
+	s.mu.Lock()
+	delete(s.joined, c)
+	s.mu.Unlock()
+}
+
+// joinRef return join_ref of topic joined by connection.
+func (s *Server) joinRef(c websocket.Connection, topic string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ref, ok := s.joined[c][topic]
+	return ref, ok
+}
+
+// reply send phx_reply for message with ok or error status. Messages without ref don't expect reply.
+func (s *Server) reply(c websocket.Connection, msg message, resp any, err error) {
+	if msg.Ref == nil {
+		return
+	}
+
+	r := reply{Status: StatusOK, Response: resp}
+	if err != nil {
+		r = reply{Status: StatusError, Response: map[string]string{"reason": err.Error()}}
+	}
+	if r.Response == nil {
+		r.Response = struct{}{}
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	_ = write(c, message{JoinRef: msg.JoinRef, Ref: msg.Ref, Topic: msg.Topic, Event: EventReply, Payload: b})
+}
+
+// match reports whether topic matches pattern, pattern with * at the end matches prefix.
+func match(pattern, topic string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(topic, prefix)
+	}
+	return pattern == topic
+}
+
+// write the message in text frame.
+func write(c websocket.Connection, msg message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.Write(ws.Header{Fin: true, OpCode: ws.OpText, Length: int64(len(b))}, b)
+}
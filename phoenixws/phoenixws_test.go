@@ -0,0 +1,205 @@
+package phoenixws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/pkgz/websocket"
+	"github.com/pkgz/websocket/websockettest"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func start(t *testing.T) (*websocket.Server, *Server) {
+	srv := websocket.New()
+	px := New(srv)
+	srv.Run(context.Background())
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+	return srv, px
+}
+
+func read(t *testing.T, c *websockettest.Client) message {
+	b, err := c.ReadRaw()
+	require.NoError(t, err)
+	var msg message
+	require.NoError(t, json.Unmarshal(b, &msg))
+	return msg
+}
+
+// expectReply read phx_reply with ref and return its status and response.
+func expectReply(t *testing.T, c *websockettest.Client, ref string) (string, string) {
+	msg := read(t, c)
+	require.Equal(t, EventReply, msg.Event)
+	require.NotNil(t, msg.Ref)
+	require.Equal(t, ref, *msg.Ref)
+
+	var r struct {
+		Status   string          `json:"status"`
+		Response json.RawMessage `json:"response"`
+	}
+	require.NoError(t, json.Unmarshal(msg.Payload, &r))
+	return r.Status, string(r.Response)
+}
+
+func TestServer_heartbeat(t *testing.T) {
+	srv, _ := start(t)
+	_, client := websockettest.NewPair(t, srv)
+
+	require.NoError(t, client.Send([]byte(`[null,"1","phoenix","heartbeat",{}]`)))
+	msg := read(t, client)
+	require.Nil(t, msg.JoinRef)
+	require.Equal(t, Topic, msg.Topic)
+	require.JSONEq(t, `{"status":"ok","response":{}}`, string(msg.Payload))
+}
+
+func TestServer_join(t *testing.T) {
+	srv, px := start(t)
+	px.OnJoin("room:*", func(c websocket.Connection, topic string, payload json.RawMessage) (any, error) {
+		var p struct {
+			Token string `json:"token"`
+		}
+		if err := json.Unmarshal(payload, &p); err != nil || p.Token != "secret" {
+			return nil, errors.New("unauthorized")
+		}
+		return map[string]string{"welcome": topic}, nil
+	})
+	_, client := websockettest.NewPair(t, srv)
+
+	require.NoError(t, client.Send([]byte(`["1","1","room:lobby","phx_join",{"token":"wrong"}]`)))
+	status, resp := expectReply(t, client, "1")
+	require.Equal(t, StatusError, status)
+	require.JSONEq(t, `{"reason":"unauthorized"}`, resp)
+	require.Nil(t, srv.Channel("room:lobby"))
+
+	require.NoError(t, client.Send([]byte(`["2","2","admin","phx_join",{}]`)))
+	status, resp = expectReply(t, client, "2")
+	require.Equal(t, StatusError, status)
+	require.JSONEq(t, `{"reason":"unmatched topic"}`, resp)
+
+	require.NoError(t, client.Send([]byte(`["3","3","room:lobby","phx_join",{"token":"secret"}]`)))
+	status, resp = expectReply(t, client, "3")
+	require.Equal(t, StatusOK, status)
+	require.JSONEq(t, `{"welcome":"room:lobby"}`, resp)
+	require.Equal(t, 1, srv.Channel("room:lobby").Count())
+
+	// rejoin closes the previous join
+	require.NoError(t, client.Send([]byte(`["4","4","room:lobby","phx_join",{"token":"secret"}]`)))
+	msg := read(t, client)
+	require.Equal(t, EventClose, msg.Event)
+	require.Equal(t, "3", *msg.JoinRef)
+	status, _ = expectReply(t, client, "4")
+	require.Equal(t, StatusOK, status)
+
+	require.NoError(t, client.Send([]byte(`["4","5","room:lobby","phx_leave",{}]`)))
+	status, _ = expectReply(t, client, "5")
+	require.Equal(t, StatusOK, status)
+	require.Equal(t, 0, srv.Channel("room:lobby").Count())
+}
+
+func TestServer_push(t *testing.T) {
+	srv, px := start(t)
+	px.OnJoin("room:lobby", func(websocket.Connection, string, json.RawMessage) (any, error) {
+		return nil, nil
+	})
+	px.On("new_msg", func(c websocket.Connection, topic string, payload json.RawMessage) (any, error) {
+		return map[string]bool{"sent": true}, px.Broadcast(topic, "new_msg", payload)
+	})
+	_, alice := websockettest.NewPair(t, srv)
+	bobConn, bob := websockettest.NewPair(t, srv)
+
+	require.NoError(t, alice.Send([]byte(`["1","1","room:lobby","new_msg",{}]`)))
+	status, resp := expectReply(t, alice, "1")
+	require.Equal(t, StatusError, status)
+	require.JSONEq(t, `{"reason":"not joined"}`, resp)
+
+	for _, c := range []*websockettest.Client{alice, bob} {
+		require.NoError(t, c.Send([]byte(`["1","2","room:lobby","phx_join",{}]`)))
+		status, _ = expectReply(t, c, "2")
+		require.Equal(t, StatusOK, status)
+	}
+
+	require.NoError(t, alice.Send([]byte(`["1","3","room:lobby","new_msg",{"body":"hi"}]`)))
+	msg := read(t, bob)
+	require.Nil(t, msg.JoinRef)
+	require.Nil(t, msg.Ref)
+	require.Equal(t, "room:lobby", msg.Topic)
+	require.Equal(t, "new_msg", msg.Event)
+	require.JSONEq(t, `{"body":"hi"}`, string(msg.Payload))
+
+	// alice gets broadcast before reply
+	require.Equal(t, "new_msg", read(t, alice).Event)
+	status, resp = expectReply(t, alice, "3")
+	require.Equal(t, StatusOK, status)
+	require.JSONEq(t, `{"sent":true}`, resp)
+
+	require.NoError(t, alice.Send([]byte(`["1","4","room:lobby","unknown",{}]`)))
+	status, resp = expectReply(t, alice, "4")
+	require.Equal(t, StatusError, status)
+	require.JSONEq(t, `{"reason":"unhandled event"}`, resp)
+
+	require.NoError(t, px.Push(bobConn, "room:lobby", "notice", map[string]int{"n": 1}))
+	msg = read(t, bob)
+	require.Equal(t, "1", *msg.JoinRef)
+	require.Equal(t, "notice", msg.Event)
+	require.ErrorIs(t, px.Push(bobConn, "room:other", "notice", nil), ErrNotJoined)
+
+	require.NoError(t, px.Leave(bobConn, "room:lobby"))
+	msg = read(t, bob)
+	require.Equal(t, EventClose, msg.Event)
+	require.Equal(t, 1, srv.Channel("room:lobby").Count())
+	require.ErrorIs(t, px.Leave(bobConn, "room:lobby"), ErrNotJoined)
+}
+
+func TestServer_join_rejoinFailed(t *testing.T) {
+	srv, px := start(t)
+	px.OnJoin("room:lobby", func(websocket.Connection, string, json.RawMessage) (any, error) {
+		return nil, nil
+	})
+	px.On("ping", func(websocket.Connection, string, json.RawMessage) (any, error) {
+		return nil, nil
+	})
+	aliceConn, alice := websockettest.NewPair(t, srv)
+	_, bob := websockettest.NewPair(t, srv)
+
+	require.NoError(t, alice.Send([]byte(`["1","1","room:lobby","phx_join",{}]`)))
+	status, _ := expectReply(t, alice, "1")
+	require.Equal(t, StatusOK, status)
+
+	// alice loses the place in full channel, so her rejoin fails
+	ch := srv.Channel("room:lobby")
+	ch.SetLimit(1)
+	ch.Remove(aliceConn)
+	require.NoError(t, bob.Send([]byte(`["1","1","room:lobby","phx_join",{}]`)))
+	status, _ = expectReply(t, bob, "1")
+	require.Equal(t, StatusOK, status)
+
+	require.NoError(t, alice.Send([]byte(`["2","2","room:lobby","phx_join",{}]`)))
+	status, _ = expectReply(t, alice, "2")
+	require.Equal(t, StatusError, status, "failed rejoin must not close the previous join")
+
+	require.NoError(t, alice.Send([]byte(`["1","3","room:lobby","ping",{}]`)))
+	status, _ = expectReply(t, alice, "3")
+	require.Equal(t, StatusOK, status, "previous join must stay")
+}
+
+func TestMatch(t *testing.T) {
+	require.True(t, match("room:*", "room:lobby"))
+	require.True(t, match("*", "any"))
+	require.True(t, match("room:lobby", "room:lobby"))
+	require.False(t, match("room:lobby", "room:other"))
+	require.False(t, match("room:*", "rooms"))
+}
+
+func TestServer_nativeSubscribe(t *testing.T) {
+	srv, px := start(t)
+	px.OnJoin("room:*", func(c websocket.Connection, topic string, payload json.RawMessage) (any, error) {
+		return nil, errors.New("forbidden")
+	})
+	_, client := websockettest.NewPair(t, srv)
+
+	client.Emit(t, websocket.EventSubscribe, map[string]string{"channel": "room:lobby"})
+	require.Contains(t, client.Expect(t, websocket.EventError).String(), "phx_join")
+	require.Nil(t, srv.Channel("room:lobby"), "topic must not be joined without OnJoin")
+}
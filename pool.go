@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gobwas/ws"
+	"sync"
+)
+
+// maxPooledBuffer is the biggest buffer which will be returned to the pool.
+// Bigger buffers are left for GC, so one huge message doesn't pin memory forever.
+const maxPooledBuffer = 64 << 10
+
+var bufferPool sync.Pool
+
+// getBuffer return a byte slice with length n from the pool.
+func getBuffer(n int) *[]byte {
+	poolCounters.bufferGets.Add(1)
+	if p, ok := bufferPool.Get().(*[]byte); ok && cap(*p) >= n {
+		*p = (*p)[:n]
+		return p
+	}
+
+	poolCounters.bufferNews.Add(1)
+	b := make([]byte, n)
+	return &b
+}
+
+// putBuffer return the byte slice to the pool.
+func putBuffer(p *[]byte) {
+	if cap(*p) > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(p)
+}
+
+// envelope is the wire format of named message.
+type envelope struct {
+	ID      uint64 `json:"id,omitempty"`
+	Name    string `json:"name"`
+	Data    any    `json:"data"`
+	Channel string `json:"channel,omitempty"`
+	Seq     uint64 `json:"seq,omitempty"`
+	// op is the opcode of frame, zero means the default of connection
+	op ws.OpCode
+}
+
+// encoder is json.Encoder with its own buffer, reused through encoderPool.
+type encoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var encoderPool = sync.Pool{
+	New: func() any {
+		poolCounters.encoderNews.Add(1)
+		e := &encoder{}
+		e.enc = json.NewEncoder(&e.buf)
+		return e
+	},
+}
+
+func getEncoder() *encoder {
+	poolCounters.encoderGets.Add(1)
+	e := encoderPool.Get().(*encoder)
+	e.buf.Reset()
+	return e
+}
+
+func putEncoder(e *encoder) {
+	if e.buf.Cap() > maxPooledBuffer {
+		return
+	}
+	encoderPool.Put(e)
+}
+
+// encode v to json. Returned bytes are valid until encoder is returned to the pool.
+func (e *encoder) encode(v any) ([]byte, error) {
+	if err := e.enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	b := e.buf.Bytes()
+	return b[:len(b)-1], nil
+}
@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"encoding/json"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestPool_buffer(t *testing.T) {
+	b := getBuffer(10)
+	require.Equal(t, 10, len(*b))
+	putBuffer(b)
+
+	b = getBuffer(5)
+	require.Equal(t, 5, len(*b))
+	putBuffer(b)
+
+	b = getBuffer(maxPooledBuffer + 1)
+	require.Equal(t, maxPooledBuffer+1, len(*b))
+	putBuffer(b)
+}
+
+func TestPool_encoder(t *testing.T) {
+	msg := envelope{
+		Name: "test",
+		Data: map[string]string{"html": "<b>"},
+	}
+
+	e := getEncoder()
+	b, err := e.encode(msg)
+	require.NoError(t, err)
+
+	expected, err := json.Marshal(msg)
+	require.NoError(t, err)
+	require.Equal(t, expected, b, "encoder must produce the same output as json.Marshal")
+	putEncoder(e)
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	msg := envelope{Name: "bench", Data: "Hello World"}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		e := getEncoder()
+		if _, err := e.encode(msg); err != nil {
+			b.Fatal(err)
+		}
+		putEncoder(e)
+	}
+}
+
+func BenchmarkEncoder_marshal(b *testing.B) {
+	msg := envelope{Name: "bench", Data: "Hello World"}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConn_Emit(b *testing.B) {
+	server, client := net.Pipe()
+	defer func() {
+		_ = server.Close()
+		_ = client.Close()
+	}()
+	go func() {
+		_, _ = io.Copy(io.Discard, client)
+	}()
+
+	c := &Conn{conn: server}
+	data := map[string]string{"text": "Hello World"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Emit("bench", data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkServer_readFrame(b *testing.B) {
+	server, client := net.Pipe()
+	defer func() {
+		_ = server.Close()
+		_ = client.Close()
+	}()
+
+	payload := []byte(`{"name":"bench","data":"Hello World"}`)
+	frame := ws.MaskFrame(ws.NewTextFrame(payload))
+	go func() {
+		for {
+			if err := ws.WriteFrame(client, frame); err != nil {
+				return
+			}
+		}
+	}()
+
+	s := New()
+	s.OnMessage(func(c Connection, h ws.Header, b []byte) {})
+	c := &Conn{conn: server, reader: newFrameReader()}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.readFrame(c, server); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
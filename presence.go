@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"sort"
+)
+
+// Presence events are emitted to channel connections when members come and leave.
+// Data is the Member.
+const (
+	EventMemberAdded   = "member_added"
+	EventMemberRemoved = "member_removed"
+)
+
+// Member is a connection of presence channel with application-provided info.
+type Member struct {
+	ID   string `json:"id"`
+	Info any    `json:"info,omitempty"`
+}
+
+// SetPresence makes channel a presence channel. Function f returns the member info for connection
+// (e.g. user name from auth), it's called once when connection is added.
+// Other connections of channel receive member_added and member_removed events.
+// Connections added before SetPresence are not tracked.
+func (c *Channel) SetPresence(f func(c Connection) any) {
+	c.mu.Lock()
+	c.presence = f
+	c.mu.Unlock()
+}
+
+// Members return the members of presence channel sorted by connection id.
+func (c *Channel) Members() []Member {
+	c.mu.Lock()
+	list := make([]Member, 0, len(c.members))
+	for _, m := range c.members {
+		list = append(list, m)
+	}
+	c.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// emitExcept emit message to all connections in channel except one.
+func (c *Channel) emitExcept(except Connection, name string, data any) {
+	for _, con := range c.snapshot() {
+		if con == except {
+			continue
+		}
+		if err := con.Emit(name, data); err != nil {
+			_ = con.Close()
+			c.Remove(con)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"encoding/json"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChannel_presence(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	var n atomic.Int32
+	ch := wsServer.NewChannel("room")
+	ch.SetPresence(func(c Connection) any {
+		return map[string]int32{"n": n.Add(1)}
+	})
+
+	join := func() net.Conn {
+		c := dial(t, ts)
+		writeMessage(t, c, EventSubscribe, map[string]string{"channel": "room"})
+		b, _, err := wsutil.ReadServerData(c)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"name":"_subscribe","data":{"channel":"room"}}`, string(b))
+		return c
+	}
+
+	c1 := join()
+	defer func() {
+		_ = c1.Close()
+	}()
+	require.Len(t, ch.Members(), 1)
+
+	c2 := join()
+
+	var msg struct {
+		Name string `json:"name"`
+		Data Member `json:"data"`
+	}
+	b, _, err := wsutil.ReadServerData(c1)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &msg))
+	require.Equal(t, EventMemberAdded, msg.Name)
+	require.Equal(t, map[string]any{"n": float64(2)}, msg.Data.Info)
+
+	members := ch.Members()
+	require.Len(t, members, 2)
+	require.Contains(t, members, Member{ID: msg.Data.ID, Info: map[string]int32{"n": 2}})
+
+	require.NoError(t, c2.Close())
+
+	b, _, err = wsutil.ReadServerData(c1)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &msg))
+	require.Equal(t, EventMemberRemoved, msg.Name)
+	require.Equal(t, map[string]any{"n": float64(2)}, msg.Data.Info)
+
+	require.Eventually(t, func() bool {
+		return len(ch.Members()) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, map[string]int32{"n": 1}, ch.Members()[0].Info)
+}
+
+func TestChannel_presence_disabled(t *testing.T) {
+	ch := newChannel("room")
+	ch.Add(&Conn{id: "1"})
+	require.Empty(t, ch.Members(), "members are tracked only in presence channel")
+}
@@ -0,0 +1,147 @@
+// Package protows maps event names to protobuf messages, so handlers receive decoded messages
+// and Emit accepts messages directly:
+//
+//	reg := protows.NewRegistry()
+//	reg.Register("chat.message", &chatpb.Message{})
+//
+//	protows.On(srv, reg, func(ctx context.Context, c websocket.Connection, msg *chatpb.Message) error {
+//		return reg.Emit(c, &chatpb.Message{Text: msg.Text})
+//	})
+//
+// Clients which select websocket.ProtocolBinary subprotocol send and receive messages in binary envelope
+// with protobuf payload, other clients use json envelope with protojson payload.
+package protows
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkgz/websocket"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"sync"
+)
+
+// Registry maps event names to protobuf message types, every name has one type and every type has one name.
+type Registry struct {
+	mu    sync.RWMutex
+	types map[string]protoreflect.MessageType
+	names map[protoreflect.FullName]string
+}
+
+// NewRegistry makes empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		types: make(map[string]protoreflect.MessageType),
+		names: make(map[protoreflect.FullName]string),
+	}
+}
+
+// Register maps event name to the type of m. It returns an error if name or type is already registered.
+func (r *Registry) Register(name string, m proto.Message) error {
+	mt := m.ProtoReflect().Type()
+	full := mt.Descriptor().FullName()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.types[name]; ok {
+		return fmt.Errorf("protows: event %q is already registered", name)
+	}
+	if n, ok := r.names[full]; ok {
+		return fmt.Errorf("protows: %s is already registered for %q", full, n)
+	}
+	r.types[name] = mt
+	r.names[full] = name
+	return nil
+}
+
+// Name return event name of the message type.
+func (r *Registry) Name(m proto.Message) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, ok := r.names[m.ProtoReflect().Descriptor().FullName()]
+	return name, ok
+}
+
+// Decode data of received event to the registered message, binary is true for binary envelope.
+func (r *Registry) Decode(name string, data []byte, binary bool) (proto.Message, error) {
+	r.mu.RLock()
+	mt, ok := r.types[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("protows: event %q is not registered", name)
+	}
+
+	m := mt.New().Interface()
+	if err := unmarshal(data, binary, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Data wraps message to be passed to Emit of connection, channel or server:
+// it's encoded with protobuf in binary envelope and with protojson in json envelope.
+func Data(m proto.Message) any {
+	return payload{m: m}
+}
+
+// Emit message to connection with the registered name.
+func (r *Registry) Emit(c websocket.Connection, m proto.Message) error {
+	name, ok := r.Name(m)
+	if !ok {
+		return fmt.Errorf("protows: %s is not registered", m.ProtoReflect().Descriptor().FullName())
+	}
+	return c.Emit(name, Data(m))
+}
+
+// EmitChannel emits message to all connections of channel with the registered name.
+func (r *Registry) EmitChannel(ch *websocket.Channel, m proto.Message) (websocket.BroadcastResult, error) {
+	name, ok := r.Name(m)
+	if !ok {
+		return websocket.BroadcastResult{}, fmt.Errorf("protows: %s is not registered", m.ProtoReflect().Descriptor().FullName())
+	}
+	return ch.Emit(name, Data(m)), nil
+}
+
+// On adding callback for event registered for T. Messages which can't be decoded don't reach the callback,
+// client receives _error event with websocket.CodeBadRequest instead, error of callback is replied in the same way.
+// It panics if T is not registered.
+func On[T proto.Message](s *websocket.Server, r *Registry, f func(ctx context.Context, c websocket.Connection, msg T) error) {
+	var zero T
+	name, ok := r.Name(zero)
+	if !ok {
+		panic(fmt.Sprintf("protows: %s is not registered", zero.ProtoReflect().Descriptor().FullName()))
+	}
+
+	s.Handle(name, func(c websocket.Connection, msg *websocket.Message) error {
+		m := zero.ProtoReflect().New().Interface().(T)
+		conn, ok := c.(*websocket.Conn)
+		binary := ok && conn.Envelope() == websocket.BinaryEnvelope
+		if err := unmarshal(msg.Data, binary, m); err != nil {
+			return websocket.NewError(websocket.CodeBadRequest, fmt.Sprintf("invalid payload: %v", err))
+		}
+		return f(c.Context(), c, m)
+	})
+}
+
+func unmarshal(data []byte, binary bool, m proto.Message) error {
+	if binary {
+		return proto.Unmarshal(data, m)
+	}
+	return protojson.Unmarshal(data, m)
+}
+
+// payload is protobuf message as data of envelope.
+type payload struct {
+	m proto.Message
+}
+
+// BinaryPayload implements websocket.BinaryPayload.
+func (p payload) BinaryPayload() ([]byte, error) {
+	return proto.Marshal(p.m)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p payload) MarshalJSON() ([]byte, error) {
+	return protojson.Marshal(p.m)
+}
@@ -0,0 +1,112 @@
+package protows
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/pkgz/websocket"
+	"github.com/pkgz/websocket/websockettest"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func registry(t *testing.T) *Registry {
+	reg := NewRegistry()
+	require.NoError(t, reg.Register("echo", &wrapperspb.StringValue{}))
+	require.NoError(t, reg.Register("time", &timestamppb.Timestamp{}))
+	require.Error(t, reg.Register("echo", &wrapperspb.Int64Value{}))
+	require.Error(t, reg.Register("echo2", &wrapperspb.StringValue{}))
+	return reg
+}
+
+func echoServer(t *testing.T, reg *Registry, opts ...websocket.Option) *websocket.Server {
+	srv := websocket.Start(context.Background(), opts...)
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+	On(srv, reg, func(ctx context.Context, c websocket.Connection, msg *wrapperspb.StringValue) error {
+		if msg.Value == "" {
+			return websocket.NewError(websocket.CodeBadRequest, "empty")
+		}
+		return reg.Emit(c, wrapperspb.String("echo: "+msg.Value))
+	})
+	return srv
+}
+
+func TestOn_json(t *testing.T) {
+	reg := registry(t)
+	_, client := websockettest.NewPair(t, echoServer(t, reg))
+
+	client.Emit(t, "echo", "hello")
+	client.ExpectJSON(t, "echo", "echo: hello")
+
+	client.Emit(t, "echo", map[string]int{"a": 1})
+	require.Contains(t, client.Expect(t, "_error").String(), "invalid payload")
+}
+
+func TestOn_binary(t *testing.T) {
+	reg := registry(t)
+	srv := echoServer(t, reg, websocket.WithEnvelope(websocket.BinaryEnvelope))
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() {
+		_ = srv.ServeStream(server, httptest.NewRequest("GET", "/ws", nil))
+	}()
+	require.NoError(t, client.SetDeadline(time.Now().Add(3*time.Second)))
+
+	b, err := proto.Marshal(wrapperspb.String("hello"))
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientMessage(client, ws.OpBinary, append([]byte{0, 4, 'e', 'c', 'h', 'o'}, b...)))
+
+	reply, _, err := wsutil.ReadServerData(client)
+	require.NoError(t, err)
+	require.Equal(t, byte(0), reply[0])
+	n, size := binary.Uvarint(reply[1:])
+	require.Equal(t, "echo", string(reply[1+size:1+size+int(n)]))
+
+	var msg wrapperspb.StringValue
+	require.NoError(t, proto.Unmarshal(reply[1+size+int(n):], &msg))
+	require.Equal(t, "echo: hello", msg.Value)
+}
+
+func TestRegistry(t *testing.T) {
+	reg := registry(t)
+
+	name, ok := reg.Name(timestamppb.New(time.Unix(0, 0)))
+	require.True(t, ok)
+	require.Equal(t, "time", name)
+	_, ok = reg.Name(&wrapperspb.BoolValue{})
+	require.False(t, ok)
+
+	m, err := reg.Decode("echo", []byte(`"hi"`), false)
+	require.NoError(t, err)
+	require.Equal(t, "hi", m.(*wrapperspb.StringValue).Value)
+	_, err = reg.Decode("unknown", nil, true)
+	require.Error(t, err)
+
+	srv := websocket.Start(context.Background())
+	defer func() {
+		_ = srv.Shutdown()
+	}()
+	require.Panics(t, func() {
+		On(srv, reg, func(ctx context.Context, c websocket.Connection, msg *wrapperspb.BoolValue) error { return nil })
+	})
+
+	conn, client := websockettest.NewPair(t, srv)
+	ch := srv.NewChannel("room")
+	ch.Add(conn)
+	res, err := reg.EmitChannel(ch, timestamppb.New(time.Unix(1, 0).UTC()))
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Delivered)
+	client.ExpectJSON(t, "time", "1970-01-01T00:00:01Z")
+
+	require.Error(t, reg.Emit(conn, &wrapperspb.BoolValue{}))
+}
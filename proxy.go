@@ -0,0 +1,296 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrProxyHeader is returned when connection doesn't start with valid PROXY protocol header.
+var ErrProxyHeader = errors.New("websocket: invalid proxy protocol header")
+
+// proxyV2Signature starts the binary header of PROXY protocol v2.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// WithProxyProtocol makes Serve, ListenAndServe and ServeListener read PROXY protocol (v1 or v2) header
+// sent by load balancer (e.g. HAProxy, NGINX with proxy_protocol) before the handshake, so client address
+// is the address of the real client. All connections must come through the load balancer then.
+func WithProxyProtocol() Option {
+	return func(s *Server) {
+		s.proxyProtocol = true
+	}
+}
+
+// WithTrustedProxies sets addresses of reverse proxies which are allowed to pass client address in
+// Forwarded or X-Forwarded-For header. Client address is the last address of the header which is not
+// a trusted proxy, headers of connections from other addresses are ignored.
+//
+//	websocket.WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8"))
+func WithTrustedProxies(prefixes ...netip.Prefix) Option {
+	return func(s *Server) {
+		s.trustedProxies = append(s.trustedProxies, prefixes...)
+	}
+}
+
+// RemoteAddr return ip address of client. Behind trusted proxy (see WithTrustedProxies) and with
+// PROXY protocol (see WithProxyProtocol) it's the address of the real client, not of the proxy.
+func (c *Conn) RemoteAddr() string {
+	return c.ip
+}
+
+// trusted reports whether ip is the address of trusted proxy.
+func (s *Server) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range s.trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP return ip of client from address of connection and forwarding headers of trusted proxies.
+func (s *Server) clientIP(remoteAddr string, h http.Header) string {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+	if !s.trusted(ip) {
+		return ip
+	}
+
+	chain := forwardedFor(h)
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !s.trusted(chain[i]) {
+			return chain[i]
+		}
+	}
+	return ip
+}
+
+// forwardedFor return client addresses of Forwarded header or X-Forwarded-For if there is no Forwarded.
+func forwardedFor(h http.Header) []string {
+	var list []string
+	for _, v := range h.Values("Forwarded") {
+		for _, elem := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				k, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(k, "for") {
+					continue
+				}
+				list = append(list, forwardedNode(strings.Trim(val, `"`)))
+			}
+		}
+	}
+	if len(list) != 0 {
+		return list
+	}
+
+	for _, v := range h.Values("X-Forwarded-For") {
+		for _, ip := range strings.Split(v, ",") {
+			if ip = strings.TrimSpace(ip); ip != "" {
+				list = append(list, ip)
+			}
+		}
+	}
+	return list
+}
+
+// forwardedNode strip port and brackets from node of Forwarded header: "[2001:db8::1]:4711" or "192.0.2.1:80".
+func forwardedNode(v string) string {
+	if host, _, err := net.SplitHostPort(v); err == nil {
+		return host
+	}
+	return strings.Trim(v, "[]")
+}
+
+// proxyListener reads PROXY protocol header of accepted connections.
+type proxyListener struct {
+	net.Listener
+	deadline func(now time.Time) time.Time
+}
+
+// Accept return connection which reads PROXY protocol header on the first Read or RemoteAddr call,
+// so slow clients don't block accepting of other connections.
+func (l proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{Conn: conn, deadline: l.deadline(time.Now())}, nil
+}
+
+// listen wraps listener of server to read PROXY protocol headers when it's enabled.
+func (s *Server) listen(l net.Listener) net.Listener {
+	if !s.proxyProtocol {
+		return l
+	}
+	return proxyListener{Listener: l, deadline: s.handshakeDeadline}
+}
+
+// proxyConn is connection with PROXY protocol header.
+type proxyConn struct {
+	net.Conn
+	deadline time.Time
+	once     sync.Once
+	r        *bufio.Reader
+	remote   net.Addr
+	err      error
+
+	// read deadline set by user, it's restored after the header is read
+	mu           sync.Mutex
+	readDeadline time.Time
+}
+
+// init read the header with deadline of handshake.
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.r = bufio.NewReader(c.Conn)
+		_ = c.Conn.SetReadDeadline(c.deadline)
+		c.remote, c.err = readProxyHeader(c.r)
+
+		c.mu.Lock()
+		_ = c.Conn.SetReadDeadline(c.readDeadline)
+		c.mu.Unlock()
+	})
+}
+
+func (c *proxyConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *proxyConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+// RemoteAddr return the address of client from header, the address of proxy for LOCAL and UNKNOWN headers.
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader read PROXY protocol v1 or v2 header and return source address,
+// nil is returned for connections of proxy itself (health checks).
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	b, err := r.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, ErrProxyHeader
+	}
+	if bytes.Equal(b, proxyV2Signature) {
+		return readProxyV2(r)
+	}
+	return readProxyV1(r)
+}
+
+// readProxyV1 read text header: PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	// the header is at most 107 bytes
+	var line []byte
+	for len(line) < 107 {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, ErrProxyHeader
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrProxyHeader
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, ErrProxyHeader
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, ErrProxyHeader
+	}
+	if len(fields) != 6 {
+		return nil, ErrProxyHeader
+	}
+	ip, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, ErrProxyHeader
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, ErrProxyHeader
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(port))), nil
+}
+
+// readProxyV2 read binary header: signature, version and command, family, length and addresses.
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrProxyHeader
+	}
+	if header[12]>>4 != 2 {
+		return nil, ErrProxyHeader
+	}
+	body := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, ErrProxyHeader
+	}
+
+	switch header[12] & 0x0f {
+	case 0: // LOCAL
+		return nil, nil
+	case 1: // PROXY
+	default:
+		return nil, ErrProxyHeader
+	}
+
+	switch header[13] {
+	case 0x11: // TCP over IPv4
+		if len(body) < 12 {
+			return nil, ErrProxyHeader
+		}
+		ip := netip.AddrFrom4([4]byte(body[:4]))
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, binary.BigEndian.Uint16(body[8:]))), nil
+	case 0x21: // TCP over IPv6
+		if len(body) < 36 {
+			return nil, ErrProxyHeader
+		}
+		ip := netip.AddrFrom16([16]byte(body[:16]))
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, binary.BigEndian.Uint16(body[32:]))), nil
+	}
+	// unsupported family, addresses are ignored as the spec says
+	return nil, nil
+}
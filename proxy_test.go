@@ -0,0 +1,144 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestServer_clientIP(t *testing.T) {
+	s := New(WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")))
+
+	tbl := []struct {
+		remote string
+		header http.Header
+		ip     string
+	}{
+		{"192.0.2.1:1234", http.Header{"X-Forwarded-For": {"1.1.1.1"}}, "192.0.2.1"},
+		{"10.0.0.1:1234", nil, "10.0.0.1"},
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"6.6.6.6, 1.1.1.1, 10.0.0.2"}}, "1.1.1.1"},
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"10.0.0.3", "10.0.0.2"}}, "10.0.0.1"},
+		{"[::1]:1234", http.Header{"Forwarded": {`for=192.0.2.60;proto=https, For="[2001:db8::1]:4711"`}}, "2001:db8::1"},
+		{"10.0.0.1:1234", http.Header{"Forwarded": {"for=192.0.2.60"}, "X-Forwarded-For": {"1.1.1.1"}}, "192.0.2.60"},
+		{"pipe", nil, "pipe"},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.remote, func(t *testing.T) {
+			require.Equal(t, tt.ip, s.clientIP(tt.remote, tt.header))
+		})
+	}
+}
+
+// proxyV2 return PROXY protocol v2 header for TCP over IPv4.
+func proxyV2(src string, port uint16) []byte {
+	b := append([]byte{}, proxyV2Signature...)
+	b = append(b, 0x21, 0x11, 0, 12)
+	ip := netip.MustParseAddr(src).As4()
+	b = append(b, ip[:]...)
+	b = append(b, 127, 0, 0, 1)
+	b = binary.BigEndian.AppendUint16(b, port)
+	b = binary.BigEndian.AppendUint16(b, 443)
+	return b
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	tbl := []struct {
+		name   string
+		header string
+		addr   string
+		err    bool
+	}{
+		{"v1", "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n", "192.0.2.1:56324", false},
+		{"v1 ipv6", "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", "[2001:db8::1]:56324", false},
+		{"v1 unknown", "PROXY UNKNOWN\r\n", "", false},
+		{"v1 invalid", "PROXY TCP4 192.0.2.1\r\n", "", true},
+		{"no header", "GET /ws HTTP/1.1\r\n", "", true},
+		{"v2", string(proxyV2("192.0.2.7", 5555)), "192.0.2.7:5555", false},
+		{"v2 local", string(append(append([]byte{}, proxyV2Signature...), 0x20, 0, 0, 0)), "", false},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.header + "rest"))
+			addr, err := readProxyHeader(r)
+			if tt.err {
+				require.ErrorIs(t, err, ErrProxyHeader)
+				return
+			}
+			require.NoError(t, err)
+			if tt.addr == "" {
+				require.Nil(t, addr)
+			} else {
+				require.Equal(t, tt.addr, addr.String())
+			}
+			rest, _ := io.ReadAll(r)
+			require.Equal(t, "rest", string(rest), "data after header must be kept")
+		})
+	}
+}
+
+func TestServer_WithProxyProtocol(t *testing.T) {
+	for _, serve := range []string{"listener", "http"} {
+		t.Run(serve, func(t *testing.T) {
+			wsServer := New(WithProxyProtocol())
+			defer func() {
+				_ = wsServer.Shutdown()
+			}()
+			addrs := make(chan string, 1)
+			wsServer.OnConnect(func(c Connection) {
+				addrs <- c.(*Conn).RemoteAddr()
+			})
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			go func() {
+				if serve == "http" {
+					_ = wsServer.Serve(l)
+					return
+				}
+				_ = wsServer.ServeListener(l)
+			}()
+
+			d := ws.Dialer{
+				NetDial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					var nd net.Dialer
+					conn, err := nd.DialContext(ctx, network, addr)
+					if err == nil {
+						_, err = conn.Write(proxyV2("192.0.2.7", 5555))
+					}
+					return conn, err
+				},
+			}
+			c, _, _, err := d.Dial(context.Background(), "ws://"+l.Addr().String()+"/ws")
+			require.NoError(t, err)
+			defer c.Close()
+			require.Equal(t, "192.0.2.7", <-addrs)
+
+			// connection without header is rejected
+			_, _, _, err = ws.Dial(context.Background(), "ws://"+l.Addr().String()+"/ws")
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestServer_WithTrustedProxies(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithTrustedProxies(netip.MustParsePrefix("127.0.0.0/8")))
+	defer shutdown()
+	addrs := make(chan string, 1)
+	wsServer.OnConnect(func(c Connection) {
+		addrs <- c.(*Conn).RemoteAddr()
+	})
+
+	d := ws.Dialer{Header: ws.HandshakeHeaderHTTP(http.Header{"X-Forwarded-For": {"203.0.113.9"}})}
+	c, _, _, err := d.Dial(context.Background(), "ws"+strings.TrimPrefix(ts.URL, "http")+"/ws")
+	require.NoError(t, err)
+	defer c.Close()
+	require.Equal(t, "203.0.113.9", <-addrs)
+}
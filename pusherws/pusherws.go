@@ -0,0 +1,420 @@
+// Package pusherws makes websocket.Server compatible with pusher-js clients (Pusher Channels protocol 7),
+// so frontends leaving hosted Pusher could point at the server:
+//
+//	srv := websocket.New()
+//	p := pusherws.New(srv, "app-key", "app-secret")
+//	srv.Run(ctx)
+//	http.HandleFunc("/app/", srv.Handler)
+//
+//	_ = p.Trigger("orders", "order-created", order)
+//
+// Clients connect with new Pusher("app-key", {wsHost: "host", wsPort: 8080, forceTLS: false}).
+// Public, private and presence channels are supported, private and presence subscriptions are checked
+// with signatures made by the auth endpoint of application with the same key and secret (see Sign).
+// Client events (client-*) of private and presence channels are sent to other members. Encrypted channels
+// are not supported.
+//
+// Pusher channels are websocket.Channel of the server with the same name, so limits and authorizers of
+// channels are applied. Server must be used for Pusher only, New replaces OnConnect, OnDisconnect,
+// OnMessage and OnSubscribe of the server. Generic _subscribe events are rejected, they would skip
+// the signature check of private and presence channels.
+package pusherws
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gobwas/ws"
+	"github.com/pkgz/websocket"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultActivityTimeout is the time after which client pings the server when nothing was received.
+const DefaultActivityTimeout = 120 * time.Second
+
+// Events of the protocol.
+const (
+	EventConnectionEstablished = "pusher:connection_established"
+	EventError                 = "pusher:error"
+	EventPing                  = "pusher:ping"
+	EventPong                  = "pusher:pong"
+	EventSubscribe             = "pusher:subscribe"
+	EventUnsubscribe           = "pusher:unsubscribe"
+	EventSubscriptionError     = "pusher:subscription_error"
+	EventSubscriptionSucceeded = "pusher_internal:subscription_succeeded"
+	EventMemberAdded           = "pusher_internal:member_added"
+	EventMemberRemoved         = "pusher_internal:member_removed"
+)
+
+// Prefixes of channel names and client events.
+const (
+	PrivatePrefix   = "private-"
+	PresencePrefix  = "presence-"
+	EncryptedPrefix = "private-encrypted-"
+	ClientPrefix    = "client-"
+)
+
+var (
+	// ErrInvalidSignature is sent when auth of private or presence subscription doesn't match.
+	ErrInvalidSignature = errors.New("pusherws: invalid signature")
+	// ErrUnsupportedChannel is sent for encrypted channels.
+	ErrUnsupportedChannel = errors.New("pusherws: encrypted channels are not supported")
+	// ErrNativeSubscribe is sent for _subscribe events of websocket.Server, clients subscribe with pusher:subscribe.
+	ErrNativeSubscribe = errors.New("pusherws: use pusher:subscribe")
+)
+
+// Option configures the Server.
+type Option func(s *Server)
+
+// WithActivityTimeout sets activity timeout sent to clients, default is DefaultActivityTimeout.
+func WithActivityTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.activityTimeout = d
+	}
+}
+
+// Server serves Pusher protocol on websocket.Server.
+type Server struct {
+	srv             *websocket.Server
+	key             string
+	secret          []byte
+	activityTimeout time.Duration
+
+	sockets  map[websocket.Connection]string
+	presence map[string]map[websocket.Connection]Member
+	// hooked are presence channels with OnLeave hook, channel recreated after close needs its own
+	hooked map[string]*websocket.Channel
+	mu     sync.Mutex
+	nextID atomic.Uint64
+}
+
+// Member is the member of presence channel, it's sent by auth endpoint of application in channel_data.
+type Member struct {
+	UserID   string          `json:"user_id"`
+	UserInfo json.RawMessage `json:"user_info,omitempty"`
+}
+
+// message is the wire format of the protocol.
+type message struct {
+	Event   string          `json:"event"`
+	Channel string          `json:"channel,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	UserID  string          `json:"user_id,omitempty"`
+}
+
+// subscription is the data of pusher:subscribe.
+type subscription struct {
+	Channel     string `json:"channel"`
+	Auth        string `json:"auth"`
+	ChannelData string `json:"channel_data"`
+}
+
+// New serve Pusher protocol on the server, key and secret are the credentials of application.
+func New(srv *websocket.Server, key, secret string, opts ...Option) *Server {
+	s := &Server{
+		srv:             srv,
+		key:             key,
+		secret:          []byte(secret),
+		activityTimeout: DefaultActivityTimeout,
+		sockets:         make(map[websocket.Connection]string),
+		presence:        make(map[string]map[websocket.Connection]Member),
+		hooked:          make(map[string]*websocket.Channel),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	var seed [4]byte
+	_, _ = rand.Read(seed[:])
+	s.nextID.Store(uint64(binary.BigEndian.Uint32(seed[:])))
+
+	srv.OnConnect(s.open)
+	srv.OnDisconnect(s.close)
+	srv.OnMessage(func(c websocket.Connection, _ ws.Header, b []byte) {
+		s.message(c, b)
+	})
+	srv.OnSubscribe(func(context.Context, websocket.Connection, string) error {
+		return ErrNativeSubscribe
+	})
+	return s
+}
+
+// Sign return auth of subscription: key and hex of HMAC-SHA256 of "socket_id:channel" (with ":channel_data"
+// for presence channels). It's what auth endpoint of application returns to pusher-js.
+func Sign(key, secret, socketID, channel, channelData string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(socketID + ":" + channel))
+	if channelData != "" {
+		mac.Write([]byte(":" + channelData))
+	}
+	return key + ":" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SocketID return Pusher socket id of connection, it's empty for connections which are not open yet.
+func (s *Server) SocketID(c websocket.Connection) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sockets[c]
+}
+
+// Trigger sends event to all subscribers of channel, data is encoded to json string unless it's a string.
+func (s *Server) Trigger(channel, event string, data any) error {
+	d, err := dataString(data)
+	if err != nil {
+		return err
+	}
+	ch := s.srv.Channel(channel)
+	if ch == nil {
+		return nil
+	}
+
+	var errs []error
+	ch.ForEach(func(c websocket.Connection) bool {
+		if err := write(c, message{Event: event, Channel: channel, Data: d}); err != nil {
+			errs = append(errs, err)
+		}
+		return true
+	})
+	return errors.Join(errs...)
+}
+
+// Members return members of presence channel.
+func (s *Server) Members(channel string) []Member {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return uniqueMembers(s.presence[channel])
+}
+
+// open send socket id to new connection.
+func (s *Server) open(c websocket.Connection) {
+	id := fmt.Sprintf("%d.%d", s.nextID.Add(1), time.Now().UnixNano()%1000000)
+	s.mu.Lock()
+	s.sockets[c] = id
+	s.mu.Unlock()
+
+	d, _ := dataString(map[string]any{"socket_id": id, "activity_timeout": int(s.activityTimeout.Seconds())})
+	_ = write(c, message{Event: EventConnectionEstablished, Data: d})
+}
+
+// close forget socket id of connection, it leaves channels with the websocket connection.
+func (s *Server) close(c websocket.Connection) {
+	s.mu.Lock()
+	delete(s.sockets, c)
+	s.mu.Unlock()
+}
+
+// message handle frame of client.
+func (s *Server) message(c websocket.Connection, b []byte) {
+	var msg message
+	if err := json.Unmarshal(b, &msg); err != nil {
+		s.error(c, 4200, "invalid message")
+		return
+	}
+
+	switch {
+	case msg.Event == EventPing:
+		_ = write(c, message{Event: EventPong, Data: json.RawMessage(`"{}"`)})
+	case msg.Event == EventSubscribe:
+		var sub subscription
+		if err := json.Unmarshal(msg.Data, &sub); err != nil || sub.Channel == "" {
+			s.error(c, 4200, "invalid subscription")
+			return
+		}
+		if err := s.subscribe(c, sub); err != nil {
+			d, _ := json.Marshal(map[string]any{"type": "AuthError", "error": err.Error(), "status": 403})
+			_ = write(c, message{Event: EventSubscriptionError, Channel: sub.Channel, Data: d})
+		}
+	case msg.Event == EventUnsubscribe:
+		var sub subscription
+		if err := json.Unmarshal(msg.Data, &sub); err == nil {
+			if ch := s.srv.Channel(sub.Channel); ch != nil {
+				ch.Remove(c)
+			}
+		}
+	case strings.HasPrefix(msg.Event, ClientPrefix):
+		s.clientEvent(c, msg)
+	}
+}
+
+// subscribe check auth of private and presence channels and add connection to channel.
+func (s *Server) subscribe(c websocket.Connection, sub subscription) error {
+	name := sub.Channel
+	if strings.HasPrefix(name, EncryptedPrefix) {
+		return ErrUnsupportedChannel
+	}
+
+	var member *Member
+	if strings.HasPrefix(name, PrivatePrefix) || strings.HasPrefix(name, PresencePrefix) {
+		data := ""
+		if strings.HasPrefix(name, PresencePrefix) {
+			data = sub.ChannelData
+			member = &Member{}
+			if err := json.Unmarshal([]byte(data), member); err != nil || member.UserID == "" {
+				return errors.New("pusherws: invalid channel_data")
+			}
+		}
+		want := Sign(s.key, string(s.secret), s.SocketID(c), name, data)
+		if !hmac.Equal([]byte(want), []byte(sub.Auth)) {
+			return ErrInvalidSignature
+		}
+	}
+
+	ch := s.channel(name)
+	if err := ch.Add(c); err != nil {
+		return err
+	}
+
+	d := json.RawMessage(`"{}"`)
+	if member != nil {
+		s.mu.Lock()
+		members := s.presence[name]
+		joined := !hasUser(members, member.UserID)
+		members[c] = *member
+		list := uniqueMembers(members)
+		s.mu.Unlock()
+
+		ids := make([]string, 0, len(list))
+		hash := make(map[string]json.RawMessage, len(list))
+		for _, m := range list {
+			ids = append(ids, m.UserID)
+			hash[m.UserID] = m.UserInfo
+		}
+		d, _ = dataString(map[string]any{"presence": map[string]any{"ids": ids, "hash": hash, "count": len(ids)}})
+
+		if joined {
+			added, _ := dataString(member)
+			s.except(ch, c, message{Event: EventMemberAdded, Channel: name, Data: added})
+		}
+	}
+	return write(c, message{Event: EventSubscriptionSucceeded, Channel: name, Data: d})
+}
+
+// channel return websocket channel of Pusher channel, it's created on the first subscription.
+// Presence channels send member_removed when the last connection of user leaves.
+func (s *Server) channel(name string) *websocket.Channel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := s.srv.Channel(name)
+	if ch == nil {
+		ch = s.srv.NewChannel(name)
+	}
+	if strings.HasPrefix(name, PresencePrefix) && s.hooked[name] != ch {
+		s.hooked[name] = ch
+		if s.presence[name] == nil {
+			s.presence[name] = make(map[websocket.Connection]Member)
+		}
+		ch.OnLeave(func(c websocket.Connection) {
+			s.leave(ch, c)
+		})
+	}
+	return ch
+}
+
+// leave remove member of presence channel.
+func (s *Server) leave(ch *websocket.Channel, c websocket.Connection) {
+	s.mu.Lock()
+	members := s.presence[ch.ID()]
+	m, ok := members[c]
+	delete(members, c)
+	left := ok && !hasUser(members, m.UserID)
+	s.mu.Unlock()
+
+	if left {
+		d, _ := dataString(Member{UserID: m.UserID})
+		s.except(ch, c, message{Event: EventMemberRemoved, Channel: ch.ID(), Data: d})
+	}
+}
+
+// clientEvent send event of client to other members of private or presence channel.
+func (s *Server) clientEvent(c websocket.Connection, msg message) {
+	if !strings.HasPrefix(msg.Channel, PrivatePrefix) && !strings.HasPrefix(msg.Channel, PresencePrefix) {
+		s.error(c, 4301, "client events are allowed only on private and presence channels")
+		return
+	}
+	ch := s.srv.Channel(msg.Channel)
+	if ch == nil || !ch.Has(c) {
+		s.error(c, 4301, "client is not subscribed to "+msg.Channel)
+		return
+	}
+
+	out := message{Event: msg.Event, Channel: msg.Channel, Data: msg.Data}
+	if strings.HasPrefix(msg.Channel, PresencePrefix) {
+		s.mu.Lock()
+		out.UserID = s.presence[msg.Channel][c].UserID
+		s.mu.Unlock()
+	}
+	s.except(ch, c, out)
+}
+
+// except write message to all connections of channel except one.
+func (s *Server) except(ch *websocket.Channel, except websocket.Connection, msg message) {
+	ch.ForEach(func(c websocket.Connection) bool {
+		if c != except {
+			_ = write(c, msg)
+		}
+		return true
+	})
+}
+
+// error send pusher:error to client.
+func (s *Server) error(c websocket.Connection, code int, text string) {
+	d, _ := json.Marshal(map[string]any{"code": code, "message": text})
+	_ = write(c, message{Event: EventError, Data: d})
+}
+
+// hasUser reports whether any connection of members belongs to user.
+func hasUser(members map[websocket.Connection]Member, userID string) bool {
+	for _, m := range members {
+		if m.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueMembers return members by user id, sorted for stable output.
+func uniqueMembers(members map[websocket.Connection]Member) []Member {
+	seen := make(map[string]bool, len(members))
+	list := make([]Member, 0, len(members))
+	for _, m := range members {
+		if !seen[m.UserID] {
+			seen[m.UserID] = true
+			list = append(list, m)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UserID < list[j].UserID })
+	return list
+}
+
+// dataString encode data as json string, Pusher events carry data as string.
+func dataString(data any) (json.RawMessage, error) {
+	s, ok := data.(string)
+	if !ok {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		s = string(b)
+	}
+	return json.Marshal(s)
+}
+
+// write the message in text frame.
+func write(c websocket.Connection, msg message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.Write(ws.Header{Fin: true, OpCode: ws.OpText, Length: int64(len(b))}, b)
+}
@@ -0,0 +1,213 @@
+package pusherws
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkgz/websocket"
+	"github.com/pkgz/websocket/websockettest"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const (
+	testKey    = "app-key"
+	testSecret = "app-secret"
+)
+
+func start(t *testing.T) (*websocket.Server, *Server) {
+	srv := websocket.New()
+	p := New(srv, testKey, testSecret)
+	srv.Run(context.Background())
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+	return srv, p
+}
+
+// connect client and return its socket id from connection_established.
+func connect(t *testing.T, srv *websocket.Server) (*websockettest.Client, string) {
+	_, client := websockettest.NewPair(t, srv)
+	msg := read(t, client)
+	require.Equal(t, EventConnectionEstablished, msg.Event)
+
+	var established struct {
+		SocketID        string `json:"socket_id"`
+		ActivityTimeout int    `json:"activity_timeout"`
+	}
+	decodeData(t, msg, &established)
+	require.Regexp(t, `^\d+\.\d+$`, established.SocketID)
+	require.Equal(t, 120, established.ActivityTimeout)
+	return client, established.SocketID
+}
+
+func send(t *testing.T, c *websockettest.Client, event string, data any) {
+	b, err := json.Marshal(map[string]any{"event": event, "data": data})
+	require.NoError(t, err)
+	require.NoError(t, c.Send(b))
+}
+
+func read(t *testing.T, c *websockettest.Client) message {
+	b, err := c.ReadRaw()
+	require.NoError(t, err)
+	var msg message
+	require.NoError(t, json.Unmarshal(b, &msg))
+	return msg
+}
+
+// decodeData decode data of event which is json string.
+func decodeData(t *testing.T, msg message, v any) {
+	var s string
+	require.NoError(t, json.Unmarshal(msg.Data, &s))
+	require.NoError(t, json.Unmarshal([]byte(s), v))
+}
+
+func TestServer_public(t *testing.T) {
+	srv, p := start(t)
+	client, _ := connect(t, srv)
+
+	send(t, client, EventPing, map[string]any{})
+	require.Equal(t, EventPong, read(t, client).Event)
+
+	send(t, client, EventSubscribe, map[string]string{"channel": "orders"})
+	msg := read(t, client)
+	require.Equal(t, EventSubscriptionSucceeded, msg.Event)
+	require.Equal(t, "orders", msg.Channel)
+
+	require.NoError(t, p.Trigger("orders", "order-created", map[string]int{"id": 7}))
+	msg = read(t, client)
+	require.Equal(t, "order-created", msg.Event)
+	var order map[string]int
+	decodeData(t, msg, &order)
+	require.Equal(t, map[string]int{"id": 7}, order)
+
+	send(t, client, EventUnsubscribe, map[string]string{"channel": "orders"})
+	require.Eventually(t, func() bool { return srv.Channel("orders").Count() == 0 }, websockettest.DefaultTimeout, websockettest.DefaultTimeout/100)
+	require.NoError(t, p.Trigger("orders", "order-created", "ignored"))
+	require.NoError(t, p.Trigger("unknown", "event", nil))
+}
+
+func TestServer_private(t *testing.T) {
+	srv, _ := start(t)
+	alice, aliceID := connect(t, srv)
+	bob, bobID := connect(t, srv)
+
+	send(t, alice, EventSubscribe, map[string]string{"channel": "private-room", "auth": Sign(testKey, "wrong", aliceID, "private-room", "")})
+	msg := read(t, alice)
+	require.Equal(t, EventSubscriptionError, msg.Event)
+	require.Contains(t, string(msg.Data), "invalid signature")
+
+	send(t, alice, EventSubscribe, map[string]string{"channel": "private-room", "auth": Sign(testKey, testSecret, aliceID, "private-room", "")})
+	require.Equal(t, EventSubscriptionSucceeded, read(t, alice).Event)
+	send(t, bob, EventSubscribe, map[string]string{"channel": "private-room", "auth": Sign(testKey, testSecret, bobID, "private-room", "")})
+	require.Equal(t, EventSubscriptionSucceeded, read(t, bob).Event)
+
+	require.NoError(t, alice.Send([]byte(`{"event":"client-typing","channel":"private-room","data":{"typing":true}}`)))
+	msg = read(t, bob)
+	require.Equal(t, "client-typing", msg.Event)
+	require.JSONEq(t, `{"typing":true}`, string(msg.Data))
+	alice.ExpectNone(t, websockettest.DefaultTimeout/20)
+
+	require.NoError(t, alice.Send([]byte(`{"event":"client-typing","channel":"public","data":{}}`)))
+	require.Equal(t, EventError, read(t, alice).Event)
+}
+
+func TestServer_presence(t *testing.T) {
+	srv, p := start(t)
+	alice, aliceID := connect(t, srv)
+	bob, bobID := connect(t, srv)
+
+	subscribe := func(c *websockettest.Client, socketID, data string) message {
+		send(t, c, EventSubscribe, map[string]string{
+			"channel":      "presence-room",
+			"auth":         Sign(testKey, testSecret, socketID, "presence-room", data),
+			"channel_data": data,
+		})
+		return read(t, c)
+	}
+
+	msg := subscribe(alice, aliceID, `{"user_id":"alice","user_info":{"name":"Alice"}}`)
+	require.Equal(t, EventSubscriptionSucceeded, msg.Event)
+	msg = subscribe(bob, bobID, `{"user_id":"bob"}`)
+	require.Equal(t, EventSubscriptionSucceeded, msg.Event)
+	var state struct {
+		Presence struct {
+			IDs   []string                   `json:"ids"`
+			Hash  map[string]json.RawMessage `json:"hash"`
+			Count int                        `json:"count"`
+		} `json:"presence"`
+	}
+	decodeData(t, msg, &state)
+	require.Equal(t, []string{"alice", "bob"}, state.Presence.IDs)
+	require.Equal(t, 2, state.Presence.Count)
+	require.JSONEq(t, `{"name":"Alice"}`, string(state.Presence.Hash["alice"]))
+
+	msg = read(t, alice)
+	require.Equal(t, EventMemberAdded, msg.Event)
+	var member Member
+	decodeData(t, msg, &member)
+	require.Equal(t, "bob", member.UserID)
+	require.Len(t, p.Members("presence-room"), 2)
+
+	require.NoError(t, bob.Send([]byte(`{"event":"client-wave","channel":"presence-room","data":{}}`)))
+	msg = read(t, alice)
+	require.Equal(t, "client-wave", msg.Event)
+	require.Equal(t, "bob", msg.UserID)
+
+	require.NoError(t, bob.Close())
+	msg = read(t, alice)
+	require.Equal(t, EventMemberRemoved, msg.Event)
+	decodeData(t, msg, &member)
+	require.Equal(t, "bob", member.UserID)
+	require.Len(t, p.Members("presence-room"), 1)
+}
+
+func TestServer_presence_recreated(t *testing.T) {
+	srv, p := start(t)
+	alice, aliceID := connect(t, srv)
+	bob, bobID := connect(t, srv)
+
+	subscribe := func(c *websockettest.Client, socketID, data string) {
+		send(t, c, EventSubscribe, map[string]string{
+			"channel":      "presence-room",
+			"auth":         Sign(testKey, testSecret, socketID, "presence-room", data),
+			"channel_data": data,
+		})
+		require.Equal(t, EventSubscriptionSucceeded, read(t, c).Event)
+	}
+
+	subscribe(alice, aliceID, `{"user_id":"alice"}`)
+	// channel is closed as it happens with WithChannelTTL and created again by the next subscription
+	srv.RemoveChannel("presence-room")
+	require.Empty(t, p.Members("presence-room"))
+
+	subscribe(alice, aliceID, `{"user_id":"alice"}`)
+	subscribe(bob, bobID, `{"user_id":"bob"}`)
+	require.Equal(t, EventMemberAdded, read(t, alice).Event)
+
+	require.NoError(t, bob.Close())
+	msg := read(t, alice)
+	require.Equal(t, EventMemberRemoved, msg.Event, "recreated channel must track leaving members")
+	var member Member
+	decodeData(t, msg, &member)
+	require.Equal(t, "bob", member.UserID)
+	require.Len(t, p.Members("presence-room"), 1)
+}
+
+func TestSign(t *testing.T) {
+	// example from Pusher documentation
+	require.Equal(t,
+		"278d425bdf160c739803:58df8b0c36d6982b82c3ecf6b4662e34fe8c25bba48f5369f135bf843651c3a4",
+		Sign("278d425bdf160c739803", "7ad3773142a6692b25b8", "1234.1234", "private-foobar", ""),
+	)
+}
+
+func TestServer_nativeSubscribe(t *testing.T) {
+	srv, _ := start(t)
+	client, _ := connect(t, srv)
+
+	b, err := json.Marshal(map[string]any{"name": websocket.EventSubscribe, "data": map[string]string{"channel": "private-orders"}})
+	require.NoError(t, err)
+	require.NoError(t, client.Send(b))
+	require.Contains(t, client.Expect(t, websocket.EventError).String(), "pusher:subscribe")
+	require.Nil(t, srv.Channel("private-orders"), "private channel must not be joined without signature")
+}
@@ -0,0 +1,219 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gobwas/ws"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when connection exceeded the rate limit.
+var ErrRateLimited = errors.New("websocket: rate limit exceeded")
+
+// RatePolicy defines what happens with message which exceeded the rate limit.
+type RatePolicy int
+
+const (
+	// RateDrop drops the message and replies with _error event with CodeTooManyRequests.
+	RateDrop RatePolicy = iota
+	// RateQueue waits for the token in queue of connection, reading from connection continues meanwhile.
+	// Messages are handled in order, they are dropped as with RateDrop when the queue is full.
+	RateQueue
+	// RateClose closes the connection with 1008 status.
+	RateClose
+)
+
+// rateQueueSize is the number of messages of connection waiting for tokens with RateQueue policy.
+const rateQueueSize = 128
+
+// rateLimit is n messages per duration.
+type rateLimit struct {
+	n   int
+	per time.Duration
+}
+
+// WithRateLimit limits every connection to n messages with name per duration.
+// Empty name limits all messages of connection. It panics if n or per is not positive.
+func WithRateLimit(name string, n int, per time.Duration) Option {
+	validLimit(n, per)
+	return func(s *Server) {
+		if s.rateLimits == nil {
+			s.rateLimits = make(map[string]rateLimit)
+		}
+		s.rateLimits[name] = rateLimit{n: n, per: per}
+	}
+}
+
+// WithGlobalRateLimit limits all connections together to n messages per duration.
+// It panics if n or per is not positive.
+func WithGlobalRateLimit(n int, per time.Duration) Option {
+	validLimit(n, per)
+	return func(s *Server) {
+		s.globalLimit = newBucket(rateLimit{n: n, per: per}, time.Now())
+	}
+}
+
+// WithRatePolicy sets what happens with messages which exceeded the rate limit, default is RateDrop.
+func WithRatePolicy(p RatePolicy) Option {
+	return func(s *Server) {
+		s.ratePolicy = p
+	}
+}
+
+// validLimit panics on limit which would never refill the bucket.
+func validLimit(n int, per time.Duration) {
+	if n <= 0 || per <= 0 {
+		panic(fmt.Sprintf("websocket: invalid rate limit %d per %v", n, per))
+	}
+}
+
+// bucket is a token bucket with n tokens refilled during per.
+type bucket struct {
+	tokens float64
+	burst  float64
+	rate   float64 // tokens per nanosecond
+	last   time.Time
+	mu     sync.Mutex
+}
+
+func newBucket(l rateLimit, now time.Time) *bucket {
+	return &bucket{
+		tokens: float64(l.n),
+		burst:  float64(l.n),
+		rate:   float64(l.n) / float64(l.per),
+		last:   now,
+	}
+}
+
+// take the token, returns the time to wait for the next token if there is no tokens.
+func (b *bucket) take(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.burst, b.tokens+float64(now.Sub(b.last))*b.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate)
+}
+
+// bucket return the bucket of connection for message name, nil if there is no limit.
+func (c *Conn) bucket(name string, now time.Time) *bucket {
+	l, ok := c.server.rateLimits[name]
+	if !ok {
+		return nil
+	}
+
+	c.limitsMu.Lock()
+	defer c.limitsMu.Unlock()
+
+	if c.limits == nil {
+		c.limits = make(map[string]*bucket)
+	}
+	b := c.limits[name]
+	if b == nil {
+		b = newBucket(l, now)
+		c.limits[name] = b
+	}
+	return b
+}
+
+// allow check rate limits for message with name, empty name checks limits of connection.
+// Returns false if message must be skipped.
+func (s *Server) allow(c *Conn, name string) (bool, error) {
+	buckets := make([]*bucket, 0, 2)
+	if b := c.bucket(name, time.Now()); b != nil {
+		buckets = append(buckets, b)
+	}
+	if name == "" && s.globalLimit != nil {
+		buckets = append(buckets, s.globalLimit)
+	}
+
+	for _, b := range buckets {
+		for {
+			wait := b.take(time.Now())
+			if wait == 0 {
+				break
+			}
+
+			switch s.ratePolicy {
+			case RateQueue:
+				select {
+				case <-time.After(wait):
+					continue
+				case <-c.Context().Done():
+					return false, c.Context().Err()
+				}
+			case RateClose:
+				s.observe(Event{Type: MessageDropped, Conn: c, Name: name, Err: ErrRateLimited})
+				_ = c.closeWith(ws.StatusPolicyViolation, "rate limit exceeded")
+				return false, ErrRateLimited
+			default:
+				s.observe(Event{Type: MessageDropped, Conn: c, Name: name, Err: ErrRateLimited})
+				replyError(c, name, "", &Error{Code: CodeTooManyRequests, Message: ErrRateLimited.Error()})
+				return false, nil
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// queued is a message waiting for rate limit tokens.
+type queued struct {
+	h        ws.Header
+	buf      *[]byte
+	received time.Time
+}
+
+// rateQueued reports if messages wait for tokens in queue of connection instead of read loop.
+func (s *Server) rateQueued() bool {
+	return s.ratePolicy == RateQueue && (len(s.rateLimits) != 0 || s.globalLimit != nil)
+}
+
+// enqueue pass the message to rate queue of connection, payload is copied as it's reused by reader.
+// Queue goroutine is started with the first message, message is dropped if the queue is full.
+func (s *Server) enqueue(c *Conn, h ws.Header, payload []byte, received time.Time) {
+	c.rateOnce.Do(func() {
+		c.rateQueue = make(chan queued, rateQueueSize)
+		spawn(&s.goroutines.background, func() { s.runRateQueue(c) })
+	})
+
+	buf := getBuffer(len(payload))
+	copy(*buf, payload)
+
+	select {
+	case c.rateQueue <- queued{h: h, buf: buf, received: received}:
+	default:
+		putBuffer(buf)
+		s.observe(Event{Type: MessageDropped, Conn: c, Err: ErrRateLimited})
+		replyError(c, "", "", &Error{Code: CodeTooManyRequests, Message: ErrRateLimited.Error()})
+	}
+}
+
+// runRateQueue handle queued messages of connection until it's closed.
+func (s *Server) runRateQueue(c *Conn) {
+	for {
+		select {
+		case q := <-c.rateQueue:
+			err := s.safe(c, func() {
+				if err := s.process(c, q.h, *q.buf, q.received); err != nil {
+					s.reportError(c, err)
+				}
+			})
+			putBuffer(q.buf)
+			if err != nil {
+				s.dropConn(c)
+				return
+			}
+		case <-c.Context().Done():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	now := time.Now()
+	b := newBucket(rateLimit{n: 2, per: time.Second}, now)
+
+	require.Zero(t, b.take(now))
+	require.Zero(t, b.take(now))
+	require.Equal(t, 500*time.Millisecond, b.take(now).Round(time.Millisecond))
+	require.Zero(t, b.take(now.Add(500*time.Millisecond)))
+	require.Zero(t, b.take(now.Add(time.Hour)))
+	require.Equal(t, 1.0, b.tokens, "tokens must not exceed burst")
+}
+
+func TestServer_WithRateLimit_drop(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithRateLimit("chat.message", 2, time.Minute))
+	defer shutdown()
+
+	wsServer.On("chat.message", func(c Connection, msg *Message) {
+		_ = c.Emit("ok", msg.Data)
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	for i := 0; i < 3; i++ {
+		writeMessage(t, c, "chat.message", i)
+	}
+	writeMessage(t, c, EventHeartbeat, 1)
+
+	name, _ := readEnvelope(t, c)
+	require.Equal(t, "ok", name)
+	name, _ = readEnvelope(t, c)
+	require.Equal(t, "ok", name)
+	name, data := readEnvelope(t, c)
+	require.Equal(t, EventError, name)
+	require.JSONEq(t, `{"event":"chat.message","code":429,"message":"websocket: rate limit exceeded"}`, string(data))
+	name, _ = readEnvelope(t, c)
+	require.Equal(t, EventHeartbeat, name, "other events must not be limited")
+}
+
+func TestServer_WithRateLimit_close(t *testing.T) {
+	ts, _, shutdown := server(t, WithRateLimit("", 1, time.Minute), WithRatePolicy(RateClose))
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	writeMessage(t, c, EventHeartbeat, 1)
+	writeMessage(t, c, EventHeartbeat, 2)
+
+	name, _ := readEnvelope(t, c)
+	require.Equal(t, EventHeartbeat, name)
+	require.Equal(t, ws.StatusPolicyViolation, readClose(t, c))
+}
+
+func TestServer_WithRateLimit_queue(t *testing.T) {
+	ts, _, shutdown := server(t, WithGlobalRateLimit(1, 100*time.Millisecond), WithRatePolicy(RateQueue))
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	started := time.Now()
+	for i := 0; i < 3; i++ {
+		writeMessage(t, c, EventHeartbeat, i)
+	}
+	for i := 0; i < 3; i++ {
+		readEnvelope(t, c)
+	}
+	require.GreaterOrEqual(t, time.Since(started), 190*time.Millisecond, "queued messages must wait for tokens")
+}
+
+func TestServer_WithRateLimit_queueReading(t *testing.T) {
+	ts, _, shutdown := server(t, WithRateLimit("", 1, time.Minute), WithRatePolicy(RateQueue))
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, EventHeartbeat, 1)
+	writeMessage(t, c, EventHeartbeat, 2)
+	name, _ := readEnvelope(t, c)
+	require.Equal(t, EventHeartbeat, name)
+
+	require.NoError(t, ws.WriteFrame(c, ws.MaskFrame(ws.NewPingFrame(nil))))
+	f, err := ws.ReadFrame(c)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpPong, f.Header.OpCode, "queued message must not pause reading")
+}
+
+func TestWithRateLimit_invalid(t *testing.T) {
+	require.Panics(t, func() { WithRateLimit("", 0, time.Second) })
+	require.Panics(t, func() { WithRateLimit("", 1, 0) })
+	require.Panics(t, func() { WithGlobalRateLimit(-1, time.Second) })
+}
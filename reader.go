@@ -0,0 +1,258 @@
+package websocket
+
+import (
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"io"
+	"log"
+	"net"
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrMessageTooBig is returned when message is bigger than the limit set by WithMaxMessageSize.
+	ErrMessageTooBig = errors.New("websocket: message too big")
+
+	// errClosed returned from readFrame when client sent close frame.
+	errClosed = errors.New("websocket: connection closed by client")
+)
+
+// frameReader keeps the state of reading frames from one connection between calls,
+// so frames could be read one by one from the loop or from the poller.
+type frameReader struct {
+	state        ws.State
+	textPending  bool
+	size         int64
+	utf8Reader   *wsutil.UTF8Reader
+	cipherReader *wsutil.CipherReader
+
+	// fragments of the message which is not finished yet
+	message []byte
+	opCode  ws.OpCode
+	// rsv bits of the first frame of message, set by extensions
+	rsv byte
+}
+
+// frame is a header of received frame with the reader of its payload.
+type frame struct {
+	header   ws.Header
+	received time.Time
+	r        io.Reader
+	utf8Fin  bool
+}
+
+func newFrameReader() *frameReader {
+	return &frameReader{
+		state:        ws.StateServerSide,
+		utf8Reader:   wsutil.NewUTF8Reader(nil),
+		cipherReader: wsutil.NewCipherReader(nil, [4]byte{0, 0, 0, 0}),
+	}
+}
+
+// readFrame read and process one frame from connection.
+// Returns an error when connection must be dropped.
+func (s *Server) readFrame(c *Conn, conn net.Conn) error {
+	fr := c.reader
+
+	f, err := s.nextFrame(c, conn)
+	if err != nil {
+		return err
+	}
+	header := f.header
+
+	if header.OpCode.IsControl() && header.OpCode != ws.OpClose {
+		return s.readControl(c, f)
+	}
+
+	if s.isStream(header) {
+		return s.readStream(c, conn, f)
+	}
+
+	buf := getBuffer(int(header.Length))
+	defer putBuffer(buf)
+
+	payload := *buf
+	_, err = io.ReadFull(f.r, payload)
+	if err == nil && f.utf8Fin && !fr.utf8Reader.Valid() {
+		err = wsutil.ErrInvalidUTF8
+	}
+
+	if err != nil {
+		log.Printf("drop ws connection: OpClose (%v)", err)
+		if s.strict && errors.Is(err, wsutil.ErrInvalidUTF8) {
+			_ = c.writeClose(conn, ws.StatusInvalidFramePayloadData, "")
+		}
+		return err
+	}
+	if header.OpCode == ws.OpClose {
+		c.recordFrame(RecordIn, ws.OpClose, payload)
+		return s.readClose(c, conn, payload)
+	}
+
+	switch {
+	case !header.Fin:
+		if header.OpCode != ws.OpContinuation {
+			fr.opCode = header.OpCode
+		}
+		fr.message = append(fr.message, payload...)
+		return nil
+	case header.OpCode == ws.OpContinuation:
+		payload = append(fr.message, payload...)
+		header.OpCode = fr.opCode
+		header.Length = int64(len(payload))
+		defer fr.reset()
+	}
+
+	if fr.rsv != 0 {
+		if payload, err = c.decodeExtensions(payload, fr.rsv); err != nil {
+			log.Printf("drop ws connection: %v", err)
+			_ = c.writeClose(conn, ws.StatusProtocolError, "")
+			return err
+		}
+		if header.OpCode == ws.OpText && !utf8.Valid(payload) {
+			_ = c.writeClose(conn, ws.StatusInvalidFramePayloadData, "")
+			return wsutil.ErrInvalidUTF8
+		}
+		header.Rsv, header.Length = 0, int64(len(payload))
+	}
+
+	c.recordFrame(RecordIn, header.OpCode, payload)
+	c.observeIn()
+	header.Masked = false
+	if s.workers != nil {
+		s.dispatch(c, header, payload, f.received)
+		return nil
+	}
+	return s.handle(c, header, payload, f.received)
+}
+
+// nextFrame read the header of the next frame, validate it and prepare the reader of payload.
+func (s *Server) nextFrame(c *Conn, conn net.Conn) (frame, error) {
+	fr := c.reader
+
+	_ = conn.SetReadDeadline(deadline(time.Now(), c.readTimeout.Load()))
+
+	header, err := ws.ReadHeader(conn)
+	if err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			log.Printf("drop ws connection: read timeout")
+		}
+		return frame{}, err
+	}
+	received := time.Now()
+	c.stats.lastReceived.Store(received.UnixNano())
+	if !header.OpCode.IsControl() {
+		c.stats.lastData.Store(received.UnixNano())
+	}
+	c.observeBytes(int64(ws.HeaderSize(header))+header.Length, 0)
+	if err = c.checkHeader(header, fr.state); err != nil {
+		log.Printf("drop ws connection: %v", err)
+		_ = c.writeClose(conn, ws.StatusProtocolError, "")
+		return frame{}, err
+	}
+
+	switch header.OpCode {
+	case ws.OpText, ws.OpBinary:
+		fr.size = header.Length
+	case ws.OpContinuation:
+		fr.size += header.Length
+	}
+	if s.maxMessageSize > 0 && fr.size > s.maxMessageSize {
+		log.Printf("drop ws connection: %v (%d bytes)", ErrMessageTooBig, fr.size)
+		_ = c.writeClose(conn, ws.StatusMessageTooBig, "")
+		return frame{}, ErrMessageTooBig
+	}
+
+	fr.cipherReader.Reset(io.LimitReader(conn, header.Length), header.Mask)
+
+	f := frame{
+		header:   header,
+		received: received,
+		r:        fr.cipherReader,
+	}
+
+	switch header.OpCode {
+	case ws.OpClose:
+		f.utf8Fin = true
+	case ws.OpContinuation:
+		if fr.textPending {
+			fr.utf8Reader.Source = fr.cipherReader
+			f.r = fr.utf8Reader
+		}
+		if header.Fin {
+			fr.state = fr.state.Clear(ws.StateFragmented)
+			fr.textPending = false
+			f.utf8Fin = true
+		}
+	case ws.OpText:
+		fr.rsv = header.Rsv
+		// payload transformed by extension is validated after decode
+		if header.Rsv == 0 {
+			fr.utf8Reader.Reset(fr.cipherReader)
+			f.r = fr.utf8Reader
+		}
+
+		if !header.Fin {
+			fr.state = fr.state.Set(ws.StateFragmented)
+			fr.textPending = header.Rsv == 0
+		} else {
+			f.utf8Fin = header.Rsv == 0
+		}
+	case ws.OpBinary:
+		fr.rsv = header.Rsv
+		if !header.Fin {
+			fr.state = fr.state.Set(ws.StateFragmented)
+		}
+	}
+
+	return f, nil
+}
+
+// readControl handle ping and pong frames.
+// Length and fragmentation of control frames are already checked by ws.CheckHeader.
+func (s *Server) readControl(c *Conn, f frame) error {
+	payload := make([]byte, f.header.Length)
+	if _, err := io.ReadFull(f.r, payload); err != nil {
+		return err
+	}
+	c.recordFrame(RecordIn, f.header.OpCode, payload)
+
+	if f.header.OpCode == ws.OpPong {
+		s.mu.RLock()
+		onPong := s.onPong
+		s.mu.RUnlock()
+		if onPong != nil {
+			return s.safe(c, func() { onPong(c, payload) })
+		}
+		return nil
+	}
+
+	err := c.Write(ws.Header{
+		Fin:    true,
+		OpCode: ws.OpPong,
+		Length: int64(len(payload)),
+	}, payload)
+
+	s.mu.RLock()
+	onPing := s.onPing
+	s.mu.RUnlock()
+	if onPing != nil {
+		if perr := s.safe(c, func() { onPing(c, payload) }); perr != nil {
+			return perr
+		}
+	}
+
+	return err
+}
+
+// reset clear assembled message, big buffers are released.
+func (fr *frameReader) reset() {
+	if cap(fr.message) > maxPooledBuffer {
+		fr.message = nil
+		return
+	}
+	fr.message = fr.message[:0]
+}
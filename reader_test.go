@@ -0,0 +1,139 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestServer_MaxMessageSize(t *testing.T) {
+	ts, _, shutdown := server(t, WithMaxMessageSize(16))
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	msg := []byte("small")
+	require.NoError(t, wsutil.WriteClientMessage(c, ws.OpText, msg))
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, msg, b)
+
+	require.NoError(t, ws.WriteHeader(c, ws.Header{
+		Fin:    true,
+		OpCode: ws.OpBinary,
+		Masked: true,
+		Length: 1 << 40,
+	}))
+	require.Equal(t, ws.StatusMessageTooBig, readClose(t, c))
+}
+
+func TestServer_MaxMessageSize_fragmented(t *testing.T) {
+	ts, _, shutdown := server(t, WithMaxMessageSize(16))
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	part := []byte("0123456789")
+	require.NoError(t, ws.WriteFrame(c, ws.MaskFrame(ws.NewFrame(ws.OpText, false, part))))
+	require.NoError(t, ws.WriteFrame(c, ws.MaskFrame(ws.NewFrame(ws.OpContinuation, true, part))))
+
+	require.Equal(t, ws.StatusMessageTooBig, readClose(t, c))
+}
+
+func TestServer_controlFrame_tooBig(t *testing.T) {
+	ts, _, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	payload := make([]byte, ws.MaxControlFramePayloadSize+1)
+	require.NoError(t, ws.WriteFrame(c, ws.MaskFrame(ws.NewPingFrame(payload))))
+	require.Equal(t, ws.StatusProtocolError, readClose(t, c))
+}
+
+func TestServer_controlFrame_fragmented(t *testing.T) {
+	ts, _, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	require.NoError(t, ws.WriteFrame(c, ws.MaskFrame(ws.NewFrame(ws.OpPing, false, []byte("ping")))))
+	require.Equal(t, ws.StatusProtocolError, readClose(t, c))
+}
+
+func TestServer_OnPing(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	received := make(chan []byte, 1)
+	wsServer.OnPing(func(c Connection, payload []byte) {
+		received <- payload
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	payload := []byte{0, 1, 2, 0xff}
+	require.NoError(t, ws.WriteFrame(c, ws.MaskFrame(ws.NewPingFrame(payload))))
+
+	f, err := ws.ReadFrame(c)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpPong, f.Header.OpCode)
+	require.Equal(t, payload, f.Payload)
+
+	select {
+	case b := <-received:
+		require.Equal(t, payload, b)
+	case <-time.After(time.Second):
+		t.Fatal("ping payload must be delivered to OnPing")
+	}
+}
+
+func TestServer_OnPong(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	received := make(chan []byte, 1)
+	wsServer.OnPong(func(c Connection, payload []byte) {
+		received <- payload
+	})
+	wsServer.On("ping", func(c Connection, msg *Message) {
+		require.ErrorIs(t, c.(*Conn).Ping(make([]byte, 126)), ws.ErrProtocolControlPayloadOverflow)
+		require.NoError(t, c.(*Conn).Ping([]byte("liveness")))
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+	writeMessage(t, c, "ping", nil)
+
+	f, err := ws.ReadFrame(c)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpPing, f.Header.OpCode)
+	require.Equal(t, "liveness", string(f.Payload))
+	require.NoError(t, ws.WriteFrame(c, ws.MaskFrame(ws.NewPongFrame(f.Payload))))
+
+	select {
+	case b := <-received:
+		require.Equal(t, "liveness", string(b))
+	case <-time.After(time.Second):
+		t.Fatal("pong payload must be delivered to OnPong")
+	}
+}
@@ -0,0 +1,40 @@
+package websocket
+
+import (
+	"errors"
+)
+
+// ErrReadOnly is returned by Channel.Publish for read-only member.
+var ErrReadOnly = errors.New("websocket: read-only channel member")
+
+// AddReadOnly adds connection to channel as read-only member, e.g. spectator: it receives messages
+// of channel, but can't publish to it (see Publish). Regular member added again becomes read-only.
+// It's checked by authorizer and limit of channel as Add.
+func (c *Channel) AddReadOnly(conn Connection) error {
+	return c.add(conn, false)
+}
+
+// ReadOnly reports whether connection is read-only member of channel.
+func (c *Channel) ReadOnly(conn Connection) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writable, ok := c.connections[conn]
+	return ok && !writable
+}
+
+// Publish emits message to channel on behalf of member, e.g. from handler of client message.
+// Read-only members are rejected with ErrReadOnly and other connections with ErrNotMember.
+func (c *Channel) Publish(from Connection, name string, data any) (BroadcastResult, error) {
+	c.mu.Lock()
+	writable, ok := c.connections[from]
+	c.mu.Unlock()
+
+	switch {
+	case !ok:
+		return BroadcastResult{}, ErrNotMember
+	case !writable:
+		return BroadcastResult{}, ErrReadOnly
+	}
+	return c.Emit(name, data), nil
+}
@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestChannel_AddReadOnly(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	stage := wsServer.NewChannel("stage")
+	wsServer.On("say", func(c Connection, msg *Message) {
+		if _, err := stage.Publish(c, "said", msg.Data); err != nil {
+			replyError(c, msg.Name, stage.ID(), err)
+		}
+	})
+
+	// connect one by one, so server side connections are known
+	connect := func() (net.Conn, *Conn) {
+		known := map[*Conn]bool{}
+		for _, c := range wsServer.ConnectionsWhere(func(*Conn) bool { return true }) {
+			known[c] = true
+		}
+		c := dial(t, ts)
+		var conn *Conn
+		require.Eventually(t, func() bool {
+			list := wsServer.ConnectionsWhere(func(c *Conn) bool { return !known[c] })
+			if len(list) == 1 {
+				conn = list[0]
+			}
+			return conn != nil
+		}, time.Second, 5*time.Millisecond)
+		return c, conn
+	}
+	speaker, speakerConn := connect()
+	defer speaker.Close()
+	spectator, spectatorConn := connect()
+	defer spectator.Close()
+
+	require.NoError(t, stage.Add(speakerConn))
+	require.NoError(t, stage.AddReadOnly(spectatorConn))
+	require.False(t, stage.ReadOnly(speakerConn))
+	require.True(t, stage.ReadOnly(spectatorConn))
+	require.Equal(t, 2, stage.Count())
+
+	writeMessage(t, speaker, "say", "hello")
+	for _, c := range []net.Conn{speaker, spectator} {
+		name, data := readEnvelope(t, c)
+		require.Equal(t, "said", name)
+		require.Equal(t, `"hello"`, string(data))
+	}
+
+	writeMessage(t, spectator, "say", "boo")
+	name, data := readEnvelope(t, spectator)
+	require.Equal(t, EventError, name)
+	require.Contains(t, string(data), "read-only")
+
+	stage.Remove(speakerConn)
+	_, err := stage.Publish(speakerConn, "said", "x")
+	require.ErrorIs(t, err, ErrNotMember)
+
+	require.NoError(t, stage.Add(spectatorConn))
+	require.False(t, stage.ReadOnly(spectatorConn), "read-only member added again becomes regular one")
+}
@@ -0,0 +1,354 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// RecordType is the type of Record.
+type RecordType string
+
+// Types of records.
+const (
+	RecordOpen RecordType = "open"
+	RecordIn   RecordType = "in"
+	RecordOut  RecordType = "out"
+)
+
+// DefaultReplayTimeout is the time ReplaySession waits for replies of server, see ReplayOptions.
+const DefaultReplayTimeout = time.Second
+
+// Record is one line of recorded session, see WithRecorder. The first record of session is RecordOpen
+// with id and query of connection, then messages received from client (RecordIn) and frames sent to
+// client (RecordOut) follow. Text payload is kept in Text, other payload in Data.
+type Record struct {
+	Time  time.Time  `json:"time"`
+	Type  RecordType `json:"type"`
+	ID    string     `json:"id,omitempty"`
+	Query string     `json:"query,omitempty"`
+	Op    ws.OpCode  `json:"op,omitempty"`
+	Text  string     `json:"text,omitempty"`
+	Data  []byte     `json:"data,omitempty"`
+}
+
+// Payload return payload of recorded frame.
+func (r Record) Payload() []byte {
+	if r.Text != "" {
+		return []byte(r.Text)
+	}
+	return r.Data
+}
+
+// WithRecorder records sessions of connections for which f returns writer, nil writer skips the connection.
+// Every record is written as json line (see Record): messages received from client after reassembly
+// and extensions, and frames sent to client before extensions. Streams (see OnStream) are not recorded.
+// Writer is closed when connection is closed if it's io.Closer. Recording is meant for debugging
+// and could be replayed with ReplaySession, it's expensive and payload is written as is,
+// so it should be enabled for selected connections only.
+func WithRecorder(f func(c Connection) io.Writer) Option {
+	return func(s *Server) {
+		s.recorder = f
+	}
+}
+
+// recorder writes records of one connection.
+type recorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// startRecording ask recorder of server whether connection is recorded and write open record.
+func (s *Server) startRecording(c *Conn) {
+	if s.recorder == nil {
+		return
+	}
+	w := s.recorder(c)
+	if w == nil {
+		return
+	}
+
+	rec := &recorder{w: w, enc: json.NewEncoder(w)}
+	c.recorder.Store(rec)
+	query := ""
+	if c.params != nil {
+		query = c.params.Encode()
+	}
+	c.record(Record{Type: RecordOpen, ID: c.id, Query: query})
+}
+
+// record write the record of connection if it's recorded, recording stops on write error.
+func (c *Conn) record(r Record) {
+	rec := c.recorder.Load()
+	if rec == nil {
+		return
+	}
+
+	r.Time = time.Now()
+	rec.mu.Lock()
+	err := rec.enc.Encode(r)
+	rec.mu.Unlock()
+	if err != nil {
+		log.Printf("websocket: stop recording %s: %v", c.id, err)
+		c.stopRecording()
+	}
+}
+
+// recordFrame write the frame to record of connection.
+func (c *Conn) recordFrame(t RecordType, op ws.OpCode, b []byte) {
+	if c.recorder.Load() == nil {
+		return
+	}
+	r := Record{Type: t, Op: op}
+	if op == ws.OpText && utf8.Valid(b) {
+		r.Text = string(b)
+	} else if len(b) != 0 {
+		r.Data = append([]byte(nil), b...)
+	}
+	c.record(r)
+}
+
+// stopRecording close the writer of recorder if it's io.Closer.
+func (c *Conn) stopRecording() {
+	rec := c.recorder.Swap(nil)
+	if rec == nil {
+		return
+	}
+	if closer, ok := rec.w.(io.Closer); ok {
+		rec.mu.Lock()
+		_ = closer.Close()
+		rec.mu.Unlock()
+	}
+}
+
+// ReadRecords decode records written by recorder, see WithRecorder.
+func ReadRecords(r io.Reader) ([]Record, error) {
+	var records []Record
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+}
+
+// ReplayOptions of ReplaySession.
+type ReplayOptions struct {
+	// Speed scales delays between recorded messages, e.g. 2 replays twice faster.
+	// Messages are sent without delays if zero.
+	Speed float64
+	// Timeout limits waiting for replies which server sent in recording before close frame,
+	// replayed handlers could send less. DefaultReplayTimeout by default.
+	Timeout time.Duration
+}
+
+// ReplaySession feeds messages of recorded session (see WithRecorder) into the server as a new
+// connection with the same query, e.g. to debug handler or to check it in regression test.
+// It returns data messages which server sent during replay, they could be compared with RecordOut
+// records of recording. Control frames sent by server are not returned.
+func (s *Server) ReplaySession(ctx context.Context, r io.Reader, opts ReplayOptions) ([]Record, error) {
+	records, err := ReadRecords(r)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultReplayTimeout
+	}
+
+	query := ""
+	for _, rec := range records {
+		if rec.Type == RecordOpen {
+			query = rec.Query
+			break
+		}
+	}
+
+	server, client := net.Pipe()
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: "/", RawQuery: query},
+		Header:     http.Header{},
+		RemoteAddr: "replay",
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- s.ServeStream(server, req)
+	}()
+
+	rc := &replayClient{conn: client, done: make(chan struct{}), changed: make(chan struct{})}
+	go rc.read()
+	defer func() {
+		_ = client.Close()
+		<-rc.done
+	}()
+
+	var (
+		prev     time.Time
+		closed   bool
+		expected int
+	)
+	for _, rec := range records {
+		if rec.Type == RecordOut && (rec.Op == ws.OpText || rec.Op == ws.OpBinary) {
+			expected++
+		}
+		if rec.Type != RecordIn {
+			continue
+		}
+		if opts.Speed > 0 && !prev.IsZero() {
+			if err = sleep(ctx, time.Duration(float64(rec.Time.Sub(prev))/opts.Speed)); err != nil {
+				return rc.result(), err
+			}
+		}
+		prev = rec.Time
+
+		// handlers are called asynchronously, replies to previous messages must be sent before close
+		if rec.Op == ws.OpClose {
+			if err = rc.wait(ctx, expected, opts.Timeout); err != nil {
+				return rc.result(), err
+			}
+		}
+		if err = rc.write(rec.Op, rec.Payload()); err != nil {
+			select {
+			case serr := <-served:
+				if serr != nil {
+					return nil, serr
+				}
+			default:
+			}
+			return rc.result(), err
+		}
+		if rec.Op == ws.OpClose {
+			closed = true
+			break
+		}
+	}
+
+	if !closed {
+		if err = rc.wait(ctx, expected, opts.Timeout); err != nil {
+			return rc.result(), err
+		}
+		_ = rc.write(ws.OpClose, ws.NewCloseFrameBody(ws.StatusNormalClosure, ""))
+	}
+
+	select {
+	case <-rc.done:
+	case <-time.After(opts.Timeout):
+	case <-ctx.Done():
+		return rc.result(), ctx.Err()
+	}
+	return rc.result(), nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// replayClient is client side of replayed session.
+type replayClient struct {
+	conn    net.Conn
+	mu      sync.Mutex
+	done    chan struct{}
+	records []Record
+	// changed is closed and replaced when message is received
+	changed chan struct{}
+}
+
+// write the whole frame with one call, so pong replies of reader don't interleave with it.
+func (rc *replayClient) write(op ws.OpCode, b []byte) error {
+	var buf bytes.Buffer
+	if err := ws.WriteFrame(&buf, ws.MaskFrameInPlace(ws.NewFrame(op, true, b))); err != nil {
+		return err
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	_, err := rc.conn.Write(buf.Bytes())
+	return err
+}
+
+func (rc *replayClient) Read(p []byte) (int, error) {
+	return rc.conn.Read(p)
+}
+
+func (rc *replayClient) Write(p []byte) (int, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.conn.Write(p)
+}
+
+// read messages of server until connection is closed.
+func (rc *replayClient) read() {
+	defer close(rc.done)
+	for {
+		b, op, err := wsutil.ReadServerData(rc)
+		if err != nil {
+			return
+		}
+		r := Record{Time: time.Now(), Type: RecordOut, Op: op}
+		if op == ws.OpText {
+			r.Text = string(b)
+		} else {
+			r.Data = b
+		}
+		rc.mu.Lock()
+		rc.records = append(rc.records, r)
+		close(rc.changed)
+		rc.changed = make(chan struct{})
+		rc.mu.Unlock()
+	}
+}
+
+// wait until server sent n messages, connection is closed or timeout expires.
+func (rc *replayClient) wait(ctx context.Context, n int, timeout time.Duration) error {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	for {
+		rc.mu.Lock()
+		received, changed := len(rc.records), rc.changed
+		rc.mu.Unlock()
+		if received >= n {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-rc.done:
+			return nil
+		case <-t.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (rc *replayClient) result() []Record {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]Record(nil), rc.records...)
+}
@@ -0,0 +1,118 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recording is a writer which signals when recorder closes it.
+type recording struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed chan struct{}
+}
+
+func (r *recording) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.Write(p)
+}
+
+func (r *recording) Close() error {
+	close(r.closed)
+	return nil
+}
+
+func TestServer_WithRecorder(t *testing.T) {
+	rec := &recording{closed: make(chan struct{})}
+	echo := func(c Connection, msg *Message) {
+		_ = c.Emit("echo", c.Param("room")+":"+msg.String())
+	}
+
+	ts, wsServer, shutdown := server(t, WithRecorder(func(c Connection) io.Writer {
+		if c.Param("room") == "" {
+			return nil
+		}
+		return rec
+	}))
+	defer shutdown()
+	wsServer.On("echo", echo)
+
+	u := url.URL{Scheme: "ws", Host: strings.TrimPrefix(ts.URL, "http://"), Path: "/ws", RawQuery: "room=1"}
+	c, _, _, err := ws.Dial(context.Background(), u.String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	writeMessage(t, c, "echo", "a")
+	writeMessage(t, c, "echo", "b")
+	for range 2 {
+		_, _, err = wsutil.ReadServerData(c)
+		require.NoError(t, err)
+	}
+	require.NoError(t, wsutil.WriteClientMessage(c, ws.OpClose, ws.NewCloseFrameBody(ws.StatusNormalClosure, "")))
+
+	select {
+	case <-rec.closed:
+	case <-time.After(time.Second):
+		t.Fatal("recording must be closed")
+	}
+
+	records, err := ReadRecords(bytes.NewReader(rec.buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, RecordOpen, records[0].Type)
+	require.Equal(t, "room=1", records[0].Query)
+
+	var in, out []string
+	for _, r := range records[1:] {
+		switch {
+		case r.Type == RecordIn && r.Op == ws.OpText:
+			in = append(in, r.Text)
+		case r.Type == RecordOut && r.Op != ws.OpClose:
+			out = append(out, string(r.Payload()))
+		}
+	}
+	require.Equal(t, []string{`{"name":"echo","data":"a"}`, `{"name":"echo","data":"b"}`}, in)
+	require.Len(t, out, 2)
+	require.Contains(t, out[0], "1:a")
+
+	// connection without room is not recorded
+	c2 := dial(t, ts)
+	writeMessage(t, c2, "echo", "c")
+	_, _, err = wsutil.ReadServerData(c2)
+	require.NoError(t, err)
+	_ = c2.Close()
+
+	// replay into fresh server gives the same replies
+	replayServer := Start(context.Background())
+	defer func() {
+		_ = replayServer.Shutdown()
+	}()
+	replayServer.On("echo", echo)
+
+	replies, err := replayServer.ReplaySession(context.Background(), bytes.NewReader(rec.buf.Bytes()), ReplayOptions{Speed: 10})
+	require.NoError(t, err)
+	var got []string
+	for _, r := range replies {
+		got = append(got, string(r.Payload()))
+	}
+	require.Equal(t, out, got)
+}
+
+func TestReadRecords(t *testing.T) {
+	records, err := ReadRecords(strings.NewReader(`{"type":"open","id":"1"}` + "\n" + `{"type":"in","op":2,"data":"AQI="}`))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, []byte{1, 2}, records[1].Payload())
+
+	_, err = ReadRecords(strings.NewReader(`{"type":`))
+	require.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"fmt"
+	"github.com/gobwas/ws"
+	"log"
+	"runtime/debug"
+)
+
+// PanicError is passed to OnError when user callback panicked.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+// Error implements error.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("websocket: panic in callback: %v", e.Value)
+}
+
+// OnError function which will be called on errors of message processing and on panics
+// in callbacks (as *PanicError with stack trace). Without it errors are logged.
+// Connection which callback panicked is closed with 1011 status.
+func (s *Server) OnError(f func(c Connection, err error)) {
+	s.mu.Lock()
+	s.onError = f
+	s.mu.Unlock()
+}
+
+// reportError pass error to OnError or log it.
+func (s *Server) reportError(c *Conn, err error) {
+	s.mu.RLock()
+	onError := s.onError
+	s.mu.RUnlock()
+
+	if onError == nil {
+		if p, ok := err.(*PanicError); ok {
+			log.Printf("%v\n%s", p, p.Stack)
+			return
+		}
+		log.Print(err)
+		return
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			log.Printf("websocket: panic in OnError: %v\n%s", v, debug.Stack())
+		}
+	}()
+	onError(c, err)
+}
+
+// safe call user callback f, panic is reported and connection is closed.
+// Returns *PanicError if f panicked.
+func (s *Server) safe(c *Conn, f func()) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			p := &PanicError{Value: v, Stack: debug.Stack()}
+			s.reportError(c, p)
+			_ = c.closeWith(ws.StatusInternalServerError, "")
+			err = p
+		}
+	}()
+
+	f()
+	return nil
+}
@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestServer_recover_handler(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	errs := make(chan error, 1)
+	wsServer.OnError(func(c Connection, err error) {
+		errs <- err
+	})
+	wsServer.On("boom", func(c Connection, msg *Message) {
+		panic("boom")
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	writeMessage(t, c, "boom", nil)
+	require.Equal(t, ws.StatusInternalServerError, readClose(t, c))
+
+	select {
+	case err := <-errs:
+		var p *PanicError
+		require.True(t, errors.As(err, &p))
+		require.Equal(t, "boom", p.Value)
+		require.Contains(t, string(p.Stack), "recover_test.go")
+	case <-time.After(time.Second):
+		t.Fatal("panic must be reported to OnError")
+	}
+
+	other := dial(t, ts)
+	defer func() {
+		require.NoError(t, other.Close())
+	}()
+	require.NoError(t, wsutil.WriteClientText(other, []byte("echo")))
+	b, err := wsutil.ReadServerText(other)
+	require.NoError(t, err)
+	require.Equal(t, "echo", string(b), "server must keep working after panic")
+}
+
+func TestServer_recover_OnConnect(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	errs := make(chan error, 1)
+	wsServer.OnError(func(c Connection, err error) {
+		errs <- err
+	})
+	wsServer.OnConnect(func(c Connection) {
+		time.Sleep(50 * time.Millisecond)
+		panic(errors.New("connect"))
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	require.Equal(t, ws.StatusInternalServerError, readClose(t, c))
+	require.ErrorContains(t, <-errs, "connect")
+}
+
+func TestServer_OnError(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	errs := make(chan error, 1)
+	wsServer.OnError(func(c Connection, err error) {
+		errs <- err
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, "_unknown", nil)
+	select {
+	case err := <-errs:
+		require.ErrorContains(t, err, "unknown system event")
+	case <-time.After(time.Second):
+		t.Fatal("processing error must be reported to OnError")
+	}
+}
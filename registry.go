@@ -0,0 +1,142 @@
+package websocket
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// registryShards is a number of buckets in connection registry.
+// Must be a power of two.
+const registryShards = 32
+
+// registry keeps live connections split into shards by connection id hash,
+// so add/drop of one connection doesn't block the whole server.
+type registry struct {
+	shards [registryShards]registryShard
+}
+
+type registryShard struct {
+	connections map[*Conn]bool
+	ids         map[string]*Conn
+	mu          sync.RWMutex
+	// snap is immutable copy of connections, it's dropped on every change and built again on demand
+	snap atomic.Pointer[[]*Conn]
+}
+
+func newRegistry() *registry {
+	r := &registry{}
+	for i := range r.shards {
+		r.shards[i].connections = make(map[*Conn]bool)
+		r.shards[i].ids = make(map[string]*Conn)
+	}
+	return r
+}
+
+func (r *registry) shard(c *Conn) *registryShard {
+	return r.shardOf(c.id)
+}
+
+func (r *registry) shardOf(id string) *registryShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return &r.shards[h.Sum32()&(registryShards-1)]
+}
+
+// get return live connection by id or nil.
+func (r *registry) get(id string) *Conn {
+	s := r.shardOf(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.ids[id]
+}
+
+// add the connection, returns false if another connection has the same id.
+func (r *registry) add(c *Conn) bool {
+	s := r.shard(c)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if other, ok := s.ids[c.id]; ok && other != c {
+		return false
+	}
+	s.connections[c] = true
+	s.ids[c.id] = c
+	s.snap.Store(nil)
+	return true
+}
+
+func (r *registry) remove(c *Conn) {
+	s := r.shard(c)
+	s.mu.Lock()
+	delete(s.connections, c)
+	if s.ids[c.id] == c {
+		delete(s.ids, c.id)
+	}
+	s.snap.Store(nil)
+	s.mu.Unlock()
+}
+
+func (r *registry) count() int {
+	n := 0
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mu.RLock()
+		n += len(s.connections)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// forEach call f for every connection. Connections are taken from shard snapshots and no lock is held
+// while f is called, so slow writes in f don't block connects and disconnects.
+func (r *registry) forEach(f func(c *Conn)) {
+	for i := range r.shards {
+		for _, c := range r.shards[i].snapshot() {
+			f(c)
+		}
+	}
+}
+
+// forEachShard is like forEach, but shards are iterated concurrently.
+// It waits until all shards are processed.
+func (r *registry) forEachShard(f func(c *Conn)) {
+	var wg sync.WaitGroup
+	wg.Add(registryShards)
+	for i := range r.shards {
+		go func(s *registryShard) {
+			defer wg.Done()
+			for _, c := range s.snapshot() {
+				f(c)
+			}
+		}(&r.shards[i])
+	}
+	wg.Wait()
+}
+
+// snapshot return copy-on-write list of shard connections, it must not be modified.
+func (s *registryShard) snapshot() []*Conn {
+	if list := s.snap.Load(); list != nil {
+		return *list
+	}
+
+	// stored under the read lock, so it can't overwrite reset of concurrent add or remove
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]*Conn, 0, len(s.connections))
+	for c := range s.connections {
+		list = append(list, c)
+	}
+	s.snap.Store(&list)
+	return list
+}
+
+// snapshot return all connections, no lock is held after it returns.
+func (r *registry) snapshot() []*Conn {
+	var list []*Conn
+	for i := range r.shards {
+		list = append(list, r.shards[i].snapshot()...)
+	}
+	return list
+}
@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestRegistry_AddRemove(t *testing.T) {
+	r := newRegistry()
+
+	connections := make([]*Conn, 0)
+	for i := 0; i < 100; i++ {
+		c := &Conn{id: fmt.Sprintf("conn-%d", i)}
+		r.add(c)
+		connections = append(connections, c)
+	}
+	require.Equal(t, 100, r.count())
+
+	for _, c := range connections[:40] {
+		r.remove(c)
+	}
+	require.Equal(t, 60, r.count())
+
+	seen := make(map[*Conn]bool)
+	r.forEach(func(c *Conn) {
+		seen[c] = true
+	})
+	require.Equal(t, 60, len(seen))
+	for _, c := range connections[40:] {
+		require.True(t, seen[c], "connection must be in registry")
+	}
+}
+
+func TestRegistry_Concurrent(t *testing.T) {
+	r := newRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := &Conn{id: fmt.Sprintf("conn-%d", i)}
+			r.add(c)
+			_ = r.count()
+			r.forEach(func(c *Conn) {})
+			if i%2 == 0 {
+				r.remove(c)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Equal(t, 25, r.count())
+}
+
+func TestRegistry_uniqueIDs(t *testing.T) {
+	r := newRegistry()
+
+	c1, c2 := &Conn{id: "conn"}, &Conn{id: "conn"}
+	require.True(t, r.add(c1))
+	require.True(t, r.add(c1))
+	require.False(t, r.add(c2), "id is already used")
+	require.Equal(t, 1, r.count())
+
+	r.remove(c2)
+	require.Equal(t, 1, r.count())
+	r.remove(c1)
+	require.True(t, r.add(c2))
+}
+
+func TestRegistry_snapshot(t *testing.T) {
+	r := newRegistry()
+
+	c1, c2 := &Conn{id: "1"}, &Conn{id: "2"}
+	r.add(c1)
+	list := r.snapshot()
+	require.Equal(t, []*Conn{c1}, list)
+	require.Equal(t, list, r.snapshot(), "snapshot must be reused until registry changes")
+
+	r.add(c2)
+	require.Equal(t, []*Conn{c1}, list, "snapshot must not change")
+	require.ElementsMatch(t, []*Conn{c1, c2}, r.snapshot())
+
+	r.remove(c1)
+	require.Equal(t, []*Conn{c2}, r.snapshot())
+}
+
+func TestRegistry_forEach_unlocked(t *testing.T) {
+	r := newRegistry()
+	for i := 0; i < 10; i++ {
+		r.add(&Conn{id: fmt.Sprintf("conn-%d", i)})
+	}
+
+	// f changes registry, it would deadlock if shard was locked during iteration
+	n := 0
+	r.forEach(func(c *Conn) {
+		n++
+		r.remove(c)
+		r.add(c)
+	})
+	require.Equal(t, 10, n)
+	require.Equal(t, 10, r.count())
+
+	var mu sync.Mutex
+	n = 0
+	r.forEachShard(func(c *Conn) {
+		r.remove(c)
+		mu.Lock()
+		n++
+		mu.Unlock()
+	})
+	require.Equal(t, 10, n)
+	require.Equal(t, 0, r.count())
+}
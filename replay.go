@@ -0,0 +1,283 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+// EventReplay is sent by client to replay channel log: {"channel": "room-1", "from": 120}.
+// Server sends one page of messages and then _replay event with offset of the next page:
+// {"channel": "room-1", "next": 220, "more": true}.
+const EventReplay = SystemPrefix + "replay"
+
+// DefaultReplayPage is the number of messages sent for one replay request.
+const DefaultReplayPage = 100
+
+// DefaultLogTimeout limits one call of channel log, see LogOptions.
+const DefaultLogTimeout = 5 * time.Second
+
+// logQueueSize is the number of messages waiting for Append, messages are dropped when it's full.
+const logQueueSize = 1024
+
+var (
+	// ErrNoLog is returned when channel has no log to replay.
+	ErrNoLog = errors.New("websocket: channel has no log")
+	// ErrNotMember is returned when connection is not in channel.
+	ErrNotMember = errors.New("websocket: connection is not in channel")
+)
+
+// LogEntry is a message stored in the channel log.
+type LogEntry struct {
+	Offset int64
+	Seq    uint64
+	Time   time.Time
+	Name   string
+	Data   []byte
+}
+
+// Log is an append-only log of channel messages (e.g. Kafka topic or Redis stream).
+// Append returns the offset of stored entry, Read returns up to limit entries starting from offset.
+// Data of entries is sealed when server has WithStateEncryption.
+type Log interface {
+	Append(ctx context.Context, channel string, entry LogEntry) (int64, error)
+	Read(ctx context.Context, channel string, from int64, limit int) ([]LogEntry, error)
+}
+
+// LogOptions defines how channel log is replayed.
+type LogOptions struct {
+	// PageSize is the max number of messages for one replay request, default is DefaultReplayPage.
+	PageSize int
+	// Rate limits replay to n messages per second, 0 means unlimited.
+	Rate int
+	// Timeout limits one Append or Read of the log, default is DefaultLogTimeout.
+	Timeout time.Duration
+}
+
+// replayRequest is the data of _replay event.
+type replayRequest struct {
+	Channel string `json:"channel"`
+	From    int64  `json:"from"`
+}
+
+// replayResult is the data of _replay event sent after the page.
+type replayResult struct {
+	Channel string `json:"channel"`
+	Next    int64  `json:"next"`
+	More    bool   `json:"more"`
+}
+
+// SetLog backs the channel with the log: every Emit is appended to the log
+// and connections of channel can replay it with _replay event.
+// Messages are appended in order from background goroutine, so slow log doesn't hold emitters.
+func (c *Channel) SetLog(l Log, opts LogOptions) {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultReplayPage
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultLogTimeout
+	}
+
+	c.mu.Lock()
+	c.log = l
+	c.logOptions = opts
+	if c.logQueue == nil {
+		c.logQueue = make(chan LogEntry, logQueueSize)
+		c.logDone = make(chan struct{})
+		if c.server != nil {
+			spawn(&c.server.goroutines.background, c.appendLog)
+		} else {
+			go c.appendLog()
+		}
+	}
+	c.mu.Unlock()
+}
+
+// appendLog append queued messages to the log until channel is closed, queued messages are appended before exit.
+func (c *Channel) appendLog() {
+	var quit chan struct{}
+	if c.server != nil {
+		quit = c.server.quit
+	}
+
+	for {
+		select {
+		case entry := <-c.logQueue:
+			c.append(entry)
+		case <-c.logDone:
+			for {
+				select {
+				case entry := <-c.logQueue:
+					c.append(entry)
+				default:
+					return
+				}
+			}
+		case <-quit:
+			return
+		}
+	}
+}
+
+// append the message to the log with timeout.
+func (c *Channel) append(entry LogEntry) {
+	c.mu.Lock()
+	l, timeout := c.log, c.logOptions.Timeout
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, err := l.Append(ctx, c.id, entry); err != nil {
+		log.Printf("websocket: append to log of channel %q: %v", c.id, err)
+	}
+}
+
+// Replay send one page of the channel log to connection starting from offset.
+// Returns offset of the next page and whether there could be more messages.
+func (c *Channel) Replay(ctx context.Context, conn Connection, from int64) (int64, bool, error) {
+	c.mu.Lock()
+	l, opts := c.log, c.logOptions
+	_, member := c.connections[conn]
+	c.mu.Unlock()
+
+	if l == nil {
+		return from, false, ErrNoLog
+	}
+	if !member {
+		return from, false, ErrNotMember
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	entries, err := l.Read(readCtx, c.id, from, opts.PageSize)
+	cancel()
+	if err != nil {
+		return from, false, err
+	}
+
+	var tick <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(opts.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	next := from
+	for i, e := range entries {
+		if tick != nil && i != 0 {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return next, true, ctx.Err()
+			}
+		}
+
+		data, err := c.open(ctx, e.Data)
+		if err != nil {
+			return next, true, err
+		}
+		if err = conn.Emit(e.Name, json.RawMessage(data)); err != nil {
+			return next, true, err
+		}
+		next = e.Offset + 1
+	}
+
+	return next, len(entries) == opts.PageSize, nil
+}
+
+// persist store message in channel log and history if they are set, pass it to OnChannelEmit and webhooks.
+// Seq is zero for channels without sequence numbers.
+func (c *Channel) persist(name string, data any, seq uint64) {
+	if c.server != nil {
+		c.server.mu.RLock()
+		onEmit, hooks := c.server.onChannelEmit, c.server.webhooks[name]
+		c.server.mu.RUnlock()
+		for _, h := range onEmit {
+			h.f(c, name, data)
+		}
+		c.server.notify(hooks, WebhookEvent{Name: name, Channel: c.id}, data)
+	}
+
+	c.mu.Lock()
+	l, h, queue := c.log, c.history, c.logQueue
+	c.mu.Unlock()
+	if l == nil && h == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultLogTimeout)
+	defer cancel()
+	v, err := jsonData(data)
+	var b []byte
+	if err == nil {
+		b, err = json.Marshal(v)
+	}
+	if err == nil {
+		b, err = c.seal(ctx, b)
+	}
+	if err != nil {
+		log.Printf("websocket: store message of channel %q: %v", c.id, err)
+		return
+	}
+
+	entry := LogEntry{Seq: seq, Time: time.Now(), Name: name, Data: b}
+	if l != nil {
+		select {
+		case queue <- entry:
+		default:
+			log.Printf("websocket: log queue of channel %q is full, message %q is not appended", c.id, name)
+		}
+	}
+	if h != nil {
+		if err = h.Add(ctx, c.id, entry); err != nil {
+			log.Printf("websocket: add to history of channel %q: %v", c.id, err)
+		}
+	}
+}
+
+func (c *Channel) seal(ctx context.Context, b []byte) ([]byte, error) {
+	if c.server == nil || c.server.sealer == nil {
+		return b, nil
+	}
+	return c.server.sealer.Seal(ctx, c.id, b)
+}
+
+func (c *Channel) open(ctx context.Context, b []byte) ([]byte, error) {
+	if c.server == nil || c.server.sealer == nil {
+		return b, nil
+	}
+	return c.server.sealer.Open(ctx, c.id, b)
+}
+
+// replay handle _replay event from client.
+func replay(c *Conn, msg *Message) {
+	var req replayRequest
+	err := json.Unmarshal(msg.Data, &req)
+	if err == nil {
+		err = c.checkChannel(req.Channel)
+	}
+
+	var ch *Channel
+	if err == nil {
+		if ch = c.server.Channel(c.channelID(req.Channel)); ch == nil {
+			err = ErrNotMember
+		}
+	}
+
+	if err != nil {
+		replyError(c, EventReplay, req.Channel, err)
+		return
+	}
+
+	// replay could be slow (log read, rate limit), it must not hold reading from connection
+	spawn(&c.server.goroutines.background, func() {
+		res := replayResult{Channel: req.Channel, Next: req.From}
+		var err error
+		if res.Next, res.More, err = ch.Replay(c.Context(), c, req.From); err != nil {
+			replyError(c, EventReplay, req.Channel, err)
+			return
+		}
+		_ = c.Emit(EventReplay, res)
+	})
+}
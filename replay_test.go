@@ -0,0 +1,206 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryLog struct {
+	entries []LogEntry
+	mu      sync.Mutex
+}
+
+func (l *memoryLog) Append(_ context.Context, _ string, e LogEntry) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.Offset = int64(len(l.entries))
+	l.entries = append(l.entries, e)
+	return e.Offset, nil
+}
+
+func (l *memoryLog) Read(_ context.Context, _ string, from int64, limit int) ([]LogEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if from >= int64(len(l.entries)) {
+		return nil, nil
+	}
+	to := min(from+int64(limit), int64(len(l.entries)))
+	return append([]LogEntry{}, l.entries[from:to]...), nil
+}
+
+func (l *memoryLog) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.entries)
+}
+
+// blockedLog blocks Append until context is done.
+type blockedLog struct {
+	memoryLog
+}
+
+func (l *blockedLog) Append(ctx context.Context, _ string, _ LogEntry) (int64, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func joinChannel(t *testing.T, c net.Conn, id string) {
+	writeMessage(t, c, EventSubscribe, map[string]string{"channel": id})
+	_, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+}
+
+func readEnvelope(t *testing.T, c net.Conn) (string, json.RawMessage) {
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+
+	var msg struct {
+		Name string          `json:"name"`
+		Data json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(b, &msg))
+	return msg.Name, msg.Data
+}
+
+func TestChannel_Replay(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	l := &memoryLog{}
+	ch := wsServer.NewChannel("room")
+	ch.SetLog(l, LogOptions{PageSize: 2})
+	for i := 0; i < 3; i++ {
+		ch.Emit("msg", i)
+	}
+	require.Eventually(t, func() bool { return l.count() == 3 }, time.Second, time.Millisecond)
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	joinChannel(t, c, "room")
+
+	writeMessage(t, c, EventReplay, map[string]any{"channel": "room", "from": 0})
+	for i := 0; i < 2; i++ {
+		name, data := readEnvelope(t, c)
+		require.Equal(t, "msg", name)
+		require.Equal(t, fmt.Sprint(i), string(data))
+	}
+	name, data := readEnvelope(t, c)
+	require.Equal(t, EventReplay, name)
+	require.JSONEq(t, `{"channel":"room","next":2,"more":true}`, string(data))
+
+	writeMessage(t, c, EventReplay, map[string]any{"channel": "room", "from": 2})
+	name, data = readEnvelope(t, c)
+	require.Equal(t, "msg", name)
+	require.Equal(t, "2", string(data))
+	name, data = readEnvelope(t, c)
+	require.Equal(t, EventReplay, name)
+	require.JSONEq(t, `{"channel":"room","next":3,"more":false}`, string(data))
+}
+
+func TestChannel_Replay_notMember(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	wsServer.NewChannel("room").SetLog(&memoryLog{}, LogOptions{})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, EventReplay, map[string]any{"channel": "room"})
+	name, data := readEnvelope(t, c)
+	require.Equal(t, EventError, name)
+	require.JSONEq(t, `{"event":"_replay","code":403,"channel":"room","message":"websocket: connection is not in channel"}`, string(data))
+}
+
+func TestChannel_Replay_noLog(t *testing.T) {
+	ch := newChannel("room")
+	c := &Conn{id: "1"}
+	ch.Add(c)
+
+	_, _, err := ch.Replay(context.Background(), c, 0)
+	require.ErrorIs(t, err, ErrNoLog)
+}
+
+func TestChannel_Replay_rate(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	ch := wsServer.NewChannel("room")
+	l := &memoryLog{}
+	ch.SetLog(l, LogOptions{Rate: 50})
+	for i := 0; i < 5; i++ {
+		ch.Emit("msg", i)
+	}
+	require.Eventually(t, func() bool { return l.count() == 5 }, time.Second, time.Millisecond)
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	joinChannel(t, c, "room")
+
+	wsServer.On("ping", func(c Connection, msg *Message) {
+		_ = c.Emit("pong", nil)
+	})
+	started := time.Now()
+	writeMessage(t, c, EventReplay, map[string]any{"channel": "room"})
+	writeMessage(t, c, "ping", nil)
+
+	name, _ := readEnvelope(t, c)
+	require.Equal(t, "pong", name, "replay must not hold reading from connection")
+	for i := 0; i < 6; i++ {
+		readEnvelope(t, c)
+	}
+	require.GreaterOrEqual(t, time.Since(started), 80*time.Millisecond, "replay must be rate limited")
+}
+
+func TestChannel_SetLog_slow(t *testing.T) {
+	wsServer := New()
+	ch := wsServer.NewChannel("room")
+	ch.SetLog(&blockedLog{}, LogOptions{Timeout: 100 * time.Millisecond})
+
+	started := time.Now()
+	for i := 0; i < 3; i++ {
+		ch.Emit("msg", i)
+	}
+	require.Less(t, time.Since(started), 50*time.Millisecond, "emit must not wait for log")
+	ch.Close()
+}
+
+func TestChannel_Replay_sealed(t *testing.T) {
+	sealer := NewSealer(StaticKey(bytes.Repeat([]byte("k"), 32)))
+	ts, wsServer, shutdown := server(t, WithStateEncryption(sealer))
+	defer shutdown()
+
+	l := &memoryLog{}
+	ch := wsServer.NewChannel("room")
+	ch.SetLog(l, LogOptions{})
+	ch.Emit("secret", "hello")
+	require.Eventually(t, func() bool { return l.count() == 1 }, time.Second, time.Millisecond)
+	require.NotContains(t, string(l.entries[0].Data), "hello", "log must not store plaintext")
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	joinChannel(t, c, "room")
+
+	writeMessage(t, c, EventReplay, map[string]any{"channel": "room"})
+	name, data := readEnvelope(t, c)
+	require.Equal(t, "secret", name)
+	require.Equal(t, `"hello"`, string(data))
+}
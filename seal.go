@@ -0,0 +1,126 @@
+package websocket
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ErrSealed is returned when sealed data can't be decrypted.
+var ErrSealed = errors.New("websocket: can't open sealed data")
+
+// KMS provides keys for encryption of stored state. Keys could be rotated: data is sealed with
+// the current key and key id is stored with data, so it can be opened later with the old key.
+type KMS interface {
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// Sealer encrypts user messages before they are stored (history, outbox) and decrypts them back.
+// Scope (e.g. channel or connection id) is authenticated, so data can't be moved between scopes.
+type Sealer interface {
+	Seal(ctx context.Context, scope string, data []byte) ([]byte, error)
+	Open(ctx context.Context, scope string, data []byte) ([]byte, error)
+}
+
+// WithStateEncryption sets the sealer for state which server keeps for connections and channels.
+// Without it stored messages are kept in plaintext.
+func WithStateEncryption(sealer Sealer) Option {
+	return func(s *Server) {
+		s.sealer = sealer
+	}
+}
+
+// StaticKey return KMS with a single key, key must be 16, 24 or 32 bytes long.
+func StaticKey(key []byte) KMS {
+	return staticKey(key)
+}
+
+type staticKey []byte
+
+func (k staticKey) CurrentKey(context.Context) (string, []byte, error) {
+	return "static", k, nil
+}
+
+func (k staticKey) Key(_ context.Context, id string) ([]byte, error) {
+	if id != "static" {
+		return nil, fmt.Errorf("websocket: unknown key %q", id)
+	}
+	return k, nil
+}
+
+// NewSealer return AES-GCM sealer with keys from kms.
+// Sealed data has format: key id length (1 byte), key id, nonce, ciphertext.
+func NewSealer(kms KMS) Sealer {
+	return &aesSealer{kms: kms}
+}
+
+type aesSealer struct {
+	kms KMS
+}
+
+// Seal implements Sealer.
+func (a *aesSealer) Seal(ctx context.Context, scope string, data []byte) ([]byte, error) {
+	id, key, err := a.kms.CurrentKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("websocket: key id %q is too long", id)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, 1+len(id)+aead.NonceSize()+len(data)+aead.Overhead())
+	out = append(out, byte(len(id)))
+	out = append(out, id...)
+
+	nonce := out[len(out) : len(out)+aead.NonceSize()]
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = out[:len(out)+aead.NonceSize()]
+
+	return aead.Seal(out, nonce, data, []byte(scope)), nil
+}
+
+// Open implements Sealer.
+func (a *aesSealer) Open(ctx context.Context, scope string, data []byte) ([]byte, error) {
+	if len(data) == 0 || len(data) < 1+int(data[0]) {
+		return nil, ErrSealed
+	}
+	id, data := string(data[1:1+data[0]]), data[1+data[0]:]
+
+	key, err := a.kms.Key(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, ErrSealed
+	}
+
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(scope))
+	if err != nil {
+		return nil, ErrSealed
+	}
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type rotatingKMS struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (k *rotatingKMS) CurrentKey(context.Context) (string, []byte, error) {
+	return k.current, k.keys[k.current], nil
+}
+
+func (k *rotatingKMS) Key(_ context.Context, id string) ([]byte, error) {
+	return k.keys[id], nil
+}
+
+func TestSealer(t *testing.T) {
+	ctx := context.Background()
+	sealer := NewSealer(StaticKey(bytes.Repeat([]byte("k"), 32)))
+
+	data := []byte(`{"name":"secret","data":"hello"}`)
+	sealed, err := sealer.Seal(ctx, "room-1", data)
+	require.NoError(t, err)
+	require.NotContains(t, string(sealed), "hello", "data must not be stored in plaintext")
+
+	plain, err := sealer.Open(ctx, "room-1", sealed)
+	require.NoError(t, err)
+	require.Equal(t, data, plain)
+
+	_, err = sealer.Open(ctx, "room-2", sealed)
+	require.ErrorIs(t, err, ErrSealed, "data must be bound to scope")
+
+	sealed[len(sealed)-1] ^= 1
+	_, err = sealer.Open(ctx, "room-1", sealed)
+	require.ErrorIs(t, err, ErrSealed)
+
+	_, err = sealer.Open(ctx, "room-1", nil)
+	require.ErrorIs(t, err, ErrSealed)
+}
+
+func TestSealer_rotation(t *testing.T) {
+	ctx := context.Background()
+	kms := &rotatingKMS{
+		current: "v1",
+		keys: map[string][]byte{
+			"v1": bytes.Repeat([]byte("1"), 16),
+			"v2": bytes.Repeat([]byte("2"), 16),
+		},
+	}
+	sealer := NewSealer(kms)
+
+	old, err := sealer.Seal(ctx, "c", []byte("old"))
+	require.NoError(t, err)
+
+	kms.current = "v2"
+	fresh, err := sealer.Seal(ctx, "c", []byte("new"))
+	require.NoError(t, err)
+
+	plain, err := sealer.Open(ctx, "c", old)
+	require.NoError(t, err)
+	require.Equal(t, "old", string(plain), "data sealed with the old key must be opened after rotation")
+
+	plain, err = sealer.Open(ctx, "c", fresh)
+	require.NoError(t, err)
+	require.Equal(t, "new", string(plain))
+}
+
+func TestSealer_badKey(t *testing.T) {
+	_, err := NewSealer(StaticKey([]byte("short"))).Seal(context.Background(), "c", []byte("data"))
+	require.Error(t, err)
+}
+
+func TestWithStateEncryption(t *testing.T) {
+	sealer := NewSealer(StaticKey(bytes.Repeat([]byte("k"), 16)))
+	s := New(WithStateEncryption(sealer))
+	require.Equal(t, sealer, s.sealer)
+}
@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"encoding/json"
+)
+
+// OnBeforeSend function which will be called before every named message is sent to connection,
+// including broadcasts and channel messages. It receives data encoded as in the envelope of connection
+// (json, []byte is passed as is for binary envelope) and returns data which is sent instead,
+// e.g. with fields redacted by permissions of user. Returned data must be valid json for json envelope.
+// Non-nil error vetoes the message, it's returned by Emit. Messages written with Send and Write
+// and system events (see SystemPrefix) are not passed. It's called for every connection, so it should be fast.
+func (s *Server) OnBeforeSend(f func(c Connection, name string, data []byte) ([]byte, error)) {
+	if f == nil {
+		s.onBeforeSend.Store(nil)
+		return
+	}
+	hook := beforeSendFunc(f)
+	s.onBeforeSend.Store(&hook)
+}
+
+// OnAfterSend function which will be called after named message is written to connection (or queued
+// with flow control), e.g. for accounting. Size is the size of written envelope, err is the write error
+// or veto of OnBeforeSend.
+func (s *Server) OnAfterSend(f func(c Connection, name string, size int, err error)) {
+	if f == nil {
+		s.onAfterSend.Store(nil)
+		return
+	}
+	hook := afterSendFunc(f)
+	s.onAfterSend.Store(&hook)
+}
+
+// beforeSendFunc and afterSendFunc are send hooks, they are stored atomically as they are loaded for every message.
+type (
+	beforeSendFunc func(c Connection, name string, data []byte) ([]byte, error)
+	afterSendFunc  func(c Connection, name string, size int, err error)
+)
+
+// sendHooks return send hooks of server.
+func (c *Conn) sendHooks() (before beforeSendFunc, after afterSendFunc) {
+	if c.server == nil {
+		return nil, nil
+	}
+	if p := c.server.onBeforeSend.Load(); p != nil {
+		before = *p
+	}
+	if p := c.server.onAfterSend.Load(); p != nil {
+		after = *p
+	}
+	return before, after
+}
+
+// envelopeData return data of envelope encoded as in the envelope format of connection.
+func (c *Conn) envelopeData(env envelope) ([]byte, error) {
+	switch data := env.Data.(type) {
+	case json.RawMessage:
+		return data, nil
+	case []byte:
+		if c.envelope == BinaryEnvelope {
+			return data, nil
+		}
+		s, err := jsonData(data)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(s)
+	case BinaryPayload:
+		if c.envelope == BinaryEnvelope {
+			return data.BinaryPayload()
+		}
+	}
+	return json.Marshal(env.Data)
+}
+
+// beforeSend pass data of envelope to hook and replace it with result.
+func (c *Conn) beforeSend(env envelope, f beforeSendFunc) (envelope, error) {
+	b, err := c.envelopeData(env)
+	if err != nil {
+		return env, err
+	}
+
+	if b, err = f(c, env.Name, b); err != nil {
+		return env, err
+	}
+	if c.envelope == BinaryEnvelope {
+		env.Data = b
+	} else {
+		env.Data = json.RawMessage(b)
+	}
+	return env, nil
+}
@@ -0,0 +1,96 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServer_OnBeforeSend(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	errBlocked := errors.New("blocked")
+	wsServer.OnBeforeSend(func(c Connection, name string, data []byte) ([]byte, error) {
+		if name == "blocked" {
+			return nil, errBlocked
+		}
+		var v map[string]any
+		if json.Unmarshal(data, &v) != nil {
+			return data, nil
+		}
+		delete(v, "secret")
+		v["conn"] = c.ID()
+		return json.Marshal(v)
+	})
+
+	var (
+		mu    sync.Mutex
+		sizes = map[string]int{}
+		fails = map[string]error{}
+	)
+	wsServer.OnAfterSend(func(c Connection, name string, size int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		sizes[name] += size
+		if err != nil {
+			fails[name] = err
+		}
+	})
+
+	vetoed := make(chan error, 1)
+	wsServer.On("profile", func(c Connection, msg *Message) {
+		vetoed <- c.Emit("blocked", nil)
+		_ = c.Emit("profile", map[string]string{"name": "bob", "secret": "42"})
+		_ = c.Emit("raw", []byte("as is"))
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+	writeMessage(t, c, "profile", nil)
+
+	require.ErrorIs(t, <-vetoed, errBlocked)
+
+	name, data := readEnvelope(t, c)
+	require.Equal(t, "profile", name)
+	var profile map[string]string
+	require.NoError(t, json.Unmarshal(data, &profile))
+	require.Equal(t, "bob", profile["name"])
+	require.NotContains(t, profile, "secret")
+	require.NotEmpty(t, profile["conn"])
+
+	name, data = readEnvelope(t, c)
+	require.Equal(t, "raw", name)
+	require.Equal(t, `"as is"`, string(data))
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return sizes["raw"] > 0
+	}, time.Second, 5*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Greater(t, sizes["profile"], 0)
+	require.Equal(t, 0, sizes["blocked"])
+	require.ErrorIs(t, fails["blocked"], errBlocked)
+}
+
+func TestServer_OnBeforeSend_system(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	wsServer.OnBeforeSend(func(c Connection, name string, data []byte) ([]byte, error) {
+		return nil, errors.New("vetoed")
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+	writeMessage(t, c, EventHeartbeat, 1)
+
+	name, data := readEnvelope(t, c)
+	require.Equal(t, EventHeartbeat, name, "system events must not be passed to hook")
+	require.Equal(t, "1", string(data))
+}
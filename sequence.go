@@ -0,0 +1,169 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// EventResync is sent by client which detected a gap in channel sequence: {"channel": "book", "seq": 41}.
+// Server sends messages of history after seq and then _resync event with the last sequence number:
+// {"channel": "book", "seq": 57, "complete": true}. Complete is false when history doesn't cover the gap,
+// client must load the full state again in that case.
+const EventResync = SystemPrefix + "resync"
+
+// WithChannelSequence stamps every channel message with the channel id and incrementing sequence number:
+// {"name": "update", "data": ..., "channel": "book", "seq": 42}, so clients can detect gaps and repair them
+// with _resync event. Messages for resync are taken from channel history (see Channel.SetHistory).
+// Messages of channel are sent one by one to keep the order.
+func WithChannelSequence() Option {
+	return func(s *Server) {
+		s.sequence = true
+		s.handleSystem(EventResync, resync)
+	}
+}
+
+// Seq return sequence number of the last message emitted to channel.
+func (c *Channel) Seq() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.seq
+}
+
+// sequenced reports whether channel messages are stamped with sequence number.
+func (c *Channel) sequenced() bool {
+	return c.server != nil && c.server.sequence
+}
+
+// emitSequenced emit message with the next sequence number.
+func (c *Channel) emitSequenced(name string, data any) BroadcastResult {
+	c.emitMu.Lock()
+	defer c.emitMu.Unlock()
+
+	c.mu.Lock()
+	c.seq++
+	seq := c.seq
+	c.mu.Unlock()
+
+	c.persist(name, data, seq)
+
+	var res BroadcastResult
+	for _, con := range c.snapshot() {
+		var err error
+		if k, ok := con.(*Conn); ok {
+			err = k.send(envelope{
+				Name:    name,
+				Data:    data,
+				Channel: k.localChannelID(c.id),
+				Seq:     seq,
+			})
+		} else {
+			err = con.Emit(name, data)
+		}
+		res.add(con, err)
+		if err != nil {
+			_ = con.Close()
+			c.Remove(con)
+		}
+	}
+	return res
+}
+
+// after return history entries with sequence number bigger than seq
+// and whether they fill the gap up to the last message.
+func (c *Channel) after(ctx context.Context, seq uint64) ([]LogEntry, bool, error) {
+	c.mu.Lock()
+	h, last := c.history, c.seq
+	c.mu.Unlock()
+
+	if seq > last {
+		return nil, false, nil
+	}
+	if h == nil {
+		return nil, seq == last, nil
+	}
+
+	entries, err := h.Since(ctx, c.id, time.Time{})
+	if err != nil {
+		return nil, false, err
+	}
+
+	var list []LogEntry
+	for _, e := range entries {
+		if e.Seq > seq {
+			list = append(list, e)
+		}
+	}
+	complete := seq == last || (len(list) != 0 && list[0].Seq == seq+1)
+	return list, complete, nil
+}
+
+// localChannelID return channel id as it's seen by connection, without namespace.
+func (c *Conn) localChannelID(id string) string {
+	if c.namespace == nil {
+		return id
+	}
+	return strings.TrimPrefix(id, c.namespace.channelID(""))
+}
+
+// resyncRequest is the data of _resync event.
+type resyncRequest struct {
+	Channel string `json:"channel"`
+	Seq     uint64 `json:"seq"`
+}
+
+// resyncResult is the data of _resync event sent after messages.
+type resyncResult struct {
+	Channel  string `json:"channel"`
+	Seq      uint64 `json:"seq"`
+	Complete bool   `json:"complete"`
+}
+
+// resync handle _resync event from client.
+func resync(c *Conn, msg *Message) {
+	var req resyncRequest
+	err := json.Unmarshal(msg.Data, &req)
+	if err == nil {
+		err = c.checkChannel(req.Channel)
+	}
+
+	var ch *Channel
+	if err == nil {
+		ch = c.server.Channel(c.channelID(req.Channel))
+		if ch == nil || !ch.Has(c) {
+			err = ErrNotMember
+		}
+	}
+
+	var (
+		list     []LogEntry
+		complete bool
+	)
+	if err == nil {
+		list, complete, err = ch.after(c.Context(), req.Seq)
+	}
+	if err != nil {
+		replyError(c, EventResync, req.Channel, err)
+		return
+	}
+
+	res := resyncResult{Channel: req.Channel, Seq: req.Seq, Complete: complete}
+	for _, e := range list {
+		data, err := ch.open(c.Context(), e.Data)
+		if err != nil {
+			replyError(c, EventResync, req.Channel, err)
+			return
+		}
+		err = c.send(envelope{Name: e.Name, Data: json.RawMessage(data), Channel: req.Channel, Seq: e.Seq})
+		if err != nil {
+			return
+		}
+		res.Seq = e.Seq
+	}
+	if complete {
+		res.Seq = max(res.Seq, ch.Seq())
+	}
+	_ = c.Emit(EventResync, res)
+}
@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"encoding/json"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"net"
+	"strconv"
+	"testing"
+)
+
+type sequenced struct {
+	Name    string          `json:"name"`
+	Data    json.RawMessage `json:"data"`
+	Channel string          `json:"channel"`
+	Seq     uint64          `json:"seq"`
+}
+
+func readSequenced(t *testing.T, c net.Conn) sequenced {
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+
+	var msg sequenced
+	require.NoError(t, json.Unmarshal(b, &msg))
+	return msg
+}
+
+func TestChannel_Emit_sequence(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithChannelSequence())
+	defer shutdown()
+
+	ch := wsServer.NewChannel("book")
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	joinChannel(t, c, "book")
+
+	ch.Emit("update", 1)
+	ch.Emit("update", 2)
+
+	for i := 1; i <= 2; i++ {
+		msg := readSequenced(t, c)
+		require.Equal(t, "update", msg.Name)
+		require.Equal(t, "book", msg.Channel)
+		require.Equal(t, uint64(i), msg.Seq)
+	}
+	require.Equal(t, uint64(2), ch.Seq())
+}
+
+func TestChannel_resync(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithChannelSequence())
+	defer shutdown()
+
+	ch := wsServer.NewChannel("book")
+	ch.SetHistory(NewMemoryHistory(2, 0))
+	for i := 1; i <= 4; i++ {
+		ch.Emit("update", i)
+	}
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	joinChannel(t, c, "book")
+
+	writeMessage(t, c, EventResync, map[string]any{"channel": "book", "seq": 2})
+	for i := 3; i <= 4; i++ {
+		msg := readSequenced(t, c)
+		require.Equal(t, uint64(i), msg.Seq)
+		require.JSONEq(t, strconv.Itoa(i), string(msg.Data))
+	}
+	name, data := readEnvelope(t, c)
+	require.Equal(t, EventResync, name)
+	require.JSONEq(t, `{"channel":"book","seq":4,"complete":true}`, string(data))
+
+	writeMessage(t, c, EventResync, map[string]any{"channel": "book", "seq": 1})
+	readSequenced(t, c)
+	readSequenced(t, c)
+	name, data = readEnvelope(t, c)
+	require.Equal(t, EventResync, name)
+	require.JSONEq(t, `{"channel":"book","seq":4,"complete":false}`, string(data), "history doesn't cover the gap")
+
+	writeMessage(t, c, EventResync, map[string]any{"channel": "book", "seq": 4})
+	name, data = readEnvelope(t, c)
+	require.Equal(t, EventResync, name)
+	require.JSONEq(t, `{"channel":"book","seq":4,"complete":true}`, string(data))
+}
+
+func TestChannel_resync_notMember(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithChannelSequence())
+	defer shutdown()
+
+	wsServer.NewChannel("book")
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, EventResync, map[string]any{"channel": "book", "seq": 0})
+	name, data := readEnvelope(t, c)
+	require.Equal(t, EventError, name)
+	require.JSONEq(t, `{"event":"_resync","code":403,"message":"websocket: connection is not in channel","channel":"book"}`, string(data))
+}
+
+func TestChannel_Emit_sequenceNamespace(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithChannelSequence())
+	defer shutdown()
+
+	ch := wsServer.Of("/chat").NewChannel("room")
+	c := dialNamespace(t, ts, "/chat")
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	joinChannel(t, c, "room")
+
+	ch.Emit("msg", "hi")
+	msg := readSequenced(t, c)
+	require.Equal(t, "room", msg.Channel, "namespace must not be visible to client")
+	require.Equal(t, uint64(1), msg.Seq)
+}
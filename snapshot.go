@@ -0,0 +1,165 @@
+package websocket
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// Snapshot is the state of the server at some moment.
+// Messages are counted since the previous snapshot, rates are per second.
+type Snapshot struct {
+	Time        time.Time      `json:"time"`
+	Connections int            `json:"connections"`
+	Channels    map[string]int `json:"channels"`
+	MessagesIn  int64          `json:"messages_in"`
+	MessagesOut int64          `json:"messages_out"`
+	InRate      float64        `json:"in_rate"`
+	OutRate     float64        `json:"out_rate"`
+}
+
+// Sink receives periodic snapshots of the server.
+type Sink interface {
+	Send(s Snapshot) error
+}
+
+// SinkFunc is an adapter to use ordinary function as Sink.
+type SinkFunc func(s Snapshot) error
+
+// Send call f(s).
+func (f SinkFunc) Send(s Snapshot) error {
+	return f(s)
+}
+
+// WithSnapshots sends the snapshot of the server to the sink every interval while server is running.
+func WithSnapshots(interval time.Duration, sink Sink) Option {
+	return func(s *Server) {
+		s.snapshotInterval = interval
+		s.snapshotSink = sink
+	}
+}
+
+// Snapshot return the current state of the server.
+// Message counters are totals since the server start.
+func (s *Server) Snapshot() Snapshot {
+	snap := Snapshot{
+		Time:        time.Now(),
+		Connections: s.Count(),
+		Channels:    make(map[string]int),
+		MessagesIn:  s.stats.messagesIn.Load(),
+		MessagesOut: s.stats.messagesOut.Load(),
+	}
+
+	s.mu.RLock()
+	for id, ch := range s.channels {
+		snap.Channels[id] = ch.Count()
+	}
+	s.mu.RUnlock()
+
+	return snap
+}
+
+// runSnapshots sends snapshots with counters relative to the previous one until server stops.
+func (s *Server) runSnapshots() {
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+
+	prev := s.Snapshot()
+	for {
+		select {
+		case <-ticker.C:
+			cur := s.Snapshot()
+			snap := cur
+			snap.MessagesIn -= prev.MessagesIn
+			snap.MessagesOut -= prev.MessagesOut
+			if d := cur.Time.Sub(prev.Time).Seconds(); d > 0 {
+				snap.InRate = float64(snap.MessagesIn) / d
+				snap.OutRate = float64(snap.MessagesOut) / d
+			}
+			prev = cur
+
+			if err := s.snapshotSink.Send(snap); err != nil {
+				log.Printf("websocket: snapshot error %v", err)
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// writerSink writes metrics line by line in the format defined by line function.
+type writerSink struct {
+	dial   func() (net.Conn, error)
+	prefix string
+	line   func(name string, value float64, t time.Time) string
+}
+
+// NewStatsDSink creates Sink which sends snapshot as StatsD gauges over UDP.
+// Metrics are named prefix.connections, prefix.channels.<id>, prefix.messages_in and so on.
+func NewStatsDSink(addr, prefix string) Sink {
+	return &writerSink{
+		dial: func() (net.Conn, error) {
+			return net.Dial("udp", addr)
+		},
+		prefix: prefix,
+		line: func(name string, value float64, _ time.Time) string {
+			return fmt.Sprintf("%s:%g|g\n", name, value)
+		},
+	}
+}
+
+// NewGraphiteSink creates Sink which sends snapshot to Graphite using plaintext protocol over TCP.
+func NewGraphiteSink(addr, prefix string) Sink {
+	return &writerSink{
+		dial: func() (net.Conn, error) {
+			return net.DialTimeout("tcp", addr, 5*time.Second)
+		},
+		prefix: prefix,
+		line: func(name string, value float64, t time.Time) string {
+			return fmt.Sprintf("%s %g %d\n", name, value, t.Unix())
+		},
+	}
+}
+
+// Send dial the sink address and write all metrics of snapshot.
+func (w *writerSink) Send(s Snapshot) error {
+	conn, err := w.dial()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	return w.write(conn, s)
+}
+
+func (w *writerSink) write(wr io.Writer, s Snapshot) error {
+	var sb strings.Builder
+	add := func(name string, value float64) {
+		if w.prefix != "" {
+			name = w.prefix + "." + name
+		}
+		sb.WriteString(w.line(name, value, s.Time))
+	}
+
+	add("connections", float64(s.Connections))
+	add("messages_in", float64(s.MessagesIn))
+	add("messages_out", float64(s.MessagesOut))
+	add("in_rate", s.InRate)
+	add("out_rate", s.OutRate)
+	for id, count := range s.Channels {
+		add("channels."+metricName(id), float64(count))
+	}
+
+	_, err := io.WriteString(wr, sb.String())
+	return err
+}
+
+// metricName replace characters which have special meaning in metric names.
+func metricName(s string) string {
+	return strings.NewReplacer(".", "_", ":", "_", "|", "_", " ", "_", "/", "_").Replace(s)
+}
@@ -0,0 +1,103 @@
+package websocket
+
+import (
+	"bufio"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_Snapshots(t *testing.T) {
+	snapshots := make(chan Snapshot, 10)
+	sink := SinkFunc(func(s Snapshot) error {
+		snapshots <- s
+		return nil
+	})
+
+	ts, wsServer, shutdown := server(t, WithSnapshots(50*time.Millisecond, sink))
+	defer shutdown()
+
+	ch := wsServer.NewChannel("room")
+	wsServer.OnConnect(func(c Connection) {
+		ch.Add(c)
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, "echo", "test")
+	_, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+
+	var total, out int64
+	deadline := time.After(time.Second)
+	for total == 0 || out == 0 {
+		select {
+		case s := <-snapshots:
+			require.Equal(t, 1, s.Connections)
+			require.Equal(t, map[string]int{"room": 1}, s.Channels)
+			total += s.MessagesIn
+			out += s.MessagesOut
+		case <-deadline:
+			t.Fatal("snapshot must count messages")
+		}
+	}
+	require.Equal(t, int64(1), total)
+	require.Equal(t, int64(1), out)
+}
+
+func TestStatsDSink(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = l.Close()
+	}()
+
+	sink := NewStatsDSink(l.LocalAddr().String(), "ws")
+	require.NoError(t, sink.Send(Snapshot{
+		Connections: 3,
+		Channels:    map[string]int{"org.team": 2},
+		MessagesIn:  10,
+	}))
+
+	buf := make([]byte, 1024)
+	require.NoError(t, l.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := l.ReadFrom(buf)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(buf[:n])), "\n")
+	require.Contains(t, lines, "ws.connections:3|g")
+	require.Contains(t, lines, "ws.messages_in:10|g")
+	require.Contains(t, lines, "ws.channels.org_team:2|g")
+}
+
+func TestGraphiteSink(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = l.Close()
+	}()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		s := bufio.NewScanner(conn)
+		for s.Scan() {
+			lines <- s.Text()
+		}
+		close(lines)
+	}()
+
+	sink := NewGraphiteSink(l.Addr().String(), "")
+	require.NoError(t, sink.Send(Snapshot{Time: time.Unix(100, 0), Connections: 5}))
+
+	require.Equal(t, "connections 5 100", <-lines)
+}
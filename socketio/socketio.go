@@ -0,0 +1,447 @@
+// Package socketio makes websocket.Server compatible with socket.io clients (Engine.IO v4, Socket.IO v5 protocols).
+//
+// Only websocket transport and the main namespace "/" are supported, so clients must connect with
+// transports: ["websocket"]. Binary events are not supported.
+//
+//	srv := websocket.New()
+//	sio := socketio.New(srv)
+//	sio.On("chat message", func(so *socketio.Socket, args []json.RawMessage, ack socketio.AckFunc) {
+//		_ = so.Emit("chat message", args[0])
+//		_ = ack("ok")
+//	})
+//	srv.Run(ctx)
+//	http.HandleFunc("/socket.io/", srv.Handler)
+//
+// Server must be used for socket.io only, New replaces OnConnect and OnMessage of the server.
+package socketio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gobwas/ws"
+	"github.com/pkgz/websocket"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults of Engine.IO heartbeat.
+const (
+	DefaultPingInterval = 25 * time.Second
+	DefaultPingTimeout  = 20 * time.Second
+	// DefaultMaxPayload is sent to client when server has no WithMaxMessageSize limit.
+	DefaultMaxPayload = 1000000
+)
+
+// Engine.IO packet types.
+const (
+	engineOpen    = '0'
+	engineClose   = '1'
+	enginePing    = '2'
+	enginePong    = '3'
+	engineMessage = '4'
+)
+
+// Socket.IO packet types.
+const (
+	packetConnect      = '0'
+	packetDisconnect   = '1'
+	packetEvent        = '2'
+	packetAck          = '3'
+	packetConnectError = '4'
+)
+
+// ErrNotConnected is returned when emitting to socket which is not connected to namespace.
+var ErrNotConnected = errors.New("socketio: socket is not connected")
+
+// HandlerFunc handle the event with its arguments. Ack sends arguments back to client,
+// it does nothing when client didn't request the acknowledgement.
+type HandlerFunc func(so *Socket, args []json.RawMessage, ack AckFunc)
+
+// AckFunc sends acknowledgement to client.
+type AckFunc func(args ...any) error
+
+// Option configures the Server.
+type Option func(s *Server)
+
+// WithPingInterval sets the interval of Engine.IO pings, default is DefaultPingInterval.
+func WithPingInterval(d time.Duration) Option {
+	return func(s *Server) {
+		s.pingInterval = d
+	}
+}
+
+// WithPingTimeout sets the time for client to answer ping, default is DefaultPingTimeout.
+func WithPingTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.pingTimeout = d
+	}
+}
+
+// Server serves socket.io clients of websocket server.
+type Server struct {
+	pingInterval time.Duration
+	pingTimeout  time.Duration
+	maxPayload   int64
+
+	handlers     map[string]HandlerFunc
+	onConnect    func(so *Socket, auth json.RawMessage) error
+	onDisconnect func(so *Socket)
+
+	sockets map[websocket.Connection]*Socket
+	mu      sync.RWMutex
+}
+
+// Socket is the socket.io connection.
+type Socket struct {
+	conn      websocket.Connection
+	connected bool
+	acks      map[int]func(args []json.RawMessage)
+	nextAck   int
+	pong      chan struct{}
+	mu        sync.Mutex
+}
+
+// New serve socket.io protocol on the server.
+func New(srv *websocket.Server, opts ...Option) *Server {
+	s := &Server{
+		pingInterval: DefaultPingInterval,
+		pingTimeout:  DefaultPingTimeout,
+		maxPayload:   srv.MaxMessageSize(),
+		handlers:     make(map[string]HandlerFunc),
+		sockets:      make(map[websocket.Connection]*Socket),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.maxPayload <= 0 {
+		s.maxPayload = DefaultMaxPayload
+	}
+
+	srv.OnConnect(s.open)
+	srv.OnMessage(func(c websocket.Connection, _ ws.Header, b []byte) {
+		s.packet(c, b)
+	})
+	return s
+}
+
+// On register handler for event.
+func (s *Server) On(event string, f HandlerFunc) {
+	s.mu.Lock()
+	s.handlers[event] = f
+	s.mu.Unlock()
+}
+
+// OnConnect function which will be called when client connects to namespace, auth is the payload of connect packet.
+// Returned error is sent to client as connect error.
+func (s *Server) OnConnect(f func(so *Socket, auth json.RawMessage) error) {
+	s.mu.Lock()
+	s.onConnect = f
+	s.mu.Unlock()
+}
+
+// OnDisconnect function which will be called when connected socket disconnects.
+func (s *Server) OnDisconnect(f func(so *Socket)) {
+	s.mu.Lock()
+	s.onDisconnect = f
+	s.mu.Unlock()
+}
+
+// ID return socket id, it's the id of websocket connection.
+func (so *Socket) ID() string {
+	return so.conn.ID()
+}
+
+// Conn return websocket connection of socket.
+func (so *Socket) Conn() websocket.Connection {
+	return so.conn
+}
+
+// Emit event with arguments to client.
+func (so *Socket) Emit(event string, args ...any) error {
+	return so.emit(-1, event, args)
+}
+
+// EmitWithAck emit event to client and call ack with arguments of client acknowledgement.
+func (so *Socket) EmitWithAck(event string, ack func(args []json.RawMessage), args ...any) error {
+	so.mu.Lock()
+	id := so.nextAck
+	so.nextAck++
+	so.acks[id] = ack
+	so.mu.Unlock()
+
+	return so.emit(id, event, args)
+}
+
+func (so *Socket) emit(id int, event string, args []any) error {
+	so.mu.Lock()
+	connected := so.connected
+	so.mu.Unlock()
+	if !connected {
+		return ErrNotConnected
+	}
+
+	payload, err := json.Marshal(append([]any{event}, args...))
+	if err != nil {
+		return err
+	}
+	return so.send(packetEvent, id, payload)
+}
+
+// send Socket.IO packet of the main namespace, id is skipped when negative.
+func (so *Socket) send(typ byte, id int, payload []byte) error {
+	b := []byte{engineMessage, typ}
+	if id >= 0 {
+		b = strconv.AppendInt(b, int64(id), 10)
+	}
+	return write(so.conn, append(b, payload...))
+}
+
+// socket return the socket of connection, it's created on the first call.
+// Packets could come before OnConnect is called, so both of them create it.
+func (s *Server) socket(c websocket.Connection) *Socket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.Context().Err() != nil {
+		return nil
+	}
+	so, ok := s.sockets[c]
+	if !ok {
+		so = &Socket{
+			conn: c,
+			acks: make(map[int]func(args []json.RawMessage)),
+			pong: make(chan struct{}, 1),
+		}
+		s.sockets[c] = so
+	}
+	return so
+}
+
+// open send Engine.IO handshake and start pinging client, it doesn't block,
+// so with WithSyncConnect reading of connection starts right after it.
+func (s *Server) open(c websocket.Connection) {
+	so := s.socket(c)
+	if so == nil {
+		return
+	}
+
+	handshake, _ := json.Marshal(map[string]any{
+		"sid":          c.ID(),
+		"upgrades":     []string{},
+		"pingInterval": s.pingInterval.Milliseconds(),
+		"pingTimeout":  s.pingTimeout.Milliseconds(),
+		"maxPayload":   s.maxPayload,
+	})
+	if err := write(c, append([]byte{engineOpen}, handshake...)); err != nil {
+		return
+	}
+
+	go s.run(so)
+}
+
+// run ping client until connection is closed and then remove the socket.
+func (s *Server) run(so *Socket) {
+	c := so.conn
+	s.heartbeat(so)
+
+	s.mu.Lock()
+	delete(s.sockets, c)
+	onDisconnect := s.onDisconnect
+	s.mu.Unlock()
+
+	so.mu.Lock()
+	connected := so.connected
+	so.connected = false
+	so.mu.Unlock()
+	if connected && onDisconnect != nil {
+		onDisconnect(so)
+	}
+}
+
+// heartbeat ping client every interval, connection which doesn't answer in time is closed.
+func (s *Server) heartbeat(so *Socket) {
+	ctx := so.conn.Context()
+	ticker := time.NewTicker(s.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := write(so.conn, []byte{enginePing}); err != nil {
+				return
+			}
+			timeout := time.NewTimer(s.pingTimeout)
+			select {
+			case <-so.pong:
+				timeout.Stop()
+			case <-timeout.C:
+				_ = so.conn.Close()
+				return
+			case <-ctx.Done():
+				timeout.Stop()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// packet handle Engine.IO packet from client.
+func (s *Server) packet(c websocket.Connection, b []byte) {
+	so := s.socket(c)
+	if so == nil || len(b) == 0 {
+		return
+	}
+
+	switch b[0] {
+	case enginePong:
+		select {
+		case so.pong <- struct{}{}:
+		default:
+		}
+	case enginePing:
+		_ = write(c, append([]byte{enginePong}, b[1:]...))
+	case engineClose:
+		_ = c.Close()
+	case engineMessage:
+		if len(b) > 1 {
+			s.message(so, b[1:])
+		}
+	}
+}
+
+// message handle Socket.IO packet.
+func (s *Server) message(so *Socket, b []byte) {
+	typ := b[0]
+	b = b[1:]
+
+	namespace := "/"
+	if len(b) != 0 && b[0] == '/' {
+		end := bytes.IndexByte(b, ',')
+		if end < 0 {
+			end = len(b)
+		}
+		namespace = string(b[:end])
+		b = b[min(end+1, len(b)):]
+	}
+
+	id := -1
+	n := 0
+	for n < len(b) && b[n] >= '0' && b[n] <= '9' {
+		n++
+	}
+	if n != 0 {
+		id, _ = strconv.Atoi(string(b[:n]))
+		b = b[n:]
+	}
+
+	if namespace != "/" {
+		if typ == packetConnect {
+			reply := []byte{engineMessage, packetConnectError}
+			reply = append(reply, namespace+","...)
+			reply = append(reply, `{"message":"Invalid namespace"}`...)
+			_ = write(so.conn, reply)
+		}
+		return
+	}
+
+	switch typ {
+	case packetConnect:
+		s.connect(so, b)
+	case packetDisconnect:
+		so.mu.Lock()
+		connected := so.connected
+		so.connected = false
+		so.mu.Unlock()
+
+		s.mu.RLock()
+		onDisconnect := s.onDisconnect
+		s.mu.RUnlock()
+		if connected && onDisconnect != nil {
+			onDisconnect(so)
+		}
+	case packetEvent:
+		s.event(so, id, b)
+	case packetAck:
+		so.mu.Lock()
+		f := so.acks[id]
+		delete(so.acks, id)
+		so.mu.Unlock()
+
+		var args []json.RawMessage
+		if f != nil && json.Unmarshal(b, &args) == nil {
+			f(args)
+		}
+	default:
+		log.Printf("socketio: unsupported packet type %q from %s", typ, so.ID())
+	}
+}
+
+func (s *Server) connect(so *Socket, auth []byte) {
+	s.mu.RLock()
+	onConnect := s.onConnect
+	s.mu.RUnlock()
+
+	if onConnect != nil {
+		if err := onConnect(so, auth); err != nil {
+			payload, _ := json.Marshal(map[string]string{"message": err.Error()})
+			_ = so.send(packetConnectError, -1, payload)
+			return
+		}
+	}
+
+	so.mu.Lock()
+	so.connected = true
+	so.mu.Unlock()
+
+	payload, _ := json.Marshal(map[string]string{"sid": so.ID()})
+	_ = so.send(packetConnect, -1, payload)
+}
+
+func (s *Server) event(so *Socket, id int, b []byte) {
+	so.mu.Lock()
+	connected := so.connected
+	so.mu.Unlock()
+	if !connected {
+		return
+	}
+
+	var args []json.RawMessage
+	var event string
+	if err := json.Unmarshal(b, &args); err != nil || len(args) == 0 || json.Unmarshal(args[0], &event) != nil {
+		log.Printf("socketio: invalid event from %s: %s", so.ID(), b)
+		return
+	}
+
+	s.mu.RLock()
+	f := s.handlers[event]
+	s.mu.RUnlock()
+	if f == nil {
+		return
+	}
+
+	ack := func(...any) error { return nil }
+	if id >= 0 {
+		ack = func(args ...any) error {
+			if args == nil {
+				args = []any{}
+			}
+			payload, err := json.Marshal(args)
+			if err != nil {
+				return fmt.Errorf("socketio: encode ack: %w", err)
+			}
+			return so.send(packetAck, id, payload)
+		}
+	}
+	f(so, args[1:], ack)
+}
+
+// write the packet in text frame.
+func write(c websocket.Connection, b []byte) error {
+	return c.Write(ws.Header{Fin: true, OpCode: ws.OpText, Length: int64(len(b))}, b)
+}
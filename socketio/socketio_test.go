@@ -0,0 +1,189 @@
+package socketio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/pkgz/websocket"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client reads frames buffered during handshake first.
+type client struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c client) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func start(t *testing.T, opts ...Option) (*Server, client) {
+	return startServer(t, websocket.New(), opts...)
+}
+
+func startServer(t *testing.T, srv *websocket.Server, opts ...Option) (*Server, client) {
+	sio := New(srv, opts...)
+	srv.Run(context.Background())
+
+	ts := httptest.NewServer(http.HandlerFunc(srv.Handler))
+	t.Cleanup(func() {
+		ts.Close()
+		_ = srv.Shutdown()
+	})
+
+	conn, br, _, err := ws.Dial(context.Background(), "ws://"+strings.TrimPrefix(ts.URL, "http://")+"/socket.io/?EIO=4&transport=websocket")
+	require.NoError(t, err)
+	require.NoError(t, conn.SetDeadline(time.Now().Add(3*time.Second)))
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	c := client{Conn: conn, r: conn}
+	if br != nil {
+		c.r = io.MultiReader(br, conn)
+	}
+	return sio, c
+}
+
+func send(t *testing.T, c client, packet string) {
+	require.NoError(t, wsutil.WriteClientText(c, []byte(packet)))
+}
+
+func read(t *testing.T, c client) string {
+	b, err := wsutil.ReadServerText(c)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func connect(t *testing.T, c client) {
+	open := read(t, c)
+	require.Equal(t, "0", open[:1])
+	var handshake struct {
+		SID          string `json:"sid"`
+		PingInterval int    `json:"pingInterval"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(open[1:]), &handshake))
+	require.NotEmpty(t, handshake.SID)
+
+	send(t, c, "40")
+	require.Equal(t, `40{"sid":"`+handshake.SID+`"}`, read(t, c))
+}
+
+func TestServer_event(t *testing.T) {
+	sio, c := start(t)
+	sio.On("echo", func(so *Socket, args []json.RawMessage, ack AckFunc) {
+		require.NoError(t, so.Emit("echo", args[0], 1))
+		require.NoError(t, ack("ok"))
+	})
+
+	connect(t, c)
+
+	send(t, c, `42["echo","hello"]`)
+	require.Equal(t, `42["echo","hello",1]`, read(t, c))
+
+	send(t, c, `4213["echo",{"a":1}]`)
+	require.Equal(t, `42["echo",{"a":1},1]`, read(t, c))
+	require.Equal(t, `4313["ok"]`, read(t, c))
+}
+
+func TestSocket_EmitWithAck(t *testing.T) {
+	sio, c := start(t)
+	acked := make(chan []json.RawMessage, 1)
+	sio.OnConnect(func(so *Socket, auth json.RawMessage) error {
+		go func() {
+			require.NoError(t, so.EmitWithAck("question", func(args []json.RawMessage) {
+				acked <- args
+			}, "ready?"))
+		}()
+		return nil
+	})
+
+	connect(t, c)
+	require.Equal(t, `420["question","ready?"]`, read(t, c))
+	send(t, c, `430["yes"]`)
+
+	args := <-acked
+	require.Len(t, args, 1)
+	require.Equal(t, `"yes"`, string(args[0]))
+}
+
+func TestServer_OnConnect_error(t *testing.T) {
+	sio, c := start(t)
+	sio.OnConnect(func(so *Socket, auth json.RawMessage) error {
+		if string(auth) != `{"token":"secret"}` {
+			return errors.New("unauthorized")
+		}
+		return nil
+	})
+
+	read(t, c)
+	send(t, c, "40")
+	require.Equal(t, `44{"message":"unauthorized"}`, read(t, c))
+
+	send(t, c, `42["echo"]`)
+	send(t, c, "40/admin,")
+	require.Equal(t, `44/admin,{"message":"Invalid namespace"}`, read(t, c), "events must be ignored before connect")
+
+	send(t, c, `40{"token":"secret"}`)
+	require.Contains(t, read(t, c), `40{"sid":`)
+}
+
+func TestServer_heartbeat(t *testing.T) {
+	sio, c := start(t, WithPingInterval(20*time.Millisecond), WithPingTimeout(50*time.Millisecond))
+	disconnected := make(chan struct{})
+	sio.OnDisconnect(func(*Socket) {
+		close(disconnected)
+	})
+
+	connect(t, c)
+	require.Equal(t, "2", read(t, c))
+	send(t, c, "3")
+	require.Equal(t, "2", read(t, c))
+
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("socket which doesn't answer ping must be disconnected")
+	}
+}
+
+func TestServer_syncConnect(t *testing.T) {
+	sio, c := startServer(t, websocket.New(websocket.WithSyncConnect(), websocket.WithMaxMessageSize(4096)))
+	sio.On("echo", func(so *Socket, args []json.RawMessage, ack AckFunc) {
+		require.NoError(t, so.Emit("echo", args[0]))
+	})
+
+	open := read(t, c)
+	var handshake struct {
+		MaxPayload int64 `json:"maxPayload"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(open[1:]), &handshake))
+	require.Equal(t, int64(4096), handshake.MaxPayload, "maxPayload must be the limit of server")
+
+	send(t, c, "40")
+	require.Contains(t, read(t, c), `40{"sid":`, "connection must be read while heartbeat runs")
+	send(t, c, `42["echo","hello"]`)
+	require.Equal(t, `42["echo","hello"]`, read(t, c))
+}
+
+func TestServer_disconnect(t *testing.T) {
+	sio, c := start(t)
+	disconnected := make(chan string, 1)
+	sio.OnDisconnect(func(so *Socket) {
+		disconnected <- so.ID()
+	})
+
+	connect(t, c)
+	send(t, c, "41")
+	require.NotEmpty(t, <-disconnected)
+}
@@ -0,0 +1,267 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"github.com/gobwas/ws"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EventOpen is the first event of Server-Sent Events stream, it has id and session token of the connection:
+// {"id": "...", "token": "..."}. Client sends messages with POST request to SSEHandler with this id in query
+// and the token in SSETokenHeader: /sse?id=...
+const EventOpen = SystemPrefix + "open"
+
+// SSETokenHeader is the header of POST request to SSEHandler with session token from EventOpen.
+// Connection id is not a secret (e.g. it's in presence), so the token proves that POST comes from the client of stream.
+const SSETokenHeader = "X-SSE-Token"
+
+// ErrUnknownConnection is returned for POST to SSEHandler with id of unknown connection or wrong token.
+var ErrUnknownConnection = errors.New("websocket: unknown connection")
+
+// SSEHandler serves clients which can't use websocket (e.g. proxy blocks upgrade) with Server-Sent Events.
+// GET request opens text/event-stream, every message to the connection is sent as data event with the same
+// envelope as for websocket: data: {"name": "event", "data": ...}. Pings are sent as comments.
+// POST request with ?id= of the connection and SSETokenHeader passes the body to handlers like websocket message.
+// POST requests of the connection are handled one by one, so messages keep the order like in websocket.
+// Connections of both handlers share callbacks, channels and OnConnect/OnDisconnect.
+func (s *Server) SSEHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.serveSSE(w, r)
+	case http.MethodPost:
+		s.receiveSSE(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// serveSSE stream messages of connection until client disconnects.
+func (s *Server) serveSSE(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		s.rejectDraining(w)
+		return
+	}
+
+	ns, err := s.namespace(r.URL.Query().Get(NamespaceParam))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	ip := s.clientIP(r.RemoteAddr, r.Header)
+	if code, err := s.reserve(ip); err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	stream, err := newSSEStream(w, r)
+	if err != nil {
+		s.release(ip)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	connection := &Conn{
+		params: r.URL.Query(),
+		conn:   stream,
+		sse:    stream,
+		done:   make(chan bool, 1),
+		server: s,
+
+		namespace: ns,
+		ip:        ip,
+		pingReset: make(chan struct{}, 1),
+
+		created: time.Now(),
+	}
+	connection.ctx, connection.cancel = context.WithCancel(context.Background())
+	connection.SetDeadlines(0, s.writeTimeout)
+	if s.ackTimeout > 0 {
+		connection.outbox = newOutbox()
+	}
+	if err := s.register(connection); err != nil {
+		s.release(ip)
+		replyError(connection, EventOpen, "", err)
+		return
+	}
+
+	_ = connection.Emit(EventOpen, map[string]string{"id": connection.id, "token": stream.token})
+
+	s.sseMu.Lock()
+	s.sseConns[connection.id] = connection
+	s.sseMu.Unlock()
+	s.addConn(connection)
+	connection.startPing()
+
+	select {
+	case <-stream.closed:
+	case <-r.Context().Done():
+	}
+
+	s.sseMu.Lock()
+	delete(s.sseConns, connection.id)
+	s.sseMu.Unlock()
+	s.dropConn(connection)
+	// Close waits for the running write, so response writer is not used after return
+	_ = connection.Close()
+}
+
+// receiveSSE process message from client of SSE connection.
+func (s *Server) receiveSSE(w http.ResponseWriter, r *http.Request) {
+	s.sseMu.Lock()
+	c := s.sseConns[r.URL.Query().Get("id")]
+	s.sseMu.Unlock()
+	token := r.Header.Get(SSETokenHeader)
+	if c == nil || subtle.ConstantTimeCompare([]byte(token), []byte(c.sse.token)) != 1 {
+		http.Error(w, ErrUnknownConnection.Error(), http.StatusNotFound)
+		return
+	}
+
+	body := r.Body
+	if s.maxMessageSize > 0 {
+		body = http.MaxBytesReader(w, r.Body, s.maxMessageSize)
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		code := http.StatusBadRequest
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	c.sse.posts.Lock()
+	defer c.sse.posts.Unlock()
+
+	received := time.Now()
+	c.stats.lastReceived.Store(received.UnixNano())
+	c.stats.lastData.Store(received.UnixNano())
+	c.observeBytes(int64(len(b)), 0)
+	c.observeIn()
+	h := ws.Header{Fin: true, OpCode: ws.OpText, Length: int64(len(b))}
+	err = s.safe(c, func() {
+		if err := s.processMessage(c, h, b, received); err != nil {
+			s.reportError(c, err)
+		}
+	})
+	c.observeHandler(received)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sseStream is net.Conn of SSE connection, it writes to the response.
+type sseStream struct {
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	remote net.Addr
+	closed chan struct{}
+	once   sync.Once
+	token  string     // session token required in POST requests
+	posts  sync.Mutex // serializes POST requests
+}
+
+func newSSEStream(w http.ResponseWriter, r *http.Request) (*sseStream, error) {
+	remote, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	if err != nil {
+		remote = &net.TCPAddr{}
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	return &sseStream{
+		w:      w,
+		rc:     http.NewResponseController(w),
+		remote: remote,
+		closed: make(chan struct{}),
+		token:  hex.EncodeToString(token),
+	}, nil
+}
+
+// frame write the websocket frame as SSE event: data frames as data lines, pings as comment.
+func (s *sseStream) frame(h ws.Header, b []byte) error {
+	var buf bytes.Buffer
+	switch {
+	case h.OpCode == ws.OpPing:
+		buf.WriteString(": ping\n\n")
+	case h.OpCode.IsControl():
+		return nil
+	default:
+		for _, line := range bytes.Split(b, []byte("\n")) {
+			buf.WriteString("data: ")
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+		buf.WriteByte('\n')
+	}
+
+	_, err := s.Write(buf.Bytes())
+	return err
+}
+
+// Write implements net.Conn.
+func (s *sseStream) Write(b []byte) (int, error) {
+	select {
+	case <-s.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	n, err := s.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	return n, s.rc.Flush()
+}
+
+// Read implements net.Conn, it blocks until stream is closed, messages come in POST requests.
+func (s *sseStream) Read([]byte) (int, error) {
+	<-s.closed
+	return 0, io.EOF
+}
+
+// Close implements net.Conn.
+func (s *sseStream) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+// LocalAddr implements net.Conn.
+func (s *sseStream) LocalAddr() net.Addr { return &net.TCPAddr{} }
+
+// RemoteAddr implements net.Conn.
+func (s *sseStream) RemoteAddr() net.Addr { return s.remote }
+
+// SetDeadline implements net.Conn.
+func (s *sseStream) SetDeadline(t time.Time) error { return s.SetWriteDeadline(t) }
+
+// SetReadDeadline implements net.Conn.
+func (s *sseStream) SetReadDeadline(time.Time) error { return nil }
+
+// SetWriteDeadline implements net.Conn.
+func (s *sseStream) SetWriteDeadline(t time.Time) error {
+	_ = s.rc.SetWriteDeadline(t)
+	return nil
+}
+
+var _ net.Conn = (*sseStream)(nil)
@@ -0,0 +1,158 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func readSSE(t *testing.T, r *bufio.Reader) (string, json.RawMessage) {
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var msg struct {
+			Name string          `json:"name"`
+			Data json.RawMessage `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &msg))
+		return msg.Name, msg.Data
+	}
+}
+
+func postSSE(t *testing.T, u, id, token, body string) int {
+	req, err := http.NewRequest(http.MethodPost, u+"?id="+id, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set(SSETokenHeader, token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	return resp.StatusCode
+}
+
+func TestServer_SSEHandler(t *testing.T) {
+	wsServer := Start(context.Background())
+	defer func() {
+		_ = wsServer.Shutdown()
+	}()
+	ts := httptest.NewServer(http.HandlerFunc(wsServer.SSEHandler))
+	defer ts.Close()
+
+	ch := wsServer.NewChannel("room")
+	disconnected := make(chan string, 1)
+	wsServer.OnDisconnect(func(c Connection) {
+		disconnected <- c.ID()
+	})
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		require.NoError(t, c.Emit("echo", msg.Data))
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, http.NoBody)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	r := bufio.NewReader(resp.Body)
+
+	name, data := readSSE(t, r)
+	require.Equal(t, EventOpen, name)
+	var open struct {
+		ID    string `json:"id"`
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(data, &open))
+	require.Len(t, open.Token, 32)
+
+	require.Equal(t, http.StatusNoContent, postSSE(t, ts.URL, open.ID, open.Token, `{"name":"echo","data":{"a":1}}`))
+	name, data = readSSE(t, r)
+	require.Equal(t, "echo", name)
+	require.JSONEq(t, `{"a":1}`, string(data))
+
+	require.Equal(t, http.StatusNoContent, postSSE(t, ts.URL, open.ID, open.Token, `{"name":"_subscribe","data":{"channel":"room"}}`))
+	name, _ = readSSE(t, r)
+	require.Equal(t, EventSubscribe, name)
+	ch.Emit("news", "hello")
+	name, data = readSSE(t, r)
+	require.Equal(t, "news", name)
+	require.JSONEq(t, `"hello"`, string(data))
+
+	require.Equal(t, http.StatusNotFound, postSSE(t, ts.URL, "unknown", open.Token, `{}`))
+	require.Equal(t, http.StatusNotFound, postSSE(t, ts.URL, open.ID, "", `{"name":"echo"}`), "token must be required")
+	require.Equal(t, http.StatusNotFound, postSSE(t, ts.URL, open.ID, strings.Repeat("0", 32), `{"name":"echo"}`))
+
+	require.NoError(t, resp.Body.Close())
+	select {
+	case id := <-disconnected:
+		require.Equal(t, open.ID, id)
+	case <-time.After(time.Second):
+		t.Fatal("OnDisconnect must be called when client closes the stream")
+	}
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 0 && ch.Count() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestServer_SSEHandler_method(t *testing.T) {
+	w := httptest.NewRecorder()
+	New().SSEHandler(w, httptest.NewRequest(http.MethodPut, "/sse", http.NoBody))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestServer_SSEHandler_serialPosts(t *testing.T) {
+	wsServer := Start(context.Background())
+	defer func() {
+		_ = wsServer.Shutdown()
+	}()
+	ts := httptest.NewServer(http.HandlerFunc(wsServer.SSEHandler))
+	defer ts.Close()
+
+	var running, overlapped atomic.Int32
+	wsServer.On("slow", func(c Connection, msg *Message) {
+		if running.Add(1) > 1 {
+			overlapped.Add(1)
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, http.NoBody)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+
+	_, data := readSSE(t, bufio.NewReader(resp.Body))
+	var open struct {
+		ID    string `json:"id"`
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(data, &open))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Equal(t, http.StatusNoContent, postSSE(t, ts.URL, open.ID, open.Token, `{"name":"slow"}`))
+		}()
+	}
+	wg.Wait()
+	require.Zero(t, overlapped.Load(), "posts of connection must be handled one by one")
+}
@@ -0,0 +1,172 @@
+package websocket
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LatencyStats describe observed durations.
+type LatencyStats struct {
+	Count int64         `json:"count"`
+	Avg   time.Duration `json:"avg"`
+	Max   time.Duration `json:"max"`
+	Last  time.Duration `json:"last"`
+}
+
+// ConnStats is a snapshot of connection statistics.
+// Queue is the time the outgoing message waited for the connection to be free,
+// Write is the time of writing to the network and Handler is the time spent
+// in callbacks for incoming message.
+// Messages count data messages, bytes count whole frames (with headers and control frames).
+type ConnStats struct {
+	LastReceived time.Time    `json:"last_received"`
+	LastWritten  time.Time    `json:"last_written"`
+	Queue        LatencyStats `json:"queue"`
+	Write        LatencyStats `json:"write"`
+	Handler      LatencyStats `json:"handler"`
+	MessagesIn   int64        `json:"messages_in"`
+	MessagesOut  int64        `json:"messages_out"`
+	BytesIn      int64        `json:"bytes_in"`
+	BytesOut     int64        `json:"bytes_out"`
+}
+
+// Stats is a snapshot of statistics aggregated over all connections of the server since the start.
+type Stats struct {
+	Queue       LatencyStats `json:"queue"`
+	Write       LatencyStats `json:"write"`
+	Handler     LatencyStats `json:"handler"`
+	MessagesIn  int64        `json:"messages_in"`
+	MessagesOut int64        `json:"messages_out"`
+	BytesIn     int64        `json:"bytes_in"`
+	BytesOut    int64        `json:"bytes_out"`
+}
+
+// latency accumulates durations, safe for concurrent use.
+type latency struct {
+	count atomic.Int64
+	total atomic.Int64
+	max   atomic.Int64
+	last  atomic.Int64
+}
+
+func (l *latency) observe(d time.Duration) {
+	l.count.Add(1)
+	l.total.Add(int64(d))
+	l.last.Store(int64(d))
+	for {
+		m := l.max.Load()
+		if int64(d) <= m || l.max.CompareAndSwap(m, int64(d)) {
+			return
+		}
+	}
+}
+
+func (l *latency) stats() LatencyStats {
+	st := LatencyStats{
+		Count: l.count.Load(),
+		Max:   time.Duration(l.max.Load()),
+		Last:  time.Duration(l.last.Load()),
+	}
+	if st.Count != 0 {
+		st.Avg = time.Duration(l.total.Load() / st.Count)
+	}
+	return st
+}
+
+type serverStats struct {
+	queue   latency
+	write   latency
+	handler latency
+
+	messagesIn  atomic.Int64
+	messagesOut atomic.Int64
+	bytesIn     atomic.Int64
+	bytesOut    atomic.Int64
+}
+
+type connStats struct {
+	serverStats
+	lastReceived atomic.Int64
+	// lastData is the time of the last data frame, control frames are not counted
+	lastData    atomic.Int64
+	lastWritten atomic.Int64
+}
+
+// Stats return the statistics of connection.
+func (c *Conn) Stats() ConnStats {
+	return ConnStats{
+		LastReceived: unixTime(c.stats.lastReceived.Load()),
+		LastWritten:  unixTime(c.stats.lastWritten.Load()),
+		Queue:        c.stats.queue.stats(),
+		Write:        c.stats.write.stats(),
+		Handler:      c.stats.handler.stats(),
+		MessagesIn:   c.stats.messagesIn.Load(),
+		MessagesOut:  c.stats.messagesOut.Load(),
+		BytesIn:      c.stats.bytesIn.Load(),
+		BytesOut:     c.stats.bytesOut.Load(),
+	}
+}
+
+// Stats return the statistics aggregated over all connections.
+func (s *Server) Stats() Stats {
+	return Stats{
+		Queue:       s.stats.queue.stats(),
+		Write:       s.stats.write.stats(),
+		Handler:     s.stats.handler.stats(),
+		MessagesIn:  s.stats.messagesIn.Load(),
+		MessagesOut: s.stats.messagesOut.Load(),
+		BytesIn:     s.stats.bytesIn.Load(),
+		BytesOut:    s.stats.bytesOut.Load(),
+	}
+}
+
+func (c *Conn) observeWrite(enqueued, started, finished time.Time) {
+	c.stats.lastWritten.Store(finished.UnixNano())
+	c.stats.queue.observe(started.Sub(enqueued))
+	c.stats.write.observe(finished.Sub(started))
+
+	if c.server != nil {
+		c.server.stats.queue.observe(started.Sub(enqueued))
+		c.server.stats.write.observe(finished.Sub(started))
+	}
+}
+
+func (c *Conn) observeIn() {
+	c.stats.messagesIn.Add(1)
+	if c.server != nil {
+		c.server.stats.messagesIn.Add(1)
+	}
+}
+
+func (c *Conn) observeOut() {
+	c.stats.messagesOut.Add(1)
+	if c.server != nil {
+		c.server.stats.messagesOut.Add(1)
+	}
+}
+
+// observeBytes count bytes of frames read from and written to the network.
+func (c *Conn) observeBytes(in, out int64) {
+	c.stats.bytesIn.Add(in)
+	c.stats.bytesOut.Add(out)
+	if c.server != nil {
+		c.server.stats.bytesIn.Add(in)
+		c.server.stats.bytesOut.Add(out)
+	}
+}
+
+func (c *Conn) observeHandler(received time.Time) {
+	d := time.Since(received)
+	c.stats.handler.observe(d)
+
+	if c.server != nil {
+		c.server.stats.handler.observe(d)
+	}
+}
+
+func unixTime(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
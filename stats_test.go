@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLatency(t *testing.T) {
+	var l latency
+	require.Equal(t, LatencyStats{}, l.stats())
+
+	l.observe(10 * time.Millisecond)
+	l.observe(30 * time.Millisecond)
+	l.observe(20 * time.Millisecond)
+
+	require.Equal(t, LatencyStats{
+		Count: 3,
+		Avg:   20 * time.Millisecond,
+		Max:   30 * time.Millisecond,
+		Last:  20 * time.Millisecond,
+	}, l.stats())
+}
+
+func TestConn_Stats(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	received := make(chan *Message, 1)
+	conns := make(chan *Conn, 1)
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		time.Sleep(5 * time.Millisecond)
+		_ = c.Emit("echo", msg.Data)
+		received <- msg
+		conns <- c.(*Conn)
+	})
+
+	before := time.Now()
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, "echo", "test")
+	_, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+
+	msg := <-received
+	require.True(t, msg.Received.After(before), "message must have receive time")
+
+	conn := <-conns
+	time.Sleep(5 * time.Millisecond)
+	st := conn.Stats()
+	require.False(t, st.LastReceived.IsZero())
+	require.False(t, st.LastWritten.IsZero())
+	require.Equal(t, int64(1), st.Handler.Count)
+	require.GreaterOrEqual(t, st.Handler.Max, 5*time.Millisecond)
+	require.Equal(t, int64(1), st.Write.Count)
+	require.Equal(t, int64(1), st.Queue.Count)
+	require.Equal(t, int64(1), st.MessagesIn)
+	require.Equal(t, int64(1), st.MessagesOut)
+	// masked client frame: 2 bytes of header, 4 bytes of mask and payload
+	require.Equal(t, int64(6+len(`{"name":"echo","data":"test"}`)), st.BytesIn)
+	require.Equal(t, int64(2+len(`{"name":"echo","data":"test"}`)), st.BytesOut)
+
+	srv := wsServer.Stats()
+	require.Equal(t, int64(1), srv.Handler.Count)
+	require.Equal(t, st.MessagesIn, srv.MessagesIn)
+	require.Equal(t, st.BytesOut, srv.BytesOut)
+}
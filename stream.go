@@ -0,0 +1,140 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"io"
+	"log"
+	"net"
+)
+
+// DefaultStreamThreshold is the message size from which messages are delivered to OnStream.
+const DefaultStreamThreshold = 64 << 10
+
+// StreamFunc receives the message payload as a reader.
+// Header describes the first frame of message, its Length is -1 for fragmented messages.
+// The reader is valid only until the function returns, unread data is discarded.
+type StreamFunc func(ctx context.Context, c Connection, h ws.Header, r io.Reader)
+
+// OnStream sets the callback for big messages. When it is set, fragmented messages and messages
+// bigger than the threshold (see WithStreamThreshold) are not buffered in memory, but delivered
+// to f as a reader. Other messages are processed by On and OnMessage as usual.
+func (s *Server) OnStream(f StreamFunc) {
+	s.mu.Lock()
+	s.onStream = f
+	s.mu.Unlock()
+}
+
+// WithStreamThreshold sets the message size from which messages are delivered to OnStream.
+// Default is DefaultStreamThreshold.
+func WithStreamThreshold(n int64) Option {
+	return func(s *Server) {
+		s.streamThreshold = n
+	}
+}
+
+// isStream reports whether the message which starts with header must be delivered to OnStream.
+func (s *Server) isStream(h ws.Header) bool {
+	if h.OpCode != ws.OpText && h.OpCode != ws.OpBinary {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.onStream != nil && (!h.Fin || h.Length > s.streamThreshold)
+}
+
+// readStream deliver the message to OnStream and skip the rest of message after callback.
+func (s *Server) readStream(c *Conn, conn net.Conn, f frame) error {
+	s.mu.RLock()
+	onStream := s.onStream
+	s.mu.RUnlock()
+
+	h := f.header
+	h.Masked = false
+	if !h.Fin {
+		h.Length = -1
+	}
+
+	r := &streamReader{
+		s:     s,
+		c:     c,
+		conn:  conn,
+		frame: f,
+	}
+
+	c.observeIn()
+	err := s.safe(c, func() { onStream(c.Context(), c, h, r) })
+	c.observeHandler(f.received)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		log.Printf("drop ws connection: %v", err)
+		return err
+	}
+	return nil
+}
+
+// streamReader reads payload of the message frame by frame.
+// Control frames which come between fragments are handled on the way.
+type streamReader struct {
+	s     *Server
+	c     *Conn
+	conn  net.Conn
+	frame frame
+	err   error
+}
+
+// Read implements io.Reader.
+func (r *streamReader) Read(p []byte) (int, error) {
+	for r.err == nil {
+		n, err := r.frame.r.Read(p)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			r.err = err
+			break
+		}
+
+		if r.frame.utf8Fin && !r.c.reader.utf8Reader.Valid() {
+			r.err = wsutil.ErrInvalidUTF8
+			break
+		}
+		if r.frame.header.Fin {
+			r.err = io.EOF
+			break
+		}
+
+		r.err = r.next()
+	}
+
+	return 0, r.err
+}
+
+// next read frames until the next continuation frame.
+func (r *streamReader) next() error {
+	for {
+		f, err := r.s.nextFrame(r.c, r.conn)
+		if err != nil {
+			return err
+		}
+
+		switch f.header.OpCode {
+		case ws.OpContinuation:
+			r.frame = f
+			return nil
+		case ws.OpClose:
+			payload, _ := io.ReadAll(f.r)
+			return r.s.readClose(r.c, r.conn, payload)
+		default:
+			if err = r.s.readControl(r.c, f); err != nil {
+				return err
+			}
+		}
+	}
+}
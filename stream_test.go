@@ -0,0 +1,151 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+	"time"
+)
+
+type streamed struct {
+	header ws.Header
+	data   []byte
+	err    error
+}
+
+func TestServer_OnStream(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithStreamThreshold(16))
+	defer shutdown()
+
+	done := make(chan streamed, 1)
+	wsServer.OnStream(func(ctx context.Context, c Connection, h ws.Header, r io.Reader) {
+		b, err := io.ReadAll(r)
+		done <- streamed{header: h, data: b, err: err}
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	payload := bytes.Repeat([]byte("a"), 1024)
+	require.NoError(t, ws.WriteFrame(c, ws.MaskFrame(ws.NewBinaryFrame(payload))))
+
+	select {
+	case s := <-done:
+		require.NoError(t, s.err)
+		require.Equal(t, ws.OpBinary, s.header.OpCode)
+		require.Equal(t, int64(len(payload)), s.header.Length)
+		require.Equal(t, payload, s.data)
+	case <-time.After(time.Second):
+		t.Fatal("message must be delivered to OnStream")
+	}
+}
+
+func TestServer_OnStream_fragmented(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	done := make(chan streamed, 1)
+	wsServer.OnStream(func(ctx context.Context, c Connection, h ws.Header, r io.Reader) {
+		b, err := io.ReadAll(r)
+		done <- streamed{header: h, data: b, err: err}
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	require.NoError(t, ws.WriteFrame(c, ws.MaskFrame(ws.NewFrame(ws.OpText, false, []byte("hello")))))
+	require.NoError(t, ws.WriteFrame(c, ws.MaskFrame(ws.NewPingFrame([]byte("ping")))))
+	require.NoError(t, ws.WriteFrame(c, ws.MaskFrame(ws.NewFrame(ws.OpContinuation, true, []byte(" world")))))
+
+	select {
+	case s := <-done:
+		require.NoError(t, s.err)
+		require.Equal(t, ws.OpText, s.header.OpCode)
+		require.Equal(t, int64(-1), s.header.Length, "length of fragmented message is unknown")
+		require.Equal(t, "hello world", string(s.data))
+	case <-time.After(time.Second):
+		t.Fatal("message must be delivered to OnStream")
+	}
+
+	h, err := ws.ReadHeader(c)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpPong, h.OpCode, "ping between fragments must be answered")
+}
+
+func TestServer_OnStream_small(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	wsServer.OnStream(func(ctx context.Context, c Connection, h ws.Header, r io.Reader) {
+		t.Error("small message must not be streamed")
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	require.NoError(t, wsutil.WriteClientText(c, []byte("hello")))
+
+	b, err := wsutil.ReadServerText(c)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+}
+
+func TestServer_OnStream_unread(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithStreamThreshold(4))
+	defer shutdown()
+
+	wsServer.OnStream(func(ctx context.Context, c Connection, h ws.Header, r io.Reader) {
+		buf := make([]byte, 2)
+		_, _ = io.ReadFull(r, buf)
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	require.NoError(t, wsutil.WriteClientBinary(c, bytes.Repeat([]byte("a"), 64)))
+	require.NoError(t, wsutil.WriteClientText(c, []byte("ok")))
+
+	b, err := wsutil.ReadServerText(c)
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(b), "unread part of message must be skipped")
+}
+
+func TestServer_OnStream_context(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithStreamThreshold(4))
+	defer shutdown()
+
+	done := make(chan context.Context, 1)
+	wsServer.OnStream(func(ctx context.Context, c Connection, h ws.Header, r io.Reader) {
+		done <- ctx
+	})
+
+	c := dial(t, ts)
+	require.NoError(t, wsutil.WriteClientBinary(c, bytes.Repeat([]byte("a"), 64)))
+
+	var ctx context.Context
+	select {
+	case ctx = <-done:
+	case <-time.After(time.Second):
+		t.Fatal("message must be delivered to OnStream")
+	}
+	require.NoError(t, ctx.Err())
+
+	require.NoError(t, c.Close())
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context must be cancelled when connection is closed")
+	}
+}
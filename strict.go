@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"errors"
+	"github.com/gobwas/ws"
+	"log"
+	"net"
+)
+
+// errCloseFrame is returned for close frame with 1 byte payload.
+var errCloseFrame = errors.New("websocket: close frame payload must be empty or have status code")
+
+// WithStrictRFC6455 enables strict mode for Autobahn compliance. Server always rejects frames
+// which are not masked, have RSV bits or reserved opcodes and control frames longer than 125 bytes
+// or without FIN with 1002, strict mode also:
+//   - rejects close frame with 1 byte payload, reserved or unknown status code with 1002
+//     and close reason which is not UTF-8 with 1007;
+//   - replies to valid close frame with the same status code;
+//   - closes connection with 1007 on text message which is not UTF-8, instead of dropping it.
+func WithStrictRFC6455() Option {
+	return func(s *Server) {
+		s.strict = true
+	}
+}
+
+// readClose handle close frame sent by client, it always returns error as connection must be closed.
+func (s *Server) readClose(c *Conn, conn net.Conn, payload []byte) error {
+	reason := closeReason(payload)
+	c.setDisconnect(reason)
+	if !s.strict {
+		return errClosed
+	}
+
+	if err := checkClose(payload, reason); err != nil {
+		log.Printf("drop ws connection: %v", err)
+		code := ws.StatusProtocolError
+		if errors.Is(err, ws.ErrProtocolInvalidUTF8) {
+			code = ws.StatusInvalidFramePayloadData
+		}
+		_ = c.writeClose(conn, code, "")
+		return err
+	}
+
+	code := reason.Code
+	if code == ws.StatusNoStatusRcvd {
+		code = ws.StatusNormalClosure
+	}
+	_ = c.writeClose(conn, code, "")
+	return errClosed
+}
+
+// checkClose validate payload of close frame.
+func checkClose(payload []byte, reason DisconnectReason) error {
+	switch len(payload) {
+	case 0:
+		return nil
+	case 1:
+		return errCloseFrame
+	}
+	return ws.CheckCloseFrameData(reason.Code, reason.Reason)
+}
@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestServer_WithStrictRFC6455(t *testing.T) {
+	ts, _, shutdown := server(t, WithStrictRFC6455())
+	defer shutdown()
+
+	tbl := []struct {
+		name    string
+		payload []byte
+		code    ws.StatusCode
+	}{
+		{name: "empty", payload: nil, code: ws.StatusNormalClosure},
+		{name: "one byte", payload: []byte{0x3}, code: ws.StatusProtocolError},
+		{name: "normal", payload: ws.NewCloseFrameBody(ws.StatusNormalClosure, "bye"), code: ws.StatusNormalClosure},
+		{name: "application", payload: ws.NewCloseFrameBody(3000, ""), code: 3000},
+		{name: "not used", payload: ws.NewCloseFrameBody(999, ""), code: ws.StatusProtocolError},
+		{name: "reserved", payload: ws.NewCloseFrameBody(ws.StatusNoStatusRcvd, ""), code: ws.StatusProtocolError},
+		{name: "unknown", payload: ws.NewCloseFrameBody(1016, ""), code: ws.StatusProtocolError},
+		{name: "invalid reason", payload: ws.NewCloseFrameBody(ws.StatusNormalClosure, "\xff"), code: ws.StatusInvalidFramePayloadData},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			c := dial(t, ts)
+			defer func() {
+				_ = c.Close()
+			}()
+
+			require.NoError(t, wsutil.WriteClientMessage(c, ws.OpClose, tt.payload))
+			require.Equal(t, tt.code, readClose(t, c))
+		})
+	}
+
+	t.Run("invalid text", func(t *testing.T) {
+		c := dial(t, ts)
+		defer func() {
+			_ = c.Close()
+		}()
+
+		require.NoError(t, wsutil.WriteClientMessage(c, ws.OpText, []byte("\xff\xfe")))
+		require.Equal(t, ws.StatusInvalidFramePayloadData, readClose(t, c))
+	})
+}
+
+func TestServer_closeNotStrict(t *testing.T) {
+	ts, _, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	require.NoError(t, wsutil.WriteClientMessage(c, ws.OpClose, ws.NewCloseFrameBody(999, "")))
+	_, err := ws.ReadFrame(c)
+	require.ErrorIs(t, err, io.EOF, "connection is closed without reply")
+}
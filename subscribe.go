@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"sync"
+)
+
+// IncomingMessage is a message received from connection and delivered through Subscribe channel.
+type IncomingMessage struct {
+	Conn    *Conn
+	Message *Message
+}
+
+// subscription is a Go channel which receives named messages.
+// Delivery is blocking, so slow consumer holds reading from connection (backpressure).
+type subscription struct {
+	ch     chan IncomingMessage
+	done   chan struct{}
+	closed bool
+	mu     sync.RWMutex
+}
+
+// Subscribe return a channel which will receive every message with name.
+// Buffer defines the size of channel, when it's full reading from connection will wait for consumer.
+// Channel will be closed on Shutdown, ErrServerClosed is returned if server is already shut down.
+// It panics if name is in reserved system namespace.
+func (s *Server) Subscribe(name string, buffer int) (<-chan IncomingMessage, error) {
+	mustNotBeSystem(name)
+
+	sub := &subscription{
+		ch:   make(chan IncomingMessage, buffer),
+		done: make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return nil, ErrServerClosed
+	}
+	s.subscriptions[name] = append(s.subscriptions[name], sub)
+
+	return sub.ch, nil
+}
+
+func (sub *subscription) send(msg IncomingMessage) {
+	sub.mu.RLock()
+	defer sub.mu.RUnlock()
+
+	if sub.closed {
+		return
+	}
+
+	select {
+	case sub.ch <- msg:
+	case <-sub.done:
+	}
+}
+
+func (sub *subscription) close() {
+	close(sub.done)
+
+	sub.mu.Lock()
+	sub.closed = true
+	close(sub.ch)
+	sub.mu.Unlock()
+}
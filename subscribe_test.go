@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestServer_Subscribe(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	events, err := wsServer.Subscribe("order", 1)
+	require.NoError(t, err)
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, "skip", "not subscribed")
+	writeMessage(t, c, "order", "first")
+	writeMessage(t, c, "order", "second")
+
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case msg := <-events:
+			require.Equal(t, "order", msg.Message.Name)
+			require.NotNil(t, msg.Conn)
+
+			var data string
+			require.NoError(t, json.Unmarshal(msg.Message.Data, &data))
+			require.Equal(t, expected, data)
+		case <-time.After(time.Second):
+			t.Fatal("message must be delivered to subscription")
+		}
+	}
+}
+
+func TestServer_Subscribe_closedOnShutdown(t *testing.T) {
+	ts, wsServer, _ := server(t)
+	defer ts.Close()
+
+	events, err := wsServer.Subscribe("order", 0)
+	require.NoError(t, err)
+
+	c := dial(t, ts)
+	writeMessage(t, c, "order", "blocked")
+	time.Sleep(10 * time.Millisecond)
+
+	require.NoError(t, wsServer.Shutdown())
+
+	for range events {
+	}
+	_, ok := <-events
+	require.False(t, ok, "subscription must be closed")
+
+	_, err = wsServer.Subscribe("order", 0)
+	require.ErrorIs(t, err, ErrServerClosed)
+}
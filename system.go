@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SystemPrefix marks names of built-in control events.
+// Application can't register handlers for such names, and clients can't send
+// system events which are not handled by the server itself.
+const SystemPrefix = "_"
+
+// Built-in control events. All of them use the same envelope as application messages:
+//
+//	{"name": "_heartbeat", "data": ...}
+const (
+	EventAuth        = SystemPrefix + "auth"
+	EventSubscribe   = SystemPrefix + "subscribe"
+	EventUnsubscribe = SystemPrefix + "unsubscribe"
+	EventAck         = SystemPrefix + "ack"
+	EventError       = SystemPrefix + "error"
+	EventHeartbeat   = SystemPrefix + "heartbeat"
+)
+
+// IsSystemEvent reports whether the name belongs to reserved namespace.
+func IsSystemEvent(name string) bool {
+	return strings.HasPrefix(name, SystemPrefix)
+}
+
+func mustNotBeSystem(name string) {
+	if IsSystemEvent(name) {
+		panic(fmt.Sprintf("websocket: event name %q is reserved for system events", name))
+	}
+}
+
+// systemFunc is a callback for built-in event, it needs connection of the server.
+type systemFunc func(c *Conn, msg *Message)
+
+// handleSystem register callback for built-in event.
+func (s *Server) handleSystem(name string, f systemFunc) {
+	s.mu.Lock()
+	s.system[name] = f
+	s.mu.Unlock()
+}
+
+// heartbeat reply to client heartbeat with the same data.
+func heartbeat(c *Conn, msg *Message) {
+	_ = c.Emit(EventHeartbeat, json.RawMessage(msg.Data))
+}
@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"encoding/json"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestServer_On_system(t *testing.T) {
+	s := New()
+
+	require.Panics(t, func() {
+		s.On(EventError, func(c Connection, msg *Message) {})
+	}, "system event must not be registered")
+	require.Panics(t, func() {
+		s.Subscribe("_custom", 1)
+	}, "system event must not be subscribed")
+	require.NotPanics(t, func() {
+		s.On("custom_event", func(c Connection, msg *Message) {})
+	})
+}
+
+func TestServer_system_spoof(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	received := make(chan []byte, 1)
+	wsServer.OnMessage(func(c Connection, h ws.Header, b []byte) {
+		received <- append([]byte{}, b...)
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, EventAck, 1)
+	writeMessage(t, c, "custom", 1)
+
+	select {
+	case b := <-received:
+		var msg envelope
+		require.NoError(t, json.Unmarshal(b, &msg))
+		require.Equal(t, "custom", msg.Name, "system event must not reach OnMessage")
+	case <-time.After(time.Second):
+		t.Fatal("message must be received")
+	}
+}
+
+func TestServer_system_heartbeat(t *testing.T) {
+	ts, _, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, EventHeartbeat, 42)
+
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"_heartbeat","data":42}`, string(b))
+}
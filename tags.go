@@ -0,0 +1,61 @@
+package websocket
+
+// Tag sets the value of connection tag, e.g. user role or app version.
+// Tags are used to select connections with EmitWhere and ConnectionsWhere.
+func (c *Conn) Tag(key string, value any) {
+	c.tagsMu.Lock()
+	if c.tags == nil {
+		c.tags = make(map[string]any)
+	}
+	c.tags[key] = value
+	c.tagsMu.Unlock()
+}
+
+// Untag removes the tag of connection.
+func (c *Conn) Untag(key string) {
+	c.tagsMu.Lock()
+	delete(c.tags, key)
+	c.tagsMu.Unlock()
+}
+
+// TagValue return the value of tag and whether connection has it.
+func (c *Conn) TagValue(key string) (any, bool) {
+	c.tagsMu.RLock()
+	defer c.tagsMu.RUnlock()
+
+	v, ok := c.tags[key]
+	return v, ok
+}
+
+// HasTag reports whether connection has the tag with value, value must be comparable.
+func (c *Conn) HasTag(key string, value any) bool {
+	v, ok := c.TagValue(key)
+	return ok && v == value
+}
+
+// ConnectionsWhere return live connections for which f returns true.
+// f is called for snapshot of connections, so it could see connection which is already closed.
+func (s *Server) ConnectionsWhere(f func(c *Conn) bool) []*Conn {
+	var list []*Conn
+	s.connections.forEach(func(c *Conn) {
+		if f(c) {
+			list = append(list, c)
+		}
+	})
+	return list
+}
+
+// EmitWhere emits message to connections for which f returns true, e.g. to all admins:
+//
+//	s.EmitWhere(func(c *websocket.Conn) bool { return c.HasTag("role", "admin") }, "alert", data)
+//
+// Returns number of connections which received the message.
+func (s *Server) EmitWhere(f func(c *Conn) bool, name string, data any) int {
+	n := 0
+	for _, c := range s.ConnectionsWhere(f) {
+		if err := c.Emit(name, data); err == nil {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,58 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConn_Tag(t *testing.T) {
+	c := &Conn{}
+	_, ok := c.TagValue("role")
+	require.False(t, ok)
+
+	c.Tag("role", "admin")
+	c.Tag("version", 3)
+	v, ok := c.TagValue("version")
+	require.True(t, ok)
+	require.Equal(t, 3, v)
+	require.True(t, c.HasTag("role", "admin"))
+	require.False(t, c.HasTag("role", "user"))
+
+	c.Untag("role")
+	require.False(t, c.HasTag("role", "admin"))
+}
+
+func TestServer_EmitWhere(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+	wsServer.On("role", func(c Connection, msg *Message) {
+		c.Tag("role", msg.String())
+		_ = c.Emit("tagged", nil)
+	})
+
+	admin, user := dial(t, ts), dial(t, ts)
+	defer func() {
+		_ = admin.Close()
+		_ = user.Close()
+	}()
+	writeMessage(t, admin, "role", "admin")
+	writeMessage(t, user, "role", "user")
+	_, _, err := wsutil.ReadServerData(admin)
+	require.NoError(t, err)
+	_, _, err = wsutil.ReadServerData(user)
+	require.NoError(t, err)
+
+	isAdmin := func(c *Conn) bool { return c.HasTag("role", "admin") }
+	require.Len(t, wsServer.ConnectionsWhere(isAdmin), 1)
+	require.Equal(t, 1, wsServer.EmitWhere(isAdmin, "alert", "disk is full"))
+
+	data, _, err := wsutil.ReadServerData(admin)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"alert","data":"disk is full"}`, string(data))
+
+	require.NoError(t, user.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, _, err = wsutil.ReadServerData(user)
+	require.Error(t, err, "user must not receive the alert")
+}
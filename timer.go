@@ -0,0 +1,167 @@
+package websocket
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// timerTick is the precision of scheduled emits.
+	timerTick = 10 * time.Millisecond
+	// timerSlots is the number of slots in the timer wheel, timers which are further than
+	// timerTick*timerSlots wait for several rounds.
+	timerSlots = 512
+)
+
+// Timer is a scheduled emit, see Server.EmitAfter and Channel.EmitAt.
+type Timer struct {
+	f      func()
+	slot   int
+	rounds int
+	done   atomic.Bool
+	wheel  *wheel
+}
+
+// Stop cancel the emit. It returns false if the message was already sent, timer was stopped
+// or server was shut down.
+func (t *Timer) Stop() bool {
+	if !t.done.CompareAndSwap(false, true) {
+		return false
+	}
+	t.wheel.remove(t)
+	return true
+}
+
+// wheel is a hashed timer wheel: timers are kept in the slot of their tick, the goroutine of wheel
+// visits one slot per tick. One goroutine serves all timers of server.
+type wheel struct {
+	slots []map[*Timer]struct{}
+	pos   int
+	last  time.Time
+	mu    sync.Mutex
+}
+
+func newWheel() *wheel {
+	w := &wheel{
+		slots: make([]map[*Timer]struct{}, timerSlots),
+		last:  time.Now(),
+	}
+	for i := range w.slots {
+		w.slots[i] = make(map[*Timer]struct{})
+	}
+	return w
+}
+
+// schedule call f not earlier than after d.
+func (w *wheel) schedule(d time.Duration, f func()) *Timer {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// ticks are counted from the last visited slot, so the timer doesn't fire early
+	ticks := int((time.Since(w.last) + d + timerTick - 1) / timerTick)
+	ticks = max(ticks, 1)
+
+	t := &Timer{
+		f:      f,
+		slot:   (w.pos + ticks) % len(w.slots),
+		rounds: (ticks - 1) / len(w.slots),
+		wheel:  w,
+	}
+	w.slots[t.slot][t] = struct{}{}
+	return t
+}
+
+func (w *wheel) remove(t *Timer) {
+	w.mu.Lock()
+	delete(w.slots[t.slot], t)
+	w.mu.Unlock()
+}
+
+// advance move the wheel to the next slot and return timers which are due.
+func (w *wheel) advance(now time.Time) []*Timer {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pos = (w.pos + 1) % len(w.slots)
+	w.last = now
+
+	var due []*Timer
+	for t := range w.slots[w.pos] {
+		if t.rounds > 0 {
+			t.rounds--
+			continue
+		}
+		delete(w.slots[w.pos], t)
+		due = append(due, t)
+	}
+	return due
+}
+
+// stop drop all timers, they will never fire.
+func (w *wheel) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, slot := range w.slots {
+		for t := range slot {
+			t.done.Store(true)
+		}
+		clear(slot)
+	}
+}
+
+// runTimers fire scheduled emits until server is shut down.
+func (s *Server) runTimers() {
+	ticker := time.NewTicker(timerTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, t := range s.timers.advance(now) {
+				if t.done.CompareAndSwap(false, true) {
+					spawn(&s.goroutines.background, t.f)
+				}
+			}
+		case <-s.quit:
+			s.timers.stop()
+			return
+		}
+	}
+}
+
+// schedule call f after d, timers are dropped on Shutdown.
+// Returns ErrNotRunning if server was not started and ErrServerClosed after Shutdown.
+func (s *Server) schedule(d time.Duration, f func()) (*Timer, error) {
+	s.mu.RLock()
+	running, done := s.running, s.done
+	s.mu.RUnlock()
+
+	if done {
+		return nil, ErrServerClosed
+	}
+	if !running {
+		return nil, ErrNotRunning
+	}
+	return s.timers.schedule(d, f), nil
+}
+
+// EmitAfter emit message to all connections after d. Pending messages are dropped on Shutdown.
+// Returns ErrNotRunning if server was not started and ErrServerClosed after Shutdown.
+func (s *Server) EmitAfter(d time.Duration, name string, data any) (*Timer, error) {
+	return s.schedule(d, func() {
+		_ = s.Emit(name, data)
+	})
+}
+
+// EmitAt emit message to all connections of channel at t. Pending messages are dropped on Shutdown
+// of the server. Returns ErrNotRunning for channel which was not created by running server.
+func (c *Channel) EmitAt(t time.Time, name string, data interface{}) (*Timer, error) {
+	if c.server == nil {
+		return nil, ErrNotRunning
+	}
+	return c.server.schedule(time.Until(t), func() {
+		c.Emit(name, data)
+	})
+}
@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServer_EmitAfter(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	start := time.Now()
+	_, err := wsServer.EmitAfter(50*time.Millisecond, "later", []byte("1"))
+	require.NoError(t, err)
+	stopped, err := wsServer.EmitAfter(20*time.Millisecond, "never", []byte("2"))
+	require.NoError(t, err)
+	require.True(t, stopped.Stop())
+	require.False(t, stopped.Stop())
+
+	name, _ := readEnvelope(t, c)
+	require.Equal(t, "later", name)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond, "message must not be sent early")
+}
+
+func TestChannel_EmitAt(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	ch := wsServer.NewChannel("room")
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+	joinChannel(t, c, "room")
+
+	at := time.Now().Add(30 * time.Millisecond)
+	timer, err := ch.EmitAt(at, "scheduled", "data")
+	require.NoError(t, err)
+
+	name, data := readEnvelope(t, c)
+	require.Equal(t, "scheduled", name)
+	require.JSONEq(t, `"data"`, string(data))
+	require.False(t, time.Now().Before(at))
+	require.False(t, timer.Stop(), "fired timer can't be stopped")
+
+	_, err = newChannel("free").EmitAt(at, "scheduled", "data")
+	require.ErrorIs(t, err, ErrNotRunning)
+}
+
+func TestServer_EmitAfter_shutdown(t *testing.T) {
+	wsServer := New()
+	_, err := wsServer.EmitAfter(time.Millisecond, "msg", nil)
+	require.ErrorIs(t, err, ErrNotRunning)
+
+	wsServer.Run(context.Background())
+	var fired atomic.Bool
+	timer, err := wsServer.schedule(50*time.Millisecond, func() { fired.Store(true) })
+	require.NoError(t, err)
+	require.NoError(t, wsServer.Shutdown())
+
+	time.Sleep(100 * time.Millisecond)
+	require.False(t, fired.Load(), "timer must not fire after shutdown")
+	require.False(t, timer.Stop())
+
+	_, err = wsServer.EmitAfter(time.Millisecond, "msg", nil)
+	require.ErrorIs(t, err, ErrServerClosed)
+}
+
+func TestWheel_rounds(t *testing.T) {
+	w := newWheel()
+	w.schedule(timerTick*(timerSlots+2), func() {})
+
+	now := time.Now()
+	for i := 0; i < timerSlots; i++ {
+		require.Empty(t, w.advance(now))
+	}
+	due := 0
+	for i := 0; i < 3; i++ {
+		due += len(w.advance(now))
+	}
+	require.Equal(t, 1, due, "timer must fire after the full round")
+}
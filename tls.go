@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+)
+
+// WithTLSConfig sets TLS config of ListenAndServeTLS, e.g. to require client certificates (mTLS):
+//
+//	websocket.WithTLSConfig(&tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool})
+//
+// Certificate and key files of ListenAndServeTLS could be empty when config has Certificates.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(s *Server) {
+		s.tlsConfig = cfg
+	}
+}
+
+// TLSState return state of TLS connection, nil when server doesn't terminate TLS itself
+// (plain connections and TLS terminated by load balancer).
+// Verified certificate of client is TLSState().VerifiedChains[0][0].
+func (c *Conn) TLSState() *tls.ConnectionState {
+	return c.tlsState
+}
+
+// RequireClientCert return UpgradeFunc which rejects upgrades without verified client certificate
+// with 401 and calls f with the certificate to authorize the client, e.g. by subject or SAN.
+// Error of f rejects the upgrade with 403, use *Error to set another status code.
+//
+//	wsServer.OnUpgrade(websocket.RequireClientCert(func(cert *x509.Certificate) error {
+//		if cert.Subject.CommonName != "billing" {
+//			return errors.New("unknown service")
+//		}
+//		return nil
+//	}))
+func RequireClientCert(f func(cert *x509.Certificate) error) UpgradeFunc {
+	return func(r *http.Request, h http.Header) error {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			return NewError(CodeUnauthorized, "client certificate required")
+		}
+		if f == nil {
+			return nil
+		}
+		return f(r.TLS.VerifiedChains[0][0])
+	}
+}
+
+// connectionState return TLS state of raw connection served by ServeListener.
+func connectionState(conn net.Conn) *tls.ConnectionState {
+	if pc, ok := conn.(*proxyConn); ok {
+		conn = pc.Conn
+	}
+	tc, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	st := tc.ConnectionState()
+	return &st
+}
@@ -0,0 +1,151 @@
+package websocket
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA is certificate authority which issues client certificates of tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// client return client certificate with the common name.
+func (ca *testCA) client(t *testing.T, name string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// dialTLS dial wss server with client certificates.
+func dialTLS(addr string, certs ...tls.Certificate) (net.Conn, error) {
+	d := ws.Dialer{TLSConfig: &tls.Config{InsecureSkipVerify: true, Certificates: certs}}
+	c, _, _, err := d.Dial(context.Background(), "wss://"+addr+"/ws")
+	return c, err
+}
+
+func TestServer_TLSState(t *testing.T) {
+	ca := newTestCA(t)
+	wsServer := Start(context.Background())
+	defer func() {
+		require.NoError(t, wsServer.Shutdown())
+	}()
+	wsServer.OnUpgrade(RequireClientCert(func(cert *x509.Certificate) error {
+		if cert.Subject.CommonName != "billing" {
+			return errors.New("unknown service")
+		}
+		return nil
+	}))
+	names := make(chan string, 1)
+	wsServer.OnConnect(func(c Connection) {
+		names <- c.(*Conn).TLSState().VerifiedChains[0][0].Subject.CommonName
+	})
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(wsServer.Handler))
+	ts.TLS = &tls.Config{ClientAuth: tls.VerifyClientCertIfGiven, ClientCAs: ca.pool}
+	ts.StartTLS()
+	defer ts.Close()
+	addr := strings.TrimPrefix(ts.URL, "https://")
+
+	c, err := dialTLS(addr, ca.client(t, "billing"))
+	require.NoError(t, err)
+	defer c.Close()
+	require.Equal(t, "billing", <-names)
+
+	_, err = dialTLS(addr, ca.client(t, "orders"))
+	require.ErrorContains(t, err, "403")
+
+	_, err = dialTLS(addr)
+	require.ErrorContains(t, err, "401")
+}
+
+func TestServer_TLSState_listener(t *testing.T) {
+	ca := newTestCA(t)
+	wsServer := New()
+	defer func() {
+		_ = wsServer.Shutdown()
+	}()
+	wsServer.OnUpgrade(RequireClientCert(nil))
+	states := make(chan *tls.ConnectionState, 1)
+	wsServer.OnConnect(func(c Connection) {
+		states <- c.(*Conn).TLSState()
+	})
+
+	// server certificate of httptest is reused for the listener
+	ts := httptest.NewUnstartedServer(nil)
+	ts.StartTLS()
+	ts.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	cfg := ts.TLS.Clone()
+	cfg.ClientAuth, cfg.ClientCAs = tls.RequireAndVerifyClientCert, ca.pool
+	go func() {
+		_ = wsServer.ServeListener(tls.NewListener(l, cfg))
+	}()
+
+	c, err := dialTLS(l.Addr().String(), ca.client(t, "billing"))
+	require.NoError(t, err)
+	defer c.Close()
+	st := <-states
+	require.NotNil(t, st)
+	require.Equal(t, "billing", st.PeerCertificates[0].Subject.CommonName)
+}
+
+func TestServer_TLSState_plain(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+	wsServer.OnUpgrade(RequireClientCert(nil))
+
+	_, _, _, err := ws.Dial(context.Background(), "ws"+strings.TrimPrefix(ts.URL, "http")+"/ws")
+	require.ErrorContains(t, err, "401")
+}
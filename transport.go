@@ -0,0 +1,123 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"github.com/gobwas/ws"
+	"net"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrDraining is returned when new connection comes during Drain.
+	ErrDraining = errors.New("websocket: server is draining")
+	// ErrNoDatagrams is returned by EmitDatagram for connection which is not served with ServeSession.
+	ErrNoDatagrams = errors.New("websocket: connection doesn't support datagrams")
+)
+
+// DatagramSession is the unreliable datagram part of transport session, e.g. *webtransport.Session
+// of webtransport-go implements it.
+type DatagramSession interface {
+	SendDatagram(b []byte) error
+	ReceiveDatagram(ctx context.Context) ([]byte, error)
+}
+
+// ServeStream serves bidirectional stream accepted by another transport with websocket framing,
+// so the same Conn and Channel API works over it. It's experimental, the intended use is
+// WebTransport (HTTP/3) streams accepted by external package (e.g. webtransport-go), client must
+// send websocket frames over the stream, see ServeSession for datagrams.
+// Request is the request of the session, it's used for params, namespace and client address.
+// ServeStream blocks until the stream is closed.
+func (s *Server) ServeStream(stream net.Conn, r *http.Request) error {
+	return s.ServeSession(stream, nil, r)
+}
+
+// ServeSession serves the stream like ServeStream and datagrams of the same session (e.g. WebTransport).
+// Every datagram is one message in envelope of connection without websocket framing, it's handled like
+// message from the stream: handlers, validation and rate limits. Connection sends datagrams with EmitDatagram.
+// Datagrams could be lost or reordered, use them for latency-sensitive updates only, e.g. positions in game.
+func (s *Server) ServeSession(stream net.Conn, d DatagramSession, r *http.Request) error {
+	if s.draining.Load() {
+		return ErrDraining
+	}
+
+	ns, err := s.namespace(r.URL.Query().Get(NamespaceParam))
+	if err != nil {
+		return err
+	}
+
+	ip := s.clientIP(r.RemoteAddr, r.Header)
+	if _, err := s.reserve(ip); err != nil {
+		return err
+	}
+
+	s.serve(stream, r.URL.Query(), ns, ip, negotiated{tls: r.TLS, datagrams: d})
+	return nil
+}
+
+// readDatagrams handle datagrams of connection until session or connection is closed.
+func (s *Server) readDatagrams(c *Conn) {
+	op := ws.OpText
+	if c.envelope == BinaryEnvelope {
+		op = ws.OpBinary
+	}
+
+	for {
+		b, err := c.datagrams.ReceiveDatagram(c.Context())
+		if err != nil {
+			return
+		}
+
+		received := time.Now()
+		c.stats.lastReceived.Store(received.UnixNano())
+		c.stats.lastData.Store(received.UnixNano())
+		c.observeBytes(int64(len(b)), 0)
+		c.observeIn()
+		h := ws.Header{Fin: true, OpCode: op, Length: int64(len(b))}
+		if s.workers != nil {
+			s.dispatch(c, h, b, received)
+			continue
+		}
+		if err := s.handle(c, h, b, received); err != nil {
+			s.dropConn(c)
+			return
+		}
+	}
+}
+
+// EmitDatagram emit message to connection in datagram of the session, see ServeSession.
+// Datagram could be lost, it's not acknowledged, batched or flow controlled,
+// message must fit into datagram size of the transport.
+func (c *Conn) EmitDatagram(name string, data any) (err error) {
+	if c.datagrams == nil {
+		return ErrNoDatagrams
+	}
+
+	env := envelope{Name: name, Data: data}
+	if pc := c.payloadCipher(); pc != nil && !IsSystemEvent(name) {
+		if env, err = c.encrypt(env, pc); err != nil {
+			return err
+		}
+	}
+
+	e := getEncoder()
+	defer putEncoder(e)
+
+	var b []byte
+	if c.envelope == BinaryEnvelope {
+		b, err = e.encodeBinary(env)
+	} else {
+		if env.Data, err = jsonData(env.Data); err == nil {
+			b, err = e.encode(env)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = c.datagrams.SendDatagram(b); err == nil {
+		c.observeBytes(0, int64(len(b)))
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServer_ServeStream(t *testing.T) {
+	wsServer := Start(context.Background())
+	defer func() {
+		_ = wsServer.Shutdown()
+	}()
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		require.Equal(t, "1", c.Param("room"))
+		require.NoError(t, c.Emit("echo", msg.Data))
+	})
+
+	client, stream := net.Pipe()
+	require.NoError(t, client.SetDeadline(time.Now().Add(3*time.Second)))
+	served := make(chan error, 1)
+	go func() {
+		served <- wsServer.ServeStream(stream, httptest.NewRequest("CONNECT", "/wt?room=1", nil))
+	}()
+
+	b, err := json.Marshal(map[string]any{"name": "echo", "data": "stream"})
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientText(client, b))
+
+	data, _, err := wsutil.ReadServerData(client)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"echo","data":"stream"}`, string(data))
+
+	require.NoError(t, client.Close())
+	select {
+	case err := <-served:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("ServeStream must return when stream is closed")
+	}
+}
+
+func TestServer_ServeStream_draining(t *testing.T) {
+	wsServer := New()
+	require.NoError(t, wsServer.Drain(context.Background()))
+
+	_, stream := net.Pipe()
+	err := wsServer.ServeStream(stream, httptest.NewRequest("CONNECT", "/wt", nil))
+	require.ErrorIs(t, err, ErrDraining)
+
+	wsServer = New()
+	err = wsServer.ServeStream(stream, httptest.NewRequest("CONNECT", "/wt?namespace=/unknown", nil))
+	require.ErrorIs(t, err, ErrUnknownNamespace)
+}
+
+// datagrams is DatagramSession over channels.
+type datagrams struct {
+	in, out chan []byte
+}
+
+func (d *datagrams) SendDatagram(b []byte) error {
+	d.out <- append([]byte{}, b...)
+	return nil
+}
+
+func (d *datagrams) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	select {
+	case b := <-d.in:
+		return b, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestServer_ServeSession(t *testing.T) {
+	wsServer := Start(context.Background())
+	defer func() {
+		_ = wsServer.Shutdown()
+	}()
+	wsServer.On("position", func(c Connection, msg *Message) {
+		require.NoError(t, c.(*Conn).EmitDatagram("position", msg.Data))
+	})
+
+	d := &datagrams{in: make(chan []byte, 1), out: make(chan []byte, 1)}
+	client, stream := net.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- wsServer.ServeSession(stream, d, httptest.NewRequest("CONNECT", "/wt", nil))
+	}()
+
+	d.in <- []byte(`{"name":"position","data":[1,2]}`)
+	select {
+	case b := <-d.out:
+		require.JSONEq(t, `{"name":"position","data":[1,2]}`, string(b))
+	case <-time.After(time.Second):
+		t.Fatal("datagram must be handled and replied with datagram")
+	}
+
+	require.NoError(t, client.Close())
+	select {
+	case err := <-served:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("ServeSession must return when stream is closed")
+	}
+}
+
+func TestConn_EmitDatagram_noSession(t *testing.T) {
+	c := &Conn{id: "1"}
+	require.ErrorIs(t, c.EmitDatagram("position", 1), ErrNoDatagrams)
+}
@@ -0,0 +1,33 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// OnTyped adding callback for message which data is decoded to T.
+// Messages with invalid data don't reach the callback, client receives _error event
+// with CodeBadRequest instead. Error returned by callback is sent to client in the same way (see Handle).
+// Returned function removes the callback.
+func OnTyped[T any](s *Server, name string, f func(ctx context.Context, c Connection, payload T) error) (off func()) {
+	return s.On(name, typed(name, f))
+}
+
+// OnTypedNamespace adding typed callback for message of namespace connections, see OnTyped.
+func OnTypedNamespace[T any](ns *Namespace, name string, f func(ctx context.Context, c Connection, payload T) error) (off func()) {
+	return ns.On(name, typed(name, f))
+}
+
+func typed[T any](name string, f func(ctx context.Context, c Connection, payload T) error) HandlerFunc {
+	return func(c Connection, msg *Message) {
+		var payload T
+		if err := json.Unmarshal(msg.Data, &payload); err != nil {
+			replyError(c, name, "", NewError(CodeBadRequest, fmt.Sprintf("invalid payload: %v", err)))
+			return
+		}
+		if err := f(c.Context(), c, payload); err != nil {
+			replyError(c, name, "", err)
+		}
+	}
+}
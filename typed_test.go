@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type order struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+func TestOnTyped(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	OnTyped(wsServer, "order.created", func(ctx context.Context, c Connection, o order) error {
+		require.NoError(t, ctx.Err())
+		if o.ID == 0 {
+			return errors.New("id is required")
+		}
+		return c.Emit("order.accepted", o.ID)
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, "order.created", order{ID: 42, Title: "book"})
+	name, data := readEnvelope(t, c)
+	require.Equal(t, "order.accepted", name)
+	require.Equal(t, "42", string(data))
+
+	writeMessage(t, c, "order.created", "not an order")
+	name, data = readEnvelope(t, c)
+	require.Equal(t, EventError, name)
+	require.Contains(t, string(data), `"event":"order.created"`)
+	require.Contains(t, string(data), "invalid payload")
+	require.Contains(t, string(data), `"code":400`)
+
+	writeMessage(t, c, "order.created", order{Title: "book"})
+	name, data = readEnvelope(t, c)
+	require.Equal(t, EventError, name)
+	require.JSONEq(t, `{"event":"order.created","code":500,"message":"internal error"}`, string(data))
+}
+
+func TestOnTypedNamespace(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	OnTypedNamespace(wsServer.Of("/shop"), "order.created", func(ctx context.Context, c Connection, o order) error {
+		return c.Emit("title", o.Title)
+	})
+
+	c := dialNamespace(t, ts, "/shop")
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, "order.created", order{ID: 1, Title: "book"})
+	_, data := readEnvelope(t, c)
+	require.Equal(t, `"book"`, string(data))
+}
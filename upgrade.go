@@ -0,0 +1,167 @@
+package websocket
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/gobwas/ws"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const headerProtocol = "Sec-WebSocket-Protocol"
+
+var (
+	// ErrHijackNotSupported is returned when http.ResponseWriter (and writers it wraps) can't be hijacked.
+	// Usually it means that some middleware wraps the writer without Unwrap method.
+	ErrHijackNotSupported = errors.New("websocket: response writer doesn't support hijacking")
+	// ErrHTTP2NotSupported is returned when HTTP/2 request is not extended CONNECT (RFC 8441).
+	ErrHTTP2NotSupported = errors.New("websocket: upgrade over HTTP/2 requires extended CONNECT, use HTTP/1.1")
+)
+
+// upgrade the http connection to websocket, returns selected subprotocol and extensions.
+// HTTP/2 streams are accepted with extended CONNECT (RFC 8441), clients which don't see
+// SETTINGS_ENABLE_CONNECT_PROTOCOL from server use HTTP/1.1 upgrade.
+// The connection is hijacked with http.ResponseController, which walks through Unwrap chain
+// of middleware wrappers, clear error is reported before the upgrade if there is no writer to hijack.
+func (s *Server) upgrade(w http.ResponseWriter, r *http.Request) (net.Conn, negotiated, error) {
+	if isExtendedConnect(r) {
+		return s.upgradeH2(w, r)
+	}
+	if r.ProtoMajor >= 2 {
+		http.Error(w, ErrHTTP2NotSupported.Error(), http.StatusHTTPVersionNotSupported)
+		return nil, negotiated{}, ErrHTTP2NotSupported
+	}
+
+	hw, err := hijackable(w)
+	if err != nil {
+		http.Error(w, ErrHijackNotSupported.Error(), http.StatusInternalServerError)
+		return nil, negotiated{}, err
+	}
+	if headerSize(r) > s.headerLimit() {
+		http.Error(w, ErrHeaderTooLarge.Error(), http.StatusRequestHeaderFieldsTooLarge)
+		return nil, negotiated{}, ErrHeaderTooLarge
+	}
+
+	h, n, err := s.negotiate(r)
+	if err != nil {
+		http.Error(w, err.Error(), rejectCode(err))
+		return nil, negotiated{}, err
+	}
+
+	// upgrader clears deadlines of hijacked connection, so the response is limited by its own timeout
+	u := ws.HTTPUpgrader{
+		Header:  h,
+		Timeout: s.handshakeLimit(),
+		Protocol: func(p string) bool {
+			return p == n.protocol
+		},
+	}
+	conn, _, hs, err := u.Upgrade(r, hw)
+	n.protocol = hs.Protocol
+	return conn, n, err
+}
+
+// UpgradeFunc is called before the handshake. Headers added to h are sent in handshake response,
+// e.g. session cookie (h.Add("Set-Cookie", cookie.String())) or tracing headers.
+// Sec-WebSocket-Protocol overrides the subprotocol selected by server, it must be one of requested by client.
+// Returned error rejects the upgrade with 403, use *Error to set another status code.
+type UpgradeFunc func(r *http.Request, h http.Header) error
+
+// OnUpgrade function which will be called before the handshake of new connection.
+func (s *Server) OnUpgrade(f UpgradeFunc) {
+	s.mu.Lock()
+	s.onUpgrade = f
+	s.mu.Unlock()
+}
+
+// negotiate return headers of handshake response, selected subprotocol and extensions.
+func (s *Server) negotiate(r *http.Request) (http.Header, negotiated, error) {
+	var requested []string
+	for _, v := range r.Header.Values(headerProtocol) {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				requested = append(requested, p)
+			}
+		}
+	}
+
+	var protocol string
+	for _, p := range requested {
+		if s.selectProtocol(p) {
+			protocol = p
+			break
+		}
+	}
+
+	s.mu.RLock()
+	onUpgrade := s.onUpgrade
+	s.mu.RUnlock()
+
+	h := make(http.Header)
+	if onUpgrade != nil {
+		if err := onUpgrade(r, h); err != nil {
+			return nil, negotiated{}, err
+		}
+	}
+	if p := h.Get(headerProtocol); p != "" {
+		h.Del(headerProtocol)
+		if slices.Contains(requested, p) {
+			protocol = p
+		}
+	}
+
+	extensions, err := s.negotiateExtensions(r, h)
+	if err != nil {
+		return nil, negotiated{}, err
+	}
+	return h, negotiated{protocol: protocol, extensions: extensions, tls: r.TLS}, nil
+}
+
+// rejectCode return http status of rejected upgrade, code of *Error which is not http status is replaced by 403.
+func rejectCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) && e.Code >= 100 && e.Code <= 599 {
+		return e.Code
+	}
+	return http.StatusForbidden
+}
+
+// hijackable return writer which hijacks the connection with http.ResponseController.
+// It checks Unwrap chain of w for http.Hijacker first, so unsupported writer is reported with the chain.
+func hijackable(w http.ResponseWriter) (*controlledWriter, error) {
+	chain := make([]string, 0, 1)
+	for next := w; next != nil; {
+		chain = append(chain, fmt.Sprintf("%T", next))
+		if _, ok := next.(http.Hijacker); ok {
+			return &controlledWriter{ResponseWriter: w, rc: http.NewResponseController(w)}, nil
+		}
+
+		u, ok := next.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		next = u.Unwrap()
+	}
+
+	return nil, fmt.Errorf("%w (%s)", ErrHijackNotSupported, strings.Join(chain, " -> "))
+}
+
+// controlledWriter is http.Hijacker for upgrader, it hijacks with http.ResponseController,
+// responses are written to the original writer, so middleware sees rejected upgrades.
+type controlledWriter struct {
+	http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// Hijack the connection with http.ResponseController.
+func (w *controlledWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.rc.Hijack()
+}
+
+// Unwrap return the original writer.
+func (w *controlledWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
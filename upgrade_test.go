@@ -0,0 +1,218 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type wrappedWriter struct {
+	http.ResponseWriter
+}
+
+type unwrapWriter struct {
+	http.ResponseWriter
+}
+
+func (w unwrapWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestServer_Handler_wrappedWriter(t *testing.T) {
+	wsServer := Start(context.Background())
+	defer func() {
+		require.NoError(t, wsServer.Shutdown())
+	}()
+
+	r := http.NewServeMux()
+	r.HandleFunc("/unwrap", func(w http.ResponseWriter, r *http.Request) {
+		wsServer.Handler(unwrapWriter{unwrapWriter{w}}, r)
+	})
+	r.HandleFunc("/wrapped", func(w http.ResponseWriter, r *http.Request) {
+		wsServer.Handler(wrappedWriter{w}, r)
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	u := url.URL{Scheme: "ws", Host: strings.Replace(ts.URL, "http://", "", 1), Path: "/unwrap"}
+	c, _, _, err := ws.Dial(context.Background(), u.String())
+	require.NoError(t, err, "writer must be unwrapped to hijacker")
+	require.NoError(t, c.Close())
+
+	u.Path = "/wrapped"
+	_, _, _, err = ws.Dial(context.Background(), u.String())
+	require.Error(t, err)
+	var statusErr ws.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusInternalServerError, int(statusErr))
+}
+
+func TestHijackable(t *testing.T) {
+	_, err := hijackable(wrappedWriter{httptest.NewRecorder()})
+	require.ErrorIs(t, err, ErrHijackNotSupported)
+	require.Contains(t, err.Error(), "websocket.wrappedWriter")
+
+	w := unwrapWriter{hijackRecorder{httptest.NewRecorder()}}
+	hw, err := hijackable(w)
+	require.NoError(t, err)
+	require.Equal(t, w, hw.Unwrap(), "responses must be written to the original writer")
+	_, _, err = hw.Hijack()
+	require.EqualError(t, err, "hijacked", "connection must be hijacked with response controller")
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("hijacked")
+}
+
+func TestServer_upgrade_http2(t *testing.T) {
+	s := New()
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.ProtoMajor, r.ProtoMinor = 2, 0
+	w := httptest.NewRecorder()
+
+	_, _, err := s.upgrade(w, r)
+	require.ErrorIs(t, err, ErrHTTP2NotSupported)
+	require.Equal(t, http.StatusHTTPVersionNotSupported, w.Code)
+}
+
+// pipeWriter is http.ResponseWriter of HTTP/2 stream which writes to the pipe.
+type pipeWriter struct {
+	header http.Header
+	code   chan int
+	w      *io.PipeWriter
+}
+
+func (w *pipeWriter) Header() http.Header         { return w.header }
+func (w *pipeWriter) WriteHeader(code int)        { w.code <- code }
+func (w *pipeWriter) Write(b []byte) (int, error) { return w.w.Write(b) }
+func (w *pipeWriter) Flush()                      {}
+
+func TestServer_Handler_extendedConnect(t *testing.T) {
+	wsServer := Start(context.Background())
+	defer func() {
+		require.NoError(t, wsServer.Shutdown())
+	}()
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		require.NoError(t, c.Emit("echo", msg.Data))
+	})
+
+	reqBody, client := io.Pipe()
+	resp, respBody := io.Pipe()
+	w := &pipeWriter{header: http.Header{}, code: make(chan int, 1), w: respBody}
+
+	r := httptest.NewRequest(http.MethodConnect, "/ws", reqBody)
+	r.ProtoMajor, r.ProtoMinor = 2, 0
+	r.Header.Set(":protocol", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Protocol", "unknown, "+ProtocolJSON)
+
+	done := make(chan struct{})
+	go func() {
+		wsServer.Handler(w, r)
+		close(done)
+	}()
+
+	require.Equal(t, http.StatusOK, <-w.code)
+	require.Equal(t, ProtocolJSON, w.header.Get("Sec-WebSocket-Protocol"))
+
+	b, err := json.Marshal(map[string]any{"name": "echo", "data": "over h2"})
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientText(client, b))
+
+	data, _, err := wsutil.ReadServerData(struct {
+		io.Reader
+		io.Writer
+	}{resp, client})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"echo","data":"over h2"}`, string(data))
+
+	require.NoError(t, client.Close())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler must return when stream is closed")
+	}
+}
+
+func TestServer_Handler_extendedConnectVersion(t *testing.T) {
+	r := httptest.NewRequest(http.MethodConnect, "/ws", http.NoBody)
+	r.ProtoMajor = 2
+	r.Header.Set(":protocol", "websocket")
+	w := httptest.NewRecorder()
+
+	New().Handler(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "13", w.Header().Get("Sec-WebSocket-Version"))
+}
+
+func TestServer_OnUpgrade(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithSubprotocols("chat.v1", "chat.v2"))
+	defer shutdown()
+
+	wsServer.OnUpgrade(func(r *http.Request, h http.Header) error {
+		if r.URL.Query().Get("token") != "secret" {
+			return NewError(http.StatusUnauthorized, "bad token")
+		}
+		h.Add("Set-Cookie", (&http.Cookie{Name: "session", Value: "42"}).String())
+		h.Set("X-Trace-Id", "trace")
+		h.Set("Sec-WebSocket-Protocol", "chat.v2")
+		return nil
+	})
+
+	u := url.URL{Scheme: "ws", Host: strings.Replace(ts.URL, "http://", "", 1), Path: "/ws", RawQuery: "token=secret"}
+	headers := make(http.Header)
+	d := ws.Dialer{
+		Protocols: []string{"chat.v1", "chat.v2"},
+		OnHeader: func(key, value []byte) error {
+			headers.Add(string(key), string(value))
+			return nil
+		},
+	}
+	c, _, hs, err := d.Dial(context.Background(), u.String())
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+	require.Equal(t, "chat.v2", hs.Protocol)
+	require.Equal(t, "session=42", headers.Get("Set-Cookie"))
+	require.Equal(t, "trace", headers.Get("X-Trace-Id"))
+
+	u.RawQuery = "token=wrong"
+	_, _, _, err = d.Dial(context.Background(), u.String())
+	var statusErr ws.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusUnauthorized, int(statusErr))
+	require.Eventually(t, func() bool {
+		return wsServer.accepted.Load() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRejectCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{err: errors.New("denied"), code: http.StatusForbidden},
+		{err: NewError(http.StatusUnauthorized, "login"), code: http.StatusUnauthorized},
+		{err: NewError(4001, "app code"), code: http.StatusForbidden},
+		{err: NewError(0, "no code"), code: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.code, rejectCode(tt.err), tt.err.Error())
+	}
+}
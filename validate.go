@@ -0,0 +1,117 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"io"
+	"strings"
+)
+
+// Validator checks data of received message before it reaches handlers, see Server.Validate.
+type Validator interface {
+	Validate(data []byte) error
+}
+
+// ValidatorFunc is a function which implements Validator.
+type ValidatorFunc func(data []byte) error
+
+// Validate implements Validator.
+func (f ValidatorFunc) Validate(data []byte) error {
+	return f(data)
+}
+
+// Validate sets validator for data of messages with name, it replaces the previous one.
+// Invalid messages don't reach handlers, subscriptions, OnAny callbacks and OnMessage: client receives _error event
+// with CodeBadRequest and the error, OnValidationError is called. Validators apply to connections of namespaces too.
+func (s *Server) Validate(name string, v Validator) {
+	s.mu.Lock()
+	s.validators[name] = v
+	s.mu.Unlock()
+}
+
+// OnValidationError function which will be called when message is rejected by validator.
+func (s *Server) OnValidationError(f func(c Connection, msg *Message, err error)) {
+	s.mu.Lock()
+	s.onValidationError = f
+	s.mu.Unlock()
+}
+
+// validate data of message with validator of its name, it reports whether message could be handled.
+func (s *Server) validate(c *Conn, msg *Message) bool {
+	s.mu.RLock()
+	v := s.validators[msg.Name]
+	onValidationError := s.onValidationError
+	s.mu.RUnlock()
+	if v == nil {
+		return true
+	}
+
+	err := v.Validate(msg.Data)
+	if err == nil {
+		return true
+	}
+
+	s.observe(Event{Type: MessageDropped, Conn: c, Name: msg.Name, Err: err})
+	replyError(c, msg.Name, "", NewError(CodeBadRequest, err.Error()))
+	if onValidationError != nil {
+		onValidationError(c, msg, err)
+	}
+	return false
+}
+
+// JSONSchema compiles JSON Schema (draft 4 to 2020-12, "$schema" selects the draft, 2020-12 by default)
+// to validator. Remote references are not resolved.
+func JSONSchema(schema []byte) (Validator, error) {
+	c := jsonschema.NewCompiler()
+	c.LoadURL = func(s string) (_ io.ReadCloser, err error) {
+		return nil, fmt.Errorf("websocket: remote schema %s is not allowed", s)
+	}
+	if err := c.AddResource("schema.json", bytes.NewReader(schema)); err != nil {
+		return nil, err
+	}
+	compiled, err := c.Compile("schema.json")
+	if err != nil {
+		return nil, err
+	}
+	return &schemaValidator{schema: compiled}, nil
+}
+
+// schemaValidator is compiled JSON Schema.
+type schemaValidator struct {
+	schema *jsonschema.Schema
+}
+
+// Validate implements Validator.
+func (v *schemaValidator) Validate(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("invalid json: %w", err)
+	}
+
+	err := v.schema.Validate(doc)
+	var ve *jsonschema.ValidationError
+	if errors.As(err, &ve) {
+		return errors.New(strings.Join(schemaErrors(ve, nil), "; "))
+	}
+	return err
+}
+
+// schemaErrors return messages of the leaf errors, prefixed with location in data.
+func schemaErrors(ve *jsonschema.ValidationError, list []string) []string {
+	if len(ve.Causes) == 0 {
+		loc := ve.InstanceLocation
+		if loc == "" {
+			loc = "/"
+		}
+		return append(list, loc+": "+ve.Message)
+	}
+	for _, cause := range ve.Causes {
+		list = schemaErrors(cause, list)
+	}
+	return list
+}
@@ -0,0 +1,120 @@
+package websocket
+
+import (
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestServer_Validate(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	schema, err := JSONSchema([]byte(`{
+		"type": "object",
+		"properties": {
+			"text": {"type": "string", "minLength": 1},
+			"room": {"type": "integer"}
+		},
+		"required": ["text"]
+	}`))
+	require.NoError(t, err)
+	wsServer.Validate("chat", schema)
+	wsServer.Validate("ping", ValidatorFunc(func(data []byte) error {
+		if string(data) != `"ping"` {
+			return errors.New("must be ping")
+		}
+		return nil
+	}))
+
+	rejected := make(chan error, 2)
+	wsServer.OnValidationError(func(c Connection, msg *Message, err error) {
+		rejected <- err
+	})
+	handled := make(chan string, 2)
+	wsServer.On("chat", func(c Connection, msg *Message) {
+		handled <- string(msg.Data)
+	})
+	wsServer.On("ping", func(c Connection, msg *Message) {
+		handled <- string(msg.Data)
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+
+	writeMessage(t, c, "chat", map[string]any{"room": "one"})
+	name, data := readEnvelope(t, c)
+	require.Equal(t, EventError, name)
+	require.Contains(t, string(data), `"code":400`)
+	require.Contains(t, string(data), "/room")
+	require.Contains(t, string(data), "missing properties")
+	select {
+	case err = <-rejected:
+		require.Contains(t, err.Error(), "/room")
+	case <-time.After(time.Second):
+		t.Fatal("OnValidationError must be called")
+	}
+
+	writeMessage(t, c, "ping", "pong")
+	name, data = readEnvelope(t, c)
+	require.Equal(t, EventError, name)
+	require.Contains(t, string(data), "must be ping")
+
+	writeMessage(t, c, "chat", map[string]any{"text": "hi", "room": 1})
+	writeMessage(t, c, "ping", "ping")
+	for _, want := range []string{`{"room":1,"text":"hi"}`, `"ping"`} {
+		select {
+		case got := <-handled:
+			require.JSONEq(t, want, got)
+		case <-time.After(time.Second):
+			t.Fatal("valid message must be handled")
+		}
+	}
+	require.Len(t, handled, 0)
+}
+
+func TestServer_Validate_onMessage(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	wsServer.Validate("raw", ValidatorFunc(func(data []byte) error {
+		if string(data) != `"ok"` {
+			return errors.New("must be ok")
+		}
+		return nil
+	}))
+	received := make(chan string, 2)
+	wsServer.OnMessage(func(c Connection, h ws.Header, b []byte) {
+		received <- string(b)
+	})
+
+	c := dial(t, ts)
+	defer c.Close()
+
+	writeMessage(t, c, "raw", "bad")
+	name, data := readEnvelope(t, c)
+	require.Equal(t, EventError, name)
+	require.Contains(t, string(data), "must be ok")
+
+	writeMessage(t, c, "raw", "ok")
+	select {
+	case b := <-received:
+		require.Contains(t, b, `"ok"`)
+	case <-time.After(time.Second):
+		t.Fatal("valid message must reach OnMessage")
+	}
+	require.Len(t, received, 0, "invalid message must not reach OnMessage")
+}
+
+func TestJSONSchema_invalid(t *testing.T) {
+	_, err := JSONSchema([]byte(`{"type": 1}`))
+	require.Error(t, err)
+	_, err = JSONSchema([]byte(`{"$ref": "https://example.com/schema.json"}`))
+	require.Error(t, err)
+
+	v, err := JSONSchema([]byte(`{"type": "string"}`))
+	require.NoError(t, err)
+	require.Error(t, v.Validate([]byte(`{`)))
+}
@@ -0,0 +1,286 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Headers of webhook requests.
+const (
+	HeaderWebhookTimestamp = "X-Webhook-Timestamp"
+	HeaderWebhookSignature = "X-Webhook-Signature"
+)
+
+// Defaults of WebhookOptions.
+const (
+	DefaultWebhookBackoff   = time.Second
+	DefaultWebhookTimeout   = 10 * time.Second
+	DefaultWebhookQueueSize = 1024
+	DefaultWebhookWorkers   = 1
+)
+
+// WebhookOptions configure delivery of webhook.
+type WebhookOptions struct {
+	// Secret signs requests with HMAC-SHA256, see WebhookSignature.
+	Secret []byte
+	// Retries is the number of retries of failed delivery, requests which didn't get 2xx are failed.
+	Retries int
+	// Backoff is the delay before the first retry, it's doubled for every next one. Default is DefaultWebhookBackoff.
+	Backoff time.Duration
+	// Timeout of one request, default is DefaultWebhookTimeout.
+	Timeout time.Duration
+	// Client sends requests, default is http.DefaultClient.
+	Client *http.Client
+	// QueueSize is the number of events waiting for delivery, events are dropped when the queue is full.
+	// Default is DefaultWebhookQueueSize.
+	QueueSize int
+	// Workers is the number of concurrent deliveries, default is DefaultWebhookWorkers.
+	// One worker delivers events in order, but event which is retried delays the next ones until it's
+	// delivered or failed. More workers keep the webhook going while some events are retried, without order.
+	Workers int
+}
+
+// WebhookEvent is the json body of webhook request. Connection is the id of client which sent the message,
+// Channel is the id of channel the message was emitted to. Data of binary messages is base64 string.
+type WebhookEvent struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Time       time.Time       `json:"time"`
+	Connection string          `json:"connection,omitempty"`
+	Channel    string          `json:"channel,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// WebhookStats is delivery metrics of webhook.
+type WebhookStats struct {
+	Delivered int64        `json:"delivered"`
+	Failed    int64        `json:"failed"`
+	Retries   int64        `json:"retries"`
+	Dropped   int64        `json:"dropped"`
+	Pending   int          `json:"pending"`
+	Latency   LatencyStats `json:"latency"`
+	LastError string       `json:"last_error,omitempty"`
+}
+
+// Webhook posts events with one name to url.
+type Webhook struct {
+	server *Server
+	name   string
+	url    string
+	opts   WebhookOptions
+
+	queue chan WebhookEvent
+	start sync.Once
+	done  chan struct{}
+	stop  sync.Once
+
+	delivered, failed, retries, dropped atomic.Int64
+	latency                             latency
+	lastError                           atomic.Value
+}
+
+// Webhook registers url which receives events with name: messages sent by clients (after validation)
+// and messages emitted to channels, so systems without websocket could observe the traffic.
+// Events are posted as json WebhookEvent in background, failed deliveries are retried with opts.
+// Several webhooks could be registered for one name.
+func (s *Server) Webhook(name, url string, opts WebhookOptions) *Webhook {
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultWebhookBackoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWebhookTimeout
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultWebhookQueueSize
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWebhookWorkers
+	}
+
+	w := &Webhook{
+		server: s,
+		name:   name,
+		url:    url,
+		opts:   opts,
+		queue:  make(chan WebhookEvent, opts.QueueSize),
+		done:   make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	if s.webhooks == nil {
+		s.webhooks = make(map[string][]*Webhook)
+	}
+	s.webhooks[name] = append(s.webhooks[name], w)
+	s.mu.Unlock()
+	return w
+}
+
+// Close removes webhook from server, events which are already queued are not delivered.
+func (w *Webhook) Close() {
+	s := w.server
+	s.mu.Lock()
+	s.webhooks[w.name] = slices.DeleteFunc(s.webhooks[w.name], func(h *Webhook) bool { return h == w })
+	if len(s.webhooks[w.name]) == 0 {
+		delete(s.webhooks, w.name)
+	}
+	s.mu.Unlock()
+
+	w.stop.Do(func() {
+		close(w.done)
+	})
+}
+
+// Stats return delivery metrics of webhook.
+func (w *Webhook) Stats() WebhookStats {
+	st := WebhookStats{
+		Delivered: w.delivered.Load(),
+		Failed:    w.failed.Load(),
+		Retries:   w.retries.Load(),
+		Dropped:   w.dropped.Load(),
+		Pending:   len(w.queue),
+		Latency:   w.latency.stats(),
+	}
+	st.LastError, _ = w.lastError.Load().(string)
+	return st
+}
+
+// WebhookSignature return signature of webhook request: hex of HMAC-SHA256 of timestamp, dot and body.
+// Receivers compare it with X-Webhook-Signature header and reject old timestamps to prevent replays.
+func WebhookSignature(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notify queue event for webhooks, data is encoded as json.
+func (s *Server) notify(hooks []*Webhook, e WebhookEvent, data any) {
+	if len(hooks) == 0 {
+		return
+	}
+
+	if b, ok := data.([]byte); ok && json.Valid(b) {
+		e.Data = b
+	} else {
+		b, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("websocket: encode webhook event %q: %v", e.Name, err)
+			return
+		}
+		e.Data = b
+	}
+	e.ID = s.idGenerator.NewID()
+	e.Time = time.Now()
+
+	for _, w := range hooks {
+		w.enqueue(e)
+	}
+}
+
+// enqueue event and start delivery loops of webhook.
+func (w *Webhook) enqueue(e WebhookEvent) {
+	w.start.Do(func() {
+		for i := 0; i < w.opts.Workers; i++ {
+			spawn(&w.server.goroutines.background, w.run)
+		}
+	})
+
+	select {
+	case w.queue <- e:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+// run deliver queued events until webhook is closed or server stops.
+func (w *Webhook) run() {
+	for {
+		select {
+		case e := <-w.queue:
+			w.deliver(e)
+		case <-w.done:
+			return
+		case <-w.server.quit:
+			return
+		}
+	}
+}
+
+// deliver post event and retry failed requests with exponential backoff.
+func (w *Webhook) deliver(e WebhookEvent) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("websocket: encode webhook event %q: %v", e.Name, err)
+		return
+	}
+
+	backoff := w.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		started := time.Now()
+		err = w.post(body)
+		if err == nil {
+			w.latency.observe(time.Since(started))
+			w.delivered.Add(1)
+			return
+		}
+		w.lastError.Store(err.Error())
+
+		if attempt >= w.opts.Retries {
+			w.failed.Add(1)
+			log.Printf("websocket: deliver webhook event %q to %s: %v", e.Name, w.url, err)
+			return
+		}
+		w.retries.Add(1)
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-w.done:
+			return
+		case <-w.server.quit:
+			return
+		}
+	}
+}
+
+// post signed request with event body.
+func (w *Webhook) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), w.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.opts.Secret != nil {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(HeaderWebhookTimestamp, ts)
+		req.Header.Set(HeaderWebhookSignature, WebhookSignature(w.opts.Secret, ts, body))
+	}
+
+	resp, err := w.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// webhookReceiver return server which fails first requests and sends received events to the channel.
+func webhookReceiver(t *testing.T, secret []byte, failures int32) (*httptest.Server, chan WebhookEvent) {
+	events := make(chan WebhookEvent, 10)
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if secret != nil {
+			ts := r.Header.Get(HeaderWebhookTimestamp)
+			require.Equal(t, WebhookSignature(secret, ts, body), r.Header.Get(HeaderWebhookSignature))
+		}
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var e WebhookEvent
+		require.NoError(t, json.Unmarshal(body, &e))
+		events <- e
+	}))
+	t.Cleanup(ts.Close)
+	return ts, events
+}
+
+func TestServer_Webhook(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+	secret := []byte("secret")
+	receiver, events := webhookReceiver(t, secret, 1)
+	hook := wsServer.Webhook("payment.received", receiver.URL, WebhookOptions{Secret: secret, Retries: 2, Backoff: 10 * time.Millisecond})
+
+	c := dial(t, ts)
+	defer c.Close()
+	writeMessage(t, c, "payment.received", map[string]int{"amount": 10})
+	writeMessage(t, c, "other", nil)
+
+	var e WebhookEvent
+	select {
+	case e = <-events:
+	case <-time.After(time.Second):
+		t.Fatal("webhook is not delivered")
+	}
+	require.Equal(t, "payment.received", e.Name)
+	require.NotEmpty(t, e.ID)
+	require.NotEmpty(t, e.Connection)
+	require.JSONEq(t, `{"amount":10}`, string(e.Data))
+
+	wsServer.NewChannel("billing").Emit("payment.received", map[string]int{"amount": 20})
+	select {
+	case e = <-events:
+	case <-time.After(time.Second):
+		t.Fatal("webhook of channel message is not delivered")
+	}
+	require.Equal(t, "billing", e.Channel)
+	require.JSONEq(t, `{"amount":20}`, string(e.Data))
+
+	require.Eventually(t, func() bool {
+		return hook.Stats().Delivered == 2
+	}, time.Second, 10*time.Millisecond)
+	st := hook.Stats()
+	require.Equal(t, int64(1), st.Retries)
+	require.Equal(t, int64(2), st.Latency.Count)
+	require.Contains(t, st.LastError, "503")
+
+	hook.Close()
+	wsServer.NewChannel("billing").Emit("payment.received", nil)
+	select {
+	case e = <-events:
+		t.Fatalf("closed webhook must not be delivered, got %v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestServer_Webhook_failed(t *testing.T) {
+	wsServer := Start(context.Background())
+	defer func() {
+		require.NoError(t, wsServer.Shutdown())
+	}()
+	receiver, _ := webhookReceiver(t, nil, 100)
+	hook := wsServer.Webhook("event", receiver.URL, WebhookOptions{Retries: 1, Backoff: time.Millisecond})
+
+	wsServer.NewChannel("ch").Emit("event", "data")
+	require.Eventually(t, func() bool {
+		return hook.Stats().Failed == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int64(0), hook.Stats().Delivered)
+	require.Equal(t, int64(1), hook.Stats().Retries)
+}
+
+func TestServer_Webhook_workers(t *testing.T) {
+	wsServer := Start(context.Background())
+	defer func() {
+		require.NoError(t, wsServer.Shutdown())
+	}()
+	events := make(chan WebhookEvent, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e WebhookEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		if string(e.Data) == `"retried"` {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		events <- e
+	}))
+	defer receiver.Close()
+	wsServer.Webhook("event", receiver.URL, WebhookOptions{Retries: 1, Backoff: time.Hour, Workers: 2})
+
+	ch := wsServer.NewChannel("ch")
+	ch.Emit("event", "retried")
+	ch.Emit("event", []byte(`{"raw":true}`))
+	select {
+	case e := <-events:
+		require.JSONEq(t, `{"raw":true}`, string(e.Data), "json bytes must be passed as is")
+	case <-time.After(time.Second):
+		t.Fatal("retried event must not block the other worker")
+	}
+}
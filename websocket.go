@@ -52,117 +52,346 @@ package websocket
 
 import (
 	"context"
-	"crypto/rand"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gobwas/ws"
-	"github.com/gobwas/ws/wsutil"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Server allows keeping connection list, broadcast channel and callbacks list.
-type Server struct {
-	connections map[*Conn]bool
-	channels    map[string]*Channel
-	broadcast   chan Message
-	callbacks   map[string]HandlerFunc
-
-	delChan []chan *Conn
+var (
+	// ErrServerClosed is returned when server is already shut down.
+	ErrServerClosed = errors.New("websocket: server closed")
+	// ErrNotRunning is returned when server was created by New, but Run was not called.
+	ErrNotRunning = errors.New("websocket: server is not running, call Run or use Start")
+	// ErrInvalidJSON is returned by EmitJSON when data is not valid json.
+	ErrInvalidJSON = errors.New("websocket: invalid json")
+	// ErrInvalidUTF8 is returned when []byte data sent in json envelope is not valid UTF-8,
+	// encode such data (e.g. to base64) or use binary envelope.
+	ErrInvalidUTF8 = errors.New("websocket: []byte data is not valid UTF-8")
+	// ErrChannelNotFound is returned by EmitToChannel for unknown channel.
+	ErrChannelNotFound = errors.New("websocket: channel not found")
+	// ErrBroadcastFull is returned by Emit when broadcast queue is full, see WithBroadcastBuffer.
+	ErrBroadcastFull = errors.New("websocket: broadcast queue is full")
+)
 
-	onConnect    func(c *Conn)
-	onDisconnect func(c *Conn)
-	onMessage    func(c *Conn, h ws.Header, b []byte)
+// DefaultBroadcastBuffer is the size of queue of messages passed to Server.Emit.
+const DefaultBroadcastBuffer = 1024
 
-	done bool
-	mu   sync.RWMutex
+// Server allows keeping connection list, broadcast channel and callbacks list.
+type Server struct {
+	connections   *registry
+	channels      map[string]*Channel
+	broadcast     chan envelope
+	callbacks     map[string][]*handler
+	onAny         []AnyHandlerFunc
+	system        map[string]systemFunc
+	subscriptions map[string][]*subscription
+	namespaces    map[string]*Namespace
+
+	onConnect    func(c Connection)
+	onDisconnect func(c Connection)
+	onMessage    func(c Connection, h ws.Header, b []byte)
+	onStream     StreamFunc
+	onSubscribe  SubscribeFunc
+	onPing       func(c Connection, payload []byte)
+	onPong       func(c Connection, payload []byte)
+	onError      func(c Connection, err error)
+	onUpgrade    UpgradeFunc
+	idGenerator  IDGenerator
+	observers    []Observer
+	onIdleClose  func(c Connection)
+
+	onDeliveryFailed func(c Connection, msg *Message)
+	onBeforeSend     atomic.Pointer[beforeSendFunc]
+	onAfterSend      atomic.Pointer[afterSendFunc]
+	onChannelEmit    []*channelEmitHook
+	webhooks         map[string][]*Webhook
+
+	validators        map[string]Validator
+	onValidationError func(c Connection, msg *Message, err error)
+
+	netpoll         bool
+	poller          *poller
+	maxMessageSize  int64
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	fragmentSize    int
+	textMessages    bool
+	broadcastBuffer int
+	workers         []chan job
+	syncConnect     bool
+	extensions      []Extension
+	strict          bool
+	recorder        func(c Connection) io.Writer
+
+	channelAuthorizer func(ctx context.Context, ch *Channel, c Connection) error
+	channelQueue      queueConfig
+	batchInterval     time.Duration
+	batchSize         int
+	flowControl       bool
+	flowCredits       int
+	flowQueue         int
+	channelTTL        time.Duration
+	idleTimeout       time.Duration
+	heartbeatInterval time.Duration
+	heartbeatTimeout  time.Duration
+	handshakeTimeout  time.Duration
+	maxHeaderSize     int
+	idleControl       bool
+	maxAge            time.Duration
+	maxAgeJitter      time.Duration
+	maxAgeGrace       time.Duration
+	rateLimits        map[string]rateLimit
+	globalLimit       *bucket
+	ratePolicy        RatePolicy
+
+	maxConnections      int64
+	maxConnectionsPerIP int
+	accepted            atomic.Int64
+	perIP               map[string]int
+	perIPMu             sync.Mutex
+	bans                map[string]time.Time
+	bansMu              sync.Mutex
+	proxyProtocol       bool
+	trustedProxies      []netip.Prefix
+	tlsConfig           *tls.Config
+
+	draining   atomic.Bool
+	drainEvent string
+	drainData  any
+	retryAfter time.Duration
+
+	ackTimeout time.Duration
+	ackRetries int
+	sequence   bool
+	envelope   EnvelopeFormat
+	protocols  []string
+	sseConns   map[string]*Conn
+	sseMu      sync.Mutex
+	timers     *wheel
+	path       string
+	listeners  []io.Closer
+
+	streamThreshold int64
+	sealer          Sealer
+
+	snapshotInterval time.Duration
+	snapshotSink     Sink
+
+	stats      serverStats
+	goroutines goroutineCounters
+
+	running bool
+	quit    chan struct{}
+	done    bool
+	mu      sync.RWMutex
 }
 
 // Message is a struct for data which sending between application and clients.
 // Name using for matching callback function in On function.
-// Body will be transformed to byte array and returned to callback.
+// Data is raw json of message data exactly as client sent it (raw payload for binary envelope), use Bind or String to read it.
+// Emit of Data sends it as is, without base64 encoding.
+// Received is the time when the first byte of message came from the network.
 type Message struct {
-	Name string `json:"name"`
-	Data []byte `json:"data"`
+	Name     string          `json:"name"`
+	Data     json.RawMessage `json:"data"`
+	Received time.Time       `json:"-"`
+}
+
+// Bind decode message data to v.
+func (m *Message) Bind(v any) error {
+	return json.Unmarshal(m.Data, v)
+}
+
+// String return message data as string: json strings are unquoted, other data is returned as is.
+func (m *Message) String() string {
+	var s string
+	if err := json.Unmarshal(m.Data, &s); err == nil {
+		return s
+	}
+	return string(m.Data)
 }
 
 // HandlerFunc is a type for handle function all function which has callback have this struct
-// as first element returns pointer to connection
+// as first element returns connection (*Conn of the server)
 // its give opportunity to close connection or emit message to exactly this connection.
-type HandlerFunc func(c *Conn, msg *Message)
+type HandlerFunc func(c Connection, msg *Message)
+
+// AnyHandlerFunc is a callback for every named message, see OnAny.
+type AnyHandlerFunc func(ctx context.Context, c Connection, msg *Message)
+
+// handler is the callback added by On, the pointer identifies it for removal.
+type handler struct {
+	f HandlerFunc
+}
+
+// removeHandler return copy of handlers without h, handlers could be called from the original list.
+func removeHandler(list []*handler, h *handler) []*handler {
+	res := make([]*handler, 0, len(list))
+	for _, v := range list {
+		if v != h {
+			res = append(res, v)
+		}
+	}
+	return res
+}
 
 // New websocket server handler with the provided options.
-func New() *Server {
+func New(opts ...Option) *Server {
 	srv := &Server{
-		connections: make(map[*Conn]bool),
-		channels:    make(map[string]*Channel),
-		broadcast:   make(chan Message),
-		callbacks:   make(map[string]HandlerFunc),
+		connections:   newRegistry(),
+		channels:      make(map[string]*Channel),
+		callbacks:     make(map[string][]*handler),
+		system:        make(map[string]systemFunc),
+		subscriptions: make(map[string][]*subscription),
+		validators:    make(map[string]Validator),
+		namespaces:    make(map[string]*Namespace),
+		quit:          make(chan struct{}),
+		timers:        newWheel(),
+		idGenerator:   &UUIDv7{},
+		sseConns:      make(map[string]*Conn),
+		writeTimeout:  DefaultWriteTimeout,
+
+		streamThreshold: DefaultStreamThreshold,
+		drainEvent:      EventReconnect,
+		retryAfter:      DefaultRetryAfter,
+		broadcastBuffer: DefaultBroadcastBuffer,
+	}
+	srv.onMessage = func(c Connection, h ws.Header, b []byte) {
+		if conn, ok := c.(*Conn); ok {
+			_ = conn.Write(h, b)
+		}
 	}
-	srv.onMessage = func(c *Conn, h ws.Header, b []byte) {
-		_ = c.Write(h, b)
+	srv.handleSystem(EventHeartbeat, heartbeat)
+	srv.handleSystem(EventSubscribe, subscribe)
+	srv.handleSystem(EventUnsubscribe, unsubscribe)
+	srv.handleSystem(EventReplay, replay)
+	srv.handleSystem(EventBackfill, backfill)
+	for _, opt := range opts {
+		opt(srv)
 	}
+	srv.broadcast = make(chan envelope, srv.broadcastBuffer)
 	return srv
 }
 
 // Start instantly create and run websocket server.
-func Start(ctx context.Context) *Server {
-	s := New()
+func Start(ctx context.Context, opts ...Option) *Server {
+	s := New(opts...)
 	s.Run(ctx)
 	return s
 }
 
 // Run start go routine which listening for channels.
+// Calling Run more than once or after Shutdown does nothing.
 func (s *Server) Run(ctx context.Context) {
-	go func() {
+	s.mu.Lock()
+	if s.running || s.done {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	if s.snapshotSink != nil && s.snapshotInterval > 0 {
+		spawn(&s.goroutines.background, s.runSnapshots)
+	}
+	if s.channelTTL > 0 {
+		spawn(&s.goroutines.background, s.collectChannels)
+	}
+	if s.idleTimeout > 0 {
+		spawn(&s.goroutines.background, s.reapIdle)
+	}
+	if s.ackTimeout > 0 {
+		spawn(&s.goroutines.background, s.redeliver)
+	}
+	if s.heartbeatInterval > 0 {
+		spawn(&s.goroutines.background, s.runHeartbeat)
+	}
+	spawn(&s.goroutines.background, s.runTimers)
+	for _, queue := range s.workers {
+		spawn(&s.goroutines.workers, func() {
+			s.runWorker(queue)
+		})
+	}
+
+	spawn(&s.goroutines.broadcasters, func() {
 		for {
 			select {
-			case msg := <-s.broadcast:
-				go func() {
-					s.mu.RLock()
-					for c := range s.connections {
-						_ = c.Emit(msg.Name, msg.Data)
-					}
-					s.mu.RUnlock()
-				}()
+			case env := <-s.broadcast:
+				spawn(&s.goroutines.broadcasters, func() {
+					s.connections.forEach(func(c *Conn) {
+						if c.namespace == nil {
+							_ = c.send(env)
+						}
+					})
+				})
 			case <-ctx.Done():
 				if err := s.Shutdown(); err != nil {
 					log.Print(err)
 				}
 				return
+			case <-s.quit:
+				return
 			}
 		}
-	}()
+	})
 }
 
 // Shutdown must be called before application died
 // its goes throw all connection and closing it
 // and stopping all goroutines.
+// Connections are closed in any case, but ErrNotRunning is returned
+// if the server was never started.
 func (s *Server) Shutdown() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	l := len(s.connections)
 	var wg sync.WaitGroup
-	wg.Add(l)
-
-	for c := range s.connections {
+	s.connections.forEach(func(c *Conn) {
+		wg.Add(1)
 		go func(c *Conn) {
-			if c.conn != nil {
-				_ = c.Close()
-			}
+			_ = c.Close()
 			wg.Done()
 		}(c)
-	}
+	})
 
 	wg.Wait()
 
+	if s.poller != nil {
+		s.poller.close()
+		s.poller = nil
+	}
+
+	for _, l := range s.listeners {
+		_ = l.Close()
+	}
+	s.listeners = nil
+
+	if !s.done {
+		for _, subs := range s.subscriptions {
+			for _, sub := range subs {
+				sub.close()
+			}
+		}
+		close(s.quit)
+	}
+
 	s.done = true
+
+	if !s.running {
+		return ErrNotRunning
+	}
 	return nil
 }
 
@@ -170,128 +399,165 @@ func (s *Server) Shutdown() error {
 func (s *Server) Handler(w http.ResponseWriter, r *http.Request) {
 	var params url.Values = nil
 
-	conn, _, _, err := ws.UpgradeHTTP(r, w)
+	if s.draining.Load() {
+		s.rejectDraining(w)
+		return
+	}
+
+	ns, err := s.namespace(r.URL.Query().Get(NamespaceParam))
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	ip := s.clientIP(r.RemoteAddr, r.Header)
+	if code, err := s.reserve(ip); err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	conn, n, err := s.upgrade(w, r)
+	if err != nil {
+		s.release(ip)
 		log.Printf("websocket: upgrade error %v", err)
 		return
 	}
-	defer func() {
-		_ = conn.Close()
-	}()
 
 	if r.URL.RawQuery != "" {
 		params, err = url.ParseQuery(r.URL.RawQuery)
 		if err != nil {
+			s.release(ip)
 			log.Print(err)
+			_ = conn.Close()
 			return
 		}
 	}
 
+	s.serve(conn, params, ns, ip, n)
+}
+
+// serve register the connection after handshake and read it until it's closed.
+// The place for connection from ip must be reserved.
+func (s *Server) serve(conn net.Conn, params url.Values, ns *Namespace, ip string, n negotiated) {
 	connection := &Conn{
-		id:     uuid(),
 		params: params,
 		conn:   conn,
 		done:   make(chan bool, 1),
+		server: s,
+		reader: newFrameReader(),
+
+		namespace: ns,
+		ip:        ip,
+		pingReset: make(chan struct{}, 1),
+		envelope:  s.envelopeFormat(n.protocol),
+		protocol:  n.protocol,
+		tlsState:  n.tls,
+
+		extensions: n.extensions,
+		datagrams:  n.datagrams,
+
+		created: time.Now(),
+	}
+	connection.ctx, connection.cancel = context.WithCancel(context.Background())
+	connection.SetDeadlines(s.readTimeout, s.writeTimeout)
+	connection.fragmentSize.Store(int64(s.fragmentSize))
+	if s.flowControl {
+		connection.flow = newFlow(s.flowCredits, s.flowQueue)
+	} else if s.batchInterval > 0 || s.batchSize > 0 {
+		connection.batch = &batch{}
+	}
+	if s.ackTimeout > 0 {
+		connection.outbox = newOutbox()
+	}
+	if err := s.register(connection); err != nil {
+		s.release(ip)
+		log.Print(err)
+		_ = connection.closeWith(ws.StatusInternalServerError, err.Error())
+		return
 	}
-	connection.startPing()
 	s.addConn(connection)
+	if connection.datagrams != nil {
+		spawn(&s.goroutines.readers, func() { s.readDatagrams(connection) })
+	}
 
-	textPending := false
+	if s.netpoll {
+		err := s.poll(connection, conn)
+		if err == nil {
+			return
+		}
+		log.Printf("websocket: netpoll is not available, fallback to read loop (%v)", err)
+	}
 
-	state := ws.StateServerSide
-	utf8Reader := wsutil.NewUTF8Reader(nil)
-	cipherReader := wsutil.NewCipherReader(nil, [4]byte{0, 0, 0, 0})
+	s.goroutines.readers.Add(1)
+	defer func() {
+		_ = connection.Close()
+		s.goroutines.readers.Add(-1)
+	}()
+	connection.startPing()
 
 	for {
-		header, _ := ws.ReadHeader(conn)
-		if err = ws.CheckHeader(header, state); err != nil {
-			log.Printf("drop ws connection: %v", err)
+		if err := s.readFrame(connection, conn); err != nil {
+			connection.readFailed(err)
 			s.dropConn(connection)
 			break
 		}
+	}
+}
 
-		cipherReader.Reset(io.LimitReader(conn, header.Length), header.Mask)
-
-		var utf8Fin bool
-		var r io.Reader = cipherReader
-
-		switch header.OpCode {
-		case ws.OpPing:
-			header.OpCode = ws.OpPong
-			header.Masked = false
-			_ = ws.WriteHeader(conn, header)
-			_, _ = io.CopyN(conn, cipherReader, header.Length)
-			continue
-		case ws.OpPong:
-			_, _ = io.CopyN(io.Discard, conn, header.Length)
-			continue
-		case ws.OpClose:
-			utf8Fin = true
-		case ws.OpContinuation:
-			if textPending {
-				utf8Reader.Source = cipherReader
-				r = utf8Reader
-			}
-			if header.Fin {
-				state = state.Clear(ws.StateFragmented)
-				textPending = false
-				utf8Fin = true
-			}
-		case ws.OpText:
-			utf8Reader.Reset(cipherReader)
-			r = utf8Reader
-
-			if !header.Fin {
-				state = state.Set(ws.StateFragmented)
-				textPending = true
-			} else {
-				utf8Fin = true
-			}
-		case ws.OpBinary:
-			if !header.Fin {
-				state = state.Set(ws.StateFragmented)
-			}
-		}
-
-		payload := make([]byte, header.Length)
-		_, err = io.ReadFull(r, payload)
-		if err == nil && utf8Fin && !utf8Reader.Valid() {
-			err = wsutil.ErrInvalidUTF8
-		}
+// On adding callback for message. Callbacks of the same name are called in order of adding.
+// Returned function removes the callback, other callbacks of the name stay.
+// It panics if name is in reserved system namespace (see SystemPrefix).
+func (s *Server) On(name string, f HandlerFunc) (off func()) {
+	mustNotBeSystem(name)
 
-		if err != nil || header.OpCode == ws.OpClose {
-			if err != nil {
-				log.Printf("drop ws connection: OpClose (%v)", err)
-			}
-			s.dropConn(connection)
-			break
-		}
+	h := &handler{f: f}
+	s.mu.Lock()
+	s.callbacks[name] = append(s.callbacks[name], h)
+	s.mu.Unlock()
 
-		header.Masked = false
-		if err = s.processMessage(connection, header, payload); err != nil {
-			log.Print(err)
+	return func() {
+		s.mu.Lock()
+		s.callbacks[name] = removeHandler(s.callbacks[name], h)
+		if len(s.callbacks[name]) == 0 {
+			delete(s.callbacks, name)
 		}
+		s.mu.Unlock()
 	}
 }
 
-// On adding callback for message.
-func (s *Server) On(name string, f HandlerFunc) {
+// OnAny adding callback which is called for every named message before message callbacks,
+// even if message has no callbacks. System events are not passed to it.
+func (s *Server) OnAny(f AnyHandlerFunc) {
 	s.mu.Lock()
-	s.callbacks[name] = f
+	s.onAny = append(s.onAny, f)
 	s.mu.Unlock()
 }
 
-// NewChannel create new channel and proxy channel delConn
-// for handling connection closing.
+// NewChannel create new channel, dropped connections are removed from it.
+// Existing channel with the same id is closed.
 func (s *Server) NewChannel(id string) *Channel {
 	c := newChannel(id)
+	c.server = s
 	s.mu.Lock()
+	old := s.channels[id]
 	s.channels[id] = c
-	s.delChan = append(s.delChan, c.delConn)
 	s.mu.Unlock()
+	s.observe(Event{Type: ChannelCreated, Channel: id})
+
+	if old != nil {
+		old.Close()
+	}
 	return c
 }
 
+// RemoveChannel close the channel and remove it from server.
+// Connections of channel stay open.
+func (s *Server) RemoveChannel(id string) {
+	if ch := s.Channel(id); ch != nil {
+		ch.Close()
+	}
+}
+
 // Channel find and return the channel.
 func (s *Server) Channel(id string) *Channel {
 	s.mu.Lock()
@@ -316,52 +582,147 @@ func (s *Server) Channels() []string {
 }
 
 // OnConnect function which will be called when new connections come.
-func (s *Server) OnConnect(f func(c *Conn)) {
+func (s *Server) OnConnect(f func(c Connection)) {
 	s.mu.Lock()
 	s.onConnect = f
 	s.mu.Unlock()
 }
 
-// OnDisconnect function which will be called when new connections come.
-func (s *Server) OnDisconnect(f func(c *Conn)) {
+// OnDisconnect function which will be called when connection is closed, see Conn.DisconnectReason.
+func (s *Server) OnDisconnect(f func(c Connection)) {
 	s.mu.Lock()
 	s.onDisconnect = f
 	s.mu.Unlock()
 }
 
-// OnMessage handling byte message. This function works as echo by default
-func (s *Server) OnMessage(f func(c *Conn, h ws.Header, b []byte)) {
+// OnMessage handling byte message. This function works as echo by default.
+// The byte slice is reused after the function returns, copy it to keep.
+func (s *Server) OnMessage(f func(c Connection, h ws.Header, b []byte)) {
 	s.mu.Lock()
 	s.onMessage = f
 	s.mu.Unlock()
 }
 
-// Emit message to all connections.
-func (s *Server) Emit(name string, data []byte) {
-	s.broadcast <- Message{
-		Name: name,
-		Data: data,
-	}
+// OnPing function which will be called when ping comes from client, after the pong was sent.
+// Payload is at most 125 bytes, frames with bigger payload close the connection with 1002.
+func (s *Server) OnPing(f func(c Connection, payload []byte)) {
+	s.mu.Lock()
+	s.onPing = f
+	s.mu.Unlock()
 }
 
-// SendTo send message to channel with id.
-func (s *Server) SendTo(id string, name string, message *Message) error {
+// OnPong function which will be called when pong comes from client, e.g. in reply to Conn.Ping.
+// Pongs to pings sent every PingInterval come with empty payload.
+func (s *Server) OnPong(f func(c Connection, payload []byte)) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.onPong = f
+	s.mu.Unlock()
+}
 
-	ch := s.Channel(id)
-	if ch != nil {
-		ch.Emit(name, message)
+// Emit message to all connections of the server (connections of namespaces are not included), data is encoded the same way as in Conn.Emit:
+// []byte is sent as string (raw payload in binary envelope), json.RawMessage as is and other types as json.
+// []byte which is not valid UTF-8 is not sent to connections with json envelope (see ErrInvalidUTF8).
+// Message is queued and Emit never blocks: ErrBroadcastFull is returned when the queue is full,
+// ErrNotRunning if server was not started and ErrServerClosed after Shutdown.
+// Queued messages are dropped on Shutdown.
+func (s *Server) Emit(name string, data any) error {
+	return s.emit(envelope{Name: name, Data: data})
+}
+
+// EmitContext is like Emit, but waits for room in the queue until ctx is done.
+func (s *Server) EmitContext(ctx context.Context, name string, data any) error {
+	if err := s.canEmit(); err != nil {
+		return err
+	}
+
+	select {
+	case s.broadcast <- envelope{Name: name, Data: data}:
+		return nil
+	case <-s.quit:
+		return ErrServerClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// EmitString emit string message to all connections.
+func (s *Server) EmitString(name string, data string) error {
+	return s.emit(envelope{Name: name, Data: data})
+}
+
+// EmitJSON emit already encoded json to all connections, it's embedded in envelope as is.
+func (s *Server) EmitJSON(name string, data []byte) error {
+	if !json.Valid(data) {
+		return ErrInvalidJSON
+	}
+	return s.emit(envelope{Name: name, Data: json.RawMessage(data)})
+}
+
+// EmitBinary emit message to all connections in binary frames, see Conn.EmitBinary.
+func (s *Server) EmitBinary(name string, data []byte) error {
+	return s.emit(envelope{Name: name, Data: data, op: ws.OpBinary})
+}
+
+// emit pass the envelope to broadcast loop.
+func (s *Server) emit(env envelope) error {
+	if err := s.canEmit(); err != nil {
+		return err
+	}
+
+	select {
+	case s.broadcast <- env:
+		return nil
+	default:
+		return ErrBroadcastFull
+	}
+}
+
+// canEmit return error if messages can't be queued for broadcast.
+func (s *Server) canEmit() error {
+	s.mu.RLock()
+	running := s.running
+	s.mu.RUnlock()
+
+	if !running {
+		return ErrNotRunning
+	}
+	select {
+	case <-s.quit:
+		return ErrServerClosed
+	default:
+		return nil
 	}
+}
 
-	return errors.New("no channel found")
+// EmitToChannel emit message to all connections of channel with id, see Channel.Emit.
+// Returns ErrChannelNotFound for unknown channel and write errors of connections which failed to receive the message.
+func (s *Server) EmitToChannel(id string, name string, data any) error {
+	ch := s.Channel(id)
+	if ch == nil {
+		return ErrChannelNotFound
+	}
+	return ch.Emit(name, data).Err()
 }
 
 // Count return number of active connections.
 func (s *Server) Count() int {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return len(s.connections)
+	return s.connections.count()
+}
+
+// Connections return snapshot of active connections. Snapshot is copied on write, so it's cheap to take
+// and connects and disconnects are not blocked while it's used.
+func (s *Server) Connections() []*Conn {
+	return s.connections.snapshot()
+}
+
+// ForEach calls f for every active connection until f returns false, e.g. to ping idle admins.
+// Connections are taken from snapshot, so f could block, close connections or write to them.
+func (s *Server) ForEach(f func(c *Conn) bool) {
+	for _, c := range s.connections.snapshot() {
+		if !f(c) {
+			return
+		}
+	}
 }
 
 // IsClosed return the state of websocket server.
@@ -372,67 +733,191 @@ func (s *Server) IsClosed() bool {
 	return s.done
 }
 
-func (s *Server) processMessage(c *Conn, h ws.Header, b []byte) error {
-	if len(b) == 0 {
-		s.onMessage(c, h, b)
+func (s *Server) processMessage(c *Conn, h ws.Header, b []byte, received time.Time) error {
+	if s.rateQueued() {
+		s.enqueue(c, h, b, received)
 		return nil
 	}
+	return s.process(c, h, b, received)
+}
 
-	if h.OpCode != ws.OpBinary && h.OpCode != ws.OpText {
-		s.onMessage(c, h, b)
-		return nil
+// process the message after rate limits, it waits for tokens with RateQueue policy.
+func (s *Server) process(c *Conn, h ws.Header, b []byte, received time.Time) error {
+	if ok, err := s.allow(c, ""); !ok {
+		return err
 	}
 
-	var msg struct {
-		Name string `json:"name"`
-		Data any    `json:"data"`
+	var (
+		msg     envelope
+		decoded bool
+	)
+	if len(b) != 0 && (h.OpCode == ws.OpBinary || h.OpCode == ws.OpText) {
+		if m, err := c.decode(b); err == nil {
+			msg, decoded = m, true
+		}
 	}
+	s.observe(Event{Type: MessageReceived, Conn: c, Name: msg.Name, Size: len(b)})
 
-	if err := json.Unmarshal(b, &msg); err == nil && s.callbacks[msg.Name] != nil {
-		buf, err := json.Marshal(msg.Data)
-		if err != nil {
+	if decoded {
+		if IsSystemEvent(msg.Name) {
+			return s.processSystem(c, msg, received)
+		}
+
+		if ok, err := s.allow(c, msg.Name); !ok {
 			return err
 		}
-		s.callbacks[msg.Name](c, &Message{
-			Name: msg.Name,
-			Data: buf,
-		})
-		return nil
+
+		s.mu.RLock()
+		callbacks := s.callbacks[msg.Name]
+		subs := s.subscriptions[msg.Name]
+		onAny := s.onAny
+		hooks := s.webhooks[msg.Name]
+		_, validated := s.validators[msg.Name]
+		s.mu.RUnlock()
+		if ns := c.namespace; ns != nil {
+			// namespace connections are routed only to the namespace
+			callbacks, subs, hooks = ns.handlers(msg.Name), nil, nil
+			ns.mu.RLock()
+			onAny = ns.onAny
+			ns.mu.RUnlock()
+		}
+
+		handled := len(callbacks) != 0 || len(subs) != 0 || len(onAny) != 0 || len(hooks) != 0
+		pc := c.payloadCipher()
+		var buf []byte
+		if handled || validated || pc != nil {
+			var err error
+			if buf, err = msg.payload(); err != nil {
+				return err
+			}
+			if pc != nil {
+				if buf, err = c.decrypt(buf, pc); err != nil {
+					replyError(c, msg.Name, "", NewError(CodeBadRequest, err.Error()))
+					return nil
+				}
+				// OnMessage gets the frame with decrypted data, as named handlers do
+				if b, err = c.plainFrame(msg, buf); err != nil {
+					replyError(c, msg.Name, "", NewError(CodeBadRequest, err.Error()))
+					return nil
+				}
+				h.Length = int64(len(b))
+			}
+		}
+
+		message := &Message{
+			Name:     msg.Name,
+			Data:     buf,
+			Received: received,
+		}
+		// invalid messages don't reach OnMessage either
+		if validated && !s.validate(c, message) {
+			return nil
+		}
+		if handled {
+			s.notify(hooks, WebhookEvent{Name: msg.Name, Connection: c.ID()}, buf)
+			for _, f := range onAny {
+				f(c.Context(), c, message)
+			}
+			for _, sub := range subs {
+				sub.send(IncomingMessage{Conn: c, Message: message})
+			}
+			for _, h := range callbacks {
+				h.f(c, message)
+			}
+			if len(callbacks) != 0 || len(subs) != 0 {
+				return nil
+			}
+		}
+	}
+	if c.namespace == nil {
+		s.onMessage(c, h, b)
 	}
-	s.onMessage(c, h, b)
 
 	return nil
 }
 
-func (s *Server) addConn(conn *Conn) {
-	if !reflect.ValueOf(s.onConnect).IsNil() {
-		go s.onConnect(conn)
+func (s *Server) processSystem(c *Conn, msg envelope, received time.Time) error {
+	s.mu.RLock()
+	callback := s.system[msg.Name]
+	s.mu.RUnlock()
+
+	if callback == nil {
+		err := fmt.Errorf("websocket: unknown system event %q from %s", msg.Name, c.ID())
+		s.observe(Event{Type: MessageDropped, Conn: c, Name: msg.Name, Err: err})
+		return err
 	}
 
-	s.mu.Lock()
-	s.connections[conn] = true
-	s.mu.Unlock()
+	buf, err := msg.payload()
+	if err != nil {
+		return err
+	}
+	callback(c, &Message{
+		Name:     msg.Name,
+		Data:     buf,
+		Received: received,
+	})
+
+	return nil
 }
 
-func (s *Server) dropConn(conn *Conn) {
-	if !reflect.ValueOf(s.onDisconnect).IsNil() {
-		go s.onDisconnect(conn)
+func (s *Server) addConn(conn *Conn) {
+	s.startRecording(conn)
+	s.observe(Event{Type: ConnectionOpened, Conn: conn})
+	s.scheduleMaxAge(conn)
+
+	var f func(c Connection)
+	if ns := conn.namespace; ns != nil {
+		ns.mu.RLock()
+		f = ns.onConnect
+		ns.mu.RUnlock()
+	} else {
+		s.mu.RLock()
+		f = s.onConnect
+		s.mu.RUnlock()
+	}
+	if f == nil {
+		return
 	}
 
-	go func() {
-		for _, dC := range s.delChan {
-			dC <- conn
-		}
-	}()
-
-	s.mu.Lock()
-	delete(s.connections, conn)
-	s.mu.Unlock()
+	if s.syncConnect {
+		_ = s.safe(conn, func() { f(conn) })
+		return
+	}
+	spawn(&s.goroutines.background, func() { _ = s.safe(conn, func() { f(conn) }) })
 }
 
-func uuid() string {
-	b := make([]byte, 16)
-	_, _ = rand.Read(b)
+func (s *Server) dropConn(conn *Conn) {
+	conn.setCloseCode(ws.StatusAbnormalClosure)
+
+	if channels := conn.channelList(); len(channels) != 0 {
+		spawn(&s.goroutines.background, func() {
+			for _, ch := range channels {
+				ch.Remove(conn)
+			}
+		})
+	}
 
-	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	s.connections.remove(conn)
+	if conn.dropped.CompareAndSwap(false, true) {
+		onDisconnect := s.onDisconnect
+		if ns := conn.namespace; ns != nil {
+			ns.mu.RLock()
+			onDisconnect = ns.onDisconnect
+			ns.mu.RUnlock()
+		}
+		if !reflect.ValueOf(onDisconnect).IsNil() {
+			spawn(&s.goroutines.background, func() { _ = s.safe(conn, func() { onDisconnect(conn) }) })
+		}
+		s.release(conn.ip)
+		conn.stopAgeTimer()
+		s.observe(Event{Type: ConnectionClosed, Conn: conn, Code: conn.DisconnectReason().Code})
+		conn.stopRecording()
+		if conn.outbox != nil {
+			pending := conn.outbox.drain()
+			spawn(&s.goroutines.background, func() { s.deliveryFailed(conn, pending) })
+		}
+	}
+	if conn.cancel != nil {
+		conn.cancel()
+	}
 }
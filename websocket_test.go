@@ -60,6 +60,76 @@ func TestServer_Shutdown_byContext(t *testing.T) {
 	require.Equal(t, true, wsServer.IsClosed(), "websocket must be closed")
 }
 
+func TestServer_notRunning(t *testing.T) {
+	wsServer := New()
+
+	require.ErrorIs(t, wsServer.Emit("test", []byte("test")), ErrNotRunning)
+	require.ErrorIs(t, wsServer.Shutdown(), ErrNotRunning)
+	require.True(t, wsServer.IsClosed(), "websocket must be closed")
+}
+
+func TestServer_Emit_afterShutdown(t *testing.T) {
+	ts, wsServer, _ := server(t)
+	defer ts.Close()
+
+	require.NoError(t, wsServer.Shutdown())
+	require.NoError(t, wsServer.Shutdown(), "second shutdown must not fail")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- wsServer.Emit("test", []byte("test"))
+	}()
+
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, ErrServerClosed)
+	case <-time.After(time.Second):
+		t.Fatal("emit must not block after shutdown")
+	}
+}
+
+func TestServer_Emit_full(t *testing.T) {
+	wsServer := New(WithBroadcastBuffer(1))
+	wsServer.running = true // broadcast loop is not started, so queue is not drained
+
+	require.NoError(t, wsServer.Emit("first", nil))
+	require.ErrorIs(t, wsServer.Emit("second", nil), ErrBroadcastFull, "emit must not block")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, wsServer.EmitContext(ctx, "second", nil), context.DeadlineExceeded)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- wsServer.EmitContext(context.Background(), "second", nil)
+	}()
+	close(wsServer.quit)
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, ErrServerClosed)
+	case <-time.After(time.Second):
+		t.Fatal("EmitContext must return after shutdown")
+	}
+}
+
+func TestServer_EmitContext(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, wsServer.EmitContext(context.Background(), "hello", 1))
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, `{"name":"hello","data":1}`, string(b))
+}
+
 func TestServer_Handler(t *testing.T) {
 	wsServer := Start(context.Background())
 	r := http.NewServeMux()
@@ -110,12 +180,12 @@ func TestServer_OnConnect(t *testing.T) {
 
 	msg := Message{
 		Name: "TesT",
-		Data: []byte("Hello World"),
+		Data: json.RawMessage(`"Hello World"`),
 	}
 	messageBytes, err := json.Marshal(msg)
 	require.NoError(t, err)
 
-	wsServer.OnConnect(func(c *Conn) {
+	wsServer.OnConnect(func(c Connection) {
 		time.Sleep(300 * time.Millisecond)
 		err := c.Emit(msg.Name, msg.Data)
 		require.NoError(t, err)
@@ -155,9 +225,9 @@ func TestServer_OnConnect2(t *testing.T) {
 		Length: int64(len(msg)),
 	}
 
-	wsServer.OnConnect(func(c *Conn) {
+	wsServer.OnConnect(func(c Connection) {
 		time.Sleep(300 * time.Millisecond)
-		err := c.Write(h, msg)
+		err := c.(*Conn).Write(h, msg)
 		require.NoError(t, err)
 	})
 
@@ -179,6 +249,30 @@ func TestServer_OnConnect2(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestServer_WithSyncConnect(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithSyncConnect())
+	defer shutdown()
+
+	wsServer.OnConnect(func(c Connection) {
+		time.Sleep(50 * time.Millisecond)
+		c.Tag("user", "john")
+	})
+	wsServer.On("whoami", func(c Connection, msg *Message) {
+		user, _ := c.TagValue("user")
+		_ = c.Emit("whoami", user)
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	writeMessage(t, c, "whoami", nil)
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, `{"name":"whoami","data":"john"}`, string(b), "OnConnect must complete before handlers")
+}
+
 func TestServer_OnDisconnect(t *testing.T) {
 	ts, wsServer, shutdown := server(t)
 	defer shutdown()
@@ -189,7 +283,7 @@ func TestServer_OnDisconnect(t *testing.T) {
 		Data: []byte("Hello World"),
 	}
 
-	wsServer.OnDisconnect(func(c *Conn) {
+	wsServer.OnDisconnect(func(c Connection) {
 		time.Sleep(300 * time.Millisecond)
 		_ = c.Emit(msg.Name, msg.Data)
 		done <- true
@@ -232,7 +326,7 @@ func TestServer_OnMessage(t *testing.T) {
 	msg := []byte("Hello from byte array")
 
 	done := make(chan bool, 1)
-	wsServer.OnMessage(func(c *Conn, h ws.Header, b []byte) {
+	wsServer.OnMessage(func(c Connection, h ws.Header, b []byte) {
 		require.Equal(t, msg, b, "response message must be the same as send")
 		done <- true
 	})
@@ -283,7 +377,7 @@ func TestServer_On(t *testing.T) {
 
 	done := make(chan bool, 1)
 
-	wsServer.On("LoL", func(c *Conn, msg *Message) {
+	wsServer.On("LoL", func(c Connection, msg *Message) {
 		require.Equal(t, _message.Name, msg.Name)
 		var respData dataStruct
 		require.NoError(t, json.Unmarshal(msg.Data, &respData))
@@ -327,11 +421,8 @@ func TestServer_Emit(t *testing.T) {
 	ts, wsServer, shutdown := server(t)
 	defer shutdown()
 
-	msg := Message{
-		Name: "test",
-		Data: []byte("Hello from emit test"),
-	}
-	messageBytes, err := json.Marshal(msg)
+	data := []byte("Hello from emit test")
+	messageBytes, err := json.Marshal(envelope{Name: "test", Data: string(data)})
 	require.NoError(t, err)
 
 	u := url.URL{Scheme: "ws", Host: strings.Replace(ts.URL, "http://", "", 1), Path: "/ws"}
@@ -344,21 +435,76 @@ func TestServer_Emit(t *testing.T) {
 		require.NoError(t, err)
 	}()
 
-	wsServer.Emit(msg.Name, msg.Data)
+	require.NoError(t, wsServer.Emit("test", data))
 
 	for {
 		mes, op, err := wsutil.ReadServerData(c)
 		require.NoError(t, err)
 		require.Equal(t, true, op.IsData())
-		require.Equal(t, messageBytes, mes, "response and request must be the same")
-
-		var message Message
-		err = json.Unmarshal(mes, &message)
-		require.Equal(t, msg, message, "response message must be the same as send")
+		require.Equal(t, messageBytes, mes, "byte data must be sent as string, not base64")
 		break
 	}
 }
 
+func TestServer_Emit_variants(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithTextMessages())
+	defer shutdown()
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, wsServer.Emit("any", map[string]int{"a": 1}))
+	require.NoError(t, wsServer.EmitString("string", "hello"))
+	require.NoError(t, wsServer.EmitJSON("json", []byte(`{"b":2}`)))
+	require.ErrorIs(t, wsServer.EmitJSON("json", []byte(`{"b":`)), ErrInvalidJSON)
+	require.NoError(t, wsServer.EmitBinary("binary", []byte("raw")))
+
+	// broadcasts are delivered concurrently, so order isn't guaranteed
+	received := make(map[string]ws.OpCode)
+	for i := 0; i < 4; i++ {
+		b, op, err := wsutil.ReadServerData(c)
+		require.NoError(t, err)
+		received[string(b)] = op
+	}
+	require.Equal(t, map[string]ws.OpCode{
+		`{"name":"any","data":{"a":1}}`:    ws.OpText,
+		`{"name":"string","data":"hello"}`: ws.OpText,
+		`{"name":"json","data":{"b":2}}`:   ws.OpText,
+		`{"name":"binary","data":"raw"}`:   ws.OpBinary,
+	}, received)
+}
+
+func TestServer_EmitToChannel(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	require.ErrorIs(t, wsServer.EmitToChannel("unknown", "hello", 1), ErrChannelNotFound)
+
+	ch := wsServer.NewChannel("room")
+	require.NoError(t, wsServer.EmitToChannel("room", "hello", 1), "empty channel is not an error")
+
+	wsServer.OnConnect(func(c Connection) {
+		ch.Add(c)
+	})
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+	require.Eventually(t, func() bool {
+		return ch.Count() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, wsServer.EmitToChannel("room", "hello", []byte("world")))
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Equal(t, `{"name":"hello","data":"world"}`, string(b))
+}
+
 func TestServer_Channel(t *testing.T) {
 	// TODO
 }
@@ -386,7 +532,7 @@ func TestServerListen(t *testing.T) {
 	messageBytes, err := json.Marshal(message)
 	require.NoError(t, err)
 
-	wsServer.On("echo", func(c *Conn, msg *Message) {
+	wsServer.On("echo", func(c Connection, msg *Message) {
 		require.Equal(t, message.Name, msg.Name)
 		var respData string
 		require.NoError(t, json.Unmarshal(msg.Data, &respData))
@@ -487,7 +633,7 @@ func TestServer_ConnectionClose(t *testing.T) {
 	ch := wsServer.NewChannel("test-channel-add")
 	msg := Message{
 		Name: "test",
-		Data: []byte("Hello World"),
+		Data: json.RawMessage(`"Hello World"`),
 	}
 	messageBytes, err := json.Marshal(msg)
 	require.NoError(t, err)
@@ -495,15 +641,15 @@ func TestServer_ConnectionClose(t *testing.T) {
 	ticker := time.NewTicker(time.Millisecond * 1)
 	done := make(chan bool, 1)
 
-	wsServer.OnConnect(func(c *Conn) {
+	wsServer.OnConnect(func(c Connection) {
 		ch.Add(c)
 		require.Equal(t, 1, ch.Count(), "channel must contain only 1 connection")
 		log.Print("Connected")
 	})
-	wsServer.OnDisconnect(func(c *Conn) {
+	wsServer.OnDisconnect(func(c Connection) {
 		log.Print("Disconnected")
 	})
-	wsServer.On("test", func(c *Conn, msg *Message) {
+	wsServer.On("test", func(c Connection, msg *Message) {
 		log.Printf("message: %s", msg.Name)
 	})
 
@@ -555,8 +701,8 @@ func TestServer_ConnectionClose(t *testing.T) {
 	require.Equal(t, 0, ch.Count())
 }
 
-func server(t *testing.T) (*httptest.Server, *Server, func()) {
-	wsServer := Start(context.Background())
+func server(t *testing.T, opts ...Option) (*httptest.Server, *Server, func()) {
+	wsServer := Start(context.Background(), opts...)
 
 	r := http.NewServeMux()
 	r.HandleFunc("/ws", wsServer.Handler)
@@ -568,3 +714,162 @@ func server(t *testing.T) (*httptest.Server, *Server, func()) {
 		ts.Close()
 	}
 }
+
+func dial(t *testing.T, ts *httptest.Server) net.Conn {
+	u := url.URL{Scheme: "ws", Host: strings.Replace(ts.URL, "http://", "", 1), Path: "/ws"}
+	c, _, _, err := ws.Dial(context.Background(), u.String())
+	require.NoError(t, err)
+	require.NoError(t, c.SetDeadline(time.Now().Add(3000*time.Millisecond)))
+	return c
+}
+
+func writeMessage(t *testing.T, c net.Conn, name string, data any) {
+	b, err := json.Marshal(struct {
+		Name string `json:"name"`
+		Data any    `json:"data"`
+	}{
+		Name: name,
+		Data: data,
+	})
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientMessage(c, ws.OpText, b))
+}
+
+func readClose(t *testing.T, c net.Conn) ws.StatusCode {
+	for {
+		frame, err := ws.ReadFrame(c)
+		require.NoError(t, err)
+		if frame.Header.OpCode == ws.OpClose {
+			code, _ := ws.ParseCloseFrameData(frame.Payload)
+			return code
+		}
+	}
+}
+
+func TestServer_On_multiple(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	calls := make(chan string, 10)
+	// closures of the same literal must be removed separately
+	offs := make([]func(), 0, 2)
+	for _, name := range []string{"first", "second"} {
+		offs = append(offs, wsServer.On("event", func(c Connection, msg *Message) {
+			calls <- name
+		}))
+	}
+	wsServer.OnAny(func(ctx context.Context, c Connection, msg *Message) {
+		require.NotNil(t, ctx)
+		calls <- "any:" + msg.Name
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, "event", 1)
+	require.Equal(t, "any:event", <-calls)
+	require.Equal(t, "first", <-calls)
+	require.Equal(t, "second", <-calls)
+
+	offs[0]()
+	offs[0]()
+	writeMessage(t, c, "event", 1)
+	require.Equal(t, "any:event", <-calls)
+	require.Equal(t, "second", <-calls)
+
+	writeMessage(t, c, "unknown", 1)
+	require.Equal(t, "any:unknown", <-calls, "OnAny must receive messages without handlers")
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"unknown","data":1}`, string(b), "message without handlers must reach OnMessage")
+
+	writeMessage(t, c, EventHeartbeat, 1)
+	_, _, err = wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.Empty(t, calls, "system events must not reach OnAny")
+}
+
+func TestMessage_Bind(t *testing.T) {
+	msg := &Message{Name: "test", Data: json.RawMessage(`{"id":1,"name":"test"}`)}
+
+	var v struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	require.NoError(t, msg.Bind(&v))
+	require.Equal(t, 1, v.ID)
+	require.Equal(t, "test", v.Name)
+
+	require.Error(t, (&Message{Data: json.RawMessage(`{`)}).Bind(&v))
+}
+
+func TestMessage_String(t *testing.T) {
+	require.Equal(t, "Hello World", (&Message{Data: json.RawMessage(`"Hello World"`)}).String())
+	require.Equal(t, `{"a":1}`, (&Message{Data: json.RawMessage(`{"a":1}`)}).String())
+	require.Equal(t, "", (&Message{}).String())
+}
+
+func TestServer_On_echoData(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	wsServer.On("echo", func(c Connection, msg *Message) {
+		require.NoError(t, c.Emit("echo", msg.Data))
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	writeMessage(t, c, "echo", map[string]int{"a": 1})
+	_, data := readEnvelope(t, c)
+	require.JSONEq(t, `{"a":1}`, string(data), "data must be sent back as json, not base64")
+}
+
+func TestServer_ForEach(t *testing.T) {
+	ts, wsServer, shutdown := server(t)
+	defer shutdown()
+
+	c1, c2 := dial(t, ts), dial(t, ts)
+	defer func() {
+		_ = c1.Close()
+		_ = c2.Close()
+	}()
+	require.Eventually(t, func() bool {
+		return len(wsServer.Connections()) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	// connections could be closed while iterating
+	visited := 0
+	wsServer.ForEach(func(c *Conn) bool {
+		visited++
+		require.NoError(t, c.Emit("bye", nil))
+		_ = c.Close()
+		return true
+	})
+	require.Equal(t, 2, visited)
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 0
+	}, time.Second, 5*time.Millisecond)
+
+	c3 := dial(t, ts)
+	defer func() {
+		_ = c3.Close()
+	}()
+	c4 := dial(t, ts)
+	defer func() {
+		_ = c4.Close()
+	}()
+	require.Eventually(t, func() bool {
+		return wsServer.Count() == 2
+	}, time.Second, 5*time.Millisecond)
+	visited = 0
+	wsServer.ForEach(func(c *Conn) bool {
+		visited++
+		return false
+	})
+	require.Equal(t, 1, visited, "iteration must stop when f returns false")
+}
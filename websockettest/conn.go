@@ -0,0 +1,205 @@
+package websockettest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/pkgz/websocket"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// ErrConnClosed is returned by Conn methods after Close.
+var ErrConnClosed = errors.New("websockettest: connection closed")
+
+// Conn is a fake websocket.Connection which records sent messages, it's used to test
+// handlers written against websocket.Connection without server:
+//
+//	c := websockettest.NewConn("1")
+//	handler(c, &websocket.Message{Name: "echo", Data: []byte(`"hello"`)})
+//	msg := c.Expect(t, "echo")
+type Conn struct {
+	// Params are returned by Param, Proto by Subprotocol and Joined by Channels.
+	Params map[string]string
+	Proto  string
+	Joined []string
+
+	id     string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	tags      map[string]any
+	messages  []websocket.Message
+	sent      []any
+	closed    bool
+	closeCode ws.StatusCode
+	reason    string
+}
+
+var _ websocket.Connection = (*Conn)(nil)
+
+// NewConn makes fake connection with id.
+func NewConn(id string) *Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Conn{
+		Params: map[string]string{},
+		id:     id,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// ID return id of connection.
+func (c *Conn) ID() string { return c.id }
+
+// Param return value of Params.
+func (c *Conn) Param(key string) string { return c.Params[key] }
+
+// Context is canceled by Close.
+func (c *Conn) Context() context.Context { return c.ctx }
+
+// Subprotocol return Proto.
+func (c *Conn) Subprotocol() string { return c.Proto }
+
+// Channels return sorted copy of Joined.
+func (c *Conn) Channels() []string {
+	ids := append([]string{}, c.Joined...)
+	sort.Strings(ids)
+	return ids
+}
+
+// Emit record named message, data is encoded to json as Conn.Emit does.
+func (c *Conn) Emit(name string, data any) error {
+	var (
+		b   []byte
+		err error
+	)
+	switch v := data.(type) {
+	case []byte:
+		b, err = json.Marshal(string(v))
+	default:
+		b, err = json.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrConnClosed
+	}
+	c.messages = append(c.messages, websocket.Message{Name: name, Data: b})
+	return nil
+}
+
+// EmitText is the same as Emit.
+func (c *Conn) EmitText(name string, data any) error { return c.Emit(name, data) }
+
+// EmitBinary is the same as Emit.
+func (c *Conn) EmitBinary(name string, data any) error { return c.Emit(name, data) }
+
+// Send record raw data, see Sent.
+func (c *Conn) Send(data any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrConnClosed
+	}
+	c.sent = append(c.sent, data)
+	return nil
+}
+
+// Write record copy of the frame payload, see Sent.
+func (c *Conn) Write(_ ws.Header, b []byte) error {
+	return c.Send(append([]byte{}, b...))
+}
+
+// Tag sets the value of tag.
+func (c *Conn) Tag(key string, value any) {
+	c.mu.Lock()
+	if c.tags == nil {
+		c.tags = make(map[string]any)
+	}
+	c.tags[key] = value
+	c.mu.Unlock()
+}
+
+// Untag removes the tag.
+func (c *Conn) Untag(key string) {
+	c.mu.Lock()
+	delete(c.tags, key)
+	c.mu.Unlock()
+}
+
+// TagValue return the value of tag and whether connection has it.
+func (c *Conn) TagValue(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.tags[key]
+	return v, ok
+}
+
+// HasTag reports whether connection has the tag with value.
+func (c *Conn) HasTag(key string, value any) bool {
+	v, ok := c.TagValue(key)
+	return ok && v == value
+}
+
+// Close the connection with 1000.
+func (c *Conn) Close() error {
+	return c.CloseWith(ws.StatusNormalClosure, "")
+}
+
+// CloseWith close the connection and record code and reason, see Closed.
+func (c *Conn) CloseWith(code ws.StatusCode, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrConnClosed
+	}
+	c.closed, c.closeCode, c.reason = true, code, reason
+	c.cancel()
+	return nil
+}
+
+// Closed reports whether connection was closed with its code and reason.
+func (c *Conn) Closed() (bool, ws.StatusCode, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed, c.closeCode, c.reason
+}
+
+// Messages return emitted messages.
+func (c *Conn) Messages() []websocket.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]websocket.Message{}, c.messages...)
+}
+
+// Sent return data passed to Send and payloads of Write.
+func (c *Conn) Sent() []any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]any{}, c.sent...)
+}
+
+// Expect remove the first emitted message and fail the test if it's not a message with name.
+func (c *Conn) Expect(t testing.TB, name string) websocket.Message {
+	t.Helper()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.messages) == 0 {
+		t.Fatalf("websockettest: expected %q, no messages", name)
+	}
+	msg := c.messages[0]
+	c.messages = c.messages[1:]
+	if msg.Name != name {
+		t.Fatalf("websockettest: expected %q, got %q with %s", name, msg.Name, msg.Data)
+	}
+	return msg
+}
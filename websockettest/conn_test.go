@@ -0,0 +1,39 @@
+package websockettest
+
+import (
+	"github.com/gobwas/ws"
+	"github.com/pkgz/websocket"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConn(t *testing.T) {
+	greet := func(c websocket.Connection, msg *websocket.Message) {
+		if c.Param("token") == "" {
+			_ = c.CloseWith(ws.StatusPolicyViolation, "unauthorized")
+			return
+		}
+		c.Tag("greeted", true)
+		_ = c.Emit("hello", msg.String())
+		_ = c.Emit("raw", []byte("bytes"))
+	}
+
+	c := NewConn("1")
+	c.Params["token"] = "secret"
+	greet(c, &websocket.Message{Name: "greet", Data: []byte(`"bob"`)})
+	require.Equal(t, "1", c.ID())
+	require.True(t, c.HasTag("greeted", true))
+	require.Len(t, c.Messages(), 2)
+	require.Equal(t, `"bob"`, string(c.Expect(t, "hello").Data))
+	require.Equal(t, `"bytes"`, string(c.Expect(t, "raw").Data))
+	require.Empty(t, c.Messages())
+
+	c = NewConn("2")
+	greet(c, &websocket.Message{Name: "greet"})
+	closed, code, reason := c.Closed()
+	require.True(t, closed)
+	require.Equal(t, ws.StatusPolicyViolation, code)
+	require.Equal(t, "unauthorized", reason)
+	require.Error(t, c.Context().Err())
+	require.ErrorIs(t, c.Emit("late", nil), ErrConnClosed)
+}
@@ -0,0 +1,249 @@
+// Package websockettest connects test client to websocket.Server in memory, so handlers
+// could be tested without httptest server and real dials:
+//
+//	srv := websocket.Start(context.Background())
+//	srv.On("echo", func(c websocket.Connection, msg *websocket.Message) {
+//		_ = c.Emit("echo", msg.Data)
+//	})
+//
+//	conn, client := websockettest.NewPair(t, srv)
+//	client.Emit(t, "echo", "hello")
+//	msg := client.Expect(t, "echo")
+//
+// Connection is served with Server.ServeStream over net.Pipe, so it goes through the same
+// handlers, channels and hooks as real connections.
+package websockettest
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/pkgz/websocket"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// PairParam is the url param which identifies the server connection of pair.
+const PairParam = "websockettest"
+
+// DefaultTimeout is the time Expect waits for the message.
+const DefaultTimeout = time.Second
+
+// ErrTimeout is returned by ReadRaw and ReadMessage when no message came in time.
+var ErrTimeout = errors.New("websockettest: timeout")
+
+var pairs atomic.Int64
+
+// Client is the client side of the pair. Messages from server are read in background,
+// so server is never blocked on writing.
+type Client struct {
+	// Timeout is the time ReadRaw and Expect wait for the message, DefaultTimeout by default.
+	Timeout time.Duration
+
+	conn     *lockedConn
+	messages chan []byte
+	done     chan struct{}
+	err      error
+}
+
+// NewPair connects new client to the server and returns the server side connection.
+// Params are passed to the connection as url params (see Conn.Param). Both sides are closed on test cleanup.
+func NewPair(t testing.TB, s *websocket.Server, params ...url.Values) (*websocket.Conn, *Client) {
+	t.Helper()
+
+	id := strconv.FormatInt(pairs.Add(1), 10)
+	query := url.Values{}
+	for _, p := range params {
+		for k, v := range p {
+			query[k] = v
+		}
+	}
+	query.Set(PairParam, id)
+
+	server, client := net.Pipe()
+	r := httptest.NewRequest(http.MethodGet, "/ws?"+query.Encode(), nil)
+	served := make(chan error, 1)
+	go func() {
+		served <- s.ServeStream(server, r)
+	}()
+
+	c := &Client{
+		Timeout:  DefaultTimeout,
+		conn:     &lockedConn{Conn: client},
+		messages: make(chan []byte, 1024),
+		done:     make(chan struct{}),
+	}
+	go c.read()
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+
+	deadline := time.Now().Add(DefaultTimeout)
+	for time.Now().Before(deadline) {
+		list := s.ConnectionsWhere(func(conn *websocket.Conn) bool {
+			return conn.Param(PairParam) == id
+		})
+		if len(list) != 0 {
+			return list[0], c
+		}
+
+		select {
+		case err := <-served:
+			if err != nil {
+				t.Fatalf("websockettest: serve connection: %v", err)
+			}
+		case <-time.After(time.Millisecond):
+		}
+	}
+	t.Fatalf("websockettest: connection is not registered by server")
+	return nil, nil
+}
+
+// lockedConn serializes writes of test and pong replies of background reader.
+type lockedConn struct {
+	net.Conn
+	mu sync.Mutex
+}
+
+func (c *lockedConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.Write(b)
+}
+
+// read messages from server until connection is closed.
+func (c *Client) read() {
+	defer close(c.done)
+	for {
+		b, _, err := wsutil.ReadServerData(c.conn)
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.messages <- b
+	}
+}
+
+// Send raw text message to server.
+func (c *Client) Send(b []byte) error {
+	return wsutil.WriteClientMessage(c.conn, ws.OpText, b)
+}
+
+// Emit send named message to server, it fails the test on error.
+func (c *Client) Emit(t testing.TB, name string, data any) {
+	t.Helper()
+
+	b, err := json.Marshal(struct {
+		Name string `json:"name"`
+		Data any    `json:"data"`
+	}{Name: name, Data: data})
+	if err == nil {
+		err = c.Send(b)
+	}
+	if err != nil {
+		t.Fatalf("websockettest: emit %q: %v", name, err)
+	}
+}
+
+// ReadRaw return payload of the next message from server.
+func (c *Client) ReadRaw() ([]byte, error) {
+	select {
+	case b := <-c.messages:
+		return b, nil
+	case <-c.done:
+		// messages which came before close are still returned
+		select {
+		case b := <-c.messages:
+			return b, nil
+		default:
+			return nil, c.err
+		}
+	case <-time.After(c.Timeout):
+		return nil, ErrTimeout
+	}
+}
+
+// ReadMessage return the next named message from server.
+func (c *Client) ReadMessage() (*websocket.Message, error) {
+	b, err := c.ReadRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &websocket.Message{}
+	if err = json.Unmarshal(b, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Expect read the next message and fail the test if it's not a message with name.
+func (c *Client) Expect(t testing.TB, name string) *websocket.Message {
+	t.Helper()
+
+	msg, err := c.ReadMessage()
+	if err != nil {
+		t.Fatalf("websockettest: expected %q: %v", name, err)
+	}
+	if msg.Name != name {
+		t.Fatalf("websockettest: expected %q, got %q with %s", name, msg.Name, msg.Data)
+	}
+	return msg
+}
+
+// ExpectJSON is like Expect, but also compares data of the message with want encoded to json.
+func (c *Client) ExpectJSON(t testing.TB, name string, want any) {
+	t.Helper()
+
+	msg := c.Expect(t, name)
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("websockettest: %v", err)
+	}
+
+	// both sides are decoded and encoded again, so they are compared regardless of formatting and key order
+	var got, expected any
+	if err = json.Unmarshal(msg.Data, &got); err != nil {
+		t.Fatalf("websockettest: decode %q: %v", name, err)
+	}
+	_ = json.Unmarshal(b, &expected)
+	x, _ := json.Marshal(got)
+	y, _ := json.Marshal(expected)
+	if string(x) != string(y) {
+		t.Fatalf("websockettest: %q data is %s, want %s", name, msg.Data, b)
+	}
+}
+
+// ExpectNone fail the test if any message comes from server during d.
+func (c *Client) ExpectNone(t testing.TB, d time.Duration) {
+	t.Helper()
+
+	select {
+	case b := <-c.messages:
+		t.Fatalf("websockettest: unexpected message %s", b)
+	case <-time.After(d):
+	}
+}
+
+// Closed wait until server closes the connection and return the close error.
+func (c *Client) Closed() error {
+	select {
+	case <-c.done:
+		return c.err
+	case <-time.After(c.Timeout):
+		return ErrTimeout
+	}
+}
+
+// Close the client side of the pipe.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,54 @@
+package websockettest
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/pkgz/websocket"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestNewPair(t *testing.T) {
+	srv := websocket.Start(context.Background())
+	defer func() {
+		_ = srv.Shutdown()
+	}()
+	srv.On("echo", func(c websocket.Connection, msg *websocket.Message) {
+		_ = c.Emit("echo", msg.Data)
+		_ = c.Emit("room", c.Param("room"))
+	})
+
+	conn, client := NewPair(t, srv, url.Values{"room": {"1"}})
+	require.Equal(t, "1", conn.Param("room"))
+	require.Equal(t, 1, srv.Count())
+
+	client.Emit(t, "echo", map[string]int{"a": 1})
+	client.ExpectJSON(t, "echo", map[string]int{"a": 1})
+	require.Equal(t, "1", client.Expect(t, "room").String())
+	client.ExpectNone(t, 20*time.Millisecond)
+
+	// server side connection could be used directly
+	require.NoError(t, conn.Emit("direct", nil))
+	client.Expect(t, "direct")
+
+	require.NoError(t, conn.CloseWith(ws.StatusNormalClosure, ""))
+	require.Error(t, client.Closed())
+}
+
+func TestNewPair_channels(t *testing.T) {
+	srv := websocket.Start(context.Background())
+	defer func() {
+		_ = srv.Shutdown()
+	}()
+
+	ch := srv.NewChannel("news")
+	c1, client1 := NewPair(t, srv)
+	_, client2 := NewPair(t, srv)
+	ch.Add(c1)
+
+	require.Equal(t, 1, ch.Emit("breaking", "hello").Delivered)
+	require.Equal(t, "hello", client1.Expect(t, "breaking").String())
+	client2.ExpectNone(t, 20*time.Millisecond)
+}
@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws"
+	"hash/fnv"
+	"time"
+)
+
+// DefaultWorkerQueue is the queue size of every worker, see WithWorkers.
+const DefaultWorkerQueue = 64
+
+// job is a message waiting for handler worker.
+type job struct {
+	c        *Conn
+	h        ws.Header
+	buf      *[]byte
+	received time.Time
+}
+
+// WithWorkers runs handlers on pool of n workers instead of read loop of connection.
+// Messages of connection are always handled by the same worker, so they keep the order,
+// while read loop continues reading frames. Every worker has queue of size queue
+// (DefaultWorkerQueue if queue <= 0), when it's full read loop waits for the worker.
+// Slow handler delays messages of other connections of the same worker, size the pool accordingly.
+// Streams (see OnStream) are still handled in read loop.
+func WithWorkers(n, queue int) Option {
+	return func(s *Server) {
+		if n <= 0 {
+			return
+		}
+		if queue <= 0 {
+			queue = DefaultWorkerQueue
+		}
+		s.workers = make([]chan job, n)
+		for i := range s.workers {
+			s.workers[i] = make(chan job, queue)
+		}
+	}
+}
+
+// runWorker handle messages of the queue until server is closed.
+// Panic in handler drops the connection like in read loop, so its queued messages are skipped.
+func (s *Server) runWorker(queue chan job) {
+	for {
+		select {
+		case j := <-queue:
+			if !j.c.dropped.Load() && s.handle(j.c, j.h, *j.buf, j.received) != nil {
+				s.dropConn(j.c)
+			}
+			putBuffer(j.buf)
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// handle the message with panic recovery, error is returned only for panic.
+func (s *Server) handle(c *Conn, h ws.Header, b []byte, received time.Time) error {
+	err := s.safe(c, func() {
+		if err := s.processMessage(c, h, b, received); err != nil {
+			s.reportError(c, err)
+		}
+	})
+	c.observeHandler(received)
+	return err
+}
+
+// dispatch pass the message to worker of connection, payload is copied as it's reused by reader.
+// It waits for room in the queue, message is dropped if connection or server is closed meanwhile.
+func (s *Server) dispatch(c *Conn, h ws.Header, payload []byte, received time.Time) {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(c.id))
+	queue := s.workers[hash.Sum32()%uint32(len(s.workers))]
+
+	buf := getBuffer(len(payload))
+	copy(*buf, payload)
+
+	select {
+	case queue <- job{c: c, h: h, buf: buf, received: received}:
+	case <-c.Context().Done():
+		putBuffer(buf)
+	case <-s.quit:
+		putBuffer(buf)
+	}
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestServer_WithWorkers(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithWorkers(2, 1))
+	defer shutdown()
+
+	release := make(chan struct{})
+	handled := make(chan string, 10)
+	wsServer.On("block", func(c Connection, msg *Message) {
+		<-release
+		handled <- "block"
+	})
+	wsServer.On("n", func(c Connection, msg *Message) {
+		handled <- msg.String()
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	writeMessage(t, c, "block", nil)
+	require.NoError(t, wsutil.WriteClientMessage(c, ws.OpPing, []byte("ping")))
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(time.Second)))
+	f, err := ws.ReadFrame(c)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpPong, f.Header.OpCode, "read loop must not wait for handler")
+
+	for _, n := range []string{"1", "2", "3"} {
+		writeMessage(t, c, "n", n)
+	}
+	close(release)
+
+	for _, want := range []string{"block", "1", "2", "3"} {
+		select {
+		case got := <-handled:
+			require.Equal(t, want, got, "messages of connection must keep the order")
+		case <-time.After(time.Second):
+			t.Fatalf("message %s is not handled", want)
+		}
+	}
+	require.Equal(t, int64(2), wsServer.Diagnostics().Goroutines.Workers)
+}
+
+func TestServer_WithWorkers_panic(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithWorkers(1, 4))
+	defer shutdown()
+
+	release := make(chan struct{})
+	handled := make(chan string, 1)
+	disconnected := make(chan struct{})
+	wsServer.On("boom", func(c Connection, msg *Message) {
+		<-release
+		panic("boom")
+	})
+	wsServer.On("n", func(c Connection, msg *Message) {
+		handled <- msg.String()
+	})
+	wsServer.OnDisconnect(func(c Connection) {
+		close(disconnected)
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	writeMessage(t, c, "boom", nil)
+	writeMessage(t, c, "n", "1")
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("panic in worker must drop the connection")
+	}
+	select {
+	case n := <-handled:
+		t.Fatalf("message %s must not be handled after panic", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
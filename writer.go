@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"errors"
+	"github.com/gobwas/ws"
+	"io"
+	"net"
+	"time"
+)
+
+// defaultStreamFragment is the size of frames sent by NextWriter when WithFragmentSize is not set.
+const defaultStreamFragment = 32 << 10
+
+// ErrWriterClosed is returned when writing to closed message writer.
+var ErrWriterClosed = errors.New("websocket: message writer closed")
+
+// NextWriter return a writer for the next data message.
+// Written data is sent as fragments (see WithFragmentSize), so big message doesn't need to be kept in memory.
+// Other messages to the connection wait until the writer is closed, pings are sent between fragments.
+// Writer must be closed to finish the message.
+func (c *Conn) NextWriter(op ws.OpCode) (io.WriteCloser, error) {
+	if op != ws.OpText && op != ws.OpBinary {
+		return nil, errors.New("websocket: message writer supports only text and binary messages")
+	}
+
+	enqueued := time.Now()
+	c.msgMu.Lock()
+
+	size := int(c.fragmentSize.Load())
+	if size <= 0 {
+		size = defaultStreamFragment
+	}
+
+	return &messageWriter{
+		c:        c,
+		op:       op,
+		buf:      getBuffer(size),
+		enqueued: enqueued,
+		started:  time.Now(),
+	}, nil
+}
+
+// messageWriter buffers data up to the fragment size and send it as continuation frames.
+type messageWriter struct {
+	c        *Conn
+	op       ws.OpCode
+	buf      *[]byte
+	n        int
+	enqueued time.Time
+	started  time.Time
+	err      error
+}
+
+// Write implements io.Writer.
+func (w *messageWriter) Write(p []byte) (int, error) {
+	if w.buf == nil {
+		return 0, ErrWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if w.n == len(*w.buf) {
+			if w.err = w.flush(false); w.err != nil {
+				return written, w.err
+			}
+		}
+
+		n := copy((*w.buf)[w.n:], p)
+		w.n += n
+		written += n
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// Close sends the rest of data as the final frame and release the connection for other messages.
+func (w *messageWriter) Close() error {
+	if w.buf == nil {
+		return ErrWriterClosed
+	}
+	defer func() {
+		putBuffer(w.buf)
+		w.buf = nil
+		w.c.msgMu.Unlock()
+	}()
+
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.flush(true); err != nil {
+		return err
+	}
+
+	w.c.observeWrite(w.enqueued, w.started, time.Now())
+	w.c.observeOut()
+	return nil
+}
+
+func (w *messageWriter) flush(fin bool) error {
+	c := w.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return net.ErrClosed
+	}
+	_ = c.conn.SetWriteDeadline(deadline(time.Now(), c.writeTimeout.Load()))
+
+	h := ws.Header{
+		Fin:    fin,
+		OpCode: w.op,
+		Length: int64(w.n),
+	}
+	if err := c.writeFrame(h, (*w.buf)[:w.n]); err != nil {
+		return err
+	}
+
+	w.op = ws.OpContinuation
+	w.n = 0
+	return nil
+}
@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"bytes"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestConn_NextWriter(t *testing.T) {
+	ts, wsServer, shutdown := server(t, WithFragmentSize(8))
+	defer shutdown()
+
+	payload := bytes.Repeat([]byte("0123456789"), 5)
+	done := make(chan error, 1)
+	wsServer.OnConnect(func(c Connection) {
+		time.Sleep(50 * time.Millisecond)
+
+		_, err := c.(*Conn).NextWriter(ws.OpClose)
+		require.Error(t, err)
+
+		w, err := c.(*Conn).NextWriter(ws.OpBinary)
+		if err != nil {
+			done <- err
+			return
+		}
+		if _, err = io.Copy(w, bytes.NewReader(payload)); err != nil {
+			done <- err
+			return
+		}
+		if err = w.Close(); err != nil {
+			done <- err
+			return
+		}
+		_, err = w.Write([]byte("after close"))
+		require.ErrorIs(t, err, ErrWriterClosed)
+
+		done <- c.Emit("next", "message")
+	})
+
+	c := dial(t, ts)
+	defer func() {
+		require.NoError(t, c.Close())
+	}()
+
+	frames := 0
+	var received []byte
+	for {
+		frame, err := ws.ReadFrame(c)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(frame.Payload), 8)
+		received = append(received, frame.Payload...)
+		frames++
+		if frame.Header.Fin {
+			break
+		}
+	}
+	require.NoError(t, <-done)
+	require.Equal(t, payload, received)
+	require.Equal(t, 7, frames)
+
+	b, _, err := wsutil.ReadServerData(c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"next","data":"message"}`, string(b), "next message must be sent after writer closed")
+}
@@ -0,0 +1,428 @@
+// Package wsbench is a load testing client for websocket servers. It opens many connections,
+// subscribes them to channels, sends events at fixed rate and reports latency and errors:
+//
+//	report, err := wsbench.Run(ctx, wsbench.Config{
+//		URL:         "ws://localhost:8080/ws",
+//		Connections: 1000,
+//		Channels:    []string{"room-1", "room-2"},
+//		Spread:      true,
+//		Rate:        1,
+//		Duration:    time.Minute,
+//	})
+//	fmt.Println(report)
+//
+// Every sent event has data {"ts": 1700000000000000000, "channel": "room-1", "pad": "..."} where ts is
+// send time in unix nanoseconds. Latency is measured for every received message which data has ts,
+// so server should echo the data back or emit it to the channel. Command cmd/wsbench wraps Run.
+package wsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultEvent is the name of event sent by connections.
+const DefaultEvent = "bench"
+
+// Error kinds of Report.Errors.
+const (
+	ErrorDial   = "dial"
+	ErrorWrite  = "write"
+	ErrorRead   = "read"
+	ErrorServer = "server"
+)
+
+// Config of the load test.
+type Config struct {
+	// URL of websocket endpoint, e.g. ws://localhost:8080/ws.
+	URL string
+	// Header is sent with every handshake, e.g. Authorization.
+	Header http.Header
+	// Connections is the number of concurrent connections.
+	Connections int
+	// Ramp spreads connects evenly over the duration, all connections are dialed at once if zero.
+	Ramp time.Duration
+	// DialTimeout limits handshake of one connection, 10s by default.
+	DialTimeout time.Duration
+
+	// Channels are joined with _subscribe after connect. Every connection joins all of them,
+	// with Spread connection i joins only Channels[i%len(Channels)].
+	Channels []string
+	Spread   bool
+
+	// Event is the name of sent events, DefaultEvent by default.
+	Event string
+	// Rate is the number of events sent by every connection per second, connections only listen if zero.
+	Rate float64
+	// Payload is the size of padding added to every event.
+	Payload int
+	// Duration of sending after all connections are dialed.
+	Duration time.Duration
+}
+
+// Latency percentiles of received messages.
+type Latency struct {
+	Count int
+	Min   time.Duration
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Report is the result of the load test.
+type Report struct {
+	// Connections is the number of connections which were dialed successfully.
+	Connections int
+	Sent        int64
+	Received    int64
+	// Errors counts errors by kind (ErrorDial, ErrorWrite, ErrorRead, ErrorServer).
+	Errors   map[string]int64
+	Latency  Latency
+	Duration time.Duration
+}
+
+// String formats report for humans.
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "connections: %d\n", r.Connections)
+	fmt.Fprintf(&b, "duration:    %v\n", r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(&b, "sent:        %d (%.1f/s)\n", r.Sent, perSecond(r.Sent, r.Duration))
+	fmt.Fprintf(&b, "received:    %d (%.1f/s)\n", r.Received, perSecond(r.Received, r.Duration))
+	l := r.Latency
+	fmt.Fprintf(&b, "latency:     min %v, mean %v, p50 %v, p90 %v, p99 %v, max %v (%d samples)\n",
+		l.Min, l.Mean, l.P50, l.P90, l.P99, l.Max, l.Count)
+
+	kinds := make([]string, 0, len(r.Errors))
+	for k := range r.Errors {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	fmt.Fprintf(&b, "errors:")
+	if len(kinds) == 0 {
+		fmt.Fprintf(&b, "      0")
+	}
+	for _, k := range kinds {
+		fmt.Fprintf(&b, " %s=%d", k, r.Errors[k])
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func perSecond(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+// Run the load test until Duration passes or ctx is canceled. Error is returned only for invalid config,
+// failures of connections are counted in the report.
+func Run(ctx context.Context, cfg Config) (Report, error) {
+	if cfg.URL == "" {
+		return Report{}, errors.New("wsbench: url is required")
+	}
+	if cfg.Connections <= 0 {
+		return Report{}, errors.New("wsbench: connections must be positive")
+	}
+	if cfg.Event == "" {
+		cfg.Event = DefaultEvent
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 10 * time.Second
+	}
+
+	b := &bench{
+		cfg:     cfg,
+		errors:  map[string]int64{},
+		clients: make([]*client, cfg.Connections),
+		pad:     strings.Repeat("x", cfg.Payload),
+	}
+
+	var wg sync.WaitGroup
+	for i := range b.clients {
+		if cfg.Ramp > 0 && i > 0 {
+			select {
+			case <-time.After(cfg.Ramp / time.Duration(cfg.Connections)):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.clients[i] = b.dial(ctx, i)
+		}()
+	}
+	wg.Wait()
+
+	started := time.Now()
+	runCtx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	for _, c := range b.clients {
+		if c == nil {
+			continue
+		}
+		b.connected++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.send(runCtx, c)
+		}()
+	}
+	wg.Wait()
+	<-runCtx.Done()
+
+	for _, c := range b.clients {
+		if c != nil {
+			c.close()
+		}
+	}
+
+	return b.report(time.Since(started)), nil
+}
+
+// bench is the state of running load test.
+type bench struct {
+	cfg       Config
+	pad       string
+	clients   []*client
+	connected int
+
+	sent     atomic.Int64
+	received atomic.Int64
+
+	mu     sync.Mutex
+	errors map[string]int64
+}
+
+// client is one connection of the test.
+type client struct {
+	conn net.Conn
+	// r reads frames, it has the data which server sent right after handshake
+	r        io.Reader
+	mu       sync.Mutex
+	channels []string
+	samples  []time.Duration
+	done     chan struct{}
+	closing  atomic.Bool
+}
+
+// write the whole frame with one call, so pong replies of reader don't interleave with it.
+func (c *client) write(op ws.OpCode, b []byte) error {
+	var buf bytes.Buffer
+	if err := wsutil.WriteClientMessage(&buf, op, b); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write(buf.Bytes())
+	return err
+}
+
+func (c *client) close() {
+	c.closing.Store(true)
+	_ = c.write(ws.OpClose, ws.NewCloseFrameBody(ws.StatusNormalClosure, ""))
+	select {
+	case <-c.done:
+	case <-time.After(time.Second):
+	}
+	_ = c.conn.Close()
+	<-c.done
+}
+
+func (b *bench) fail(kind string) {
+	b.mu.Lock()
+	b.errors[kind]++
+	b.mu.Unlock()
+}
+
+// dial connects client i and subscribes it to channels, nil is returned on error.
+func (b *bench) dial(ctx context.Context, i int) *client {
+	dialer := ws.Dialer{Timeout: b.cfg.DialTimeout}
+	if b.cfg.Header != nil {
+		dialer.Header = ws.HandshakeHeaderHTTP(b.cfg.Header)
+	}
+	conn, br, _, err := dialer.Dial(ctx, b.cfg.URL)
+	if err != nil {
+		b.fail(ErrorDial)
+		return nil
+	}
+
+	c := &client{conn: conn, r: conn, done: make(chan struct{})}
+	if br != nil {
+		c.r = io.MultiReader(br, conn)
+	}
+	switch {
+	case len(b.cfg.Channels) == 0:
+	case b.cfg.Spread:
+		c.channels = []string{b.cfg.Channels[i%len(b.cfg.Channels)]}
+	default:
+		c.channels = b.cfg.Channels
+	}
+	go b.read(c)
+
+	for _, ch := range c.channels {
+		data, _ := json.Marshal(map[string]any{"name": "_subscribe", "data": map[string]string{"channel": ch}})
+		if err = c.write(ws.OpText, data); err != nil {
+			b.fail(ErrorWrite)
+		}
+	}
+	return c
+}
+
+// send events at configured rate until ctx is done.
+func (b *bench) send(ctx context.Context, c *client) {
+	if b.cfg.Rate <= 0 {
+		return
+	}
+	interval := time.Duration(float64(time.Second) / b.cfg.Rate)
+
+	// random offset, so connections don't send in bursts
+	select {
+	case <-time.After(time.Duration(rand.Int63n(int64(interval) + 1))):
+	case <-ctx.Done():
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for n := 0; ; n++ {
+		data := struct {
+			Ts      int64  `json:"ts"`
+			Channel string `json:"channel,omitempty"`
+			Pad     string `json:"pad,omitempty"`
+		}{Ts: time.Now().UnixNano(), Pad: b.pad}
+		if len(c.channels) != 0 {
+			data.Channel = c.channels[n%len(c.channels)]
+		}
+		msg, _ := json.Marshal(map[string]any{"name": b.cfg.Event, "data": data})
+		if err := c.write(ws.OpText, msg); err != nil {
+			b.fail(ErrorWrite)
+			return
+		}
+		b.sent.Add(1)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// read messages of client until connection is closed, latency is recorded for messages with ts.
+func (b *bench) read(c *client) {
+	defer close(c.done)
+
+	for {
+		payload, _, err := wsutil.ReadServerData(pongWriter{c})
+		if err != nil {
+			// server may drop connection without close reply when test is finished
+			var closed wsutil.ClosedError
+			if !c.closing.Load() && !errors.As(err, &closed) {
+				b.fail(ErrorRead)
+			}
+			return
+		}
+		now := time.Now()
+		b.received.Add(1)
+
+		var msg struct {
+			Name string `json:"name"`
+			Data struct {
+				Ts int64 `json:"ts"`
+			} `json:"data"`
+		}
+		if json.Unmarshal(payload, &msg) != nil {
+			continue
+		}
+		if msg.Name == "_error" {
+			b.fail(ErrorServer)
+			continue
+		}
+		if msg.Data.Ts > 0 {
+			c.samples = append(c.samples, now.Sub(time.Unix(0, msg.Data.Ts)))
+		}
+	}
+}
+
+// pongWriter reads from connection of client and writes pong replies under the lock of client.
+type pongWriter struct {
+	c *client
+}
+
+func (w pongWriter) Read(p []byte) (int, error) {
+	return w.c.r.Read(p)
+}
+
+func (w pongWriter) Write(p []byte) (int, error) {
+	w.c.mu.Lock()
+	defer w.c.mu.Unlock()
+	return w.c.conn.Write(p)
+}
+
+// report collects counters and latency samples of all clients.
+func (b *bench) report(d time.Duration) Report {
+	var samples []time.Duration
+	for _, c := range b.clients {
+		if c != nil {
+			samples = append(samples, c.samples...)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return Report{
+		Connections: b.connected,
+		Sent:        b.sent.Load(),
+		Received:    b.received.Load(),
+		Errors:      b.errors,
+		Latency:     percentiles(samples),
+		Duration:    d,
+	}
+}
+
+// percentiles of samples, they are sorted in place.
+func percentiles(samples []time.Duration) Latency {
+	if len(samples) == 0 {
+		return Latency{}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	var sum time.Duration
+	for _, s := range samples {
+		sum += s
+	}
+	at := func(p float64) time.Duration {
+		return samples[int(p*float64(len(samples)-1))]
+	}
+	return Latency{
+		Count: len(samples),
+		Min:   samples[0],
+		Mean:  sum / time.Duration(len(samples)),
+		P50:   at(0.5),
+		P90:   at(0.9),
+		P99:   at(0.99),
+		Max:   samples[len(samples)-1],
+	}
+}
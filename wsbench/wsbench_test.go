@@ -0,0 +1,101 @@
+package wsbench
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkgz/websocket"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func benchServer(t *testing.T) (string, *websocket.Server) {
+	srv := websocket.Start(context.Background())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", srv.Handler)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+		ts.Close()
+	})
+	return "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws", srv
+}
+
+func TestRun_echo(t *testing.T) {
+	url, srv := benchServer(t)
+	srv.On(DefaultEvent, func(c websocket.Connection, msg *websocket.Message) {
+		_ = c.Emit(DefaultEvent, json.RawMessage(msg.Data))
+	})
+
+	report, err := Run(context.Background(), Config{
+		URL:         url,
+		Connections: 4,
+		Rate:        50,
+		Payload:     16,
+		Duration:    200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, report.Connections)
+	require.Empty(t, report.Errors)
+	require.Greater(t, report.Sent, int64(10))
+	require.Greater(t, report.Received, int64(0))
+	require.Greater(t, report.Latency.Count, 0)
+	require.LessOrEqual(t, report.Latency.P50, report.Latency.Max)
+	require.Contains(t, report.String(), "connections: 4")
+}
+
+func TestRun_channels(t *testing.T) {
+	url, srv := benchServer(t)
+	srv.On(DefaultEvent, func(c websocket.Connection, msg *websocket.Message) {
+		var data struct {
+			Channel string `json:"channel"`
+		}
+		_ = json.Unmarshal(msg.Data, &data)
+		_ = srv.EmitToChannel(data.Channel, DefaultEvent, json.RawMessage(msg.Data))
+	})
+
+	report, err := Run(context.Background(), Config{
+		URL:         url,
+		Connections: 4,
+		Channels:    []string{"a", "b"},
+		Spread:      true,
+		Rate:        20,
+		Ramp:        20 * time.Millisecond,
+		Duration:    200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.Empty(t, report.Errors)
+	// every message is delivered to both connections of its channel
+	require.Greater(t, report.Received, report.Sent/2)
+	require.Greater(t, report.Latency.Count, 0)
+}
+
+func TestRun_errors(t *testing.T) {
+	_, err := Run(context.Background(), Config{Connections: 1})
+	require.Error(t, err)
+
+	url, _ := benchServer(t)
+	report, err := Run(context.Background(), Config{URL: url + "/missing-ws", Connections: 1, Duration: time.Millisecond})
+	require.NoError(t, err)
+	require.Equal(t, 0, report.Connections)
+	require.Equal(t, map[string]int64{ErrorDial: 1}, report.Errors)
+	require.Contains(t, report.String(), "dial=1")
+}
+
+func TestPercentiles(t *testing.T) {
+	var samples []time.Duration
+	for i := 100; i > 0; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	l := percentiles(samples)
+	require.Equal(t, 100, l.Count)
+	require.Equal(t, time.Millisecond, l.Min)
+	require.Equal(t, 100*time.Millisecond, l.Max)
+	require.Equal(t, 50*time.Millisecond, l.P50)
+	require.Equal(t, 90*time.Millisecond, l.P90)
+	require.Equal(t, 99*time.Millisecond, l.P99)
+	require.Equal(t, Latency{}, percentiles(nil))
+}